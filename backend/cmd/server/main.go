@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"san11-trade/internal/api"
 	"san11-trade/internal/config"
 	"san11-trade/internal/database"
+	"san11-trade/internal/scheduler"
 	"san11-trade/internal/service"
 )
 
@@ -48,6 +51,11 @@ func main() {
 		os.Exit(0) // Exit after creating admin, don't start HTTP server
 	}
 
+	// Start background scheduler for timed deadlines
+	sched := scheduler.New(5 * time.Second)
+	registerJobs(sched)
+	go sched.Run(context.Background())
+
 	// Setup router
 	router := api.SetupRouter()
 
@@ -60,3 +68,96 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// registerJobs registers all timed jobs with the scheduler
+func registerJobs(sched *scheduler.Scheduler) {
+	// Auto-assign a club when the current 国策 selector misses their deadline
+	sched.Register(scheduler.Job{
+		Name: "policy-deadline",
+		Next: service.NextPolicyDeadline,
+		Run: func() error {
+			_, err := service.CheckAndHandleTimeout()
+			return err
+		},
+	})
+
+	// Bring the hammer down on expired auction lots
+	sched.Register(scheduler.Job{
+		Name: "auction-lots",
+		Next: service.NextAuctionLotDeadline,
+		Run: func() error {
+			_, err := service.SettleExpiredAuctionLots()
+			return err
+		},
+	})
+
+	// Expire trade offers past their expires_at
+	sched.Register(scheduler.Job{
+		Name: "trade-expiry",
+		Next: service.NextTradeExpiry,
+		Run: func() error {
+			_, err := service.ExpireTrades()
+			return err
+		},
+	})
+
+	// Execute trades whose league veto vote ended without a veto
+	sched.Register(scheduler.Job{
+		Name: "trade-review",
+		Next: service.NextTradeReviewDeadline,
+		Run: func() error {
+			_, err := service.ProcessTradeReviews()
+			return err
+		},
+	})
+
+	// Award released generals once their waiver claim period closes
+	sched.Register(scheduler.Job{
+		Name: "waivers",
+		Next: service.NextWaiverDeadline,
+		Run: func() error {
+			_, err := service.ProcessWaivers()
+			return err
+		},
+	})
+
+	// Lock the round's match lineups, flagging illegal ones, once its lineup deadline passes
+	sched.Register(scheduler.Job{
+		Name: "lineup-deadline",
+		Next: service.NextLineupDeadline,
+		Run: func() error {
+			_, err := service.LockDueLineups()
+			return err
+		},
+	})
+
+	// Return over-cap draws of players who did not get back under the cap in time
+	sched.Register(scheduler.Job{
+		Name: "draw-over-cap",
+		Next: service.NextOverCapDeadline,
+		Run: func() error {
+			_, err := service.ResolveOverCapDraws()
+			return err
+		},
+	})
+
+	// Reveal the next draw of a running draw ceremony
+	sched.Register(scheduler.Job{
+		Name: "draw-ceremony",
+		Next: service.NextDrawCeremonyStep,
+		Run: func() error {
+			_, err := service.StepDrawCeremony()
+			return err
+		},
+	})
+
+	// Auto-pick for the drafter on the clock when their timer runs out
+	sched.Register(scheduler.Job{
+		Name: "draft-deadline",
+		Next: service.NextDraftDeadline,
+		Run: func() error {
+			_, err := service.CheckDraftTimeout()
+			return err
+		},
+	})
+}
@@ -103,3 +103,127 @@ func ResetAuction(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "拍卖记录已重置"})
 }
+
+// ===== Live Auction APIs =====
+
+// GetAuctionLots returns live auction lots, optionally filtered by status
+func GetAuctionLots(c *gin.Context) {
+	lots, err := service.GetAuctionLots(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lots)
+}
+
+// GetAuctionLot returns a single lot with its bid history
+func GetAuctionLot(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lot id"})
+		return
+	}
+
+	lot, err := service.GetAuctionLot(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lot)
+}
+
+// PlaceAuctionBidRequest is the request body for bidding on a lot
+type PlaceAuctionBidRequest struct {
+	Amount int `json:"amount" binding:"required"`
+}
+
+// PlaceAuctionBid handles a player's bid on a live lot
+func PlaceAuctionBid(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lot id"})
+		return
+	}
+
+	var req PlaceAuctionBidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lot, err := service.PlaceAuctionBid(userID, uint(id), req.Amount)
+	if err != nil {
+		status := http.StatusBadRequest
+		switch err {
+		case service.ErrNotInAuctionPhase:
+			status = http.StatusForbidden
+		case service.ErrAuctionLotNotFound:
+			status = http.StatusNotFound
+		case service.ErrBidOutdated:
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "出价成功",
+		"lot":     lot,
+	})
+}
+
+// AdminOpenAuctionLot puts an auction general up for live bidding
+func AdminOpenAuctionLot(c *gin.Context) {
+	var req service.OpenAuctionLotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lot, err := service.OpenAuctionLot(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrAuctionGeneralNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrGeneralAlreadyAuctioned || err == service.ErrAuctionLotExists {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "拍卖已开始",
+		"lot":     lot,
+	})
+}
+
+// AdminSettleAuctionLot brings the hammer down on a lot immediately
+func AdminSettleAuctionLot(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lot id"})
+		return
+	}
+
+	lot, err := service.SettleAuctionLot(uint(id), true)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrAuctionLotNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "拍卖已落锤",
+		"lot":     lot,
+	})
+}
@@ -37,7 +37,7 @@ func DraftPick(c *gin.Context) {
 	general, err := service.DraftPick(userID, req.GeneralID)
 	if err != nil {
 		status := http.StatusBadRequest
-		if err == service.ErrNotInDraftPhase || err == service.ErrNotYourTurn {
+		if err == service.ErrNotInDraftPhase || err == service.ErrNotYourTurn || err == service.ErrDraftNotRunning {
 			status = http.StatusForbidden
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
@@ -50,6 +50,111 @@ func DraftPick(c *gin.Context) {
 	})
 }
 
+// GetDraftState returns the draft order and who is on the clock
+func GetDraftState(c *gin.Context) {
+	state, err := service.GetDraftState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// DraftQueueRequest represents a wish-list update
+type DraftQueueRequest struct {
+	GeneralIDs []uint `json:"general_ids"`
+}
+
+// GetDraftQueue returns the current user's draft wish-list
+func GetDraftQueue(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	queue, err := service.GetDraftQueue(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, queue)
+}
+
+// SetDraftQueue replaces the current user's draft wish-list
+func SetDraftQueue(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req DraftQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.SetDraftQueue(userID, req.GeneralIDs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "心愿单已保存"})
+}
+
+// AutoDraftRequest toggles auto-draft
+type AutoDraftRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SetAutoDraft turns auto-draft on or off for the current user
+func SetAutoDraft(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req AutoDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.SetAutoDraft(userID, req.Enabled); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "已关闭自动选秀"
+	if req.Enabled {
+		message = "已开启自动选秀"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// AdminStartDraft generates the draft order and starts the draft
+func AdminStartDraft(c *gin.Context) {
+	var req service.StartDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	state, err := service.StartDraft(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "选秀已开始",
+		"state":   state,
+	})
+}
+
+// AdminSkipDraftPick skips the player currently on the clock
+func AdminSkipDraftPick(c *gin.Context) {
+	state, err := service.SkipDraftPick()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已跳过当前选秀",
+		"state":   state,
+	})
+}
+
 // GetAllGenerals returns all generals
 func GetAllGenerals(c *gin.Context) {
 	generals, err := service.GetAllGenerals()
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle connections open through proxies
+const eventHeartbeat = 25 * time.Second
+
+// StreamEvents handles GET /api/events as a Server-Sent Events stream
+// Authentication is optional: anonymous clients only receive public events.
+// Since EventSource cannot set headers, the token may also be passed as ?token=.
+// Clients resume with the Last-Event-ID header or ?last_event_id=.
+func StreamEvents(c *gin.Context) {
+	var userID uint
+	tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if tokenString == "" {
+		tokenString = c.Query("token")
+	}
+	if tokenString != "" {
+		claims, err := service.ValidateToken(tokenString)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+			return
+		}
+		userID = claims.UserID
+	}
+
+	resumeToken := c.GetHeader("Last-Event-ID")
+	if resumeToken == "" {
+		resumeToken = c.Query("last_event_id")
+	}
+
+	sub, backlog := service.SubscribeEvents(userID, resumeToken)
+	defer service.UnsubscribeEvents(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	for _, event := range backlog {
+		writeEvent(c.Writer, event)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		case event, ok := <-sub.C:
+			if !ok {
+				return false // Dropped for being too slow; client will resume
+			}
+			writeEvent(w, event)
+			return true
+		}
+	})
+}
+
+// writeEvent encodes a single SSE frame
+func writeEvent(w io.Writer, event service.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -107,3 +107,38 @@ func ResetSeason(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "赛季已重置"})
 }
+
+// GetSeasonRules returns the active rule set of the season
+func GetSeasonRules(c *gin.Context) {
+	rules, err := service.GetSeasonRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// AdminUpdateSeasonRules changes the season rules (admin only)
+func AdminUpdateSeasonRules(c *gin.Context) {
+	var req service.SeasonRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules, err := service.UpdateSeasonRules(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrSeasonRulesLocked {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "赛季规则已更新",
+		"rules":   rules,
+	})
+}
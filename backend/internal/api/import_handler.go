@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
+	"san11-trade/internal/service"
 
 	"github.com/gin-gonic/gin"
 	"github.com/xuri/excelize/v2"
@@ -46,10 +48,14 @@ func ImportData(c *gin.Context) {
 		"cities":            result.CitiesCount,
 		"clubs":             result.ClubsCount,
 		"policies":          result.PoliciesCount,
+		"policy_rules":      result.PolicyRulesCount,
+		"unparsed_policies": result.UnparsedPolicies,
 		"rules":             result.RulesCount,
 		"initial_guarantee": result.InitialGuaranteeCount,
 		"initial_normal":    result.InitialNormalCount,
 		"auction":           result.AuctionCount,
+		"injuries":          result.InjuriesCount,
+		"matches":           result.MatchesCount,
 	})
 }
 
@@ -60,10 +66,24 @@ type ImportResult struct {
 	CitiesCount           int
 	ClubsCount            int
 	PoliciesCount         int
+	PolicyRulesCount      int
+	UnparsedPolicies      []UnparsedPolicy
 	RulesCount            int
 	InitialGuaranteeCount int
 	InitialNormalCount    int
 	AuctionCount          int
+	InjuriesCount         int
+	MatchesCount          int
+}
+
+// UnparsedPolicy is an imported 国策 that has no machine-readable rule
+// Its effects are not applied until an admin sets a rule for it.
+type UnparsedPolicy struct {
+	Club      string `json:"club"`
+	Row       int    `json:"row"` // 1-based row in the 国策 sheet
+	Condition string `json:"condition"`
+	Effect    string `json:"effect"`
+	Reason    string `json:"reason"`
 }
 
 // parseExcelFile parses the Excel file and imports data
@@ -130,9 +150,11 @@ func parseExcelFile(filePath string) (*ImportResult, error) {
 
 	// 4. Parse clubs and policies from "国策" sheet
 	if rows, err := f.GetRows("国策"); err == nil && len(rows) > 1 {
-		clubs, totalPolicies := parseClubsAndPolicies(rows, db)
+		clubs, totalPolicies, rules, unparsed := parseClubsAndPolicies(rows, db)
 		result.ClubsCount = clubs
 		result.PoliciesCount = totalPolicies
+		result.PolicyRulesCount = rules
+		result.UnparsedPolicies = unparsed
 	}
 
 	// 5. Parse game rules from "规则" sheet
@@ -188,9 +210,110 @@ func parseExcelFile(filePath string) (*ImportResult, error) {
 		}
 	}
 
+	// 9. Parse injuries from "伤病" sheet
+	// Format: 序号|姓名|伤病轮数|起始轮次|原因
+	if rows, err := f.GetRows("伤病"); err == nil && len(rows) > 1 {
+		var injuries []service.InjuryImportRow
+		for _, row := range rows[1:] { // Skip header row
+			if injury := parseInjuryRow(row); injury != nil {
+				injuries = append(injuries, *injury)
+			}
+		}
+		count, err := service.ImportInjuries(injuries)
+		if err != nil {
+			return nil, err
+		}
+		result.InjuriesCount = count
+	}
+
+	// 10. Parse match results from "赛果" sheet
+	// Format: 轮次|主场|客场|主场得分|客场得分|伤病武将|伤病轮数
+	if rows, err := f.GetRows("赛果"); err == nil && len(rows) > 1 {
+		var matches []service.MatchImportRow
+		for _, row := range rows[1:] { // Skip header row
+			if match := parseMatchRow(row); match != nil {
+				matches = append(matches, *match)
+			}
+		}
+		count, err := service.ImportMatches(matches)
+		if err != nil {
+			return nil, err
+		}
+		result.MatchesCount = count
+	}
+
 	return result, nil
 }
 
+// parseMatchRow parses a match result from Excel row
+// Format: 轮次|主场|客场|主场得分|客场得分|伤病武将|伤病轮数
+func parseMatchRow(row []string) *service.MatchImportRow {
+	if len(row) < 3 {
+		return nil
+	}
+
+	round, err := strconv.Atoi(strings.TrimSpace(row[0]))
+	if err != nil || round <= 0 {
+		return nil
+	}
+	match := &service.MatchImportRow{
+		RoundNumber: round,
+		Home:        strings.TrimSpace(row[1]),
+		Away:        strings.TrimSpace(row[2]),
+	}
+	if match.Home == "" || match.Away == "" {
+		return nil
+	}
+
+	// Scores are optional; a row without them only schedules the match
+	if len(row) > 4 {
+		home, errHome := strconv.Atoi(strings.TrimSpace(row[3]))
+		away, errAway := strconv.Atoi(strings.TrimSpace(row[4]))
+		if errHome == nil && errAway == nil {
+			match.HomeScore = &home
+			match.AwayScore = &away
+		}
+	}
+	if len(row) > 5 {
+		match.Injured = strings.TrimSpace(row[5])
+	}
+	if len(row) > 6 {
+		match.InjuryRounds, _ = strconv.Atoi(strings.TrimSpace(row[6]))
+	}
+	return match
+}
+
+// parseInjuryRow parses an injury from Excel row
+// Format: 序号|姓名|伤病轮数|起始轮次|原因
+func parseInjuryRow(row []string) *service.InjuryImportRow {
+	if len(row) < 3 {
+		return nil
+	}
+
+	excelID, _ := strconv.Atoi(strings.TrimSpace(row[0]))
+	name := strings.TrimSpace(row[1])
+	if excelID == 0 && name == "" {
+		return nil
+	}
+	rounds, err := strconv.Atoi(strings.TrimSpace(row[2]))
+	if err != nil {
+		return nil
+	}
+
+	injury := &service.InjuryImportRow{
+		ExcelID: excelID,
+		Name:    name,
+		Rounds:  rounds,
+	}
+	if len(row) > 3 {
+		injury.FromRound, _ = strconv.Atoi(strings.TrimSpace(row[3]))
+	}
+	if len(row) > 4 {
+		injury.Reason = strings.TrimSpace(row[4])
+	}
+	return injury
+}
+
 // parseGeneralRow parses a general from Excel row
 // Format: 序号|姓名|价值|统御|武力|智力|政治|魅力|五维|相性|枪|戟|弩|骑|兵|水|特技|义理|野望|性格|统武和|改动
 func parseGeneralRow(row []string) *model.General {
@@ -469,15 +592,20 @@ func parseCityRow(row []string) *model.City {
 // parseClubsAndPolicies parses clubs and their policies from "国策" sheet
 // New format based on user's Excel:
 //
-//	Row: "N"(序号)  | "条件" | "效果"     <- 俱乐部段开始标记
-//	Row: "俱乐部名" | ""     | "基础效果"  <- 俱乐部名和基础效果
-//	Row: "联赛名"   | "条件" | "效果"     <- 第一个国策条目，同时联赛名作为标签
-//	Row: "标签1"    | "条件" | "效果"     <- 国策条目，标签名
-//	Row: "标签2"    | "条件" | "效果"     <- 国策条目，标签名
+//	Row: "N"(序号)  | "条件" | "效果"     | "规则"  <- 俱乐部段开始标记
+//	Row: "俱乐部名" | ""     | "基础效果"  | "规则"  <- 俱乐部名和基础效果
+//	Row: "联赛名"   | "条件" | "效果"     | "规则"  <- 第一个国策条目，同时联赛名作为标签
+//	Row: "标签1"    | "条件" | "效果"     | "规则"  <- 国策条目，标签名
+//	Row: "标签2"    | "条件" | "效果"     | "规则"  <- 国策条目，标签名
 //	空行 -> 下一个俱乐部
-func parseClubsAndPolicies(rows [][]string, db *gorm.DB) (int, int) {
+//
+// The optional 规则 column holds the machine-readable policy rule. Policies without a
+// valid rule are imported as text only and returned so the admin can write their rules.
+func parseClubsAndPolicies(rows [][]string, db *gorm.DB) (int, int, int, []UnparsedPolicy) {
 	clubsCount := 0
 	policiesCount := 0
+	rulesCount := 0
+	unparsed := []UnparsedPolicy{}
 
 	i := 0
 	for i < len(rows) {
@@ -537,6 +665,12 @@ func parseClubsAndPolicies(rows [][]string, db *gorm.DB) (int, int) {
 					Condition: "", // Empty condition means base effect
 					Effect:    baseEffect,
 				}
+				if rule, reason := parsePolicyRuleCell(clubName, clubRow); reason == "" {
+					policy.Rule = rule
+					rulesCount++
+				} else {
+					unparsed = append(unparsed, UnparsedPolicy{Club: clubName, Row: i + 1, Effect: baseEffect, Reason: reason})
+				}
 				db.Create(policy)
 				policiesCount++
 				sortOrder++
@@ -603,6 +737,12 @@ func parseClubsAndPolicies(rows [][]string, db *gorm.DB) (int, int) {
 						Condition: condition,
 						Effect:    effect,
 					}
+					if rule, reason := parsePolicyRuleCell(clubName, policyRow); reason == "" {
+						policy.Rule = rule
+						rulesCount++
+					} else {
+						unparsed = append(unparsed, UnparsedPolicy{Club: clubName, Row: i + 1, Condition: condition, Effect: effect, Reason: reason})
+					}
 					db.Create(policy)
 					policiesCount++
 					sortOrder++
@@ -626,7 +766,24 @@ func parseClubsAndPolicies(rows [][]string, db *gorm.DB) (int, int) {
 		i++
 	}
 
-	return clubsCount, policiesCount
+	return clubsCount, policiesCount, rulesCount, unparsed
+}
+
+// parsePolicyRuleCell returns the validated rule from the 规则 column of a 国策 row
+// When there is no usable rule it returns why instead.
+func parsePolicyRuleCell(clubName string, row []string) (string, string) {
+	rule := ""
+	if len(row) > 3 {
+		rule = strings.TrimSpace(row[3])
+	}
+	if rule == "" || rule == "规则" {
+		return "", "no rule"
+	}
+	if _, err := service.ParsePolicyRule(rule); err != nil {
+		log.Printf("Policy import: %s: %v, rule skipped", clubName, err)
+		return "", err.Error()
+	}
+	return rule, ""
 }
 
 // isTagOrLeagueName checks if a string looks like a tag or league name (short Chinese string)
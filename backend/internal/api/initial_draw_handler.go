@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -19,6 +20,8 @@ func DrawOnce(c *gin.Context) {
 		status := http.StatusBadRequest
 		if err == service.ErrNotInDrawPhase {
 			status = http.StatusForbidden
+		} else if err == service.ErrCeremonyActive {
+			status = http.StatusConflict
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
 		return
@@ -78,8 +81,57 @@ func GetDrawPoolHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, generals)
 }
 
+// GetDrawSeeds returns every draw seed commitment; seeds show once revealed
+func GetDrawSeeds(c *gin.Context) {
+	seeds, err := service.GetDrawSeeds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, seeds)
+}
+
+// VerifyDrawSeed re-computes every draw made with a revealed seed
+func VerifyDrawSeed(c *gin.Context) {
+	seedID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid seed id"})
+		return
+	}
+
+	verification, err := service.VerifyDrawSeed(uint(seedID))
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch err {
+		case service.ErrDrawSeedNotFound:
+			status = http.StatusNotFound
+		case service.ErrDrawSeedNotRevealed:
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, verification)
+}
+
 // ===== Admin Draw APIs =====
 
+// AdminRevealDrawSeed reveals the current draw seed ahead of the phase change
+func AdminRevealDrawSeed(c *gin.Context) {
+	seeds, err := service.RevealDrawSeeds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "抽将种子已公开",
+		"seeds":   seeds,
+	})
+}
+
 // AdminResetUserDraw resets a user's draw results
 func AdminResetUserDraw(c *gin.Context) {
 	userIDStr := c.Param("userId")
@@ -121,6 +173,10 @@ func AdminDrawForUser(c *gin.Context) {
 	}
 
 	generals, err := service.DrawForUser(uint(userID))
+	if err == service.ErrCeremonyActive {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil && err != service.ErrDrawLimitReached {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -134,8 +190,36 @@ func AdminDrawForUser(c *gin.Context) {
 }
 
 // AdminDrawForAll performs all draws for all registered users
+// With {"ceremony": true} it starts a draw ceremony instead, which draws one at a time.
 func AdminDrawForAll(c *gin.Context) {
-	results, err := service.DrawForAllUsers()
+	var req struct {
+		Ceremony bool `json:"ceremony"`
+		service.DrawCeremonyRequest
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Ceremony {
+		ceremony, err := service.StartDrawCeremony(&req.DrawCeremonyRequest)
+		if err != nil {
+			c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "抽将仪式已开始",
+			"ceremony": ceremony,
+		})
+		return
+	}
+
+	results, failures, err := service.DrawForAllUsers()
+	if err == service.ErrCeremonyActive {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -159,10 +243,251 @@ func AdminDrawForAll(c *gin.Context) {
 		totalCount += len(generals)
 	}
 
+	message := "批量抽将完成"
+	if len(failures) > 0 {
+		message = fmt.Sprintf("批量抽将完成，%d 名玩家抽将失败", len(failures))
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"message":     "批量抽将完成",
+		"message":     message,
 		"results":     response,
+		"failures":    failures,
 		"user_count":  len(results),
 		"total_count": totalCount,
 	})
 }
+
+// AdminGetDrawTables returns the tier odds of every draw pool
+func AdminGetDrawTables(c *gin.Context) {
+	tables, err := service.GetDrawTables()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tables)
+}
+
+// AdminUpdateDrawTable sets the tier weights, caps and pity of a draw pool
+func AdminUpdateDrawTable(c *gin.Context) {
+	var req service.DrawTableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	table, err := service.UpdateDrawTable(&req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrDrawTablesLocked {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "抽将概率表已更新",
+		"table":   table,
+	})
+}
+
+// AdminPreviewDrawTables returns the odds of every draw pool with warnings
+func AdminPreviewDrawTables(c *gin.Context) {
+	previews, err := service.PreviewDrawTables()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, previews)
+}
+
+// AdminSetGeneralTiers changes the tier of several generals at once
+func AdminSetGeneralTiers(c *gin.Context) {
+	var req struct {
+		Tiers map[uint]int `json:"tiers" binding:"required"` // General ID -> tier
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := service.SetGeneralTiers(req.Tiers)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrDrawTablesLocked {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "武将档位已更新",
+		"updated": updated,
+	})
+}
+
+// AdminGetDrawRerolls returns the logged draw re-rolls
+func AdminGetDrawRerolls(c *gin.Context) {
+	rerolls, err := service.GetDrawRerolls()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rerolls)
+}
+
+// AdminResolveOverCapDraws settles every player whose over-cap window has closed
+func AdminResolveOverCapDraws(c *gin.Context) {
+	resolved, err := service.ResolveOverCapDraws()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "超额抽将已处理",
+		"resolved": resolved,
+	})
+}
+
+// DrawMulligan returns the current user's latest draw with a mulligan token and draws again
+func DrawMulligan(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+
+	result, err := service.Mulligan(userID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotInDrawPhase {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "重抽成功",
+		"returned":  result.Returned,
+		"general":   result.General,
+		"draw_type": result.DrawType,
+		"remaining": result.Remaining,
+	})
+}
+
+// AdminSetMulliganTokens sets the mulligan tokens granted to a user
+func AdminSetMulliganTokens(c *gin.Context) {
+	userIDStr := c.Param("userId")
+	userID, err := strconv.ParseUint(userIDStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req struct {
+		Tokens int `json:"tokens"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := service.SetMulliganTokens(uint(userID), req.Tokens)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrUserNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "重抽次数已更新",
+		"user":    user,
+	})
+}
+
+// GetDrawCeremony returns the public state of the draw ceremony
+func GetDrawCeremony(c *gin.Context) {
+	view, err := service.GetDrawCeremony()
+	if err != nil {
+		c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// AdminPauseDrawCeremony pauses the automatic steps of the draw ceremony
+func AdminPauseDrawCeremony(c *gin.Context) {
+	ceremony, err := service.PauseDrawCeremony()
+	if err != nil {
+		c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "抽将仪式已暂停",
+		"ceremony": ceremony,
+	})
+}
+
+// AdminResumeDrawCeremony resumes a paused draw ceremony
+func AdminResumeDrawCeremony(c *gin.Context) {
+	ceremony, err := service.ResumeDrawCeremony()
+	if err != nil {
+		c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "抽将仪式已继续",
+		"ceremony": ceremony,
+	})
+}
+
+// AdminStepDrawCeremony reveals the next draw of the ceremony
+func AdminStepDrawCeremony(c *gin.Context) {
+	reveal, err := service.StepDrawCeremony()
+	if err != nil {
+		c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "已揭晓下一抽"
+	if reveal == nil {
+		message = "抽将仪式已结束"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"reveal":  reveal,
+	})
+}
+
+// AdminSkipDrawCeremonyPlayer moves the ceremony on to the next player
+func AdminSkipDrawCeremonyPlayer(c *gin.Context) {
+	ceremony, err := service.SkipDrawCeremonyPlayer()
+	if err != nil {
+		c.JSON(drawCeremonyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "已跳过当前玩家",
+		"ceremony": ceremony,
+	})
+}
+
+func drawCeremonyErrorStatus(err error) int {
+	switch err {
+	case service.ErrCeremonyNotFound:
+		return http.StatusNotFound
+	case service.ErrNotInDrawPhase:
+		return http.StatusForbidden
+	case service.ErrCeremonyActive, service.ErrCeremonyNotActive, service.ErrCeremonyBusy:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInjuries returns injury records, active ones unless ?all=true
+func GetInjuries(c *gin.Context) {
+	injuries, err := service.GetInjuries(c.Query("all") != "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, injuries)
+}
+
+// AdminInjureGeneral flags a general injured for a number of rounds (admin only)
+func AdminInjureGeneral(c *gin.Context) {
+	adminID := GetCurrentUserID(c)
+	var req service.InjuryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	injury, err := service.InjureGeneral(&req, service.InjurySourceAdmin, adminID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrGeneralNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "伤病已登记",
+		"injury":  injury,
+	})
+}
+
+// AdminClearInjury makes an injured general available again (admin only)
+func AdminClearInjury(c *gin.Context) {
+	generalID, err := strconv.ParseUint(c.Param("generalId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid general id"})
+		return
+	}
+
+	if err := service.ClearInjury(uint(generalID)); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrGeneralNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "伤病已解除"})
+}
@@ -0,0 +1,239 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// lineupErrorStatus maps lineup errors to HTTP status codes
+func lineupErrorStatus(err error) int {
+	switch {
+	case err == service.ErrLineupNotFound, err == service.ErrMatchNotFound:
+		return http.StatusNotFound
+	case err == service.ErrNotMatchPlayer, err == service.ErrNotInMatchPhase:
+		return http.StatusForbidden
+	case err == service.ErrLineupLocked, err == service.ErrLineupDeadlinePassed:
+		return http.StatusConflict
+	case errors.Is(err, service.ErrIllegalLineup), err == service.ErrLineupRoundPassed, err == service.ErrMatchNotPlayable:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// GetLineupRules returns the lineup rules of a round (?round=, default current round)
+func GetLineupRules(c *gin.Context) {
+	round, _ := strconv.Atoi(c.Query("round"))
+	if round <= 0 {
+		phase, err := service.GetGamePhase()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		round = phase.RoundNumber
+	}
+
+	settings, err := service.GetLineupRound(round)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetMyLineup returns the current user's lineup (?round=, default current round)
+func GetMyLineup(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	round, _ := strconv.Atoi(c.Query("round"))
+
+	lineup, err := service.GetMyLineup(userID, round)
+	if err != nil {
+		c.JSON(lineupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lineup)
+}
+
+// SubmitLineup saves the current user's lineup for a round
+func SubmitLineup(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req service.LineupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	lineup, err := service.SubmitLineup(userID, &req)
+	if err != nil {
+		c.JSON(lineupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "阵容已保存"
+	if lineup.Status == "locked" {
+		message = "阵容已锁定"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"lineup":  lineup,
+	})
+}
+
+// LockLineup locks the current user's lineup for a round
+func LockLineup(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req struct {
+		RoundNumber int `json:"round_number"`
+	}
+	c.ShouldBindJSON(&req)
+
+	lineup, err := service.LockLineup(userID, req.RoundNumber)
+	if err != nil {
+		c.JSON(lineupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "阵容已锁定",
+		"lineup":  lineup,
+	})
+}
+
+// GetMatchLineups returns both lineups of a match, the opponent's once both are locked
+func GetMatchLineups(c *gin.Context) {
+	getMatchLineups(c, false)
+}
+
+// AdminGetMatchLineups returns both lineups of a match regardless of locks (admin only)
+func AdminGetMatchLineups(c *gin.Context) {
+	getMatchLineups(c, true)
+}
+
+func getMatchLineups(c *gin.Context, isAdmin bool) {
+	matchID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	lineups, err := service.GetMatchLineups(uint(matchID), GetCurrentUserID(c), isAdmin)
+	if err != nil {
+		c.JSON(lineupErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lineups)
+}
+
+// AdminGetLineups returns every lineup of a round (?round=, default current round)
+func AdminGetLineups(c *gin.Context) {
+	round, _ := strconv.Atoi(c.Query("round"))
+	if round <= 0 {
+		phase, err := service.GetGamePhase()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		round = phase.RoundNumber
+	}
+
+	lineups, err := service.GetRoundLineups(round)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, lineups)
+}
+
+// AdminGetLineupRounds returns the lineup rules of every configured round (admin only)
+func AdminGetLineupRounds(c *gin.Context) {
+	rounds, err := service.GetLineupRounds()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rounds)
+}
+
+// AdminSetLineupRound sets the lineup deadline and size rules of a round (admin only)
+func AdminSetLineupRound(c *gin.Context) {
+	var req service.LineupRoundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := service.SetLineupRound(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "阵容规则已更新",
+		"settings": settings,
+	})
+}
+
+// AdminGetLineupRestrictions returns the lineup restrictions of all clubs (admin only)
+func AdminGetLineupRestrictions(c *gin.Context) {
+	restrictions, err := service.GetLineupRestrictions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, restrictions)
+}
+
+// AdminSetLineupRestriction sets the lineup restriction of a club (admin only)
+func AdminSetLineupRestriction(c *gin.Context) {
+	clubID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid club id"})
+		return
+	}
+
+	var req service.LineupRestrictionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	restriction, err := service.SetLineupRestriction(uint(clubID), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrClubNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "俱乐部阵容限制已更新",
+		"restriction": restriction,
+	})
+}
+
+// AdminLockDueLineups locks the lineups of every round past its deadline (admin only)
+func AdminLockDueLineups(c *gin.Context) {
+	locked, err := service.LockDueLineups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "阵容已锁定",
+		"locked":  locked,
+	})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listingAptitudeParams and listingStatParams are the general filters accepted by the search
+var (
+	listingAptitudeParams = []string{"spear", "halberd", "crossbow", "cavalry", "soldier", "water"}
+	listingStatParams     = []string{"command", "force", "intelligence", "politics", "charm"}
+)
+
+// SearchListings browses active trade listings
+// Query: type, item_type, user_id, keyword, skill, max_salary, <aptitude>=S, min_<stat>=90
+func SearchListings(c *gin.Context) {
+	query := &service.ListingQuery{
+		Type:     c.Query("type"),
+		ItemType: c.Query("item_type"),
+	}
+	if userID, err := strconv.ParseUint(c.Query("user_id"), 10, 32); err == nil {
+		query.UserID = uint(userID)
+	}
+
+	query.General.Keyword = c.Query("keyword")
+	query.General.Skill = c.Query("skill")
+	query.General.MaxSalary, _ = strconv.Atoi(c.Query("max_salary"))
+	for _, key := range listingAptitudeParams {
+		if grade := c.Query(key); grade != "" {
+			if query.General.Aptitudes == nil {
+				query.General.Aptitudes = make(map[string]string)
+			}
+			query.General.Aptitudes[key] = grade
+		}
+	}
+	for _, key := range listingStatParams {
+		if min, err := strconv.Atoi(c.Query("min_" + key)); err == nil {
+			if query.General.MinStats == nil {
+				query.General.MinStats = make(map[string]int)
+			}
+			query.General.MinStats[key] = min
+		}
+	}
+
+	listings, err := service.SearchListings(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, listings)
+}
+
+// CreateListing puts an asset on the block or posts a wanted listing
+func CreateListing(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req service.ListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	listing, err := service.CreateListing(userID, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "挂牌已发布",
+		"listing": listing,
+	})
+}
+
+// GetListingByID returns a listing by ID
+func GetListingByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listing id"})
+		return
+	}
+
+	listing, err := service.GetListingByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "listing not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, listing)
+}
+
+// CloseListing takes down one of the current user's listings
+func CloseListing(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listing id"})
+		return
+	}
+
+	if err := service.CloseListing(uint(id), userID); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant {
+			status = http.StatusForbidden
+		} else if err == service.ErrListingNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "挂牌已下架"})
+}
+
+// GetListingMatches returns generals that satisfy a wanted listing
+func GetListingMatches(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listing id"})
+		return
+	}
+
+	generals, err := service.GetListingMatches(uint(id))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrListingNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, generals)
+}
+
+// ProposeFromListing creates a trade proposal pre-filled from a listing
+func ProposeFromListing(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid listing id"})
+		return
+	}
+
+	var req service.ListingProposalRequest
+	c.ShouldBindJSON(&req)
+
+	trade, err := service.ProposeFromListing(uint(id), userID, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotInTradingPhase || err == service.ErrTradeWindowClosed || err == service.ErrTradeLimitReached {
+			status = http.StatusForbidden
+		} else if err == service.ErrListingNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "交易请求已发送",
+		"trade":   trade,
+	})
+}
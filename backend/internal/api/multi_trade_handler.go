@@ -0,0 +1,226 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateMultiTrade handles multi-party trade creation
+func CreateMultiTrade(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req service.MultiTradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trade, err := service.CreateMultiTrade(userID, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotInTradingPhase {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "多方交易请求已发送",
+		"trade":   trade,
+	})
+}
+
+// GetMultiTrades returns multi-party trades of the current user
+func GetMultiTrades(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	trades, err := service.GetMultiTrades(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trades)
+}
+
+// GetMultiTradeByID returns a multi-party trade by ID
+func GetMultiTradeByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	trade, err := service.GetMultiTradeByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "trade not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, trade)
+}
+
+// AcceptMultiTrade handles a participant accepting a multi-party trade
+func AcceptMultiTrade(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	if err := service.AcceptMultiTrade(uint(id), userID); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant {
+			status = http.StatusForbidden
+		} else if err == service.ErrTradeConflict {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已同意多方交易"})
+}
+
+// RejectMultiTrade handles a participant rejecting a multi-party trade
+func RejectMultiTrade(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	if err := service.RejectMultiTrade(uint(id), userID); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "多方交易已拒绝"})
+}
+
+// CancelMultiTrade handles the proposer withdrawing a multi-party trade
+func CancelMultiTrade(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	if err := service.CancelMultiTrade(uint(id), userID); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "多方交易已取消"})
+}
+
+// GetMultiTradeReviewQueue returns multi-party trades awaiting approval
+func GetMultiTradeReviewQueue(c *gin.Context) {
+	trades, err := service.GetMultiTradeReviewQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trades)
+}
+
+// CastMultiTradeVeto records the current user's league veto vote on a multi-party trade
+func CastMultiTradeVeto(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	c.ShouldBindJSON(&req)
+
+	if err := service.CastMultiTradeVeto(uint(id), userID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrCannotVetoOwnTrade || err == service.ErrNotRegisteredToVote {
+			status = http.StatusForbidden
+		} else if err == service.ErrMultiTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "否决票已提交"})
+}
+
+// AdminApproveMultiTrade settles a multi-party trade awaiting approval (admin only)
+func AdminApproveMultiTrade(c *gin.Context) {
+	adminID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	c.ShouldBindJSON(&req)
+
+	if err := service.ApproveMultiTrade(uint(id), adminID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMultiTradeNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrTradeConflict {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "多方交易已批准并执行"})
+}
+
+// AdminVetoMultiTrade blocks a multi-party trade awaiting approval (admin only)
+func AdminVetoMultiTrade(c *gin.Context) {
+	adminID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.VetoMultiTrade(uint(id), adminID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMultiTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "多方交易已否决"})
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"san11-trade/internal/service"
@@ -273,7 +274,8 @@ func AdminSelectClubForUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "已为用户选择国策"})
 }
 
-// AdminCheckPolicyTimeout checks and handles timeout (can be called by scheduler)
+// AdminCheckPolicyTimeout checks and handles timeout immediately
+// The background scheduler does this automatically; this endpoint forces a check.
 func AdminCheckPolicyTimeout(c *gin.Context) {
 	handled, err := service.CheckAndHandleTimeout()
 	if err != nil {
@@ -307,3 +309,97 @@ func AdminForceNextSelector(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "已跳过当前选择者并自动分配"})
 }
+
+// GetMyPolicyEffects evaluates the current user's 国策 against their roster
+func GetMyPolicyEffects(c *gin.Context) {
+	status, err := service.GetPlayerPolicyStatus(GetCurrentUserID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetPlayerPolicyEffects evaluates a player's 国策 against their roster
+func GetPlayerPolicyEffects(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid player id"})
+		return
+	}
+
+	status, err := service.GetPlayerPolicyStatus(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "player not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// AdminParsePolicyRule checks a policy rule and returns its parsed form
+func AdminParsePolicyRule(c *gin.Context) {
+	var req struct {
+		Rule string `json:"rule" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := service.ParsePolicyRule(req.Rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// AdminSetPolicyRule sets or clears the machine-readable rule of a 国策 (admin only)
+func AdminSetPolicyRule(c *gin.Context) {
+	policyID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid policy id"})
+		return
+	}
+
+	var req struct {
+		Rule string `json:"rule"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := service.SetPolicyRule(uint(policyID), strings.TrimSpace(req.Rule))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrPolicyNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "国策规则已更新",
+		"policy":  policy,
+	})
+}
+
+// AdminApplyClubEffects recomputes the 国策 effects of every player (admin only)
+// Effects are recomputed automatically on club and roster changes; this forces a full pass.
+func AdminApplyClubEffects(c *gin.Context) {
+	count, err := service.ApplyAllClubEffects()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "国策效果已重新计算",
+		"count":   count,
+	})
+}
@@ -77,6 +77,12 @@ func GetCurrentUserID(c *gin.Context) uint {
 	return userID.(uint)
 }
 
+// IsCurrentUserAdmin reports whether the current user is an admin
+func IsCurrentUserAdmin(c *gin.Context) bool {
+	isAdmin, exists := c.Get("is_admin")
+	return exists && isAdmin.(bool)
+}
+
 // SetupRouter configures all API routes
 func SetupRouter() *gin.Engine {
 	if config.AppConfig.Server.Port == "" {
@@ -89,7 +95,7 @@ func SetupRouter() *gin.Engine {
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Last-Event-ID")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(http.StatusNoContent)
@@ -125,11 +131,24 @@ func SetupRouter() *gin.Engine {
 		api.GET("/clubs/:id/detail", GetClubDetail) // Club with policies
 		api.GET("/cities", GetCities)               // City list
 		api.GET("/rules", GetGameRules)             // Game rules
+		api.GET("/season-rules", GetSeasonRules)    // Active season rule set
 		api.GET("/players", GetRegisteredPlayers)
+		api.GET("/draw/seeds", GetDrawSeeds)
+		api.GET("/draw/seeds/:id/verify", VerifyDrawSeed)
+		api.GET("/draw/ceremony", GetDrawCeremony)
 		api.GET("/players/:id/roster", GetPlayerRoster)
+		api.GET("/players/:id/policies", GetPlayerPolicyEffects)
+		api.GET("/injuries", GetInjuries)
+		api.GET("/matches", GetMatches)
+		api.GET("/matches/:id", GetMatchByID)
+		api.GET("/tournament/groups", GetTournamentGroups)
+		api.GET("/tournament/standings", GetTournamentStandings)
+		api.GET("/tournament/bracket", GetKnockoutBracket)
+		api.GET("/standings", GetStandings)
 		api.GET("/statistics", GetStatistics)
 		api.GET("/config/registration", GetRegistrationConfig) // Registration config (invite code required?)
 		api.GET("/invite-codes/validate", ValidateInviteCode)  // Validate invite code (public)
+		api.GET("/events", StreamEvents)                       // Server-Sent Events stream (auth optional)
 
 		// Protected routes (require authentication)
 		protected := api.Group("")
@@ -149,26 +168,77 @@ func SetupRouter() *gin.Engine {
 				// Draw routes (unified)
 				game.POST("/draw", DrawOnce)
 				game.GET("/draw/status", GetDrawStatus)
+				game.POST("/draw/mulligan", DrawMulligan)
 				game.GET("/draw/results", GetAllDrawResults)
 				game.GET("/draw/pool", GetDrawPoolHandler)
 
 				// Draft routes
 				game.GET("/draft/pool", GetDraftPool)
 				game.POST("/draft/pick", DraftPick)
+				game.GET("/draft/state", GetDraftState)
+				game.GET("/draft/queue", GetDraftQueue)
+				game.POST("/draft/queue", SetDraftQueue)
+				game.POST("/draft/auto", SetAutoDraft)
 
 				// Trade routes
 				game.POST("/trades", CreateTrade)
+				game.POST("/trades/evaluate", EvaluateTrade)
 				game.GET("/trades/pending", GetPendingTrades)
 				game.GET("/trades/history", GetTradeHistory)
+				game.GET("/trades/window", GetTradeWindowStatus)
+				game.GET("/trades/review", GetTradeReviewQueue)
 				game.GET("/trades/:id", GetTradeByID)
 				game.POST("/trades/:id/accept", AcceptTrade)
 				game.POST("/trades/:id/reject", RejectTrade)
 				game.POST("/trades/:id/cancel", CancelTrade)
+				game.POST("/trades/:id/counter", CounterTrade)
+				game.POST("/trades/:id/messages", PostTradeMessage)
+				game.POST("/trades/:id/veto", CastTradeVeto)
+
+				// Trade block / wanted listings
+				game.GET("/listings", SearchListings)
+				game.POST("/listings", CreateListing)
+				game.GET("/listings/:id", GetListingByID)
+				game.DELETE("/listings/:id", CloseListing)
+				game.GET("/listings/:id/matches", GetListingMatches)
+				game.POST("/listings/:id/propose", ProposeFromListing)
+
+				// Multi-party trade routes
+				game.POST("/multi-trades", CreateMultiTrade)
+				game.GET("/multi-trades", GetMultiTrades)
+				game.GET("/multi-trades/review", GetMultiTradeReviewQueue)
+				game.GET("/multi-trades/:id", GetMultiTradeByID)
+				game.POST("/multi-trades/:id/accept", AcceptMultiTrade)
+				game.POST("/multi-trades/:id/reject", RejectMultiTrade)
+				game.POST("/multi-trades/:id/cancel", CancelMultiTrade)
+				game.POST("/multi-trades/:id/veto", CastMultiTradeVeto)
+
+				// Waiver routes
+				game.GET("/waivers", GetWaivers)
+				game.GET("/waivers/priority", GetWaiverPriority)
+				game.POST("/waivers/release", ReleaseGeneral)
+				game.POST("/waivers/:id/claim", ClaimWaiver)
+				game.DELETE("/waivers/:id/claim", CancelWaiverClaim)
+
+				// Match result routes
+				game.POST("/matches/:id/report", SubmitMatchReport)
+				game.POST("/matches/:id/confirm", ConfirmMatchReport)
+				game.POST("/matches/:id/dispute", DisputeMatchReport)
+
+				// Lineup routes
+				game.GET("/lineups/rules", GetLineupRules)
+				game.GET("/lineups/me", GetMyLineup)
+				game.POST("/lineups", SubmitLineup)
+				game.POST("/lineups/lock", LockLineup)
+				game.GET("/matches/:id/lineups", GetMatchLineups)
 
 				// Auction routes
 				game.GET("/auction/pool", GetAuctionPool)
 				game.GET("/auction/results", GetAuctionResults)
 				game.GET("/auction/stats", GetAuctionStats)
+				game.GET("/auction/lots", GetAuctionLots)
+				game.GET("/auction/lots/:id", GetAuctionLot)
+				game.POST("/auction/lots/:id/bid", PlaceAuctionBid)
 
 				// Policy routes (国策拍卖)
 				game.GET("/policy/status", GetPolicyStatus)
@@ -179,6 +249,7 @@ func SetupRouter() *gin.Engine {
 				game.GET("/policy/results", GetPolicySelectionResults)
 				game.GET("/policy/clubs", GetClubsWithFilters)
 				game.GET("/policy/filters", GetClubFilters)
+				game.GET("/policy/effects", GetMyPolicyEffects)
 			}
 
 			// Sign up (doesn't require previous registration)
@@ -191,7 +262,26 @@ func SetupRouter() *gin.Engine {
 		{
 			admin.POST("/phase", SetGamePhase)
 			admin.POST("/reset", ResetSeason)
+			admin.GET("/season-rules", GetSeasonRules)
+			admin.PUT("/season-rules", AdminUpdateSeasonRules)
 			admin.GET("/trades", GetAllTrades)
+			admin.GET("/trades/review", GetTradeReviewQueue)
+			admin.POST("/trades/:id/approve", AdminApproveTrade)
+			admin.POST("/trades/:id/veto", AdminVetoTrade)
+			admin.GET("/multi-trades/review", GetMultiTradeReviewQueue)
+			admin.POST("/multi-trades/:id/approve", AdminApproveMultiTrade)
+			admin.POST("/multi-trades/:id/veto", AdminVetoMultiTrade)
+			admin.GET("/trade-settings", AdminGetTradeSettings)
+			admin.PUT("/trade-settings", AdminUpdateTradeSettings)
+			admin.GET("/trade-windows", AdminGetTradeWindows)
+			admin.POST("/trade-windows", AdminCreateTradeWindow)
+			admin.PUT("/trade-windows/:id", AdminUpdateTradeWindow)
+			admin.DELETE("/trade-windows/:id", AdminDeleteTradeWindow)
+			admin.GET("/waiver-settings", AdminGetWaiverSettings)
+			admin.PUT("/waiver-settings", AdminUpdateWaiverSettings)
+			admin.POST("/waivers/process", AdminProcessWaivers)
+			admin.POST("/injuries", AdminInjureGeneral)
+			admin.DELETE("/injuries/:generalId", AdminClearInjury)
 			admin.POST("/import", ImportData)
 
 			// Invite code management
@@ -206,11 +296,29 @@ func SetupRouter() *gin.Engine {
 			admin.POST("/draw/reset-all", AdminResetAllDraw)
 			admin.POST("/draw/for/:userId", AdminDrawForUser)
 			admin.POST("/draw/for-all", AdminDrawForAll)
+			admin.POST("/draw/ceremony/pause", AdminPauseDrawCeremony)
+			admin.POST("/draw/ceremony/resume", AdminResumeDrawCeremony)
+			admin.POST("/draw/ceremony/step", AdminStepDrawCeremony)
+			admin.POST("/draw/ceremony/skip", AdminSkipDrawCeremonyPlayer)
+			admin.POST("/draw/seed/reveal", AdminRevealDrawSeed)
+			admin.GET("/draw/tables", AdminGetDrawTables)
+			admin.PUT("/draw/tables", AdminUpdateDrawTable)
+			admin.GET("/draw/tables/preview", AdminPreviewDrawTables)
+			admin.PUT("/generals/tiers", AdminSetGeneralTiers)
+			admin.GET("/draw/rerolls", AdminGetDrawRerolls)
+			admin.POST("/draw/resolve-over-cap", AdminResolveOverCapDraws)
+			admin.PUT("/draw/mulligans/:userId", AdminSetMulliganTokens)
+
+			// Draft management
+			admin.POST("/draft/start", AdminStartDraft)
+			admin.POST("/draft/skip", AdminSkipDraftPick)
 
 			// Auction management
 			admin.GET("/auction/stats", GetAuctionStats)
 			admin.POST("/auction/assign", AssignAuction)
 			admin.POST("/auction/reset/:generalId", ResetAuction)
+			admin.POST("/auction/lots", AdminOpenAuctionLot)
+			admin.POST("/auction/lots/:id/settle", AdminSettleAuctionLot)
 
 			// Policy management (国策管理)
 			admin.POST("/policy/close-bidding", AdminClosePolicyBidding)
@@ -221,6 +329,26 @@ func SetupRouter() *gin.Engine {
 			admin.POST("/policy/select-for/:userId", AdminSelectClubForUser)
 			admin.POST("/policy/check-timeout", AdminCheckPolicyTimeout)
 			admin.POST("/policy/force-next", AdminForceNextSelector)
+			admin.POST("/policy/rules/parse", AdminParsePolicyRule)
+			admin.PUT("/policies/:id/rule", AdminSetPolicyRule)
+			admin.POST("/policy/apply-effects", AdminApplyClubEffects)
+
+			// Tournament management
+			admin.POST("/tournament/groups", AdminDrawGroups)
+			admin.POST("/tournament/knockout", AdminGenerateKnockout)
+			admin.DELETE("/tournament", AdminResetTournament)
+			admin.POST("/matches", AdminCreateMatch)
+			admin.GET("/matches/disputed", AdminGetDisputedMatches)
+			admin.POST("/matches/:id/result", AdminRecordMatchResult)
+
+			// Lineup management
+			admin.GET("/lineups", AdminGetLineups)
+			admin.GET("/matches/:id/lineups", AdminGetMatchLineups)
+			admin.GET("/lineup-rounds", AdminGetLineupRounds)
+			admin.PUT("/lineup-rounds", AdminSetLineupRound)
+			admin.GET("/lineup-restrictions", AdminGetLineupRestrictions)
+			admin.PUT("/clubs/:id/lineup-restriction", AdminSetLineupRestriction)
+			admin.POST("/lineups/lock-due", AdminLockDueLineups)
 		}
 	}
 
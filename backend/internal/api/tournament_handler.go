@@ -0,0 +1,316 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTournamentGroups returns the group-stage groups
+func GetTournamentGroups(c *gin.Context) {
+	groups, err := service.GetTournamentGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetTournamentStandings returns the group tables
+func GetTournamentStandings(c *gin.Context) {
+	standings, err := service.GetGroupStandings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// GetKnockoutBracket returns the knockout bracket
+func GetKnockoutBracket(c *gin.Context) {
+	matches, err := service.GetKnockoutBracket()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+// GetMatches returns fixtures
+// Query: round (default current round, "all" for every round), stage, user_id
+func GetMatches(c *gin.Context) {
+	round := 0
+	switch roundStr := c.Query("round"); roundStr {
+	case "all":
+	case "":
+		phase, err := service.GetGamePhase()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		round = phase.RoundNumber
+	default:
+		r, err := strconv.Atoi(roundStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid round"})
+			return
+		}
+		round = r
+	}
+
+	var userID uint
+	if id, err := strconv.ParseUint(c.Query("user_id"), 10, 32); err == nil {
+		userID = uint(id)
+	}
+
+	matches, err := service.GetMatches(round, c.Query("stage"), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+// GetMatchByID returns a match by ID
+func GetMatchByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	match, err := service.GetMatchByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, match)
+}
+
+// AdminDrawGroups draws the groups and schedules the group stage (admin only)
+func AdminDrawGroups(c *gin.Context) {
+	var req service.DrawGroupsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	groups, err := service.DrawGroups(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "小组抽签完成，赛程已生成",
+		"groups":  groups,
+	})
+}
+
+// AdminGenerateKnockout seeds the knockout bracket from the group standings (admin only)
+func AdminGenerateKnockout(c *gin.Context) {
+	var req service.KnockoutRequest
+	c.ShouldBindJSON(&req)
+
+	bracket, err := service.GenerateKnockout(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "淘汰赛对阵已生成",
+		"bracket": bracket,
+	})
+}
+
+// AdminRecordMatchResult sets the final score of a match (admin only)
+func AdminRecordMatchResult(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	var req service.MatchResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match, err := service.RecordMatchResult(uint(id), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMatchNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "比赛结果已记录",
+		"match":   match,
+	})
+}
+
+// AdminResetTournament deletes all groups and matches (admin only)
+func AdminResetTournament(c *gin.Context) {
+	if err := service.ResetTournament(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "赛事已重置"})
+}
+
+// DisputeMatchRequest represents a request to dispute a reported result
+type DisputeMatchRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// GetStandings returns the group tables and the overall table
+func GetStandings(c *gin.Context) {
+	standings, err := service.GetStandings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, standings)
+}
+
+// SubmitMatchReport handles a player reporting the result of their match
+func SubmitMatchReport(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	var req service.MatchReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := service.SubmitMatchReport(userID, uint(id), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMatchNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrNotMatchPlayer {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "比赛结果已提交，等待对手确认"
+	switch report.Status {
+	case "confirmed":
+		message = "双方结果一致，比赛已完成"
+	case "disputed":
+		message = "双方结果不一致，等待管理员裁定"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"report":  report,
+	})
+}
+
+// ConfirmMatchReport handles a player confirming the opponent's result
+func ConfirmMatchReport(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	match, err := service.ConfirmMatchReport(userID, uint(id))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMatchNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrNotMatchPlayer {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "比赛结果已确认",
+		"match":   match,
+	})
+}
+
+// DisputeMatchReport handles a player disputing the opponent's result
+func DisputeMatchReport(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid match id"})
+		return
+	}
+
+	var req DisputeMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.DisputeMatchReport(userID, uint(id), req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrMatchNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrNotMatchPlayer {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已提出异议，等待管理员裁定"})
+}
+
+// AdminCreateMatch schedules a match between two players (admin only)
+func AdminCreateMatch(c *gin.Context) {
+	var req service.CreateMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	match, err := service.CreateMatch(&req, "admin")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "比赛已创建",
+		"match":   match,
+	})
+}
+
+// AdminGetDisputedMatches returns matches waiting for an admin decision (admin only)
+func AdminGetDisputedMatches(c *gin.Context) {
+	matches, err := service.GetDisputedMatches()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
@@ -21,7 +21,7 @@ func CreateTrade(c *gin.Context) {
 	trade, err := service.CreateTrade(userID, &req)
 	if err != nil {
 		status := http.StatusBadRequest
-		if err == service.ErrNotInTradingPhase {
+		if err == service.ErrNotInTradingPhase || err == service.ErrTradeWindowClosed || err == service.ErrTradeLimitReached {
 			status = http.StatusForbidden
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
@@ -60,7 +60,8 @@ func GetTradeHistory(c *gin.Context) {
 
 // GetAllTrades returns all trades (admin only)
 func GetAllTrades(c *gin.Context) {
-	trades, err := service.GetAllTrades()
+	minImbalance, _ := strconv.ParseFloat(c.Query("min_imbalance"), 64)
+	trades, err := service.GetAllTrades(minImbalance)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -78,8 +79,12 @@ func GetTradeByID(c *gin.Context) {
 		return
 	}
 
-	trade, err := service.GetTradeByID(uint(id))
+	trade, err := service.GetTradeByID(uint(id), GetCurrentUserID(c), IsCurrentUserAdmin(c))
 	if err != nil {
+		if err == service.ErrNotTradeParticipant {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{"error": "trade not found"})
 		return
 	}
@@ -87,6 +92,93 @@ func GetTradeByID(c *gin.Context) {
 	c.JSON(http.StatusOK, trade)
 }
 
+// EvaluateTrade scores both sides of a prospective trade
+func EvaluateTrade(c *gin.Context) {
+	var req service.TradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	evaluation, err := service.EvaluateTrade(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, evaluation)
+}
+
+// CounterTrade handles a counter-offer to a pending trade
+func CounterTrade(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req service.TradeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trade, err := service.CounterTrade(uint(id), userID, &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant || err == service.ErrNotInTradingPhase {
+			status = http.StatusForbidden
+		} else if err == service.ErrTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "还价已发送",
+		"trade":   trade,
+	})
+}
+
+// TradeMessageRequest represents a chat message in a trade thread
+type TradeMessageRequest struct {
+	Content string `json:"content" binding:"required"`
+}
+
+// PostTradeMessage adds a chat message to a trade's negotiation thread
+func PostTradeMessage(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, err := service.AddTradeMessage(uint(id), userID, req.Content)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrNotTradeParticipant {
+			status = http.StatusForbidden
+		} else if err == service.ErrTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, message)
+}
+
 // AcceptTrade handles trade acceptance
 func AcceptTrade(c *gin.Context) {
 	userID := GetCurrentUserID(c)
@@ -99,8 +191,11 @@ func AcceptTrade(c *gin.Context) {
 
 	if err := service.AcceptTrade(uint(id), userID); err != nil {
 		status := http.StatusBadRequest
-		if err == service.ErrNotTradeParticipant {
+		if err == service.ErrNotTradeParticipant || err == service.ErrNotInTradingPhase ||
+			err == service.ErrTradeWindowClosed || err == service.ErrTradeLimitReached {
 			status = http.StatusForbidden
+		} else if err == service.ErrTradeConflict {
+			status = http.StatusConflict
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
 		return
@@ -152,3 +247,226 @@ func CancelTrade(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "交易已取消"})
 }
+
+// GetTradeWindowStatus returns whether trading is currently open
+func GetTradeWindowStatus(c *gin.Context) {
+	status, err := service.GetTradeWindowStatus()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// AdminGetTradeWindows returns all trade windows (admin only)
+func AdminGetTradeWindows(c *gin.Context) {
+	windows, err := service.GetTradeWindows()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, windows)
+}
+
+// AdminCreateTradeWindow adds a trade window (admin only)
+func AdminCreateTradeWindow(c *gin.Context) {
+	var req service.TradeWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	window, err := service.CreateTradeWindow(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "交易窗口已创建",
+		"window":  window,
+	})
+}
+
+// AdminUpdateTradeWindow updates a trade window (admin only)
+func AdminUpdateTradeWindow(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window id"})
+		return
+	}
+
+	var req service.TradeWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	window, err := service.UpdateTradeWindow(uint(id), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrTradeWindowNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "交易窗口已更新",
+		"window":  window,
+	})
+}
+
+// AdminDeleteTradeWindow removes a trade window (admin only)
+func AdminDeleteTradeWindow(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid window id"})
+		return
+	}
+
+	if err := service.DeleteTradeWindow(uint(id)); err != nil {
+		status := http.StatusInternalServerError
+		if err == service.ErrTradeWindowNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "交易窗口已删除"})
+}
+
+// TradeReviewRequest carries the reason for a review decision or veto vote
+type TradeReviewRequest struct {
+	Reason string `json:"reason"`
+}
+
+// GetTradeReviewQueue returns trades awaiting approval
+func GetTradeReviewQueue(c *gin.Context) {
+	trades, err := service.GetTradeReviewQueue()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, trades)
+}
+
+// CastTradeVeto records the current user's league veto vote
+func CastTradeVeto(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	c.ShouldBindJSON(&req)
+
+	if err := service.CastTradeVeto(uint(id), userID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrCannotVetoOwnTrade || err == service.ErrNotRegisteredToVote {
+			status = http.StatusForbidden
+		} else if err == service.ErrTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "否决票已提交"})
+}
+
+// AdminApproveTrade executes a trade awaiting approval (admin only)
+func AdminApproveTrade(c *gin.Context) {
+	adminID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	c.ShouldBindJSON(&req)
+
+	if err := service.ApproveTrade(uint(id), adminID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrTradeNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrTradeConflict {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "交易已批准并执行"})
+}
+
+// AdminVetoTrade blocks a trade awaiting approval (admin only)
+func AdminVetoTrade(c *gin.Context) {
+	adminID := GetCurrentUserID(c)
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid trade id"})
+		return
+	}
+
+	var req TradeReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := service.VetoTrade(uint(id), adminID, req.Reason); err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrTradeNotFound {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "交易已否决"})
+}
+
+// AdminGetTradeSettings returns the trade review configuration (admin only)
+func AdminGetTradeSettings(c *gin.Context) {
+	settings, err := service.GetTradeSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// AdminUpdateTradeSettings changes the trade review configuration (admin only)
+func AdminUpdateTradeSettings(c *gin.Context) {
+	var req service.TradeSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := service.UpdateTradeSettings(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "交易审核设置已更新",
+		"settings": settings,
+	})
+}
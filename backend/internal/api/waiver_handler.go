@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"san11-trade/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReleaseGeneralRequest represents a request to release a general to waivers
+type ReleaseGeneralRequest struct {
+	GeneralID uint `json:"general_id" binding:"required"`
+}
+
+// GetWaivers returns waivers, open ones by default
+// Query: status=open|claimed|cleared|all
+func GetWaivers(c *gin.Context) {
+	status := c.DefaultQuery("status", "open")
+	if status == "all" {
+		status = ""
+	}
+
+	waivers, err := service.GetWaivers(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, waivers)
+}
+
+// ReleaseGeneral drops one of the current user's generals onto waivers
+func ReleaseGeneral(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	var req ReleaseGeneralRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	waiver, err := service.ReleaseGeneral(userID, req.GeneralID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrTradeConflict {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "武将已放入自由球员名单",
+		"waiver":  waiver,
+	})
+}
+
+// ClaimWaiver puts in a claim for a general on waivers
+func ClaimWaiver(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	waiverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid waiver id"})
+		return
+	}
+
+	claim, err := service.ClaimWaiver(userID, uint(waiverID))
+	if err != nil {
+		status := http.StatusBadRequest
+		if err == service.ErrWaiverNotFound {
+			status = http.StatusNotFound
+		} else if err == service.ErrNotInTradingPhase || err == service.ErrUserNotRegistered {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已提交认领申请",
+		"claim":   claim,
+	})
+}
+
+// CancelWaiverClaim withdraws the current user's claim
+func CancelWaiverClaim(c *gin.Context) {
+	userID := GetCurrentUserID(c)
+	waiverID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid waiver id"})
+		return
+	}
+
+	if err := service.CancelWaiverClaim(userID, uint(waiverID)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已撤回认领申请"})
+}
+
+// GetWaiverPriority returns the current waiver claim order
+func GetWaiverPriority(c *gin.Context) {
+	entries, err := service.GetWaiverPriority()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// AdminGetWaiverSettings returns the waiver configuration (admin only)
+func AdminGetWaiverSettings(c *gin.Context) {
+	settings, err := service.GetWaiverSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// AdminUpdateWaiverSettings changes the waiver configuration (admin only)
+func AdminUpdateWaiverSettings(c *gin.Context) {
+	var req service.WaiverSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := service.UpdateWaiverSettings(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "自由球员设置已更新",
+		"settings": settings,
+	})
+}
+
+// AdminProcessWaivers resolves every waiver whose claim period has closed (admin only)
+func AdminProcessWaivers(c *gin.Context) {
+	processed, err := service.ProcessWaivers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "自由球员认领已处理",
+		"processed": processed,
+	})
+}
@@ -34,6 +34,9 @@ type GameConfig struct {
 	NormalDraws      int // Number of normal draws (default 7)
 	DraftRounds      int // Number of draft rounds (default 4)
 	PlayersPerSeason int // Number of players per season (default 32)
+
+	AuctionLotSeconds    int // Default duration of a live auction lot (default 120)
+	AuctionExtendSeconds int // Anti-sniping extension applied to late bids (default 20)
 }
 
 type RegistrationConfig struct {
@@ -60,6 +63,9 @@ func DefaultConfig() *Config {
 			NormalDraws:      7,
 			DraftRounds:      4,
 			PlayersPerSeason: 32,
+
+			AuctionLotSeconds:    120,
+			AuctionExtendSeconds: 20,
 		},
 		Registration: RegistrationConfig{
 			RequireInviteCode: getEnvBool("REQUIRE_INVITE_CODE", true), // Default: require invite code
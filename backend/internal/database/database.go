@@ -59,19 +59,49 @@ func migrate() error {
 		&model.Policy{},
 		&model.GameRule{},
 		&model.Trade{},
+		&model.TradeMessage{},
+		&model.TradeWindow{},
+		&model.TradeSettings{},
+		&model.TradeVetoVote{},
+		&model.TradeListing{},
+		&model.WaiverSettings{},
+		&model.Waiver{},
+		&model.WaiverClaim{},
+		&model.Injury{},
+		&model.MultiTrade{},
+		&model.MultiTradeParticipant{},
+		&model.MultiTradeItem{},
+		&model.MultiTradeVetoVote{},
 		&model.GamePhase{},
+		&model.SeasonRules{},
 		&model.DrawRecord{},
+		&model.DrawReroll{},
+		&model.DrawSeed{},
+		&model.DrawCeremony{},
+		&model.DrawTable{},
 		&model.DraftRecord{},
+		&model.DraftQueue{},
 		&model.TradeLog{},
 		&model.InviteCode{},
 		&model.InviteCodeUsage{},
 		&model.AuctionRecord{},
+		&model.AuctionLot{},
+		&model.AuctionBid{},
 		// Policy auction models
 		&model.ClubTag{},
 		&model.PolicyBid{},
 		&model.PolicyPreference{},
 		&model.PolicySelection{},
 		&model.PolicyPhaseConfig{},
+		// Tournament models
+		&model.TournamentGroup{},
+		&model.TournamentGroupMember{},
+		&model.Match{},
+		&model.MatchReport{},
+		// Lineup models
+		&model.LineupRound{},
+		&model.LineupRestriction{},
+		&model.Lineup{},
 	)
 }
 
@@ -8,21 +8,28 @@ import (
 
 // User represents a player or admin
 type User struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	Username     string         `gorm:"uniqueIndex;size:50;not null" json:"username"`
-	Password     string         `gorm:"size:255;not null" json:"-"`
-	Nickname     string         `gorm:"size:50" json:"nickname"`
-	IsAdmin      bool           `gorm:"default:false" json:"is_admin"`
-	IsRegistered bool           `gorm:"default:false" json:"is_registered"` // Has signed up for the league
-	Space        int            `gorm:"default:350" json:"space"`           // Available space for generals
-	UsedSpace    int            `gorm:"default:0" json:"used_space"`        // Used space
-	ClubID       *uint          `json:"club_id"`                            // Selected club
-	Club         *Club          `gorm:"foreignKey:ClubID" json:"club,omitempty"`
-	Generals     []General      `gorm:"many2many:player_generals;" json:"generals,omitempty"`
-	Treasures    []Treasure     `gorm:"many2many:player_treasures;" json:"treasures,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	Username         string         `gorm:"uniqueIndex;size:50;not null" json:"username"`
+	Password         string         `gorm:"size:255;not null" json:"-"`
+	Nickname         string         `gorm:"size:50" json:"nickname"`
+	IsAdmin          bool           `gorm:"default:false" json:"is_admin"`
+	IsRegistered     bool           `gorm:"default:false" json:"is_registered"`      // Has signed up for the league
+	Space            int            `gorm:"default:350" json:"space"`                // Available space for generals
+	UsedSpace        int            `gorm:"default:0" json:"used_space"`             // Used space
+	ClubID           *uint          `json:"club_id"`                                 // Selected club
+	AutoDraft        bool           `gorm:"default:false" json:"auto_draft"`         // Let the draft pick from the queue automatically
+	WaiverPriority   int            `gorm:"default:0" json:"waiver_priority"`        // Rolling waiver order, lower claims first
+	PolicySpace      int            `gorm:"default:0" json:"policy_space"`           // Space added by active 国策, included in Space
+	PolicySalary     int            `gorm:"default:0" json:"policy_salary"`          // Salary adjustment from active 国策, included in UsedSpace
+	OverCapDeadline  *time.Time     `json:"over_cap_deadline"`                       // An over-cap draw must be resolved by then
+	OverCapEscalated bool           `gorm:"default:false" json:"over_cap_escalated"` // Still over the cap after the deadline; left to the admin
+	MulliganTokens   int            `gorm:"default:0" json:"mulligan_tokens"`        // Re-draws granted by the league, before 国策 effects
+	Club             *Club          `gorm:"foreignKey:ClubID" json:"club,omitempty"`
+	Generals         []General      `gorm:"many2many:player_generals;" json:"generals,omitempty"`
+	Treasures        []Treasure     `gorm:"many2many:player_treasures;" json:"treasures,omitempty"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 // General represents a warrior/general in the game
@@ -54,8 +61,26 @@ type General struct {
 	Owner        *User     `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
 	IsAvailable  bool      `gorm:"default:true" json:"is_available"` // Available in pool
 	InjuredUntil *int      `json:"injured_until"`                    // Injured until round X
+	Version      int       `gorm:"default:0" json:"version"`         // Optimistic lock, bumped on every trade transfer
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
+
+	Injured bool `gorm:"-" json:"injured"` // InjuredUntil covers the current round
+}
+
+// Injury records a general being flagged injured
+type Injury struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	GeneralID  uint       `gorm:"index;not null" json:"general_id"`
+	General    General    `gorm:"foreignKey:GeneralID" json:"general"`
+	FromRound  int        `json:"from_round"`                                 // First round missed
+	UntilRound int        `json:"until_round"`                                // Last round missed
+	Reason     string     `gorm:"size:200" json:"reason"`                     // 伤病原因
+	Source     string     `gorm:"size:20" json:"source"`                      // admin/import/match
+	ReportedBy uint       `json:"reported_by"`                                // Admin who flagged it, 0 = import
+	Status     string     `gorm:"size:20;default:active;index" json:"status"` // active/healed/cleared
+	ClearedAt  *time.Time `json:"cleared_at"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
 
 // Treasure represents an item/treasure in the game
@@ -70,6 +95,7 @@ type Treasure struct {
 	OwnerID     *uint     `json:"owner_id"`               // Current owner
 	Owner       *User     `gorm:"foreignKey:OwnerID" json:"owner,omitempty"`
 	IsAvailable bool      `gorm:"default:true" json:"is_available"`
+	Version     int       `gorm:"default:0" json:"version"` // Optimistic lock, bumped on every trade transfer
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
@@ -112,6 +138,7 @@ type Policy struct {
 	SortOrder int       `json:"sort_order"`                    // 排序顺序
 	Condition string    `gorm:"size:500" json:"condition"`     // 条件 (空表示无条件/基础效果)
 	Effect    string    `gorm:"size:500" json:"effect"`        // 效果
+	Rule      string    `gorm:"size:500" json:"rule"`          // Machine-readable rule, empty = not enforced
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -129,33 +156,230 @@ type GameRule struct {
 
 // Trade represents a trade proposal between two players
 type Trade struct {
-	ID               uint      `gorm:"primaryKey" json:"id"`
-	ProposerID       uint      `gorm:"not null" json:"proposer_id"`
-	Proposer         User      `gorm:"foreignKey:ProposerID" json:"proposer"`
-	ReceiverID       uint      `gorm:"not null" json:"receiver_id"`
-	Receiver         User      `gorm:"foreignKey:ReceiverID" json:"receiver"`
-	OfferGenerals    string    `gorm:"type:text" json:"offer_generals"`       // JSON array of general IDs
-	OfferTreasures   string    `gorm:"type:text" json:"offer_treasures"`      // JSON array of treasure IDs
-	OfferSpace       int       `json:"offer_space"`                           // Space offered
-	RequestGenerals  string    `gorm:"type:text" json:"request_generals"`     // JSON array of general IDs
-	RequestTreasures string    `gorm:"type:text" json:"request_treasures"`    // JSON array of treasure IDs
-	RequestSpace     int       `json:"request_space"`                         // Space requested
-	Status           string    `gorm:"size:20;default:pending" json:"status"` // pending/accepted/rejected/cancelled
-	Message          string    `gorm:"size:500" json:"message"`               // Optional message
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ProposerID       uint       `gorm:"not null" json:"proposer_id"`
+	Proposer         User       `gorm:"foreignKey:ProposerID" json:"proposer"`
+	ReceiverID       uint       `gorm:"not null" json:"receiver_id"`
+	Receiver         User       `gorm:"foreignKey:ReceiverID" json:"receiver"`
+	OfferGenerals    string     `gorm:"type:text" json:"offer_generals"`       // JSON array of general IDs
+	OfferTreasures   string     `gorm:"type:text" json:"offer_treasures"`      // JSON array of treasure IDs
+	OfferSpace       int        `json:"offer_space"`                           // Space offered
+	RequestGenerals  string     `gorm:"type:text" json:"request_generals"`     // JSON array of general IDs
+	RequestTreasures string     `gorm:"type:text" json:"request_treasures"`    // JSON array of treasure IDs
+	RequestSpace     int        `json:"request_space"`                         // Space requested
+	Status           string     `gorm:"size:20;default:pending" json:"status"` // pending/awaiting_approval/accepted/rejected/cancelled/countered/expired/vetoed
+	Message          string     `gorm:"size:500" json:"message"`               // Optional message
+	ParentTradeID    *uint      `gorm:"index" json:"parent_trade_id"`          // Trade this one counters
+	ThreadID         uint       `gorm:"index" json:"thread_id"`                // ID of the first trade of the negotiation
+	ExpiresAt        *time.Time `gorm:"index" json:"expires_at"`               // Offer auto-expires after this, nil = never
+	AcceptedAt       *time.Time `json:"accepted_at"`
+	AcceptedRound    int        `gorm:"default:0" json:"accepted_round"` // Round the trade was executed in
+	ReviewDeadline   *time.Time `json:"review_deadline"`                 // End of the league veto vote, nil = commissioner decides
+	OfferValue       float64    `json:"offer_value"`                     // Valuation of what the proposer gives
+	RequestValue     float64    `json:"request_value"`                   // Valuation of what the receiver gives
+	Imbalance        float64    `gorm:"index" json:"imbalance"`          // Value difference in % of the larger side
+	ListingID        *uint      `json:"listing_id"`                      // Listing the proposal was made from
+	ItemVersions     string     `gorm:"type:text" json:"item_versions"`  // JSON object of "general:ID"/"treasure:ID" -> version at proposal time
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+
+	Thread   []Trade         `gorm:"-" json:"thread,omitempty"` // All offers of the negotiation, oldest first
+	Vetoes   []TradeVetoVote `gorm:"foreignKey:TradeID" json:"vetoes,omitempty"`
+	Messages []TradeMessage  `gorm:"-" json:"messages,omitempty"` // Chat messages of the negotiation
+
+	InjuredGenerals []InjuredGeneral `gorm:"-" json:"injured_generals,omitempty"` // Generals in the trade that are currently injured
 }
 
-// GamePhase represents the current phase of the game
-type GamePhase struct {
+// InjuredGeneral flags an injured general in a trade
+type InjuredGeneral struct {
+	GeneralID  uint `json:"general_id"`
+	UntilRound int  `json:"until_round"`
+}
+
+// TradeSettings is the single-row trade review configuration
+type TradeSettings struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	ReviewMode    string    `gorm:"size:20;default:none" json:"review_mode"` // none/commissioner/league_vote
+	VetoThreshold int       `gorm:"default:3" json:"veto_threshold"`         // League votes needed to veto
+	VoteHours     int       `gorm:"default:24" json:"vote_hours"`            // League vote length; unvetoed trades then execute
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TradeVetoVote is a league member's vote against a trade awaiting approval
+type TradeVetoVote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TradeID   uint      `gorm:"uniqueIndex:idx_trade_veto_user;not null" json:"trade_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_trade_veto_user;not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Reason    string    `gorm:"size:200" json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TradeListing advertises an owned asset on the trade block or a wanted general
+type TradeListing struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"index;not null" json:"user_id"`
+	User        User      `gorm:"foreignKey:UserID" json:"user"`
+	Type        string    `gorm:"size:20;not null" json:"type"` // block/wanted
+	GeneralID   *uint     `json:"general_id"`                   // Block listing of a general
+	General     *General  `gorm:"foreignKey:GeneralID" json:"general,omitempty"`
+	TreasureID  *uint     `json:"treasure_id"` // Block listing of a treasure
+	Treasure    *Treasure `gorm:"foreignKey:TreasureID" json:"treasure,omitempty"`
+	AskingSpace int       `json:"asking_space"`                         // Space asked for (block) or offered (wanted)
+	Terms       string    `gorm:"size:500" json:"terms"`                // Free-text asking terms
+	Filters     string    `gorm:"type:text" json:"filters"`             // JSON GeneralFilter for wanted listings
+	Status      string    `gorm:"size:20;default:active" json:"status"` // active/closed
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// WaiverSettings is the single-row waiver configuration
+type WaiverSettings struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RefundPercent int       `gorm:"default:100" json:"refund_percent"`            // Share of the salary returned to UsedSpace on release
+	ClaimHours    int       `gorm:"default:24" json:"claim_hours"`                // Length of the claim period
+	PriorityMode  string    `gorm:"size:20;default:rolling" json:"priority_mode"` // rolling/space
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Waiver is a released general waiting out the claim period
+type Waiver struct {
+	ID            uint          `gorm:"primaryKey" json:"id"`
+	GeneralID     uint          `gorm:"index;not null" json:"general_id"`
+	General       General       `gorm:"foreignKey:GeneralID" json:"general"`
+	ReleasedBy    uint          `gorm:"not null" json:"released_by"`
+	Releaser      User          `gorm:"foreignKey:ReleasedBy" json:"releaser"`
+	Salary        int           `json:"salary"`                                   // Salary at release time
+	RefundedSpace int           `json:"refunded_space"`                           // UsedSpace returned to the releaser
+	Status        string        `gorm:"size:20;default:open;index" json:"status"` // open/claimed/cleared
+	ClaimDeadline time.Time     `json:"claim_deadline"`
+	ClaimedBy     *uint         `json:"claimed_by"`
+	ProcessedAt   *time.Time    `json:"processed_at"`
+	Claims        []WaiverClaim `gorm:"foreignKey:WaiverID" json:"claims,omitempty"`
+	CreatedAt     time.Time     `json:"created_at"`
+}
+
+// WaiverClaim is a player's claim on a general on waivers
+type WaiverClaim struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	WaiverID  uint      `gorm:"uniqueIndex:idx_waiver_claim_user;not null" json:"waiver_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_waiver_claim_user;not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Status    string    `gorm:"size:20;default:pending" json:"status"` // pending/won/lost/failed
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TradeWindow is an admin-defined period in which trades may be made
+// When any window exists for the current round, trading is only allowed inside one.
+type TradeWindow struct {
+	ID                 uint       `gorm:"primaryKey" json:"id"`
+	RoundNumber        int        `gorm:"default:0" json:"round_number"`          // 0 = applies to every round
+	OpensAt            *time.Time `json:"opens_at"`                               // nil = open since forever
+	ClosesAt           *time.Time `json:"closes_at"`                              // nil = never closes
+	MaxTradesPerPlayer int        `gorm:"default:0" json:"max_trades_per_player"` // Executed trades per player per round, 0 = unlimited
+	Note               string     `gorm:"size:200" json:"note"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+}
+
+// MultiTrade is a trade between three or more players settled atomically
+type MultiTrade struct {
+	ID             uint                    `gorm:"primaryKey" json:"id"`
+	ProposerID     uint                    `gorm:"not null" json:"proposer_id"`
+	Proposer       User                    `gorm:"foreignKey:ProposerID" json:"proposer"`
+	Status         string                  `gorm:"size:20;default:pending" json:"status"` // pending/awaiting_approval/accepted/rejected/cancelled/vetoed
+	Message        string                  `gorm:"size:500" json:"message"`
+	Participants   []MultiTradeParticipant `gorm:"foreignKey:MultiTradeID" json:"participants"`
+	Items          []MultiTradeItem        `gorm:"foreignKey:MultiTradeID" json:"items"`
+	Imbalance      float64                 `gorm:"index" json:"imbalance"` // Largest value difference of a participant, in % of their larger side
+	AcceptedAt     *time.Time              `json:"accepted_at"`
+	AcceptedRound  int                     `gorm:"default:0" json:"accepted_round"` // Round the trade was settled in
+	ReviewDeadline *time.Time              `json:"review_deadline"`                 // End of the league veto vote, nil = commissioner decides
+	Vetoes         []MultiTradeVetoVote    `gorm:"foreignKey:MultiTradeID" json:"vetoes,omitempty"`
+	CreatedAt      time.Time               `json:"created_at"`
+	UpdatedAt      time.Time               `json:"updated_at"`
+
+	InjuredGenerals []InjuredGeneral `gorm:"-" json:"injured_generals,omitempty"` // Generals in the trade that are currently injured
+}
+
+// MultiTradeVetoVote is a league member's veto vote against a multi-party trade under review
+type MultiTradeVetoVote struct {
 	ID           uint      `gorm:"primaryKey" json:"id"`
-	CurrentPhase string    `gorm:"size:30;not null" json:"current_phase"` // signup/guarantee_draw/normal_draw/draft/trading/match
-	RoundNumber  int       `gorm:"default:1" json:"round_number"`         // Current round number
-	DraftRound   int       `gorm:"default:0" json:"draft_round"`          // Current draft round (1-4)
-	DraftOrder   string    `gorm:"type:text" json:"draft_order"`          // JSON array of user IDs in draft order
-	Config       string    `gorm:"type:text" json:"config"`               // Additional configuration in JSON
+	MultiTradeID uint      `gorm:"uniqueIndex:idx_multi_trade_veto_user;not null" json:"multi_trade_id"`
+	UserID       uint      `gorm:"uniqueIndex:idx_multi_trade_veto_user;not null" json:"user_id"`
+	User         User      `gorm:"foreignKey:UserID" json:"user"`
+	Reason       string    `gorm:"size:200" json:"reason"`
 	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MultiTradeParticipant is one player of a multi-party trade and their answer
+type MultiTradeParticipant struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	MultiTradeID uint       `gorm:"index;not null" json:"multi_trade_id"`
+	UserID       uint       `gorm:"not null" json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID" json:"user"`
+	Accepted     bool       `gorm:"default:false" json:"accepted"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	GiveValue    float64    `json:"give_value"`    // Valuation of what the participant sends
+	ReceiveValue float64    `json:"receive_value"` // Valuation of what the participant gets
+}
+
+// MultiTradeItem is one asset sent from one participant to another
+type MultiTradeItem struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	MultiTradeID uint   `gorm:"index;not null" json:"multi_trade_id"`
+	FromUserID   uint   `gorm:"not null" json:"from_user_id"`
+	ToUserID     uint   `gorm:"not null" json:"to_user_id"`
+	ItemType     string `gorm:"size:20;not null" json:"item_type"` // general/treasure/space
+	ItemID       uint   `json:"item_id"`                           // General or treasure ID
+	Amount       int    `json:"amount"`                            // Space amount
+	Version      *int   `json:"version"`                           // General or treasure version at proposal time
+}
+
+// TradeMessage is a chat message in a trade negotiation thread
+type TradeMessage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ThreadID  uint      `gorm:"index;not null" json:"thread_id"`
+	TradeID   uint      `gorm:"not null" json:"trade_id"` // Offer the message was posted on
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	Content   string    `gorm:"size:500;not null" json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GamePhase represents the current phase of the game
+type GamePhase struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	CurrentPhase  string     `gorm:"size:30;not null" json:"current_phase"` // signup/guarantee_draw/normal_draw/draft/trading/match
+	RoundNumber   int        `gorm:"default:1" json:"round_number"`         // Current round number
+	DraftRound    int        `gorm:"default:0" json:"draft_round"`          // Current draft round (1-4)
+	DraftOrder    string     `gorm:"type:text" json:"draft_order"`          // JSON array of user IDs in draft order
+	DraftPick     int        `gorm:"default:0" json:"draft_pick"`           // Current pick within the round (1-based)
+	DraftRounds   int        `gorm:"default:0" json:"draft_rounds"`         // Total rounds of the running draft
+	DraftMode     string     `gorm:"size:20" json:"draft_mode"`             // snake/linear
+	DraftStatus   string     `gorm:"size:20" json:"draft_status"`           // ""(not started)/running/completed
+	DraftTimeout  int        `gorm:"default:0" json:"draft_timeout"`        // Minutes per pick, 0 = no timer
+	DraftDeadline *time.Time `json:"draft_deadline"`                        // When the current pick times out
+	Config        string     `gorm:"type:text" json:"config"`               // Additional configuration in JSON
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// SeasonRules is the rule set of the current season (single row)
+type SeasonRules struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	GuaranteeDraws  int       `json:"guarantee_draws"`                // Base guarantee draws per player, before 国策 effects
+	NormalDraws     int       `json:"normal_draws"`                   // Base normal draws per player, before 国策 effects
+	PoolOrder       string    `gorm:"size:100" json:"pool_order"`     // Comma-separated order the draw pools are used in
+	InitialSpace    int       `json:"initial_space"`                  // Space each player starts the season with
+	MaxPlayers      int       `json:"max_players"`                    // Registration cap
+	DraftRounds     int       `json:"draft_rounds"`                   // Default number of draft rounds
+	OverCapPolicy   string    `gorm:"size:20" json:"over_cap_policy"` // filter/over_cap/reroll when a draw does not fit the player's space
+	OverCapHours    int       `json:"over_cap_hours"`                 // Window to get back under the cap after an over_cap draw
+	MulliganMinutes int       `json:"mulligan_minutes"`               // How long after a draw a mulligan token can return it
+	UpdatedAt       time.Time `json:"updated_at"`
+
+	PoolList []string `gorm:"-" json:"pool_list"` // Parsed PoolOrder
 }
 
 // DrawRecord records each draw action
@@ -167,28 +391,115 @@ type DrawRecord struct {
 	General   General   `gorm:"foreignKey:GeneralID" json:"general"`
 	DrawType  string    `gorm:"size:20" json:"draw_type"` // guarantee/normal/initial_guarantee/initial_normal
 	CreatedAt time.Time `json:"created_at"`
+
+	// Commit-reveal audit data of the initial draw
+	SeedID     *uint  `gorm:"index" json:"seed_id"`
+	Nonce      uint64 `json:"nonce"`              // Per-seed draw counter the pick is derived from
+	PickIndex  int    `json:"pick_index"`         // Index into Candidates
+	Candidates string `gorm:"type:text" json:"-"` // JSON array of the candidate general IDs, sorted by ID
+	Tier       int    `json:"tier"`               // Tier the draw rolled
+	TierOdds   string `gorm:"type:text" json:"-"` // JSON array of the tier weights the tier was rolled from
+
+	Status  string `gorm:"size:20;default:drawn" json:"status"` // drawn/voided/returned (mulligan)/reset (admin)
+	OverCap bool   `gorm:"default:false" json:"over_cap"`       // Took the player over their space
+}
+
+// DrawReroll logs a pick the player could not afford, which was rolled again
+type DrawReroll struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"user_id"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+	GeneralID  uint      `gorm:"not null" json:"general_id"`
+	General    General   `gorm:"foreignKey:GeneralID" json:"general"`
+	DrawType   string    `gorm:"size:20" json:"draw_type"`
+	Reason     string    `gorm:"size:200" json:"reason"`
+	SeedID     *uint     `gorm:"index" json:"seed_id"`
+	Nonce      uint64    `json:"nonce"`
+	PickIndex  int       `json:"pick_index"`
+	Candidates string    `gorm:"type:text" json:"-"`
+	Tier       int       `json:"tier"`
+	TierOdds   string    `gorm:"type:text" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DrawCeremony is a live, step-by-step run of everyone's initial draws
+type DrawCeremony struct {
+	ID              uint       `gorm:"primaryKey" json:"id"`
+	Status          string     `gorm:"size:20;index" json:"status"` // running/paused/finished
+	PlayerOrder     string     `gorm:"type:text" json:"-"`          // Comma-separated user IDs in drawing order
+	CurrentIndex    int        `json:"current_index"`               // Position in the order of the player drawing now
+	IntervalSeconds int        `json:"interval_seconds"`            // Pause between automatic steps, 0 = admin steps manually
+	NextStepAt      *time.Time `json:"next_step_at"`
+	BusyAt          *time.Time `json:"-"`                          // When the step being drawn was claimed
+	Steps           int        `json:"steps"`                      // Draws revealed so far
+	LastError       string     `gorm:"size:255" json:"last_error"` // Why the ceremony paused itself
+	StartRecordID   uint       `json:"-"`                          // Draw records after this one were made during the ceremony
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	OrderList []uint `gorm:"-" json:"order"` // Parsed PlayerOrder
+}
+
+// DrawTable is the tier odds of one initial draw pool
+type DrawTable struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PoolType    string    `gorm:"size:20;uniqueIndex;not null" json:"pool_type"`
+	TierWeights string    `gorm:"size:200" json:"-"` // JSON object tier -> weight; empty draws uniformly
+	TierCaps    string    `gorm:"size:200" json:"-"` // JSON object tier -> most generals of that tier one player draws here
+	PityTier    int       `json:"pity_tier"`         // Tier (or better) the pity counter guarantees, 0 = off
+	PityAfter   int       `json:"pity_after"`        // Draws here without PityTier before it is guaranteed
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Weights map[int]int `gorm:"-" json:"weights"`
+	Caps    map[int]int `gorm:"-" json:"caps"`
+}
+
+// DrawSeed is a committed seed the initial draw picks are derived from
+// Only SeedHash is public until the seed is revealed after the draw phase.
+type DrawSeed struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	Seed       string     `gorm:"size:64;not null" json:"-"`
+	SeedHash   string     `gorm:"size:64;not null" json:"seed_hash"` // Hex SHA-256 of Seed
+	DrawCount  uint64     `json:"draw_count"`                        // Last nonce handed out
+	Revealed   bool       `gorm:"default:false" json:"revealed"`
+	RevealedAt *time.Time `json:"revealed_at"`
+	CreatedAt  time.Time  `json:"created_at"`
 }
 
 // DraftRecord records each draft pick
 type DraftRecord struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null" json:"user_id"`
+	User       User      `gorm:"foreignKey:UserID" json:"user"`
+	GeneralID  uint      `gorm:"not null" json:"general_id"`
+	General    General   `gorm:"foreignKey:GeneralID" json:"general"`
+	Round      int       `json:"round"`                            // Draft round (1-4)
+	Pick       int       `json:"pick"`                             // Pick number in the round
+	AutoPicked bool      `gorm:"default:false" json:"auto_picked"` // Picked from the queue (timeout or auto-draft)
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// DraftQueue records a player's ordered wish-list of draft-pool generals
+type DraftQueue struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	UserID    uint      `gorm:"not null" json:"user_id"`
-	User      User      `gorm:"foreignKey:UserID" json:"user"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
 	GeneralID uint      `gorm:"not null" json:"general_id"`
-	General   General   `gorm:"foreignKey:GeneralID" json:"general"`
-	Round     int       `json:"round"` // Draft round (1-4)
-	Pick      int       `json:"pick"`  // Pick number in the round
+	General   *General  `gorm:"foreignKey:GeneralID" json:"general,omitempty"`
+	Priority  int       `gorm:"not null" json:"priority"` // Priority order (1 = highest priority)
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // TradeLog records trade history for audit
 type TradeLog struct {
-	ID          uint      `gorm:"primaryKey" json:"id"`
-	TradeID     uint      `gorm:"not null" json:"trade_id"`
-	Action      string    `gorm:"size:20" json:"action"` // created/accepted/rejected/cancelled
-	PerformedBy uint      `json:"performed_by"`
-	Details     string    `gorm:"type:text" json:"details"` // JSON details
-	CreatedAt   time.Time `json:"created_at"`
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	TradeID      uint      `gorm:"not null" json:"trade_id"`    // 0 for multi-party trades
+	MultiTradeID uint      `gorm:"index" json:"multi_trade_id"` // Set for multi-party trades
+	Action       string    `gorm:"size:20" json:"action"`       // created/accepted/transferred/rejected/cancelled/auto_cancelled/countered/expired/awaiting_approval/approved/vetoed/veto_vote
+	PerformedBy  uint      `json:"performed_by"`
+	Details      string    `gorm:"type:text" json:"details"` // JSON details
+	CreatedAt    time.Time `json:"created_at"`
 }
 
 // AuctionRecord records each auction result
@@ -205,6 +516,37 @@ type AuctionRecord struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// AuctionLot represents a live auction of a single auction-pool general
+type AuctionLot struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	GeneralID    uint         `gorm:"not null;index" json:"general_id"`
+	General      General      `gorm:"foreignKey:GeneralID" json:"general"`
+	Status       string       `gorm:"size:20;default:open" json:"status"` // open/closing/sold/unsold
+	StartPrice   int          `json:"start_price"`                        // Minimum opening bid
+	MinIncrement int          `gorm:"default:1" json:"min_increment"`     // Minimum raise over the current price
+	CurrentPrice int          `gorm:"default:0" json:"current_price"`     // Highest bid so far (0 = no bids)
+	HighBidderID *uint        `json:"high_bidder_id"`                     // Current highest bidder
+	HighBidder   *User        `gorm:"foreignKey:HighBidderID" json:"high_bidder,omitempty"`
+	BidCount     int          `gorm:"default:0" json:"bid_count"`
+	ExtendSecs   int          `json:"extend_secs"` // Anti-sniping window: late bids push EndsAt to now + ExtendSecs
+	EndsAt       time.Time    `json:"ends_at"`     // Hammer time
+	RecordID     *uint        `json:"record_id"`   // AuctionRecord created when the lot was settled
+	SettledAt    *time.Time   `json:"settled_at"`  // When the hammer fell
+	Bids         []AuctionBid `gorm:"foreignKey:LotID" json:"bids,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+}
+
+// AuctionBid records a single bid placed on an auction lot
+type AuctionBid struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	LotID     uint      `gorm:"not null;index" json:"lot_id"`
+	UserID    uint      `gorm:"not null" json:"user_id"`
+	User      *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Amount    int       `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // InviteCode represents an invitation code for registration
 type InviteCode struct {
 	ID        uint       `gorm:"primaryKey" json:"id"`
@@ -301,3 +643,105 @@ type PolicyPhaseConfig struct {
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 }
+
+// TournamentGroup is a group of the group stage (小组赛)
+type TournamentGroup struct {
+	ID        uint                    `gorm:"primaryKey" json:"id"`
+	Name      string                  `gorm:"size:10;not null" json:"name"` // A, B, C...
+	Members   []TournamentGroupMember `gorm:"foreignKey:GroupID" json:"members,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// TournamentGroupMember places a player in a group
+type TournamentGroupMember struct {
+	ID      uint `gorm:"primaryKey" json:"id"`
+	GroupID uint `gorm:"index;not null" json:"group_id"`
+	UserID  uint `gorm:"uniqueIndex;not null" json:"user_id"`
+	User    User `gorm:"foreignKey:UserID" json:"user"`
+	Seed    int  `gorm:"default:0" json:"seed"` // 0 = unseeded
+}
+
+// Match is a fixture between two players: group stage, knockout (淘汰赛) bracket or a one-off league match
+type Match struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Stage         string    `gorm:"size:20;not null;index" json:"stage"` // group/knockout/league
+	GroupID       *uint     `gorm:"index" json:"group_id"`
+	RoundNumber   int       `gorm:"index" json:"round_number"`       // GamePhase round the match is played in
+	KnockoutRound int       `gorm:"default:0" json:"knockout_round"` // 1 = first knockout round
+	BracketSlot   int       `gorm:"default:0" json:"bracket_slot"`   // Position within the knockout round, 1-based
+	HomeID        *uint     `json:"home_id"`                         // nil = not decided yet
+	Home          *User     `gorm:"foreignKey:HomeID" json:"home,omitempty"`
+	AwayID        *uint     `json:"away_id"`
+	Away          *User     `gorm:"foreignKey:AwayID" json:"away,omitempty"`
+	HomeScore     int       `json:"home_score"`
+	AwayScore     int       `json:"away_score"`
+	WinnerID      *uint     `json:"winner_id"`                               // nil = draw or not played
+	Status        string    `gorm:"size:20;default:scheduled" json:"status"` // scheduled/reported/disputed/completed/bye
+	Source        string    `gorm:"size:20;default:schedule" json:"source"`  // schedule/admin/import
+	NextMatchID   *uint     `json:"next_match_id"`                           // Knockout match the winner advances to
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+
+	Reports []MatchReport `gorm:"foreignKey:MatchID" json:"reports,omitempty"`
+}
+
+// MatchReport is a result submitted by one of the players, pending the opponent's confirmation
+type MatchReport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	MatchID     uint       `gorm:"index;not null" json:"match_id"`
+	ReportedBy  uint       `gorm:"not null" json:"reported_by"`
+	Reporter    User       `gorm:"foreignKey:ReportedBy" json:"reporter"`
+	HomeScore   int        `json:"home_score"`
+	AwayScore   int        `json:"away_score"`
+	SaveHash    string     `gorm:"size:128" json:"save_hash"`  // Hash of the save file
+	Screenshot  string     `gorm:"size:500" json:"screenshot"` // Screenshot URL or reference
+	Note        string     `gorm:"size:500" json:"note"`
+	Status      string     `gorm:"size:20;default:pending" json:"status"` // pending/confirmed/disputed/superseded/resolved
+	RespondedBy *uint      `json:"responded_by"`                          // Opponent who confirmed or disputed
+	Response    string     `gorm:"size:500" json:"response"`              // Dispute reason
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// LineupRound holds the lineup size rules and lock deadline of a round
+type LineupRound struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	RoundNumber  int        `gorm:"uniqueIndex;not null" json:"round_number"`
+	LockAt       *time.Time `json:"lock_at"`                     // Draft lineups are locked at this time, nil = no deadline
+	MinGenerals  int        `json:"min_generals"`                // Generals a lineup must field
+	MaxGenerals  int        `json:"max_generals"`                // 0 = unlimited
+	MaxTreasures int        `json:"max_treasures"`               // 0 = unlimited
+	Closed       bool       `gorm:"default:false" json:"closed"` // Deadline has passed and lineups were locked
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// LineupRestriction limits the lineups of a club's owner, set by the admin from the club's 国策
+type LineupRestriction struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	ClubID       uint      `gorm:"uniqueIndex;not null" json:"club_id"`
+	MaxGenerals  int       `json:"max_generals"`  // 0 = no limit
+	MaxTreasures int       `json:"max_treasures"` // 0 = no limit
+	MaxSalary    int       `json:"max_salary"`    // Total salary of the fielded generals, 0 = no limit
+	Note         string    `gorm:"size:500" json:"note"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Lineup is the generals and treasures a player brings to a round
+type Lineup struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"uniqueIndex:idx_lineup_user_round;not null" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"user"`
+	RoundNumber int        `gorm:"uniqueIndex:idx_lineup_user_round;not null" json:"round_number"`
+	Generals    string     `gorm:"type:text" json:"generals"`           // JSON array of general IDs
+	Treasures   string     `gorm:"type:text" json:"treasures"`          // JSON array of treasure IDs
+	Status      string     `gorm:"size:20;default:draft" json:"status"` // draft/locked/illegal
+	Problems    string     `gorm:"type:text" json:"problems"`           // Why the lineup was illegal at the deadline
+	LockedAt    *time.Time `json:"locked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	GeneralList  []General  `gorm:"-" json:"general_list,omitempty"`
+	TreasureList []Treasure `gorm:"-" json:"treasure_list,omitempty"`
+}
@@ -0,0 +1,101 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	retryDelay = 5 * time.Second        // How long a failing job waits before it is tried again
+	rerunDelay = 200 * time.Millisecond // Re-check delay after a job has run
+)
+
+// Job is a timed task whose due time is recomputed from the database on every
+// tick, so pending deadlines survive restarts without any in-memory state
+type Job struct {
+	Name string
+	Next func() (*time.Time, error) // Next due time, nil if nothing is scheduled
+	Run  func() error               // Called once the due time has passed
+}
+
+// Scheduler runs registered jobs when they fall due
+type Scheduler struct {
+	jobs       []Job
+	pollPeriod time.Duration
+	retryAt    map[string]time.Time
+}
+
+// New creates a scheduler that re-checks every job at least once per pollPeriod
+func New(pollPeriod time.Duration) *Scheduler {
+	return &Scheduler{
+		pollPeriod: pollPeriod,
+		retryAt:    make(map[string]time.Time),
+	}
+}
+
+// Register adds a job; must be called before Run
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Run blocks until ctx is cancelled, running jobs as they fall due
+func (s *Scheduler) Run(ctx context.Context) {
+	log.Printf("Scheduler started with %d jobs", len(s.jobs))
+
+	for {
+		wait := s.tick(time.Now())
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			log.Printf("Scheduler stopped")
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// tick runs every due job and returns how long to sleep until the next check
+func (s *Scheduler) tick(now time.Time) time.Duration {
+	wait := s.pollPeriod
+
+	for _, job := range s.jobs {
+		if retry, ok := s.retryAt[job.Name]; ok && now.Before(retry) {
+			continue
+		}
+
+		next, err := job.Next()
+		if err != nil {
+			log.Printf("Scheduler: job %s: failed to compute next run: %v", job.Name, err)
+			s.retryAt[job.Name] = now.Add(retryDelay)
+			continue
+		}
+		if next == nil {
+			continue
+		}
+
+		if now.Before(*next) {
+			if d := next.Sub(now); d < wait {
+				wait = d
+			}
+			continue
+		}
+
+		if err := job.Run(); err != nil {
+			log.Printf("Scheduler: job %s failed: %v", job.Name, err)
+			s.retryAt[job.Name] = now.Add(retryDelay)
+			continue
+		}
+		delete(s.retryAt, job.Name)
+
+		// The job may have scheduled follow-up work (e.g. the next selector's
+		// deadline); look again shortly
+		if wait > rerunDelay {
+			wait = rerunDelay
+		}
+	}
+
+	return wait
+}
@@ -13,6 +13,9 @@ func GetAllGenerals() ([]model.General, error) {
 	if err := db.Preload("Owner").Order("tier ASC, salary DESC").Find(&generals).Error; err != nil {
 		return nil, err
 	}
+	if err := markInjuredGenerals(generals); err != nil {
+		return nil, err
+	}
 
 	return generals, nil
 }
@@ -25,6 +28,9 @@ func GetGeneralByID(id uint) (*model.General, error) {
 	if err := db.Preload("Owner").First(&general, id).Error; err != nil {
 		return nil, err
 	}
+	if phase, err := GetGamePhase(); err == nil {
+		general.Injured = generalInjured(&general, phase.RoundNumber)
+	}
 
 	return &general, nil
 }
@@ -37,6 +43,9 @@ func GetUserGenerals(userID uint) ([]model.General, error) {
 	if err := db.Where("owner_id = ?", userID).Order("tier ASC, salary DESC").Find(&generals).Error; err != nil {
 		return nil, err
 	}
+	if err := markInjuredGenerals(generals); err != nil {
+		return nil, err
+	}
 
 	return generals, nil
 }
@@ -103,10 +112,12 @@ func GetClubByID(id uint) (*model.Club, error) {
 
 // GetUserRoster returns a user's complete roster
 type Roster struct {
-	User      *model.User      `json:"user"`
-	Generals  []model.General  `json:"generals"`
-	Treasures []model.Treasure `json:"treasures"`
-	Club      *model.Club      `json:"club"`
+	User           *model.User      `json:"user"`
+	Generals       []model.General  `json:"generals"`
+	Treasures      []model.Treasure `json:"treasures"`
+	Club           *model.Club      `json:"club"`
+	RoundNumber    int              `json:"round_number"`    // Round availability is computed for
+	AvailableCount int              `json:"available_count"` // Generals not injured this round
 }
 
 func GetUserRoster(userID uint) (*Roster, error) {
@@ -120,6 +131,17 @@ func GetUserRoster(userID uint) (*Roster, error) {
 		return nil, err
 	}
 
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	available := 0
+	for _, general := range generals {
+		if !general.Injured {
+			available++
+		}
+	}
+
 	treasures, err := GetUserTreasures(userID)
 	if err != nil {
 		return nil, err
@@ -131,10 +153,12 @@ func GetUserRoster(userID uint) (*Roster, error) {
 	}
 
 	return &Roster{
-		User:      user,
-		Generals:  generals,
-		Treasures: treasures,
-		Club:      club,
+		User:           user,
+		Generals:       generals,
+		Treasures:      treasures,
+		Club:           club,
+		RoundNumber:    phase.RoundNumber,
+		AvailableCount: available,
 	}, nil
 }
 
@@ -0,0 +1,444 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"san11-trade/internal/config"
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuctionLotNotFound   = errors.New("auction lot not found")
+	ErrAuctionLotExists     = errors.New("general already has an active auction lot")
+	ErrAuctionLotClosed     = errors.New("auction lot is closed")
+	ErrAuctionLotNotExpired = errors.New("auction lot has not reached its end time")
+	ErrBidTooLow            = errors.New("bid is below the minimum required amount")
+	ErrAlreadyHighBidder    = errors.New("you are already the highest bidder")
+	ErrBidOutdated          = errors.New("another bid was placed first, please retry")
+	ErrAuctionInsufficient  = errors.New("insufficient space for this bid")
+)
+
+// Auction lot statuses
+const (
+	AuctionLotOpen    = "open"
+	AuctionLotClosing = "closing"
+	AuctionLotSold    = "sold"
+	AuctionLotUnsold  = "unsold"
+)
+
+// OpenAuctionLotRequest represents a request to put an auction general up for bidding
+type OpenAuctionLotRequest struct {
+	GeneralID    uint `json:"general_id" binding:"required"`
+	StartPrice   int  `json:"start_price"`   // Defaults to the general's salary
+	MinIncrement int  `json:"min_increment"` // Defaults to 1
+	DurationSecs int  `json:"duration_secs"` // Defaults to GameConfig.AuctionLotSeconds
+	ExtendSecs   int  `json:"extend_secs"`   // Defaults to GameConfig.AuctionExtendSeconds
+}
+
+// OpenAuctionLot starts a live auction for an auction-pool general (admin only)
+func OpenAuctionLot(req *OpenAuctionLotRequest) (*model.AuctionLot, error) {
+	db := database.GetDB()
+	gameCfg := config.AppConfig.Game
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "auction" {
+		return nil, ErrNotInAuctionPhase
+	}
+
+	var general model.General
+	if err := db.First(&general, req.GeneralID).Error; err != nil {
+		return nil, ErrAuctionGeneralNotFound
+	}
+	if general.PoolType != "auction" {
+		return nil, ErrAuctionGeneralNotFound
+	}
+
+	// A general can only be hammered once; use ResetAuction to put it back up
+	var existingRecord model.AuctionRecord
+	if err := db.Where("general_id = ?", general.ID).First(&existingRecord).Error; err == nil {
+		return nil, ErrGeneralAlreadyAuctioned
+	}
+
+	var activeCount int64
+	db.Model(&model.AuctionLot{}).
+		Where("general_id = ? AND status IN ?", general.ID, []string{AuctionLotOpen, AuctionLotClosing}).
+		Count(&activeCount)
+	if activeCount > 0 {
+		return nil, ErrAuctionLotExists
+	}
+
+	if req.StartPrice <= 0 {
+		req.StartPrice = general.Salary
+	}
+	if req.MinIncrement <= 0 {
+		req.MinIncrement = 1
+	}
+	if req.DurationSecs <= 0 {
+		req.DurationSecs = gameCfg.AuctionLotSeconds
+	}
+	if req.ExtendSecs <= 0 {
+		req.ExtendSecs = gameCfg.AuctionExtendSeconds
+	}
+
+	lot := &model.AuctionLot{
+		GeneralID:    general.ID,
+		Status:       AuctionLotOpen,
+		StartPrice:   req.StartPrice,
+		MinIncrement: req.MinIncrement,
+		ExtendSecs:   req.ExtendSecs,
+		EndsAt:       time.Now().Add(time.Duration(req.DurationSecs) * time.Second),
+	}
+	if err := db.Create(lot).Error; err != nil {
+		return nil, err
+	}
+
+	lot.General = general
+	return lot, nil
+}
+
+// PlaceAuctionBid places a bid on a live lot
+// A bid that lands inside the anti-sniping window pushes the end time back
+// and moves the lot into the "closing" state.
+func PlaceAuctionBid(userID uint, lotID uint, amount int) (*model.AuctionLot, error) {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "auction" {
+		return nil, ErrNotInAuctionPhase
+	}
+
+	var lot model.AuctionLot
+	if err := db.First(&lot, lotID).Error; err != nil {
+		return nil, ErrAuctionLotNotFound
+	}
+
+	now := time.Now()
+	if !isAuctionLotActive(&lot) {
+		return nil, ErrAuctionLotClosed
+	}
+	if !now.Before(lot.EndsAt) {
+		// Time is up; settle instead of accepting a late bid
+		SettleAuctionLot(lot.ID, false)
+		return nil, ErrAuctionLotClosed
+	}
+
+	if lot.HighBidderID != nil && *lot.HighBidderID == userID {
+		return nil, ErrAlreadyHighBidder
+	}
+
+	minimum := lot.StartPrice
+	if lot.HighBidderID != nil {
+		minimum = lot.CurrentPrice + lot.MinIncrement
+	}
+	if amount < minimum {
+		return nil, ErrBidTooLow
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsRegistered {
+		return nil, ErrUserNotRegistered
+	}
+
+	status := lot.Status
+	endsAt := lot.EndsAt
+	extendUntil := now.Add(time.Duration(lot.ExtendSecs) * time.Second)
+	if endsAt.Before(extendUntil) {
+		endsAt = extendUntil
+		status = AuctionLotClosing
+	}
+
+	tx := db.Begin()
+
+	// Optimistic check: only succeed if nobody else bid in between
+	result := tx.Model(&model.AuctionLot{}).
+		Where("id = ? AND bid_count = ? AND status IN ?", lot.ID, lot.BidCount, []string{AuctionLotOpen, AuctionLotClosing}).
+		Updates(map[string]interface{}{
+			"current_price":  amount,
+			"high_bidder_id": userID,
+			"bid_count":      lot.BidCount + 1,
+			"ends_at":        endsAt,
+			"status":         status,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrBidOutdated
+	}
+
+	// Validate against remaining space, minus what the user is already
+	// leading on in other lots. Checked after claiming the lot so that
+	// concurrent bids on other lots see this one.
+	if err := tx.First(&user, userID).Error; err != nil {
+		tx.Rollback()
+		return nil, ErrUserNotFound
+	}
+	committed, err := committedAuctionSpace(tx, userID, lot.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if amount > user.Space-user.UsedSpace-committed {
+		tx.Rollback()
+		return nil, ErrAuctionInsufficient
+	}
+
+	bid := model.AuctionBid{
+		LotID:  lot.ID,
+		UserID: userID,
+		Amount: amount,
+	}
+	if err := tx.Create(&bid).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	updated, err := GetAuctionLot(lot.ID)
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventAuctionBid, updated)
+
+	return updated, nil
+}
+
+// committedAuctionSpace sums the prices a user is currently leading on in
+// other active lots
+func committedAuctionSpace(db *gorm.DB, userID uint, excludeLotID uint) (int, error) {
+	var sum struct {
+		Sum int64
+	}
+	if err := db.Model(&model.AuctionLot{}).
+		Select("COALESCE(SUM(current_price), 0) as sum").
+		Where("high_bidder_id = ? AND id <> ? AND status IN ?", userID, excludeLotID, []string{AuctionLotOpen, AuctionLotClosing}).
+		Scan(&sum).Error; err != nil {
+		return 0, err
+	}
+	return int(sum.Sum), nil
+}
+
+// SettleAuctionLot brings the hammer down on a lot and creates its AuctionRecord
+// Without force, the lot must have reached its end time.
+func SettleAuctionLot(lotID uint, force bool) (*model.AuctionLot, error) {
+	db := database.GetDB()
+
+	var lot model.AuctionLot
+	if err := db.First(&lot, lotID).Error; err != nil {
+		return nil, ErrAuctionLotNotFound
+	}
+	if !isAuctionLotActive(&lot) {
+		return nil, ErrAuctionLotClosed
+	}
+	if !force && time.Now().Before(lot.EndsAt) {
+		return nil, ErrAuctionLotNotExpired
+	}
+
+	var general model.General
+	if err := db.First(&general, lot.GeneralID).Error; err != nil {
+		return nil, ErrAuctionGeneralNotFound
+	}
+
+	now := time.Now()
+	tx := db.Begin()
+
+	// Claim the lot first so that concurrent settlements cannot both succeed;
+	// the final status is set once the winner is confirmed below
+	result := tx.Model(&model.AuctionLot{}).
+		Where("id = ? AND status IN ?", lot.ID, []string{AuctionLotOpen, AuctionLotClosing}).
+		Updates(map[string]interface{}{
+			"status":     AuctionLotUnsold,
+			"settled_at": now,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrAuctionLotClosed
+	}
+
+	winnerID := lot.HighBidderID
+	remark := fmt.Sprintf("在线拍卖 #%d", lot.ID)
+	if winnerID != nil {
+		// Space may have changed since the bid (trades, draft); re-check at the hammer
+		var winner model.User
+		if err := tx.First(&winner, *winnerID).Error; err != nil || winner.Space-winner.UsedSpace < lot.CurrentPrice {
+			winnerID = nil
+			remark += "，出价者空间不足，流拍"
+		}
+	}
+
+	status := AuctionLotUnsold
+	price := 0
+	if winnerID != nil {
+		status = AuctionLotSold
+		price = lot.CurrentPrice
+	}
+
+	if err := tx.SavePoint("hammer").Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	record, err := createAuctionRecord(tx, &general, winnerID, price, remark)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var effects []clubEffectsUpdate
+	if winnerID != nil {
+		effects, err = applyClubEffects(tx, *winnerID)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		// The general would cost the winner a 国策 bonus they need to stay under the cap
+		if checkClubEffectsCap(effects) != nil {
+			if err := tx.RollbackTo("hammer").Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			status, effects = AuctionLotUnsold, nil
+			record, err = createAuctionRecord(tx, &general, nil, 0, remark+"，出价者国策空间不足，流拍")
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	if err := tx.Model(&model.AuctionLot{}).Where("id = ?", lot.ID).Updates(map[string]interface{}{
+		"status":    status,
+		"record_id": record.ID,
+	}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	publishClubEffects(effects)
+
+	settled, err := GetAuctionLot(lot.ID)
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventAuctionAssigned, settled)
+
+	return settled, nil
+}
+
+// SettleExpiredAuctionLots settles every active lot whose end time has passed
+func SettleExpiredAuctionLots() (int, error) {
+	db := database.GetDB()
+
+	var lots []model.AuctionLot
+	if err := db.Where("status IN ? AND ends_at <= ?", []string{AuctionLotOpen, AuctionLotClosing}, time.Now()).
+		Find(&lots).Error; err != nil {
+		return 0, err
+	}
+
+	settled := 0
+	for _, lot := range lots {
+		if _, err := SettleAuctionLot(lot.ID, false); err != nil {
+			if err == ErrAuctionLotClosed {
+				continue // Settled concurrently
+			}
+			return settled, err
+		}
+		settled++
+	}
+
+	return settled, nil
+}
+
+// NextAuctionLotDeadline returns the earliest end time among active lots, or nil
+func NextAuctionLotDeadline() (*time.Time, error) {
+	db := database.GetDB()
+
+	var lot model.AuctionLot
+	err := db.Where("status IN ?", []string{AuctionLotOpen, AuctionLotClosing}).
+		Order("ends_at asc").First(&lot).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lot.EndsAt, nil
+}
+
+// GetAuctionLots returns lots filtered by status (all if empty)
+// Expired lots are settled first so callers never see a stale "open" lot.
+func GetAuctionLots(status string) ([]model.AuctionLot, error) {
+	db := database.GetDB()
+
+	if _, err := SettleExpiredAuctionLots(); err != nil {
+		return nil, err
+	}
+
+	query := db.Preload("General").Preload("HighBidder").Order("id desc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var lots []model.AuctionLot
+	if err := query.Find(&lots).Error; err != nil {
+		return nil, err
+	}
+
+	return lots, nil
+}
+
+// GetAuctionLot returns a lot with its bid history
+func GetAuctionLot(lotID uint) (*model.AuctionLot, error) {
+	db := database.GetDB()
+
+	var lot model.AuctionLot
+	if err := db.Preload("General").Preload("HighBidder").
+		Preload("Bids", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id desc")
+		}).
+		Preload("Bids.User").
+		First(&lot, lotID).Error; err != nil {
+		return nil, ErrAuctionLotNotFound
+	}
+
+	return &lot, nil
+}
+
+// isAuctionLotActive reports whether a lot still accepts bids
+func isAuctionLotActive(lot *model.AuctionLot) bool {
+	return lot.Status == AuctionLotOpen || lot.Status == AuctionLotClosing
+}
+
+// deleteAuctionLots removes all lots and bids for a general inside tx
+func deleteAuctionLots(tx *gorm.DB, generalID uint) error {
+	if err := tx.Where("lot_id IN (?)", tx.Model(&model.AuctionLot{}).Select("id").Where("general_id = ?", generalID)).
+		Delete(&model.AuctionBid{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("general_id = ?", generalID).Delete(&model.AuctionLot{}).Error
+}
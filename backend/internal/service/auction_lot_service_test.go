@@ -0,0 +1,166 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// openTestLot puts an auction-pool general up for ten minutes
+func openTestLot(t *testing.T, general *model.General) *model.AuctionLot {
+	t.Helper()
+	lot, err := OpenAuctionLot(&OpenAuctionLotRequest{GeneralID: general.ID, DurationSecs: 600, ExtendSecs: 20})
+	if err != nil {
+		t.Fatalf("OpenAuctionLot: %v", err)
+	}
+	return lot
+}
+
+// expireTestLot moves a lot's end time into the past
+func expireTestLot(db *gorm.DB, lot *model.AuctionLot) {
+	db.Model(&model.AuctionLot{}).Where("id = ?", lot.ID).Update("ends_at", time.Now().Add(-time.Second))
+}
+
+func TestPlaceAuctionBid(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("auction", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	lot := openTestLot(t, createTestGeneral(t, db, 1, "auction", 50))
+	other := openTestLot(t, createTestGeneral(t, db, 2, "auction", 300))
+
+	if _, err := PlaceAuctionBid(a.ID, lot.ID, 40); err != ErrBidTooLow {
+		t.Errorf("bid under the start price = %v, want ErrBidTooLow", err)
+	}
+	if _, err := PlaceAuctionBid(a.ID, lot.ID, 50); err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+	if _, err := PlaceAuctionBid(a.ID, lot.ID, 55); err != ErrAlreadyHighBidder {
+		t.Errorf("raise own bid = %v, want ErrAlreadyHighBidder", err)
+	}
+	if _, err := PlaceAuctionBid(b.ID, lot.ID, 50); err != ErrBidTooLow {
+		t.Errorf("bid matching the current price = %v, want ErrBidTooLow", err)
+	}
+	if _, err := PlaceAuctionBid(b.ID, lot.ID, 60); err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+
+	// a leads with 300 elsewhere, leaving 50 of 350 for this lot
+	if _, err := PlaceAuctionBid(a.ID, other.ID, 300); err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+	if _, err := PlaceAuctionBid(a.ID, lot.ID, 70); err != ErrAuctionInsufficient {
+		t.Errorf("bid beyond the uncommitted space = %v, want ErrAuctionInsufficient", err)
+	}
+	current, err := GetAuctionLot(lot.ID)
+	if err != nil {
+		t.Fatalf("GetAuctionLot: %v", err)
+	}
+	if current.HighBidderID == nil || *current.HighBidderID != b.ID || current.CurrentPrice != 60 || current.BidCount != 2 {
+		t.Errorf("lot after a rejected bid = %+v, want b leading at 60 after 2 bids", current)
+	}
+
+	// A bid in the last seconds pushes the hammer back
+	c := createTestUser(t, db, "c")
+	db.Model(&model.AuctionLot{}).Where("id = ?", lot.ID).Update("ends_at", time.Now().Add(5*time.Second))
+	current, err = PlaceAuctionBid(c.ID, lot.ID, 70)
+	if err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+	if current.Status != AuctionLotClosing || current.EndsAt.Before(time.Now().Add(15*time.Second)) {
+		t.Errorf("late bid left status %q ending %v, want closing with the end time extended", current.Status, current.EndsAt)
+	}
+
+	SetGamePhase("trading", 1, 0)
+	if _, err := PlaceAuctionBid(b.ID, lot.ID, 80); err != ErrNotInAuctionPhase {
+		t.Errorf("bid outside the auction phase = %v, want ErrNotInAuctionPhase", err)
+	}
+}
+
+func TestSettleAuctionLot(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("auction", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	general := createTestGeneral(t, db, 1, "auction", 50)
+	lot := openTestLot(t, general)
+	if _, err := PlaceAuctionBid(a.ID, lot.ID, 80); err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+
+	if _, err := SettleAuctionLot(lot.ID, false); err != ErrAuctionLotNotExpired {
+		t.Errorf("settle before the end time = %v, want ErrAuctionLotNotExpired", err)
+	}
+	settled, err := SettleAuctionLot(lot.ID, true)
+	if err != nil {
+		t.Fatalf("SettleAuctionLot: %v", err)
+	}
+	if settled.Status != AuctionLotSold || settled.RecordID == nil {
+		t.Errorf("settled lot = %+v, want sold with a record", settled)
+	}
+	if owner := generalOwner(t, db, general.ID); owner != a.ID {
+		t.Errorf("general owner = %d, want the winner %d", owner, a.ID)
+	}
+	if got := reloadTestUser(t, db, a.ID).UsedSpace; got != 80 {
+		t.Errorf("winner used space = %d, want the winning bid 80", got)
+	}
+	var record model.AuctionRecord
+	db.First(&record, *settled.RecordID)
+	if record.Price != 80 || record.IsUnsold {
+		t.Errorf("auction record = %+v, want sold at 80", record)
+	}
+
+	if _, err := SettleAuctionLot(lot.ID, true); err != ErrAuctionLotClosed {
+		t.Errorf("second settlement = %v, want ErrAuctionLotClosed", err)
+	}
+	if _, err := OpenAuctionLot(&OpenAuctionLotRequest{GeneralID: general.ID}); err != ErrGeneralAlreadyAuctioned {
+		t.Errorf("reopen a sold general = %v, want ErrGeneralAlreadyAuctioned", err)
+	}
+}
+
+func TestSettleExpiredAuctionLotsUnsold(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("auction", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	overCap := openTestLot(t, createTestGeneral(t, db, 1, "auction", 50))
+	noBids := openTestLot(t, createTestGeneral(t, db, 2, "auction", 50))
+	if _, err := PlaceAuctionBid(a.ID, overCap.ID, 100); err != nil {
+		t.Fatalf("PlaceAuctionBid: %v", err)
+	}
+
+	// a fills up elsewhere before the hammer and can no longer pay
+	db.Model(&model.User{}).Where("id = ?", a.ID).Update("used_space", 300)
+	expireTestLot(db, overCap)
+	expireTestLot(db, noBids)
+
+	settled, err := SettleExpiredAuctionLots()
+	if err != nil {
+		t.Fatalf("SettleExpiredAuctionLots: %v", err)
+	}
+	if settled != 2 {
+		t.Errorf("settled %d lots, want 2", settled)
+	}
+	for _, lot := range []*model.AuctionLot{overCap, noBids} {
+		current, err := GetAuctionLot(lot.ID)
+		if err != nil {
+			t.Fatalf("GetAuctionLot: %v", err)
+		}
+		if current.Status != AuctionLotUnsold {
+			t.Errorf("lot %d status = %q, want unsold", lot.ID, current.Status)
+		}
+		if owner := generalOwner(t, db, lot.GeneralID); owner != 0 {
+			t.Errorf("unsold general %d went to user %d", lot.GeneralID, owner)
+		}
+	}
+	if got := reloadTestUser(t, db, a.ID).UsedSpace; got != 300 {
+		t.Errorf("bidder used space = %d, want 300 unchanged", got)
+	}
+	if _, err := PlaceAuctionBid(a.ID, noBids.ID, 50); err != ErrAuctionLotClosed {
+		t.Errorf("bid on a settled lot = %v, want ErrAuctionLotClosed", err)
+	}
+}
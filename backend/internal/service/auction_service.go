@@ -5,6 +5,8 @@ import (
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -130,6 +132,17 @@ func AssignAuction(req *AssignAuctionRequest) (*model.AuctionRecord, error) {
 		return nil, ErrGeneralAlreadyAuctioned
 	}
 
+	// A live lot settles itself; assigning by hand would sell the general twice
+	var activeLots int64
+	if err := db.Model(&model.AuctionLot{}).
+		Where("general_id = ? AND status IN ?", general.ID, []string{AuctionLotOpen, AuctionLotClosing}).
+		Count(&activeLots).Error; err != nil {
+		return nil, err
+	}
+	if activeLots > 0 {
+		return nil, ErrAuctionLotExists
+	}
+
 	// Determine price: use provided price, or default to salary
 	price := req.Price
 	if price == 0 && req.UserID != nil {
@@ -139,46 +152,75 @@ func AssignAuction(req *AssignAuctionRequest) (*model.AuctionRecord, error) {
 	// Begin transaction
 	tx := db.Begin()
 
-	// Create auction record
+	record, err := createAuctionRecord(tx, &general, req.UserID, price, req.Remark)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	var effects []clubEffectsUpdate
+	if req.UserID != nil {
+		if effects, err = applyClubEffects(tx, *req.UserID); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	publishClubEffects(effects)
+
+	// Reload record with associations
+	db.Preload("User").Preload("General").First(record, record.ID)
+
+	PublishEvent(EventAuctionAssigned, record)
+
+	return record, nil
+}
+
+// createAuctionRecord writes the auction result inside tx and, if there is a
+// winner, hands the general over and charges the price to their used space
+func createAuctionRecord(tx *gorm.DB, general *model.General, userID *uint, price int, remark string) (*model.AuctionRecord, error) {
+	// Re-check inside tx: a general can only be hammered once
+	var existing int64
+	if err := tx.Model(&model.AuctionRecord{}).Where("general_id = ?", general.ID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrGeneralAlreadyAuctioned
+	}
+
 	record := &model.AuctionRecord{
-		GeneralID: req.GeneralID,
-		UserID:    req.UserID,
+		GeneralID: general.ID,
+		UserID:    userID,
 		Price:     price,
-		IsUnsold:  req.UserID == nil,
-		Remark:    req.Remark,
+		IsUnsold:  userID == nil,
+		Remark:    remark,
 	}
 
 	if err := tx.Create(record).Error; err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
 	// If there's a winner, assign the general and update space
-	if req.UserID != nil {
+	if userID != nil {
 		// Update general owner
-		if err := tx.Model(&general).Updates(map[string]interface{}{
-			"owner_id":     *req.UserID,
+		if err := tx.Model(general).Updates(map[string]interface{}{
+			"owner_id":     *userID,
 			"is_available": false,
+			"version":      gorm.Expr("version + 1"),
 		}).Error; err != nil {
-			tx.Rollback()
 			return nil, err
 		}
 
 		// Update user's used space
-		if err := tx.Model(&model.User{}).Where("id = ?", *req.UserID).
-			UpdateColumn("used_space", database.GetDB().Raw("used_space + ?", price)).Error; err != nil {
-			tx.Rollback()
+		if err := tx.Model(&model.User{}).Where("id = ?", *userID).
+			UpdateColumn("used_space", gorm.Expr("used_space + ?", price)).Error; err != nil {
 			return nil, err
 		}
 	}
 
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
-	}
-
-	// Reload record with associations
-	db.Preload("User").Preload("General").First(record, record.ID)
-
 	return record, nil
 }
 
@@ -201,6 +243,7 @@ func ResetAuction(recordID uint) error {
 		if err := tx.Model(&model.General{}).Where("id = ?", record.GeneralID).Updates(map[string]interface{}{
 			"owner_id":     nil,
 			"is_available": true,
+			"version":      gorm.Expr("version + 1"),
 		}).Error; err != nil {
 			tx.Rollback()
 			return err
@@ -208,7 +251,16 @@ func ResetAuction(recordID uint) error {
 
 		// Restore user's space
 		if err := tx.Model(&model.User{}).Where("id = ?", *record.UserID).
-			UpdateColumn("used_space", database.GetDB().Raw("used_space - ?", record.Price)).Error; err != nil {
+			UpdateColumn("used_space", gorm.Expr("used_space - ?", record.Price)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	var effects []clubEffectsUpdate
+	if record.UserID != nil {
+		var err error
+		if effects, err = applyClubEffects(tx, *record.UserID); err != nil {
 			tx.Rollback()
 			return err
 		}
@@ -220,7 +272,17 @@ func ResetAuction(recordID uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	// Drop any live lot that produced the record so the general can be re-auctioned
+	if err := deleteAuctionLots(tx, record.GeneralID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishClubEffects(effects)
+	return nil
 }
 
 // ResetAuctionByGeneralID resets an auction record by general ID (admin only)
@@ -43,11 +43,16 @@ func Register(username, password, nickname string) (*model.User, error) {
 		return nil, err
 	}
 
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return nil, err
+	}
+
 	user := &model.User{
 		Username: username,
 		Password: string(hashedPassword),
 		Nickname: nickname,
-		Space:    config.AppConfig.Game.InitialSpace,
+		Space:    rules.InitialSpace,
 	}
 
 	if err := db.Create(user).Error; err != nil {
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+var (
+	ErrInvalidDraftQueue   = errors.New("invalid draft queue")
+	ErrDraftQueueExhausted = errors.New("no available general left in the draft queue")
+)
+
+// SetDraftQueue replaces a user's ordered wish-list of draft-pool generals
+func SetDraftQueue(userID uint, generalIDs []uint) error {
+	db := database.GetDB()
+
+	// Validate all generals are draft-pool generals still on the board
+	seen := make(map[uint]bool, len(generalIDs))
+	for _, generalID := range generalIDs {
+		if seen[generalID] {
+			return ErrInvalidDraftQueue
+		}
+		seen[generalID] = true
+
+		var general model.General
+		if err := db.First(&general, generalID).Error; err != nil {
+			return ErrInvalidDraftQueue
+		}
+		if general.PoolType != "draft" || general.OwnerID != nil || !general.IsAvailable {
+			return ErrGeneralNotAvailable
+		}
+	}
+
+	// Start transaction
+	tx := db.Begin()
+
+	// Delete existing queue
+	if err := tx.Where("user_id = ?", userID).Delete(&model.DraftQueue{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Create new queue
+	for i, generalID := range generalIDs {
+		entry := model.DraftQueue{
+			UserID:    userID,
+			GeneralID: generalID,
+			Priority:  i + 1,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetDraftQueue retrieves a user's wish-list
+func GetDraftQueue(userID uint) ([]model.DraftQueue, error) {
+	db := database.GetDB()
+	var queue []model.DraftQueue
+	if err := db.Where("user_id = ?", userID).Order("priority asc").Preload("General").Find(&queue).Error; err != nil {
+		return nil, err
+	}
+	return queue, nil
+}
+
+// SetAutoDraft turns auto-draft on or off for a user
+// Turning it on while on the clock picks immediately.
+func SetAutoDraft(userID uint, enabled bool) error {
+	db := database.GetDB()
+	if err := db.Model(&model.User{}).Where("id = ?", userID).Update("auto_draft", enabled).Error; err != nil {
+		return err
+	}
+
+	if enabled {
+		processAutoDrafts()
+	}
+	return nil
+}
+
+// autoDraftPick picks for the user on the clock: the first still-available,
+// affordable general in their queue. It never reaches past the queue; once the
+// queue is exhausted the pick is left to the timer or an admin.
+func autoDraftPick(userID uint) error {
+	db := database.GetDB()
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	remainingSpace := user.Space - user.UsedSpace
+
+	var candidates []model.General
+	queue, err := GetDraftQueue(userID)
+	if err != nil {
+		return err
+	}
+	for _, entry := range queue {
+		if entry.General != nil {
+			candidates = append(candidates, *entry.General)
+		}
+	}
+
+	for _, general := range candidates {
+		if general.OwnerID != nil || !general.IsAvailable || general.PoolType != "draft" {
+			continue
+		}
+		if general.Salary > remainingSpace {
+			continue
+		}
+
+		_, err := draftPick(userID, general.ID, true)
+		if err == ErrGeneralNotAvailable || err == ErrInsufficientSpace || err == ErrClubEffectsOverCap {
+			continue
+		}
+		return err
+	}
+
+	return ErrDraftQueueExhausted
+}
+
+// skipDraftPick passes the pick of the user on the clock so the draft is not stuck
+func skipDraftPick(userID uint) error {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+	if currentDraftPicker(phase) != userID {
+		return ErrNotYourTurn
+	}
+	if err := advanceDraft(db, phase); err != nil {
+		return err
+	}
+	log.Printf("Draft: user %d has no pick left in their queue, pick skipped", userID)
+	return nil
+}
+
+// processAutoDrafts keeps picking while the player on the clock has auto-draft on
+func processAutoDrafts() {
+	db := database.GetDB()
+
+	for {
+		phase, err := GetGamePhase()
+		if err != nil {
+			return
+		}
+		picker := currentDraftPicker(phase)
+		if picker == 0 {
+			return
+		}
+
+		var user model.User
+		if err := db.First(&user, picker).Error; err != nil || !user.AutoDraft {
+			return
+		}
+
+		if err := autoDraftPick(picker); err != nil {
+			if err == ErrDraftQueueExhausted {
+				// The pick timer (or an admin) takes it from here
+				log.Printf("Draft: user %d has an empty queue, waiting for the pick timer", picker)
+				return
+			}
+			log.Printf("Draft: auto-pick for user %d failed: %v", picker, err)
+			return
+		}
+	}
+}
+
+// CheckDraftTimeout auto-picks for the player on the clock once their timer expires
+func CheckDraftTimeout() (bool, error) {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return false, err
+	}
+
+	picker := currentDraftPicker(phase)
+	if picker == 0 || phase.DraftDeadline == nil || time.Now().Before(*phase.DraftDeadline) {
+		return false, nil
+	}
+
+	// Time is up: take the next queued general, or pass the pick
+	err = autoDraftPick(picker)
+	if err == ErrDraftQueueExhausted {
+		err = skipDraftPick(picker)
+	}
+	if err != nil {
+		return false, err
+	}
+	processAutoDrafts()
+
+	if state, err := GetDraftState(); err == nil {
+		PublishEvent(EventDraftUpdated, state)
+	}
+	return true, nil
+}
+
+// NextDraftDeadline returns when the current pick times out, or nil. Used by the background scheduler.
+func NextDraftDeadline() (*time.Time, error) {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.DraftStatus != DraftStatusRunning {
+		return nil, nil
+	}
+	return phase.DraftDeadline, nil
+}
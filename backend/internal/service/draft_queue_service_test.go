@@ -0,0 +1,131 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// expireDraftPick runs out the timer of the pick on the clock
+func expireDraftPick(db *gorm.DB) {
+	db.Model(&model.GamePhase{}).Where("1 = 1").Update("draft_deadline", time.Now().Add(-time.Minute))
+}
+
+func TestSetDraftQueue(t *testing.T) {
+	db := setupTestDB(t)
+	user := createTestUser(t, db, "user")
+	other := createTestUser(t, db, "other")
+	free := createTestGeneral(t, db, 1, "draft", 10)
+	owned := createTestGeneral(t, db, 2, "draft", 10)
+	giveTestGeneral(t, db, owned, other)
+
+	if err := SetDraftQueue(user.ID, []uint{free.ID, free.ID}); err != ErrInvalidDraftQueue {
+		t.Errorf("queue with a repeated general = %v, want ErrInvalidDraftQueue", err)
+	}
+	if err := SetDraftQueue(user.ID, []uint{free.ID, owned.ID}); err != ErrGeneralNotAvailable {
+		t.Errorf("queue with an owned general = %v, want ErrGeneralNotAvailable", err)
+	}
+	if err := SetDraftQueue(user.ID, []uint{free.ID}); err != nil {
+		t.Fatalf("SetDraftQueue: %v", err)
+	}
+	queue, err := GetDraftQueue(user.ID)
+	if err != nil {
+		t.Fatalf("GetDraftQueue: %v", err)
+	}
+	if len(queue) != 1 || queue[0].GeneralID != free.ID || queue[0].Priority != 1 {
+		t.Errorf("draft queue = %+v, want just general %d", queue, free.ID)
+	}
+}
+
+func TestAutoDraftFromQueue(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("draft", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	first := createTestGeneral(t, db, 1, "draft", 10)
+	second := createTestGeneral(t, db, 2, "draft", 10)
+	expensive := createTestGeneral(t, db, 3, "draft", 400)
+
+	if err := SetDraftQueue(b.ID, []uint{first.ID, expensive.ID, second.ID}); err != nil {
+		t.Fatalf("SetDraftQueue: %v", err)
+	}
+	if err := SetAutoDraft(b.ID, true); err != nil {
+		t.Fatalf("SetAutoDraft: %v", err)
+	}
+	if _, err := StartDraft(&StartDraftRequest{Method: "manual", UserIDs: []uint{a.ID, b.ID}, Mode: DraftModeLinear, Rounds: 1}); err != nil {
+		t.Fatalf("StartDraft: %v", err)
+	}
+
+	// a takes b's first choice; b's auto-pick passes over it and the general b cannot afford
+	if _, err := DraftPick(a.ID, first.ID); err != nil {
+		t.Fatalf("DraftPick: %v", err)
+	}
+
+	var record model.DraftRecord
+	if err := db.Where("user_id = ?", b.ID).First(&record).Error; err != nil {
+		t.Fatalf("b has no draft record: %v", err)
+	}
+	if record.GeneralID != second.ID || !record.AutoPicked {
+		t.Errorf("b's pick = general %d auto %v, want general %d auto-picked", record.GeneralID, record.AutoPicked, second.ID)
+	}
+	if state, _ := GetDraftState(); state.Status != DraftStatusCompleted {
+		t.Errorf("draft status = %q, want completed", state.Status)
+	}
+}
+
+func TestCheckDraftTimeout(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("draft", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	general := createTestGeneral(t, db, 1, "draft", 10)
+
+	if err := SetDraftQueue(a.ID, []uint{general.ID}); err != nil {
+		t.Fatalf("SetDraftQueue: %v", err)
+	}
+	state, err := StartDraft(&StartDraftRequest{Method: "manual", UserIDs: []uint{a.ID, b.ID}, Mode: DraftModeLinear, Rounds: 1, TimeoutMinutes: 5})
+	if err != nil {
+		t.Fatalf("StartDraft: %v", err)
+	}
+	if state.Deadline == nil {
+		t.Fatal("draft started with a timer has no deadline")
+	}
+
+	if acted, err := CheckDraftTimeout(); err != nil || acted {
+		t.Errorf("CheckDraftTimeout before the deadline = %v, %v, want false", acted, err)
+	}
+
+	// a's timer runs out: the queued general is picked for them
+	expireDraftPick(db)
+	if acted, err := CheckDraftTimeout(); err != nil || !acted {
+		t.Fatalf("CheckDraftTimeout = %v, %v, want true", acted, err)
+	}
+	var reloaded model.General
+	db.First(&reloaded, general.ID)
+	if reloaded.OwnerID == nil || *reloaded.OwnerID != a.ID {
+		t.Errorf("general owner = %v, want a", reloaded.OwnerID)
+	}
+
+	// b has nothing queued: the pick is passed and the draft ends
+	expireDraftPick(db)
+	if acted, err := CheckDraftTimeout(); err != nil || !acted {
+		t.Fatalf("CheckDraftTimeout = %v, %v, want true", acted, err)
+	}
+	state, err = GetDraftState()
+	if err != nil {
+		t.Fatalf("GetDraftState: %v", err)
+	}
+	if state.Status != DraftStatusCompleted {
+		t.Errorf("draft status = %q, want completed", state.Status)
+	}
+	var count int64
+	db.Model(&model.DraftRecord{}).Where("user_id = ?", b.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("b has %d draft records after a skipped pick, want 0", count)
+	}
+}
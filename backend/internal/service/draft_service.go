@@ -0,0 +1,363 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sort"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDraftNotRunning     = errors.New("draft is not running")
+	ErrDraftAlreadyRunning = errors.New("draft is already running")
+	ErrInvalidDraftOrder   = errors.New("invalid draft order")
+	ErrInvalidDraftMethod  = errors.New("invalid draft order method")
+	ErrInvalidDraftMode    = errors.New("invalid draft mode")
+)
+
+// Draft statuses
+const (
+	DraftStatusRunning   = "running"
+	DraftStatusCompleted = "completed"
+)
+
+// Draft ordering modes
+const (
+	DraftModeSnake  = "snake"  // Order reverses every other round
+	DraftModeLinear = "linear" // Same order every round
+)
+
+// StartDraftRequest configures a new draft
+type StartDraftRequest struct {
+	Method  string `json:"method"`   // random/manual/policy
+	UserIDs []uint `json:"user_ids"` // Required when method is manual
+	Mode    string `json:"mode"`     // snake/linear (default snake)
+	Rounds  int    `json:"rounds"`   // Defaults to GameConfig.DraftRounds
+
+	TimeoutMinutes int `json:"timeout_minutes"` // Per-pick timer, 0 = no timer
+}
+
+// DraftSlot is one pick in a round's order
+type DraftSlot struct {
+	Pick     int    `json:"pick"`
+	UserID   uint   `json:"user_id"`
+	Nickname string `json:"nickname"`
+}
+
+// DraftState describes the draft for the "on the clock" view
+type DraftState struct {
+	Status      string              `json:"status"`
+	Mode        string              `json:"mode"`
+	Deadline    *time.Time          `json:"deadline"`
+	Round       int                 `json:"round"`
+	Pick        int                 `json:"pick"`
+	Rounds      int                 `json:"rounds"`
+	OverallPick int                 `json:"overall_pick"`
+	TotalPicks  int                 `json:"total_picks"`
+	Order       []uint              `json:"order"`       // Base (round 1) order
+	RoundOrder  []DraftSlot         `json:"round_order"` // Order of the current round
+	OnTheClock  *model.User         `json:"on_the_clock"`
+	RecentPicks []model.DraftRecord `json:"recent_picks"`
+}
+
+// StartDraft generates the draft order and puts the first picker on the clock (admin only)
+func StartDraft(req *StartDraftRequest) (*DraftState, error) {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "draft" {
+		return nil, ErrNotInDraftPhase
+	}
+	if phase.DraftStatus == DraftStatusRunning {
+		return nil, ErrDraftAlreadyRunning
+	}
+
+	if req.Mode == "" {
+		req.Mode = DraftModeSnake
+	}
+	if req.Mode != DraftModeSnake && req.Mode != DraftModeLinear {
+		return nil, ErrInvalidDraftMode
+	}
+	if req.Rounds <= 0 {
+		rules, err := GetSeasonRules()
+		if err != nil {
+			return nil, err
+		}
+		req.Rounds = rules.DraftRounds
+	}
+	if req.TimeoutMinutes < 0 {
+		req.TimeoutMinutes = 0
+	}
+
+	order, err := generateDraftOrder(req.Method, req.UserIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	orderJSON, _ := json.Marshal(order)
+	if err := db.Model(&model.GamePhase{}).Where("id = ?", phase.ID).Updates(map[string]interface{}{
+		"draft_order":    string(orderJSON),
+		"draft_round":    1,
+		"draft_pick":     1,
+		"draft_rounds":   req.Rounds,
+		"draft_mode":     req.Mode,
+		"draft_status":   DraftStatusRunning,
+		"draft_timeout":  req.TimeoutMinutes,
+		"draft_deadline": draftDeadline(req.TimeoutMinutes),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	processAutoDrafts()
+
+	state, err := GetDraftState()
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventDraftUpdated, state)
+
+	return state, nil
+}
+
+// generateDraftOrder builds the round 1 order of registered players
+func generateDraftOrder(method string, userIDs []uint) ([]uint, error) {
+	db := database.GetDB()
+
+	var users []model.User
+	if err := db.Where("is_registered = ?", true).Order("id asc").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	registered := make(map[uint]bool, len(users))
+	for _, user := range users {
+		registered[user.ID] = true
+	}
+
+	order := make([]uint, 0, len(users))
+	switch method {
+	case "", "random":
+		for _, user := range users {
+			order = append(order, user.ID)
+		}
+		rand.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+
+	case "manual":
+		seen := make(map[uint]bool, len(userIDs))
+		for _, id := range userIDs {
+			if !registered[id] || seen[id] {
+				return nil, ErrInvalidDraftOrder
+			}
+			seen[id] = true
+			order = append(order, id)
+		}
+
+	case "policy":
+		// Follow the 国策 bid ranking; players without a bid pick last
+		var bids []model.PolicyBid
+		if err := db.Where("rank > 0").Order("rank asc").Find(&bids).Error; err != nil {
+			return nil, err
+		}
+		seen := make(map[uint]bool, len(users))
+		for _, bid := range bids {
+			if registered[bid.UserID] && !seen[bid.UserID] {
+				seen[bid.UserID] = true
+				order = append(order, bid.UserID)
+			}
+		}
+		for _, user := range users {
+			if !seen[user.ID] {
+				order = append(order, user.ID)
+			}
+		}
+
+	default:
+		return nil, ErrInvalidDraftMethod
+	}
+
+	if len(order) == 0 {
+		return nil, ErrInvalidDraftOrder
+	}
+	return order, nil
+}
+
+// parseDraftOrder decodes GamePhase.DraftOrder
+func parseDraftOrder(phase *model.GamePhase) []uint {
+	var order []uint
+	json.Unmarshal([]byte(phase.DraftOrder), &order)
+	return order
+}
+
+// draftRoundOrder returns the pick order for a round, reversing even rounds in snake mode
+func draftRoundOrder(order []uint, mode string, round int) []uint {
+	roundOrder := make([]uint, len(order))
+	copy(roundOrder, order)
+	if mode == DraftModeSnake && round%2 == 0 {
+		for i, j := 0, len(roundOrder)-1; i < j; i, j = i+1, j-1 {
+			roundOrder[i], roundOrder[j] = roundOrder[j], roundOrder[i]
+		}
+	}
+	return roundOrder
+}
+
+// currentDraftPicker returns the user on the clock, or 0 if the draft is not running
+func currentDraftPicker(phase *model.GamePhase) uint {
+	if phase.DraftStatus != DraftStatusRunning {
+		return 0
+	}
+	order := parseDraftOrder(phase)
+	if phase.DraftPick < 1 || phase.DraftPick > len(order) {
+		return 0
+	}
+	return draftRoundOrder(order, phase.DraftMode, phase.DraftRound)[phase.DraftPick-1]
+}
+
+// advanceDraft moves the clock to the next pick inside tx
+// The update is conditional on the current round/pick so that two concurrent
+// picks for the same slot cannot both succeed.
+func advanceDraft(tx *gorm.DB, phase *model.GamePhase) error {
+	order := parseDraftOrder(phase)
+
+	round, pick, status := phase.DraftRound, phase.DraftPick+1, DraftStatusRunning
+	if pick > len(order) {
+		round, pick = round+1, 1
+	}
+	if round > phase.DraftRounds {
+		round, pick, status = phase.DraftRounds, 0, DraftStatusCompleted
+	}
+
+	var deadline *time.Time
+	if status == DraftStatusRunning {
+		deadline = draftDeadline(phase.DraftTimeout)
+	}
+
+	result := tx.Model(&model.GamePhase{}).
+		Where("id = ? AND draft_round = ? AND draft_pick = ? AND draft_status = ?",
+			phase.ID, phase.DraftRound, phase.DraftPick, DraftStatusRunning).
+		Updates(map[string]interface{}{
+			"draft_round":    round,
+			"draft_pick":     pick,
+			"draft_status":   status,
+			"draft_deadline": deadline,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotYourTurn
+	}
+	return nil
+}
+
+// draftDeadline returns the deadline for a pick starting now, nil without a timer
+func draftDeadline(timeoutMinutes int) *time.Time {
+	if timeoutMinutes <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(time.Duration(timeoutMinutes) * time.Minute)
+	return &deadline
+}
+
+// SkipDraftPick passes the current pick without a selection (admin only)
+func SkipDraftPick() (*DraftState, error) {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.DraftStatus != DraftStatusRunning {
+		return nil, ErrDraftNotRunning
+	}
+
+	if err := advanceDraft(db, phase); err != nil {
+		return nil, err
+	}
+
+	processAutoDrafts()
+
+	state, err := GetDraftState()
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventDraftUpdated, state)
+
+	return state, nil
+}
+
+// GetDraftState returns the current draft order and who is on the clock
+func GetDraftState() (*DraftState, error) {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+
+	order := parseDraftOrder(phase)
+	state := &DraftState{
+		Status:     phase.DraftStatus,
+		Mode:       phase.DraftMode,
+		Deadline:   phase.DraftDeadline,
+		Round:      phase.DraftRound,
+		Pick:       phase.DraftPick,
+		Rounds:     phase.DraftRounds,
+		TotalPicks: len(order) * phase.DraftRounds,
+		Order:      order,
+	}
+	if state.Status == DraftStatusRunning {
+		state.OverallPick = (phase.DraftRound-1)*len(order) + phase.DraftPick
+	} else if state.Status == DraftStatusCompleted {
+		state.OverallPick = state.TotalPicks
+	}
+
+	// Resolve nicknames for the round order
+	var users []model.User
+	if len(order) > 0 {
+		if err := db.Where("id IN ?", order).Find(&users).Error; err != nil {
+			return nil, err
+		}
+	}
+	userMap := make(map[uint]model.User, len(users))
+	for _, user := range users {
+		userMap[user.ID] = user
+	}
+
+	round := phase.DraftRound
+	if round < 1 {
+		round = 1
+	}
+	for i, id := range draftRoundOrder(order, phase.DraftMode, round) {
+		state.RoundOrder = append(state.RoundOrder, DraftSlot{
+			Pick:     i + 1,
+			UserID:   id,
+			Nickname: userMap[id].Nickname,
+		})
+	}
+
+	if picker := currentDraftPicker(phase); picker != 0 {
+		if user, ok := userMap[picker]; ok {
+			state.OnTheClock = &user
+		}
+	}
+
+	if err := db.Preload("User").Preload("General").
+		Order("id desc").Limit(10).
+		Find(&state.RecentPicks).Error; err != nil {
+		return nil, err
+	}
+	sort.Slice(state.RecentPicks, func(i, j int) bool {
+		return state.RecentPicks[i].ID < state.RecentPicks[j].ID
+	})
+
+	return state, nil
+}
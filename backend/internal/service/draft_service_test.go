@@ -0,0 +1,114 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDraftRoundOrder(t *testing.T) {
+	order := []uint{1, 2, 3}
+	tests := []struct {
+		name  string
+		mode  string
+		round int
+		want  []uint
+	}{
+		{"snake first round", DraftModeSnake, 1, []uint{1, 2, 3}},
+		{"snake second round reverses", DraftModeSnake, 2, []uint{3, 2, 1}},
+		{"snake third round", DraftModeSnake, 3, []uint{1, 2, 3}},
+		{"linear second round", DraftModeLinear, 2, []uint{1, 2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := draftRoundOrder(order, tt.mode, tt.round); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("draftRoundOrder = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if order[0] != 1 {
+		t.Errorf("draftRoundOrder changed the base order to %v", order)
+	}
+}
+
+func TestDraftTurnOrder(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("draft", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	c := createTestUser(t, db, "c")
+	for id := uint(1); id <= 6; id++ {
+		createTestGeneral(t, db, id, "draft", 10)
+	}
+
+	if _, err := StartDraft(&StartDraftRequest{Method: "manual", UserIDs: []uint{a.ID, a.ID, b.ID}}); err != ErrInvalidDraftOrder {
+		t.Errorf("StartDraft with a repeated player = %v, want ErrInvalidDraftOrder", err)
+	}
+	state, err := StartDraft(&StartDraftRequest{Method: "manual", UserIDs: []uint{c.ID, a.ID, b.ID}, Rounds: 2})
+	if err != nil {
+		t.Fatalf("StartDraft: %v", err)
+	}
+	if state.OnTheClock == nil || state.OnTheClock.ID != c.ID || state.TotalPicks != 6 {
+		t.Fatalf("draft state = %+v, want c on the clock with 6 picks", state)
+	}
+	if _, err := StartDraft(&StartDraftRequest{Method: "random"}); err != ErrDraftAlreadyRunning {
+		t.Errorf("second StartDraft = %v, want ErrDraftAlreadyRunning", err)
+	}
+
+	if _, err := DraftPick(a.ID, 1); err != ErrNotYourTurn {
+		t.Errorf("pick out of turn = %v, want ErrNotYourTurn", err)
+	}
+
+	// Snake order: c a b, then b a c
+	pickers := []uint{c.ID, a.ID, b.ID, b.ID, a.ID, c.ID}
+	for i, userID := range pickers {
+		if _, err := DraftPick(userID, uint(i+1)); err != nil {
+			t.Fatalf("pick %d by user %d: %v", i+1, userID, err)
+		}
+	}
+
+	state, err = GetDraftState()
+	if err != nil {
+		t.Fatalf("GetDraftState: %v", err)
+	}
+	if state.Status != DraftStatusCompleted || state.OnTheClock != nil || state.OverallPick != 6 {
+		t.Errorf("draft state = %+v, want completed after 6 picks", state)
+	}
+	if _, err := DraftPick(a.ID, 1); err != ErrDraftNotRunning {
+		t.Errorf("pick after the draft = %v, want ErrDraftNotRunning", err)
+	}
+	for _, user := range []uint{a.ID, b.ID, c.ID} {
+		if got := reloadTestUser(t, db, user).UsedSpace; got != 20 {
+			t.Errorf("user %d used space = %d, want 20", user, got)
+		}
+	}
+}
+
+func TestSkipDraftPick(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("draft", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	createTestGeneral(t, db, 1, "draft", 10)
+
+	if _, err := SkipDraftPick(); err != ErrDraftNotRunning {
+		t.Errorf("skip before the draft = %v, want ErrDraftNotRunning", err)
+	}
+	if _, err := StartDraft(&StartDraftRequest{Method: "manual", UserIDs: []uint{a.ID, b.ID}, Mode: DraftModeLinear, Rounds: 1}); err != nil {
+		t.Fatalf("StartDraft: %v", err)
+	}
+	state, err := SkipDraftPick()
+	if err != nil {
+		t.Fatalf("SkipDraftPick: %v", err)
+	}
+	if state.OnTheClock == nil || state.OnTheClock.ID != b.ID {
+		t.Errorf("on the clock after a skip = %+v, want b", state.OnTheClock)
+	}
+	if _, err := DraftPick(b.ID, 1); err != nil {
+		t.Fatalf("DraftPick: %v", err)
+	}
+	if state, _ := GetDraftState(); state.Status != DraftStatusCompleted {
+		t.Errorf("draft status = %q, want completed", state.Status)
+	}
+}
@@ -0,0 +1,487 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Draw ceremony states
+const (
+	CeremonyRunning  = "running"
+	CeremonyPaused   = "paused"
+	CeremonyFinished = "finished"
+)
+
+// ceremonyStepTimeout is how long a claimed step may run; a claim older than that
+// was left behind by a crash and is taken over by the next step
+const ceremonyStepTimeout = 2 * time.Minute
+
+var (
+	ErrCeremonyNotFound  = errors.New("no draw ceremony has been started")
+	ErrCeremonyActive    = errors.New("a draw ceremony is already in progress")
+	ErrCeremonyNotActive = errors.New("the draw ceremony is not in progress")
+	ErrCeremonyBusy      = errors.New("the draw ceremony is already drawing, please retry")
+	ErrInvalidCeremony   = errors.New("invalid draw ceremony order or interval")
+)
+
+// DrawCeremonyRequest starts a ceremony; an empty order draws every registered player by ID
+type DrawCeremonyRequest struct {
+	Order           []uint `json:"order"`
+	IntervalSeconds int    `json:"interval_seconds"`
+}
+
+// CeremonyPlayer is one player's place in the ceremony
+type CeremonyPlayer struct {
+	Position int    `json:"position"`
+	UserID   uint   `json:"user_id"`
+	Nickname string `json:"nickname"`
+	Drawn    int    `json:"drawn"`
+	Total    int    `json:"total"`
+	Current  bool   `json:"current"`
+	Done     bool   `json:"done"` // Drew everything or was skipped
+}
+
+// CeremonyReveal is a draw revealed during the ceremony
+type CeremonyReveal struct {
+	RecordID  uint          `json:"record_id"`
+	UserID    uint          `json:"user_id"`
+	Nickname  string        `json:"nickname"`
+	General   model.General `json:"general"`
+	DrawType  string        `json:"draw_type"`
+	Status    string        `json:"status"` // Returned by a mulligan or voided later on
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// DrawCeremonyView is the public, read-only state of the ceremony
+type DrawCeremonyView struct {
+	Ceremony model.DrawCeremony `json:"ceremony"`
+	Players  []CeremonyPlayer   `json:"players"`
+	Current  *CeremonyPlayer    `json:"current"`
+	Reveals  []CeremonyReveal   `json:"reveals"`
+}
+
+// latestDrawCeremony returns the most recent ceremony, nil if there has been none
+func latestDrawCeremony() (*model.DrawCeremony, error) {
+	var ceremony model.DrawCeremony
+	err := database.GetDB().Order("id desc").First(&ceremony).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	ceremony.OrderList = parseCeremonyOrder(ceremony.PlayerOrder)
+	return &ceremony, nil
+}
+
+func parseCeremonyOrder(order string) []uint {
+	userIDs := []uint{}
+	for _, part := range strings.Split(order, ",") {
+		if id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32); err == nil {
+			userIDs = append(userIDs, uint(id))
+		}
+	}
+	return userIDs
+}
+
+func formatCeremonyOrder(userIDs []uint) string {
+	parts := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		parts = append(parts, strconv.FormatUint(uint64(id), 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// checkDrawPhase rejects ceremony draws outside the draw phase
+func checkDrawPhase() error {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+	if phase.CurrentPhase != "draw" {
+		return ErrNotInDrawPhase
+	}
+	return nil
+}
+
+// checkNoDrawCeremony rejects draws made outside a ceremony that is not finished yet
+func checkNoDrawCeremony() error {
+	ceremony, err := latestDrawCeremony()
+	if err != nil {
+		return err
+	}
+	if ceremony != nil && ceremony.Status != CeremonyFinished {
+		return ErrCeremonyActive
+	}
+	return nil
+}
+
+// ceremonyNextStep is when a running ceremony draws again on its own
+func ceremonyNextStep(intervalSeconds int) *time.Time {
+	if intervalSeconds <= 0 {
+		return nil
+	}
+	next := time.Now().Add(time.Duration(intervalSeconds) * time.Second)
+	return &next
+}
+
+// StartDrawCeremony starts drawing for every player one draw at a time (admin only)
+// Draws happen every IntervalSeconds, or only when the admin steps when it is 0.
+func StartDrawCeremony(req *DrawCeremonyRequest) (*model.DrawCeremony, error) {
+	db := database.GetDB()
+
+	if req.IntervalSeconds < 0 {
+		return nil, ErrInvalidCeremony
+	}
+	if err := checkDrawPhase(); err != nil {
+		return nil, err
+	}
+	latest, err := latestDrawCeremony()
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Status != CeremonyFinished {
+		return nil, ErrCeremonyActive
+	}
+
+	var registered []uint
+	if err := db.Model(&model.User{}).Where("is_registered = ?", true).Order("id asc").Pluck("id", &registered).Error; err != nil {
+		return nil, err
+	}
+	order := req.Order
+	if len(order) == 0 {
+		order = registered
+	}
+	isRegistered := map[uint]bool{}
+	for _, id := range registered {
+		isRegistered[id] = true
+	}
+	seen := map[uint]bool{}
+	for _, id := range order {
+		if !isRegistered[id] || seen[id] {
+			return nil, ErrInvalidCeremony
+		}
+		seen[id] = true
+	}
+	if len(order) == 0 {
+		return nil, ErrInvalidCeremony
+	}
+
+	// Only draws made from here on are revealed by this ceremony
+	var lastRecord model.DrawRecord
+	if err := db.Order("id desc").Limit(1).Find(&lastRecord).Error; err != nil {
+		return nil, err
+	}
+
+	ceremony := model.DrawCeremony{
+		Status:          CeremonyRunning,
+		PlayerOrder:     formatCeremonyOrder(order),
+		IntervalSeconds: req.IntervalSeconds,
+		NextStepAt:      ceremonyNextStep(req.IntervalSeconds),
+		StartRecordID:   lastRecord.ID,
+		StartedAt:       time.Now(),
+	}
+	if err := db.Create(&ceremony).Error; err != nil {
+		return nil, err
+	}
+	ceremony.OrderList = order
+
+	PublishEvent(EventDrawCeremony, ceremony)
+	return &ceremony, nil
+}
+
+// PauseDrawCeremony stops the automatic steps until the ceremony is resumed (admin only)
+func PauseDrawCeremony() (*model.DrawCeremony, error) {
+	return setDrawCeremonyStatus(CeremonyRunning, CeremonyPaused)
+}
+
+// ResumeDrawCeremony continues a paused ceremony (admin only)
+func ResumeDrawCeremony() (*model.DrawCeremony, error) {
+	return setDrawCeremonyStatus(CeremonyPaused, CeremonyRunning)
+}
+
+func setDrawCeremonyStatus(from string, to string) (*model.DrawCeremony, error) {
+	db := database.GetDB()
+
+	ceremony, err := latestDrawCeremony()
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrCeremonyNotFound
+	}
+
+	updates := map[string]interface{}{
+		"status":       to,
+		"next_step_at": nil,
+	}
+	if to == CeremonyRunning {
+		updates["next_step_at"] = ceremonyNextStep(ceremony.IntervalSeconds)
+		updates["last_error"] = ""
+	}
+	result := db.Model(&model.DrawCeremony{}).Where("id = ? AND status = ?", ceremony.ID, from).Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCeremonyNotActive
+	}
+
+	if ceremony, err = latestDrawCeremony(); err != nil {
+		return nil, err
+	}
+	PublishEvent(EventDrawCeremony, ceremony)
+	return ceremony, nil
+}
+
+// StepDrawCeremony reveals the next draw of the ceremony
+// The player on stage draws until they have used all their draws, then the next
+// one takes over. A draw that fails pauses the ceremony with the reason, so the
+// admin can fix it and resume or skip the player. The reveal is nil when nobody
+// left in the order had a draw to make.
+func StepDrawCeremony() (*CeremonyReveal, error) {
+	db := database.GetDB()
+
+	ceremony, err := latestDrawCeremony()
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrCeremonyNotFound
+	}
+	if ceremony.Status == CeremonyFinished {
+		return nil, ErrCeremonyNotActive
+	}
+	// Outside the draw phase a running ceremony pauses until the admin resumes it
+	if err := checkDrawPhase(); err != nil {
+		if ceremony.Status == CeremonyRunning {
+			result := db.Model(&model.DrawCeremony{}).Where("id = ? AND status = ?", ceremony.ID, CeremonyRunning).
+				Updates(map[string]interface{}{"status": CeremonyPaused, "next_step_at": nil, "last_error": err.Error()})
+			if result.Error != nil {
+				return nil, result.Error
+			}
+			if updated, lerr := latestDrawCeremony(); lerr == nil && updated != nil && result.RowsAffected > 0 {
+				PublishEvent(EventDrawCeremony, updated)
+			}
+		}
+		return nil, err
+	}
+
+	// Claim the step so the scheduler and the admin never draw at the same time
+	claimedAt := time.Now()
+	result := db.Model(&model.DrawCeremony{}).
+		Where("id = ? AND (busy_at IS NULL OR busy_at < ?) AND status IN ?",
+			ceremony.ID, claimedAt.Add(-ceremonyStepTimeout), []string{CeremonyRunning, CeremonyPaused}).
+		Update("busy_at", claimedAt)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCeremonyBusy
+	}
+	// Release the claim however the step ends
+	defer db.Model(&model.DrawCeremony{}).Where("id = ? AND busy_at = ?", ceremony.ID, claimedAt).Update("busy_at", nil)
+
+	var reveal *CeremonyReveal
+	var stepErr error
+	index := ceremony.CurrentIndex
+	for index < len(ceremony.OrderList) {
+		userID := ceremony.OrderList[index]
+		general, drawType, err := AdminDraw(userID)
+		if err == ErrDrawLimitReached {
+			index++
+			continue
+		}
+		if err != nil {
+			stepErr = err
+			break
+		}
+
+		reveal = &CeremonyReveal{
+			UserID:    userID,
+			General:   *general,
+			DrawType:  drawType,
+			Status:    "drawn",
+			CreatedAt: time.Now(),
+		}
+		var record model.DrawRecord
+		if err := db.Where("user_id = ? AND general_id = ?", userID, general.ID).Order("id desc").First(&record).Error; err == nil {
+			reveal.RecordID = record.ID
+			reveal.CreatedAt = record.CreatedAt
+		}
+		var user model.User
+		if err := db.First(&user, userID).Error; err == nil {
+			reveal.Nickname = user.Nickname
+		}
+
+		// Hand over right away once the player is done, so the page shows who is next
+		if status, err := getDrawCounts(userID); err == nil && status.TotalRemaining <= 0 {
+			index++
+		}
+		break
+	}
+
+	updates := map[string]interface{}{
+		"current_index": index,
+	}
+	if reveal != nil {
+		updates["steps"] = gorm.Expr("steps + ?", 1)
+	}
+	switch {
+	case stepErr == ErrDrawConflict:
+		// Someone drew at the same moment; the step is simply tried again
+	case stepErr != nil:
+		updates["status"] = CeremonyPaused
+		updates["next_step_at"] = nil
+		updates["last_error"] = fmt.Sprintf("user %d: %v", ceremony.OrderList[index], stepErr)
+	case index >= len(ceremony.OrderList):
+		now := time.Now()
+		updates["status"] = CeremonyFinished
+		updates["next_step_at"] = nil
+		updates["finished_at"] = &now
+	case ceremony.Status == CeremonyRunning:
+		updates["next_step_at"] = ceremonyNextStep(ceremony.IntervalSeconds)
+	}
+	if err := db.Model(&model.DrawCeremony{}).Where("id = ?", ceremony.ID).Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if reveal != nil {
+		PublishEvent(EventDrawCeremonyReveal, reveal)
+	}
+	if updated, err := latestDrawCeremony(); err == nil && updated != nil && updated.Status != ceremony.Status {
+		PublishEvent(EventDrawCeremony, updated)
+	}
+	return reveal, stepErr
+}
+
+// SkipDrawCeremonyPlayer moves the ceremony on to the next player (admin only)
+// The skipped player keeps the draws they have left for drawing on their own.
+func SkipDrawCeremonyPlayer() (*model.DrawCeremony, error) {
+	db := database.GetDB()
+
+	ceremony, err := latestDrawCeremony()
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrCeremonyNotFound
+	}
+	if ceremony.Status == CeremonyFinished {
+		return nil, ErrCeremonyNotActive
+	}
+
+	index := ceremony.CurrentIndex + 1
+	updates := map[string]interface{}{
+		"current_index": index,
+		"last_error":    "",
+	}
+	if index >= len(ceremony.OrderList) {
+		now := time.Now()
+		updates["status"] = CeremonyFinished
+		updates["next_step_at"] = nil
+		updates["finished_at"] = &now
+	}
+	result := db.Model(&model.DrawCeremony{}).
+		Where("id = ? AND current_index = ? AND (busy_at IS NULL OR busy_at < ?)",
+			ceremony.ID, ceremony.CurrentIndex, time.Now().Add(-ceremonyStepTimeout)).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrCeremonyBusy
+	}
+
+	if ceremony, err = latestDrawCeremony(); err != nil {
+		return nil, err
+	}
+	PublishEvent(EventDrawCeremony, ceremony)
+	return ceremony, nil
+}
+
+// GetDrawCeremony returns the order, the player on stage and every revealed draw
+func GetDrawCeremony() (*DrawCeremonyView, error) {
+	db := database.GetDB()
+
+	ceremony, err := latestDrawCeremony()
+	if err != nil {
+		return nil, err
+	}
+	if ceremony == nil {
+		return nil, ErrCeremonyNotFound
+	}
+
+	var users []model.User
+	if err := db.Where("id IN ?", ceremony.OrderList).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	nicknames := map[uint]string{}
+	for _, user := range users {
+		nicknames[user.ID] = user.Nickname
+	}
+
+	view := &DrawCeremonyView{
+		Ceremony: *ceremony,
+		Players:  make([]CeremonyPlayer, 0, len(ceremony.OrderList)),
+		Reveals:  []CeremonyReveal{},
+	}
+	for i, userID := range ceremony.OrderList {
+		status, err := getDrawCounts(userID)
+		if err != nil {
+			return nil, err
+		}
+		player := CeremonyPlayer{
+			Position: i + 1,
+			UserID:   userID,
+			Nickname: nicknames[userID],
+			Drawn:    status.TotalDone,
+			Total:    status.TotalDone + status.TotalRemaining,
+			Current:  i == ceremony.CurrentIndex && ceremony.Status != CeremonyFinished,
+			Done:     i < ceremony.CurrentIndex || status.TotalRemaining <= 0,
+		}
+		view.Players = append(view.Players, player)
+	}
+	if ceremony.CurrentIndex < len(view.Players) && ceremony.Status != CeremonyFinished {
+		view.Current = &view.Players[ceremony.CurrentIndex]
+	}
+
+	var records []model.DrawRecord
+	if err := db.Where("id > ? AND user_id IN ? AND (draw_type = ? OR draw_type = ?) AND status <> ?",
+		ceremony.StartRecordID, ceremony.OrderList, DrawPoolGuarantee, DrawPoolNormal, "reset").
+		Preload("General").Order("id asc").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		view.Reveals = append(view.Reveals, CeremonyReveal{
+			RecordID:  record.ID,
+			UserID:    record.UserID,
+			Nickname:  nicknames[record.UserID],
+			General:   record.General,
+			DrawType:  record.DrawType,
+			Status:    record.Status,
+			CreatedAt: record.CreatedAt,
+		})
+	}
+	return view, nil
+}
+
+// NextDrawCeremonyStep returns when a running ceremony draws next
+func NextDrawCeremonyStep() (*time.Time, error) {
+	ceremony, err := latestDrawCeremony()
+	if err != nil || ceremony == nil {
+		return nil, err
+	}
+	if ceremony.Status != CeremonyRunning {
+		return nil, nil
+	}
+	return ceremony.NextStepAt, nil
+}
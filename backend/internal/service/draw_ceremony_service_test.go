@@ -0,0 +1,113 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// setupDrawTestPools adds two players and enough generals in both initial pools
+func setupDrawTestPools(t *testing.T, db *gorm.DB) []*model.User {
+	t.Helper()
+	users := []*model.User{createTestUser(t, db, "player1"), createTestUser(t, db, "player2")}
+	for id := uint(1); id <= 40; id++ {
+		pool := DrawPoolGuarantee
+		if id > 10 {
+			pool = DrawPoolNormal
+		}
+		createTestGeneral(t, db, id, pool, 10)
+	}
+	return users
+}
+
+func TestDrawCeremony(t *testing.T) {
+	db := setupTestDB(t)
+	users := setupDrawTestPools(t, db)
+
+	if _, err := StartDrawCeremony(&DrawCeremonyRequest{}); err != ErrNotInDrawPhase {
+		t.Fatalf("StartDrawCeremony outside the draw phase = %v, want ErrNotInDrawPhase", err)
+	}
+	if err := SetGamePhase("draw", 1, 0); err != nil {
+		t.Fatalf("SetGamePhase: %v", err)
+	}
+	ceremony, err := StartDrawCeremony(&DrawCeremonyRequest{IntervalSeconds: 20})
+	if err != nil {
+		t.Fatalf("StartDrawCeremony: %v", err)
+	}
+
+	// Nobody draws outside the ceremony until it is finished
+	if _, _, err := Draw(users[0].ID); err != ErrCeremonyActive {
+		t.Errorf("Draw during the ceremony = %v, want ErrCeremonyActive", err)
+	}
+	if _, err := DrawForUser(users[0].ID); err != ErrCeremonyActive {
+		t.Errorf("DrawForUser during the ceremony = %v, want ErrCeremonyActive", err)
+	}
+	if _, _, err := DrawForAllUsers(); err != ErrCeremonyActive {
+		t.Errorf("DrawForAllUsers during the ceremony = %v, want ErrCeremonyActive", err)
+	}
+
+	reveal, err := StepDrawCeremony()
+	if err != nil || reveal == nil {
+		t.Fatalf("StepDrawCeremony = %v, %v, want a reveal", reveal, err)
+	}
+	if reveal.UserID != users[0].ID {
+		t.Errorf("first reveal is for user %d, want %d", reveal.UserID, users[0].ID)
+	}
+	if ceremony, _ = latestDrawCeremony(); ceremony.BusyAt != nil {
+		t.Errorf("busy_at = %v after a step, want it released", ceremony.BusyAt)
+	}
+
+	// A fresh claim blocks the step, a stale one is taken over
+	db.Model(&model.DrawCeremony{}).Where("id = ?", ceremony.ID).Update("busy_at", time.Now())
+	if _, err := StepDrawCeremony(); err != ErrCeremonyBusy {
+		t.Errorf("StepDrawCeremony while claimed = %v, want ErrCeremonyBusy", err)
+	}
+	db.Model(&model.DrawCeremony{}).Where("id = ?", ceremony.ID).Update("busy_at", time.Now().Add(-2*ceremonyStepTimeout))
+	if reveal, err := StepDrawCeremony(); err != nil || reveal == nil {
+		t.Errorf("StepDrawCeremony over a stale claim = %v, %v, want a reveal", reveal, err)
+	}
+
+	// Leaving the draw phase pauses the ceremony instead of drawing
+	if err := SetGamePhase("auction", 1, 0); err != nil {
+		t.Fatalf("SetGamePhase: %v", err)
+	}
+	if _, err := StepDrawCeremony(); err != ErrNotInDrawPhase {
+		t.Errorf("StepDrawCeremony outside the draw phase = %v, want ErrNotInDrawPhase", err)
+	}
+	if ceremony, _ = latestDrawCeremony(); ceremony.Status != CeremonyPaused || ceremony.NextStepAt != nil {
+		t.Errorf("ceremony status = %s next step %v, want paused without a next step", ceremony.Status, ceremony.NextStepAt)
+	}
+
+	SetGamePhase("draw", 1, 0)
+	if _, err := ResumeDrawCeremony(); err != nil {
+		t.Fatalf("ResumeDrawCeremony: %v", err)
+	}
+	for i := 0; ; i++ {
+		if i > 100 {
+			t.Fatalf("ceremony never finished")
+		}
+		reveal, err := StepDrawCeremony()
+		if err != nil {
+			t.Fatalf("StepDrawCeremony: %v", err)
+		}
+		if ceremony, _ = latestDrawCeremony(); ceremony.Status == CeremonyFinished {
+			break
+		}
+		if reveal == nil {
+			t.Fatalf("step revealed nothing but the ceremony is %s", ceremony.Status)
+		}
+	}
+
+	for _, user := range users {
+		status, err := getDrawCounts(user.ID)
+		if err != nil || status.TotalRemaining != 0 {
+			t.Errorf("user %d has %d draws left after the ceremony (%v)", user.ID, status.TotalRemaining, err)
+		}
+		if generals, err := DrawForUser(user.ID); err != nil || len(generals) != 0 {
+			t.Errorf("DrawForUser after the ceremony = %d generals, %v, want none and no error", len(generals), err)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoMulliganTokens      = errors.New("no mulligan tokens left")
+	ErrNoMulliganDraw        = errors.New("no draw can be returned right now")
+	ErrInvalidMulliganTokens = errors.New("mulligan tokens cannot be negative")
+)
+
+// MulliganResult is a returned draw and the draw that replaced it
+type MulliganResult struct {
+	Returned  model.DrawRecord `json:"returned"`
+	General   *model.General   `json:"general"`
+	DrawType  string           `json:"draw_type"`
+	Remaining int              `json:"remaining"` // Tokens left afterwards
+}
+
+// mulliganTokens returns how many mulligan tokens a player has in total and has used
+func mulliganTokens(user *model.User) (int, int, error) {
+	bonus, err := policyEffectTotal(user.ID, PolicyEffectMulligans)
+	if err != nil {
+		return 0, 0, err
+	}
+	total := user.MulliganTokens + bonus
+	if total < 0 {
+		total = 0
+	}
+
+	var used int64
+	if err := database.GetDB().Model(&model.DrawRecord{}).
+		Where("user_id = ? AND status = ?", user.ID, "returned").
+		Count(&used).Error; err != nil {
+		return 0, 0, err
+	}
+	return total, int(used), nil
+}
+
+// mulliganCandidate returns the player's latest draw if it can still be returned
+func mulliganCandidate(userID uint, rules *model.SeasonRules) (*model.DrawRecord, error) {
+	var record model.DrawRecord
+	err := database.GetDB().Where("user_id = ? AND (draw_type = ? OR draw_type = ?)",
+		userID, DrawPoolGuarantee, DrawPoolNormal).
+		Preload("General").Order("id desc").First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Status != "drawn" || time.Now().After(mulliganDeadline(&record, rules)) {
+		return nil, nil
+	}
+	return &record, nil
+}
+
+func mulliganDeadline(record *model.DrawRecord, rules *model.SeasonRules) time.Time {
+	return record.CreatedAt.Add(time.Duration(rules.MulliganMinutes) * time.Minute)
+}
+
+// fillMulliganStatus adds a player's tokens and returnable draw to their draw status
+func fillMulliganStatus(status *DrawStatus, user *model.User, rules *model.SeasonRules) error {
+	total, used, err := mulliganTokens(user)
+	if err != nil {
+		return err
+	}
+	status.MulliganTotal = total
+	status.MulliganUsed = used
+	status.MulliganRemaining = total - used
+	if status.MulliganRemaining < 0 {
+		status.MulliganRemaining = 0
+	}
+
+	record, err := mulliganCandidate(user.ID, rules)
+	if err != nil || record == nil {
+		return err
+	}
+	deadline := mulliganDeadline(record, rules)
+	status.MulliganRecordID = &record.ID
+	status.MulliganDeadline = &deadline
+	return nil
+}
+
+// Mulligan spends a token to return a player's latest draw and draw again
+// Only the newest draw can be returned, within the season's mulligan window. The
+// returned general goes back to its pool and cannot come up in the re-draw; if the
+// re-draw fails the token stays spent and the freed slot can be drawn normally.
+func Mulligan(userID uint) (*MulliganResult, error) {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "draw" {
+		return nil, ErrNotInDrawPhase
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return nil, err
+	}
+	total, used, err := mulliganTokens(&user)
+	if err != nil {
+		return nil, err
+	}
+	if used >= total {
+		return nil, ErrNoMulliganTokens
+	}
+	record, err := mulliganCandidate(userID, rules)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, ErrNoMulliganDraw
+	}
+
+	tx := db.Begin()
+
+	// The general must still be the player's; it may have been released meanwhile
+	result := tx.Model(&model.General{}).Where("id = ? AND owner_id = ?", record.GeneralID, userID).
+		Updates(map[string]interface{}{
+			"owner_id":     nil,
+			"is_available": true,
+			"version":      gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrNoMulliganDraw
+	}
+
+	result = tx.Model(&model.DrawRecord{}).Where("id = ? AND status = ?", record.ID, "drawn").
+		Update("status", "returned")
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrNoMulliganDraw
+	}
+
+	if err := tx.Model(&model.User{}).Where("id = ?", userID).
+		Update("used_space", gorm.Expr("used_space - ?", record.General.Salary)).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	effects, err := applyClubEffects(tx, userID)
+	if err == nil {
+		err = checkClubEffectsCap(effects)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	publishClubEffects(effects)
+
+	record.Status = "returned"
+	PublishEvent(EventDrawReturned, map[string]interface{}{
+		"user_id": userID,
+		"record":  record,
+	})
+
+	general, drawType, err := performDraw(userID, record.GeneralID)
+	if err != nil {
+		return nil, err
+	}
+	return &MulliganResult{
+		Returned:  *record,
+		General:   general,
+		DrawType:  drawType,
+		Remaining: total - used - 1,
+	}, nil
+}
+
+// SetMulliganTokens sets the mulligan tokens the league grants a player (admin only)
+// 国策 effects come on top of them.
+func SetMulliganTokens(userID uint, tokens int) (*model.User, error) {
+	db := database.GetDB()
+
+	if tokens < 0 {
+		return nil, ErrInvalidMulliganTokens
+	}
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if err := db.Model(&user).Update("mulligan_tokens", tokens).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"san11-trade/internal/model"
+)
+
+func TestMulligan(t *testing.T) {
+	db := setupTestDB(t)
+	users := setupDrawTestPools(t, db)
+	player := users[0]
+	SetGamePhase("draw", 1, 0)
+
+	if _, err := Mulligan(player.ID); err != ErrNoMulliganTokens {
+		t.Errorf("mulligan without tokens = %v, want ErrNoMulliganTokens", err)
+	}
+	if _, err := SetMulliganTokens(player.ID, -1); err != ErrInvalidMulliganTokens {
+		t.Errorf("negative tokens = %v, want ErrInvalidMulliganTokens", err)
+	}
+	if _, err := SetMulliganTokens(player.ID, 1); err != nil {
+		t.Fatalf("SetMulliganTokens: %v", err)
+	}
+	if _, err := Mulligan(player.ID); err != ErrNoMulliganDraw {
+		t.Errorf("mulligan before drawing = %v, want ErrNoMulliganDraw", err)
+	}
+
+	drawn, _, err := Draw(player.ID)
+	if err != nil {
+		t.Fatalf("Draw: %v", err)
+	}
+	status, err := GetDrawStatus(player.ID)
+	if err != nil {
+		t.Fatalf("GetDrawStatus: %v", err)
+	}
+	if status.MulliganRemaining != 1 || status.MulliganRecordID == nil || status.MulliganDeadline == nil {
+		t.Errorf("draw status = %+v, want one token and a returnable draw", status)
+	}
+
+	result, err := Mulligan(player.ID)
+	if err != nil {
+		t.Fatalf("Mulligan: %v", err)
+	}
+	if result.Returned.GeneralID != drawn.ID || result.Returned.Status != "returned" {
+		t.Errorf("returned record = %+v, want general %d returned", result.Returned, drawn.ID)
+	}
+	if result.General == nil || result.General.ID == drawn.ID {
+		t.Errorf("re-draw = %+v, want a different general than %d", result.General, drawn.ID)
+	}
+	if result.Remaining != 0 {
+		t.Errorf("tokens remaining = %d, want 0", result.Remaining)
+	}
+
+	// The returned general is back in its pool and its salary refunded
+	var returned model.General
+	db.First(&returned, drawn.ID)
+	if returned.OwnerID != nil || !returned.IsAvailable {
+		t.Errorf("returned general owner = %v available = %v, want back in the pool", returned.OwnerID, returned.IsAvailable)
+	}
+	if got := reloadTestUser(t, db, player.ID).UsedSpace; got != result.General.Salary {
+		t.Errorf("used space = %d, want only the re-drawn salary %d", got, result.General.Salary)
+	}
+
+	// The history keeps the returned pick next to the re-draw
+	records, err := GetUserDrawRecords(player.ID)
+	if err != nil {
+		t.Fatalf("GetUserDrawRecords: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("draw history has %d records, want the returned one and the re-draw", len(records))
+	}
+
+	if _, err := Mulligan(player.ID); err != ErrNoMulliganTokens {
+		t.Errorf("mulligan with the token spent = %v, want ErrNoMulliganTokens", err)
+	}
+}
+
+func TestMulliganWindow(t *testing.T) {
+	db := setupTestDB(t)
+	users := setupDrawTestPools(t, db)
+	player := users[0]
+	SetGamePhase("draw", 1, 0)
+
+	if _, err := SetMulliganTokens(player.ID, 1); err != nil {
+		t.Fatalf("SetMulliganTokens: %v", err)
+	}
+	if _, _, err := Draw(player.ID); err != nil {
+		t.Fatalf("Draw: %v", err)
+	}
+
+	// The default window is ten minutes after the draw
+	db.Model(&model.DrawRecord{}).Where("user_id = ?", player.ID).Update("created_at", time.Now().Add(-11*time.Minute))
+	if _, err := Mulligan(player.ID); err != ErrNoMulliganDraw {
+		t.Errorf("mulligan after the window = %v, want ErrNoMulliganDraw", err)
+	}
+
+	SetGamePhase("auction", 1, 0)
+	if _, err := Mulligan(player.ID); err != ErrNotInDrawPhase {
+		t.Errorf("mulligan outside the draw phase = %v, want ErrNotInDrawPhase", err)
+	}
+}
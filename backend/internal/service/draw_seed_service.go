@@ -0,0 +1,278 @@
+package service
+
+import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// DrawRollAlgorithm describes how a pick is derived, for anyone re-computing it
+const DrawRollAlgorithm = "roll(msg, n) = uint64(HMAC-SHA256(key=seed, msg)[0:8], big-endian) mod n; " +
+	"tier = walk tier_odds in order subtracting weights from roll(\"<nonce>:tier\", total weight) until it falls inside one; " +
+	"index = roll(\"<nonce>\", len(candidates)); candidates are the rolled tier's generals sorted by id"
+
+var (
+	ErrDrawSeedNotFound    = errors.New("draw seed not found")
+	ErrDrawSeedNotRevealed = errors.New("draw seed has not been revealed yet")
+	ErrDrawConflict        = errors.New("another draw was made at the same time, please retry")
+)
+
+// DrawSeedInfo is the public view of a draw seed; Seed stays empty until revealed
+type DrawSeedInfo struct {
+	model.DrawSeed
+	Seed string `json:"seed,omitempty"`
+}
+
+// DrawAudit is the re-computation of one recorded draw
+type DrawAudit struct {
+	RecordID          uint       `json:"record_id"`
+	Rerolled          bool       `json:"rerolled"` // RecordID is a DrawReroll: the pick was thrown back
+	Reason            string     `json:"reason"`
+	UserID            uint       `json:"user_id"`
+	DrawType          string     `json:"draw_type"`
+	Nonce             uint64     `json:"nonce"`
+	TierOdds          []TierOdds `json:"tier_odds"`
+	Tier              int        `json:"tier"`
+	ExpectedTier      int        `json:"expected_tier"`
+	Candidates        []uint     `json:"candidates"`
+	PickIndex         int        `json:"pick_index"`
+	GeneralID         uint       `json:"general_id"`
+	ExpectedIndex     int        `json:"expected_index"`
+	ExpectedGeneralID uint       `json:"expected_general_id"`
+	Valid             bool       `json:"valid"`
+}
+
+// DrawVerification is the audit of every draw made with a revealed seed
+type DrawVerification struct {
+	Seed      DrawSeedInfo `json:"seed"`
+	HashValid bool         `json:"hash_valid"` // SHA-256 of the revealed seed matches the commitment
+	Algorithm string       `json:"algorithm"`
+	Records   []DrawAudit  `json:"records"`
+	// Nonces up to the seed's draw count without a record or re-roll, i.e. deleted draws
+	MissingNonces []uint64 `json:"missing_nonces"`
+	Valid         bool     `json:"valid"`
+}
+
+func drawSeedInfo(seed model.DrawSeed) DrawSeedInfo {
+	info := DrawSeedInfo{DrawSeed: seed}
+	if seed.Revealed {
+		info.Seed = seed.Seed
+	}
+	return info
+}
+
+// drawSeedHash is the public commitment to a seed
+func drawSeedHash(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// drawRoll derives a number in [0, n) from the seed and a message
+func drawRoll(seed string, message string, n int) int {
+	mac := hmac.New(sha256.New, []byte(seed))
+	mac.Write([]byte(message))
+	sum := mac.Sum(nil)
+	return int(binary.BigEndian.Uint64(sum[:8]) % uint64(n))
+}
+
+// drawIndexMessage is the roll message of a draw's pick index
+func drawIndexMessage(nonce uint64) string {
+	return strconv.FormatUint(nonce, 10)
+}
+
+// drawTierMessage is the roll message of a draw's tier
+func drawTierMessage(nonce uint64) string {
+	return strconv.FormatUint(nonce, 10) + ":tier"
+}
+
+// currentDrawSeed returns the unrevealed seed of the season, committing a new one if needed
+func currentDrawSeed() (*model.DrawSeed, error) {
+	db := database.GetDB()
+
+	var seed model.DrawSeed
+	err := db.Where("revealed = ?", false).Order("id desc").First(&seed).Error
+	if err == nil {
+		return &seed, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	raw := make([]byte, 32)
+	if _, err := crand.Read(raw); err != nil {
+		return nil, err
+	}
+	seed = model.DrawSeed{Seed: hex.EncodeToString(raw)}
+	seed.SeedHash = drawSeedHash(seed.Seed)
+	if err := db.Create(&seed).Error; err != nil {
+		return nil, err
+	}
+
+	PublishEvent(EventDrawSeedCommitted, drawSeedInfo(seed))
+	return &seed, nil
+}
+
+// CommitDrawSeed makes sure the hash of the season's seed is published before drawing starts
+func CommitDrawSeed() (*DrawSeedInfo, error) {
+	seed, err := currentDrawSeed()
+	if err != nil {
+		return nil, err
+	}
+	info := drawSeedInfo(*seed)
+	return &info, nil
+}
+
+// RevealDrawSeeds reveals every committed seed so its draws can be verified
+// Draws made afterwards commit to a fresh seed.
+func RevealDrawSeeds() ([]DrawSeedInfo, error) {
+	db := database.GetDB()
+
+	var seeds []model.DrawSeed
+	if err := db.Where("revealed = ?", false).Find(&seeds).Error; err != nil {
+		return nil, err
+	}
+
+	revealed := make([]DrawSeedInfo, 0, len(seeds))
+	for _, seed := range seeds {
+		now := time.Now()
+		result := db.Model(&model.DrawSeed{}).Where("id = ? AND revealed = ?", seed.ID, false).
+			Updates(map[string]interface{}{"revealed": true, "revealed_at": now})
+		if result.Error != nil {
+			return revealed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		seed.Revealed = true
+		seed.RevealedAt = &now
+		info := drawSeedInfo(seed)
+		revealed = append(revealed, info)
+		PublishEvent(EventDrawSeedRevealed, info)
+	}
+	return revealed, nil
+}
+
+// GetDrawSeeds returns every seed commitment, newest first
+func GetDrawSeeds() ([]DrawSeedInfo, error) {
+	db := database.GetDB()
+
+	var seeds []model.DrawSeed
+	if err := db.Order("id desc").Find(&seeds).Error; err != nil {
+		return nil, err
+	}
+
+	infos := make([]DrawSeedInfo, 0, len(seeds))
+	for _, seed := range seeds {
+		infos = append(infos, drawSeedInfo(seed))
+	}
+	return infos, nil
+}
+
+// VerifyDrawSeed re-computes every draw made with a revealed seed, re-rolls included
+func VerifyDrawSeed(seedID uint) (*DrawVerification, error) {
+	db := database.GetDB()
+
+	var seed model.DrawSeed
+	if err := db.First(&seed, seedID).Error; err != nil {
+		return nil, ErrDrawSeedNotFound
+	}
+	if !seed.Revealed {
+		return nil, ErrDrawSeedNotRevealed
+	}
+
+	var records []model.DrawRecord
+	if err := db.Where("seed_id = ?", seed.ID).Order("nonce asc").Find(&records).Error; err != nil {
+		return nil, err
+	}
+	var rerolls []model.DrawReroll
+	if err := db.Where("seed_id = ?", seed.ID).Order("nonce asc").Find(&rerolls).Error; err != nil {
+		return nil, err
+	}
+
+	verification := &DrawVerification{
+		Seed:      drawSeedInfo(seed),
+		HashValid: drawSeedHash(seed.Seed) == seed.SeedHash,
+		Algorithm: DrawRollAlgorithm,
+		Records:   make([]DrawAudit, 0, len(records)),
+	}
+	for _, record := range records {
+		audit := auditDraw(seed.Seed, record.Nonce, record.TierOdds, record.Tier, record.Candidates, record.PickIndex, record.GeneralID)
+		audit.RecordID = record.ID
+		audit.UserID = record.UserID
+		audit.DrawType = record.DrawType
+		verification.Records = append(verification.Records, audit)
+	}
+	for _, reroll := range rerolls {
+		audit := auditDraw(seed.Seed, reroll.Nonce, reroll.TierOdds, reroll.Tier, reroll.Candidates, reroll.PickIndex, reroll.GeneralID)
+		audit.RecordID = reroll.ID
+		audit.Rerolled = true
+		audit.Reason = reroll.Reason
+		audit.UserID = reroll.UserID
+		audit.DrawType = reroll.DrawType
+		verification.Records = append(verification.Records, audit)
+	}
+	sort.SliceStable(verification.Records, func(i, j int) bool {
+		return verification.Records[i].Nonce < verification.Records[j].Nonce
+	})
+	verification.MissingNonces = missingDrawNonces(verification.Records, seed.DrawCount)
+
+	verification.Valid = verification.HashValid && len(verification.MissingNonces) == 0
+	for _, audit := range verification.Records {
+		verification.Valid = verification.Valid && audit.Valid
+	}
+	return verification, nil
+}
+
+// missingDrawNonces returns the nonces in 1..drawCount that no audited draw used
+// Every nonce a seed hands out is kept as a record or a re-roll, so a gap means a draw was removed.
+func missingDrawNonces(audits []DrawAudit, drawCount uint64) []uint64 {
+	used := make(map[uint64]bool, len(audits))
+	for _, audit := range audits {
+		used[audit.Nonce] = true
+	}
+	missing := []uint64{}
+	for nonce := uint64(1); nonce <= drawCount; nonce++ {
+		if !used[nonce] {
+			missing = append(missing, nonce)
+		}
+	}
+	return missing
+}
+
+// auditDraw re-computes one roll from the revealed seed
+func auditDraw(seed string, nonce uint64, tierOdds string, tier int, candidates string, pickIndex int, generalID uint) DrawAudit {
+	audit := DrawAudit{
+		Nonce:      nonce,
+		Tier:       tier,
+		TierOdds:   []TierOdds{},
+		Candidates: []uint{},
+		PickIndex:  pickIndex,
+		GeneralID:  generalID,
+	}
+	json.Unmarshal([]byte(candidates), &audit.Candidates)
+	json.Unmarshal([]byte(tierOdds), &audit.TierOdds)
+
+	tierValid := true
+	if len(audit.TierOdds) > 0 {
+		audit.ExpectedTier = rollTier(seed, nonce, audit.TierOdds)
+		tierValid = audit.ExpectedTier == tier
+	}
+	if len(audit.Candidates) > 0 {
+		audit.ExpectedIndex = drawRoll(seed, drawIndexMessage(nonce), len(audit.Candidates))
+		audit.ExpectedGeneralID = audit.Candidates[audit.ExpectedIndex]
+		audit.Valid = tierValid && audit.ExpectedIndex == pickIndex && audit.ExpectedGeneralID == generalID
+	}
+	return audit
+}
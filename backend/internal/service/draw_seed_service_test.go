@@ -0,0 +1,228 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"san11-trade/internal/model"
+)
+
+const testDrawSeed = "0123456789abcdef0123456789abcdef"
+
+func TestDrawRoll(t *testing.T) {
+	for _, n := range []int{1, 2, 7, 100} {
+		for nonce := uint64(1); nonce <= 50; nonce++ {
+			message := drawIndexMessage(nonce)
+			got := drawRoll(testDrawSeed, message, n)
+			if got < 0 || got >= n {
+				t.Fatalf("drawRoll(%q, %d) = %d, out of range", message, n, got)
+			}
+			if again := drawRoll(testDrawSeed, message, n); again != got {
+				t.Fatalf("drawRoll(%q, %d) = %d then %d, want the same roll", message, n, got, again)
+			}
+		}
+	}
+
+	// Index and tier rolls of one nonce use different messages
+	differ := false
+	for nonce := uint64(1); nonce <= 20; nonce++ {
+		if drawRoll(testDrawSeed, drawIndexMessage(nonce), 1000) != drawRoll(testDrawSeed, drawTierMessage(nonce), 1000) {
+			differ = true
+		}
+	}
+	if !differ {
+		t.Errorf("index and tier rolls never differ")
+	}
+	if drawRoll(testDrawSeed, "1", 1<<30) == drawRoll("another seed", "1", 1<<30) {
+		t.Errorf("different seeds gave the same roll")
+	}
+}
+
+func TestRollTier(t *testing.T) {
+	tests := []struct {
+		name string
+		odds []TierOdds
+		want map[int]bool // Tiers the roll may land on
+	}{
+		{"no odds", nil, map[int]bool{0: true}},
+		{"zero weights", []TierOdds{{Tier: 1}, {Tier: 2}}, map[int]bool{0: true}},
+		{"single tier", []TierOdds{{Tier: 3, Weight: 5}}, map[int]bool{3: true}},
+		{"zero weight tier is never rolled", []TierOdds{{Tier: 1, Weight: 0}, {Tier: 2, Weight: 4}, {Tier: 3, Weight: 0}}, map[int]bool{2: true}},
+		{"weighted tiers", []TierOdds{{Tier: 1, Weight: 1}, {Tier: 2, Weight: 3}, {Tier: 4, Weight: 6}}, map[int]bool{1: true, 2: true, 4: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for nonce := uint64(1); nonce <= 200; nonce++ {
+				if got := rollTier(testDrawSeed, nonce, tt.odds); !tt.want[got] {
+					t.Fatalf("rollTier(nonce %d) = %d, want one of %v", nonce, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRollTierFollowsWeights(t *testing.T) {
+	odds := []TierOdds{{Tier: 1, Weight: 1}, {Tier: 2, Weight: 3}}
+	counts := map[int]int{}
+	const draws = 4000
+	for nonce := uint64(1); nonce <= draws; nonce++ {
+		tier := rollTier(testDrawSeed, nonce, odds)
+		counts[tier]++
+
+		// The documented walk: subtract weights from the roll until it falls inside a tier
+		roll := drawRoll(testDrawSeed, drawTierMessage(nonce), 4)
+		want := 1
+		if roll >= 1 {
+			want = 2
+		}
+		if tier != want {
+			t.Fatalf("rollTier(nonce %d) = %d, want %d for roll %d", nonce, tier, want, roll)
+		}
+	}
+	if share := float64(counts[1]) / draws; share < 0.2 || share > 0.3 {
+		t.Errorf("tier 1 share = %.3f, want about 0.25", share)
+	}
+}
+
+func TestAuditDraw(t *testing.T) {
+	plan := &drawPlan{
+		Generals: map[int][]model.General{
+			1: {{ID: 3}, {ID: 8}},
+			2: {{ID: 1}, {ID: 4}, {ID: 5}, {ID: 9}},
+		},
+		Odds: []TierOdds{{Tier: 1, Weight: 1}, {Tier: 2, Weight: 2}},
+	}
+	seed := &model.DrawSeed{Seed: testDrawSeed}
+	const nonce = 7
+	pick := rollDraw(seed, nonce, plan)
+	otherTier := 1
+	if pick.Tier == 1 {
+		otherTier = 2
+	}
+
+	tests := []struct {
+		name       string
+		seed       string
+		tierOdds   string
+		tier       int
+		candidates string
+		index      int
+		generalID  uint
+		want       bool
+	}{
+		{"recorded pick", testDrawSeed, pick.TierOdds, pick.Tier, pick.Candidates, pick.Index, pick.General.ID, true},
+		{"other seed", "another seed", pick.TierOdds, pick.Tier, pick.Candidates, pick.Index, pick.General.ID, false},
+		{"other tier", testDrawSeed, pick.TierOdds, otherTier, pick.Candidates, pick.Index, pick.General.ID, false},
+		{"other index", testDrawSeed, pick.TierOdds, pick.Tier, pick.Candidates, (pick.Index + 1) % len(plan.Generals[pick.Tier]), pick.General.ID, false},
+		{"other general", testDrawSeed, pick.TierOdds, pick.Tier, pick.Candidates, pick.Index, 99, false},
+		{"untiered record only checks the index", testDrawSeed, "", 0, pick.Candidates, pick.Index, pick.General.ID, true},
+		{"no candidates", testDrawSeed, pick.TierOdds, pick.Tier, "", pick.Index, pick.General.ID, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audit := auditDraw(tt.seed, nonce, tt.tierOdds, tt.tier, tt.candidates, tt.index, tt.generalID)
+			if audit.Valid != tt.want {
+				t.Errorf("Valid = %v, want %v (%+v)", audit.Valid, tt.want, audit)
+			}
+		})
+	}
+
+	audit := auditDraw(testDrawSeed, nonce, pick.TierOdds, pick.Tier, pick.Candidates, pick.Index, pick.General.ID)
+	if audit.ExpectedTier != pick.Tier || audit.ExpectedIndex != pick.Index || audit.ExpectedGeneralID != pick.General.ID {
+		t.Errorf("audit expected tier %d index %d general %d, want %d %d %d",
+			audit.ExpectedTier, audit.ExpectedIndex, audit.ExpectedGeneralID, pick.Tier, pick.Index, pick.General.ID)
+	}
+	if !reflect.DeepEqual(audit.TierOdds, plan.Odds) {
+		t.Errorf("audit tier odds = %+v, want %+v", audit.TierOdds, plan.Odds)
+	}
+}
+
+func TestMissingDrawNonces(t *testing.T) {
+	audits := func(nonces ...uint64) []DrawAudit {
+		list := make([]DrawAudit, 0, len(nonces))
+		for _, nonce := range nonces {
+			list = append(list, DrawAudit{Nonce: nonce})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name      string
+		audits    []DrawAudit
+		drawCount uint64
+		want      []uint64
+	}{
+		{"no draws", nil, 0, []uint64{}},
+		{"every nonce used", audits(1, 2, 3), 3, []uint64{}},
+		{"audits out of nonce order", audits(3, 1, 2, 4), 4, []uint64{}},
+		{"deleted draws", audits(1, 4), 5, []uint64{2, 3, 5}},
+		{"nothing recorded", nil, 2, []uint64{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingDrawNonces(tt.audits, tt.drawCount); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingDrawNonces = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyDrawSeedAfterSeasonReset(t *testing.T) {
+	db := setupTestDB(t)
+
+	users := []*model.User{createTestUser(t, db, "player1"), createTestUser(t, db, "player2")}
+	for id := uint(1); id <= 20; id++ {
+		pool := DrawPoolGuarantee
+		if id > 6 {
+			pool = DrawPoolNormal
+		}
+		createTestGeneral(t, db, id, pool, 10)
+	}
+	if err := SetGamePhase("draw", 1, 0); err != nil {
+		t.Fatalf("SetGamePhase: %v", err)
+	}
+
+	draws := 0
+	for _, user := range users {
+		for i := 0; i < 2; i++ {
+			if _, _, err := Draw(user.ID); err != nil {
+				t.Fatalf("Draw(%d): %v", user.ID, err)
+			}
+			draws++
+		}
+	}
+	seed, err := currentDrawSeed()
+	if err != nil {
+		t.Fatalf("currentDrawSeed: %v", err)
+	}
+
+	if err := ResetSeason(); err != nil {
+		t.Fatalf("ResetSeason: %v", err)
+	}
+
+	verification, err := VerifyDrawSeed(seed.ID)
+	if err != nil {
+		t.Fatalf("VerifyDrawSeed: %v", err)
+	}
+	if !verification.Valid {
+		t.Errorf("seed of the reset season is not valid: missing nonces %v", verification.MissingNonces)
+	}
+	if len(verification.Records) != draws {
+		t.Errorf("verified %d draws, want %d", len(verification.Records), draws)
+	}
+
+	// The reset draws no longer count for the players
+	for _, user := range users {
+		for _, pool := range []string{DrawPoolGuarantee, DrawPoolNormal} {
+			if count, err := GetDrawCount(user.ID, pool); err != nil || count != 0 {
+				t.Errorf("GetDrawCount(%d, %s) = %d, %v, want 0", user.ID, pool, count, err)
+			}
+		}
+		if records, err := GetUserDrawRecords(user.ID); err != nil || len(records) != 0 {
+			t.Errorf("GetUserDrawRecords(%d) = %d records, %v, want none", user.ID, len(records), err)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -35,6 +37,19 @@ func GetDraftPool() ([]model.General, error) {
 
 // DraftPick performs a draft pick for a user
 func DraftPick(userID uint, generalID uint) (*model.General, error) {
+	general, err := draftPick(userID, generalID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// The next players may be on auto-draft
+	processAutoDrafts()
+
+	return general, nil
+}
+
+// draftPick performs a pick for the user on the clock; auto marks queue/timeout picks
+func draftPick(userID uint, generalID uint, auto bool) (*model.General, error) {
 	db := database.GetDB()
 
 	// Check phase
@@ -45,6 +60,14 @@ func DraftPick(userID uint, generalID uint) (*model.General, error) {
 	if phase.CurrentPhase != "draft" {
 		return nil, ErrNotInDraftPhase
 	}
+	if phase.DraftStatus != DraftStatusRunning {
+		return nil, ErrDraftNotRunning
+	}
+
+	// Check whose turn it is
+	if currentDraftPicker(phase) != userID {
+		return nil, ErrNotYourTurn
+	}
 
 	// Get user
 	var user model.User
@@ -72,45 +95,89 @@ func DraftPick(userID uint, generalID uint) (*model.General, error) {
 	// Begin transaction
 	tx := db.Begin()
 
-	// Assign general to user
-	if err := tx.Model(&general).Updates(map[string]interface{}{
+	// Assign general to user (only if still unowned)
+	result := tx.Model(&model.General{}).Where("id = ? AND owner_id IS NULL", general.ID).Updates(map[string]interface{}{
 		"owner_id":     userID,
 		"is_available": false,
-	}).Error; err != nil {
+		"version":      gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
 		tx.Rollback()
-		return nil, err
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrGeneralNotAvailable
 	}
 
-	// Update user's used space
-	if err := tx.Model(&user).Update("used_space", user.UsedSpace+general.Salary).Error; err != nil {
+	// Charge the salary only if it still fits; a trade or pick since the check may have used the space
+	result = tx.Model(&model.User{}).Where("id = ? AND used_space + ? <= space", userID, general.Salary).
+		UpdateColumn("used_space", gorm.Expr("used_space + ?", general.Salary))
+	if result.Error != nil {
 		tx.Rollback()
-		return nil, err
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrInsufficientSpace
 	}
 
 	// Record the draft
 	record := model.DraftRecord{
-		UserID:    userID,
-		GeneralID: general.ID,
-		Round:     phase.DraftRound,
+		UserID:     userID,
+		GeneralID:  general.ID,
+		Round:      phase.DraftRound,
+		Pick:       phase.DraftPick,
+		AutoPicked: auto,
 	}
 	if err := tx.Create(&record).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
 
+	// Nobody can pick this general any more; drop it from wish-lists
+	if err := tx.Where("general_id = ?", general.ID).Delete(&model.DraftQueue{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	effects, err := applyClubEffects(tx, userID)
+	if err == nil {
+		err = checkClubEffectsCap(effects)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Put the next picker on the clock
+	if err := advanceDraft(tx, phase); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, err
 	}
+	publishClubEffects(effects)
+
+	PublishEvent(EventDraftPicked, map[string]interface{}{
+		"user_id":     userID,
+		"general":     general,
+		"round":       record.Round,
+		"pick":        record.Pick,
+		"auto_picked": record.AutoPicked,
+	})
 
 	return &general, nil
 }
 
-// GetUserDrawRecords returns draw records for a user
+// GetUserDrawRecords returns draw records for a user, leaving out reset ones
 func GetUserDrawRecords(userID uint) ([]model.DrawRecord, error) {
 	db := database.GetDB()
 
 	var records []model.DrawRecord
-	if err := db.Where("user_id = ?", userID).Preload("General").Find(&records).Error; err != nil {
+	if err := db.Where("user_id = ? AND status <> ?", userID, "reset").Preload("General").Find(&records).Error; err != nil {
 		return nil, err
 	}
 
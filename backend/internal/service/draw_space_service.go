@@ -0,0 +1,203 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// ResolveOverCapDraws settles every player whose over-cap window has closed
+// A player back under the cap just has the window cleared; otherwise their newest
+// over-cap draws go back to the draw pool, refunded, until they fit again. A player
+// still over the cap after that (e.g. they traded for salary in the window) keeps
+// the deadline and is escalated to the admin.
+func ResolveOverCapDraws() (int, error) {
+	db := database.GetDB()
+
+	var users []model.User
+	if err := db.Where("over_cap_deadline IS NOT NULL").Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	resolved := 0
+	now := time.Now()
+	for i := range users {
+		user := &users[i]
+		overCap := user.UsedSpace > user.Space
+		if overCap && (user.OverCapEscalated || user.OverCapDeadline.After(now)) {
+			continue
+		}
+		if overCap {
+			if err := voidOverCapDraws(user); err != nil {
+				return resolved, err
+			}
+			if err := db.First(user, user.ID).Error; err != nil {
+				return resolved, err
+			}
+			if user.UsedSpace > user.Space {
+				if err := escalateOverCap(user); err != nil {
+					return resolved, err
+				}
+				continue
+			}
+		}
+		if err := db.Model(&model.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"over_cap_deadline":  nil,
+			"over_cap_escalated": false,
+		}).Error; err != nil {
+			return resolved, err
+		}
+		resolved++
+	}
+	return resolved, nil
+}
+
+// escalateOverCap flags a player who is still over the cap for the admin
+// The deadline is kept, so the player may still release generals in any phase.
+func escalateOverCap(user *model.User) error {
+	db := database.GetDB()
+
+	result := db.Model(&model.User{}).Where("id = ? AND over_cap_escalated = ?", user.ID, false).
+		Update("over_cap_escalated", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	log.Printf("User %d is still over the cap (%d/%d) after their over-cap window", user.ID, user.UsedSpace, user.Space)
+
+	var adminIDs []uint
+	if err := db.Model(&model.User{}).Where("is_admin = ?", true).Pluck("id", &adminIDs).Error; err != nil {
+		return err
+	}
+	PublishEvent(EventDrawOverCap, map[string]interface{}{
+		"user_id":    user.ID,
+		"used_space": user.UsedSpace,
+		"space":      user.Space,
+		"deadline":   user.OverCapDeadline,
+	}, append(adminIDs, user.ID)...)
+	return nil
+}
+
+// voidOverCapDraws returns a player's newest over-cap draws to their pool until they fit
+// Generals the player no longer holds are skipped.
+func voidOverCapDraws(user *model.User) error {
+	db := database.GetDB()
+
+	var records []model.DrawRecord
+	if err := db.Where("user_id = ? AND over_cap = ? AND status = ?", user.ID, true, "drawn").
+		Preload("General").Order("id desc").Find(&records).Error; err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+
+	usedSpace := user.UsedSpace
+	voided := make([]model.DrawRecord, 0, len(records))
+	for _, record := range records {
+		if usedSpace <= user.Space {
+			break
+		}
+
+		result := tx.Model(&model.General{}).Where("id = ? AND owner_id = ?", record.GeneralID, user.ID).
+			Updates(map[string]interface{}{
+				"owner_id":     nil,
+				"is_available": true,
+				"version":      gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		if err := tx.Model(&model.User{}).Where("id = ?", user.ID).
+			Update("used_space", gorm.Expr("used_space - ?", record.General.Salary)).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Model(&model.DrawRecord{}).Where("id = ?", record.ID).Update("status", "voided").Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		usedSpace -= record.General.Salary
+		record.Status = "voided"
+		voided = append(voided, record)
+	}
+
+	var effects []clubEffectsUpdate
+	if len(voided) > 0 {
+		var err error
+		if effects, err = applyClubEffects(tx, user.ID); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	if len(voided) == 0 {
+		return nil
+	}
+	publishClubEffects(effects)
+
+	PublishEvent(EventDrawVoided, map[string]interface{}{
+		"user_id": user.ID,
+		"records": voided,
+	})
+	return nil
+}
+
+// clearOverCapWindows ends the over-cap window of players who fit their space again,
+// escalated players included; nil userIDs checks every player
+// It runs in the transaction of every roster, 国策 or space change, so a player
+// who releases or trades away enough salary is cleared without the scheduler.
+func clearOverCapWindows(tx *gorm.DB, userIDs []uint) error {
+	query := tx.Model(&model.User{}).Where("over_cap_deadline IS NOT NULL AND used_space <= space")
+	if userIDs != nil {
+		query = query.Where("id IN ?", userIDs)
+	}
+	return query.Updates(map[string]interface{}{
+		"over_cap_deadline":  nil,
+		"over_cap_escalated": false,
+	}).Error
+}
+
+// NextOverCapDeadline returns when the earliest over-cap window closes
+func NextOverCapDeadline() (*time.Time, error) {
+	db := database.GetDB()
+
+	// Escalated players wait for the admin, or are cleared once they fit again
+	var user model.User
+	err := db.Where("over_cap_deadline IS NOT NULL AND over_cap_escalated = ?", false).
+		Order("over_cap_deadline ASC").
+		Limit(1).
+		Find(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, nil
+	}
+	return user.OverCapDeadline, nil
+}
+
+// GetDrawRerolls returns the logged re-rolls, newest first
+// Re-rolls of earlier seasons stay so their seeds can still be verified.
+func GetDrawRerolls() ([]model.DrawReroll, error) {
+	db := database.GetDB()
+
+	var rerolls []model.DrawReroll
+	if err := db.Preload("User").Preload("General").Order("id desc").Find(&rerolls).Error; err != nil {
+		return nil, err
+	}
+	return rerolls, nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// reloadTestUser reads a player afresh; scanning into a used struct keeps stale pointers
+func reloadTestUser(t *testing.T, db *gorm.DB, userID uint) *model.User {
+	t.Helper()
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		t.Fatalf("reload user %d: %v", userID, err)
+	}
+	return &user
+}
+
+func TestResolveOverCapDraws(t *testing.T) {
+	db := setupTestDB(t)
+	if err := SetGamePhase("draw", 1, 0); err != nil {
+		t.Fatalf("SetGamePhase: %v", err)
+	}
+
+	// 380 signed plus a 100 draw against 350 space
+	user := createTestUser(t, db, "player1")
+	signed := createTestGeneral(t, db, 1, "draft", 380)
+	drawn := createTestGeneral(t, db, 2, DrawPoolNormal, 100)
+	giveTestGeneral(t, db, signed, user)
+	giveTestGeneral(t, db, drawn, user)
+	record := model.DrawRecord{UserID: user.ID, GeneralID: drawn.ID, DrawType: DrawPoolNormal, Status: "drawn", OverCap: true}
+	db.Create(&record)
+	db.Model(user).Update("over_cap_deadline", time.Now().Add(-time.Minute))
+
+	if resolved, err := ResolveOverCapDraws(); err != nil || resolved != 0 {
+		t.Fatalf("ResolveOverCapDraws = %d, %v, want 0 resolved", resolved, err)
+	}
+
+	// The draw went back to the pool and gave its slot back
+	db.First(&record, record.ID)
+	if record.Status != "voided" {
+		t.Errorf("draw record is %s, want voided", record.Status)
+	}
+	if count, err := GetDrawCount(user.ID, DrawPoolNormal); err != nil || count != 0 {
+		t.Errorf("GetDrawCount = %d, %v, want the voided draw not to count", count, err)
+	}
+
+	// Still over the cap without the draw: escalated and left to the admin
+	user = reloadTestUser(t, db, user.ID)
+	if user.UsedSpace != signed.Salary || !user.OverCapEscalated || user.OverCapDeadline == nil {
+		t.Fatalf("user used %d escalated %v deadline %v, want %d, escalated with the deadline kept",
+			user.UsedSpace, user.OverCapEscalated, user.OverCapDeadline, signed.Salary)
+	}
+	if next, err := NextOverCapDeadline(); err != nil || next != nil {
+		t.Errorf("NextOverCapDeadline = %v, %v, want nothing scheduled for an escalated player", next, err)
+	}
+
+	// Releasing the general brings the player back under the cap and ends the window
+	if _, err := ReleaseGeneral(user.ID, signed.ID); err != nil {
+		t.Fatalf("ReleaseGeneral: %v", err)
+	}
+	user = reloadTestUser(t, db, user.ID)
+	if user.OverCapEscalated || user.OverCapDeadline != nil {
+		t.Errorf("user escalated %v deadline %v after getting under the cap, want both cleared", user.OverCapEscalated, user.OverCapDeadline)
+	}
+}
+
+func TestOverCapWindowClearedOnlyOnceUnderCap(t *testing.T) {
+	db := setupTestDB(t)
+	if err := SetGamePhase("draw", 1, 0); err != nil {
+		t.Fatalf("SetGamePhase: %v", err)
+	}
+
+	// 500 used against 350 space
+	user := createTestUser(t, db, "player1")
+	generals := []*model.General{
+		createTestGeneral(t, db, 1, "draft", 300),
+		createTestGeneral(t, db, 2, "draft", 100),
+		createTestGeneral(t, db, 3, "draft", 100),
+	}
+	for _, general := range generals {
+		giveTestGeneral(t, db, general, user)
+	}
+	db.Model(user).Updates(map[string]interface{}{"over_cap_deadline": time.Now().Add(time.Hour), "over_cap_escalated": true})
+
+	// 400 used is still over the cap
+	if _, err := ReleaseGeneral(user.ID, generals[1].ID); err != nil {
+		t.Fatalf("ReleaseGeneral: %v", err)
+	}
+	if user = reloadTestUser(t, db, user.ID); user.OverCapDeadline == nil || !user.OverCapEscalated {
+		t.Errorf("window cleared at %d/%d, want it kept while over the cap", user.UsedSpace, user.Space)
+	}
+
+	// 300 used fits
+	if _, err := ReleaseGeneral(user.ID, generals[2].ID); err != nil {
+		t.Fatalf("ReleaseGeneral: %v", err)
+	}
+	if user = reloadTestUser(t, db, user.ID); user.OverCapDeadline != nil || user.OverCapEscalated {
+		t.Errorf("window kept at %d/%d, want it cleared", user.UsedSpace, user.Space)
+	}
+}
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// General tiers run from 1 (best) to 5
+const (
+	MinGeneralTier = 1
+	MaxGeneralTier = 5
+)
+
+var (
+	ErrInvalidDrawTable = errors.New("invalid draw table")
+	ErrDrawTablesLocked = errors.New("draw odds can only be changed during signup")
+	ErrDrawTierCapped   = errors.New("every tier left in this pool is capped for the player")
+)
+
+// DrawTableRequest sets the tier odds of one draw pool
+type DrawTableRequest struct {
+	PoolType  string      `json:"pool_type" binding:"required"`
+	Weights   map[int]int `json:"weights"` // Tier -> weight; empty draws every general with equal chance
+	Caps      map[int]int `json:"caps"`    // Tier -> most generals of that tier one player draws from the pool
+	PityTier  int         `json:"pity_tier"`
+	PityAfter int         `json:"pity_after"`
+}
+
+// TierOdds is the chance of one tier on a draw from a pool
+type TierOdds struct {
+	Tier        int     `json:"tier"`
+	Weight      int     `json:"weight"`
+	Available   int     `json:"available"`   // Generals of the tier left in the pool
+	Probability float64 `json:"probability"` // Chance the tier is rolled
+	PerGeneral  float64 `json:"per_general"` // Chance of each general of the tier
+}
+
+// DrawTablePreview is what a player without earlier draws would draw a pool with
+type DrawTablePreview struct {
+	Table     model.DrawTable `json:"table"`
+	Available int             `json:"available"`
+	Odds      []TierOdds      `json:"odds"`
+	PityOdds  []TierOdds      `json:"pity_odds"` // Odds once the pity counter triggers
+	Warnings  []string        `json:"warnings"`
+}
+
+// drawFilter narrows the generals a draw is planned with
+type drawFilter struct {
+	forcePity bool          // Plan as if the pity counter had triggered
+	maxSalary int           // Leave out generals above this salary, -1 = no limit
+	exclude   map[uint]bool // Picks already thrown back by a re-roll
+}
+
+// noDrawFilter plans with every available general
+var noDrawFilter = drawFilter{maxSalary: -1}
+
+// drawPlan is everything one draw from a pool rolls against
+type drawPlan struct {
+	PoolType     string
+	Available    int
+	Unaffordable int                     // Generals left out for being above the salary limit
+	Generals     map[int][]model.General // Available generals by tier, sorted by ID
+	Odds         []TierOdds
+	TotalWeight  int
+	Pity         int // Draws here since the last PityTier-or-better general
+	PityActive   bool
+}
+
+// getDrawTable gets or creates the draw table of a pool
+func getDrawTable(poolType string) (*model.DrawTable, error) {
+	db := database.GetDB()
+	var table model.DrawTable
+	if err := db.Where("pool_type = ?", poolType).First(&table).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Create a uniform table
+			table = model.DrawTable{PoolType: poolType}
+			if err := db.Create(&table).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+
+	table.Weights = map[int]int{}
+	table.Caps = map[int]int{}
+	if table.TierWeights != "" {
+		json.Unmarshal([]byte(table.TierWeights), &table.Weights)
+	}
+	if table.TierCaps != "" {
+		json.Unmarshal([]byte(table.TierCaps), &table.Caps)
+	}
+	return &table, nil
+}
+
+// GetDrawTables returns the draw table of every initial draw pool
+func GetDrawTables() ([]model.DrawTable, error) {
+	tables := make([]model.DrawTable, 0, 2)
+	for _, pool := range []string{DrawPoolGuarantee, DrawPoolNormal} {
+		table, err := getDrawTable(pool)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, *table)
+	}
+	return tables, nil
+}
+
+// UpdateDrawTable sets the tier odds of a draw pool (admin only)
+// Odds are frozen once signup closes so every player draws under the same table.
+func UpdateDrawTable(req *DrawTableRequest) (*model.DrawTable, error) {
+	db := database.GetDB()
+
+	if err := checkDrawTablesOpen(); err != nil {
+		return nil, err
+	}
+	if req.PoolType != DrawPoolGuarantee && req.PoolType != DrawPoolNormal {
+		return nil, ErrInvalidDrawTable
+	}
+	for tier, weight := range req.Weights {
+		if tier < MinGeneralTier || tier > MaxGeneralTier || weight < 0 {
+			return nil, ErrInvalidDrawTable
+		}
+	}
+	for tier, limit := range req.Caps {
+		if tier < MinGeneralTier || tier > MaxGeneralTier || limit < 0 {
+			return nil, ErrInvalidDrawTable
+		}
+	}
+	if req.PityTier < 0 || req.PityTier > MaxGeneralTier || req.PityAfter < 0 {
+		return nil, ErrInvalidDrawTable
+	}
+	if (req.PityTier > 0) != (req.PityAfter > 0) {
+		return nil, ErrInvalidDrawTable
+	}
+
+	table, err := getDrawTable(req.PoolType)
+	if err != nil {
+		return nil, err
+	}
+	table.Weights = map[int]int{}
+	for tier, weight := range req.Weights {
+		if weight > 0 {
+			table.Weights[tier] = weight
+		}
+	}
+	table.Caps = map[int]int{}
+	for tier, limit := range req.Caps {
+		table.Caps[tier] = limit
+	}
+	weights, _ := json.Marshal(table.Weights)
+	caps, _ := json.Marshal(table.Caps)
+	table.TierWeights = string(weights)
+	table.TierCaps = string(caps)
+	table.PityTier = req.PityTier
+	table.PityAfter = req.PityAfter
+
+	if err := db.Save(table).Error; err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// SetGeneralTiers changes the tier of draw pool generals (admin only)
+func SetGeneralTiers(tiers map[uint]int) (int, error) {
+	db := database.GetDB()
+
+	if err := checkDrawTablesOpen(); err != nil {
+		return 0, err
+	}
+	for _, tier := range tiers {
+		if tier < MinGeneralTier || tier > MaxGeneralTier {
+			return 0, ErrInvalidDrawTable
+		}
+	}
+
+	tx := db.Begin()
+	updated := 0
+	for generalID, tier := range tiers {
+		result := tx.Model(&model.General{}).Where("id = ?", generalID).Update("tier", tier)
+		if result.Error != nil {
+			tx.Rollback()
+			return 0, result.Error
+		}
+		updated += int(result.RowsAffected)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// checkDrawTablesOpen rejects odds changes once the season is past signup
+func checkDrawTablesOpen() error {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+	if phase.CurrentPhase != "signup" {
+		return ErrDrawTablesLocked
+	}
+	return nil
+}
+
+// PreviewDrawTables shows the odds of every pool before the draw phase opens (admin only)
+func PreviewDrawTables() ([]DrawTablePreview, error) {
+	tables, err := GetDrawTables()
+	if err != nil {
+		return nil, err
+	}
+
+	previews := make([]DrawTablePreview, 0, len(tables))
+	for i := range tables {
+		table := &tables[i]
+		plan, err := planDraw(0, table, noDrawFilter)
+		if err != nil {
+			return nil, err
+		}
+		preview := DrawTablePreview{
+			Table:     *table,
+			Available: plan.Available,
+			Odds:      plan.Odds,
+			PityOdds:  []TierOdds{},
+			Warnings:  []string{},
+		}
+		if table.PityTier > 0 {
+			pityPlan, err := planDraw(0, table, drawFilter{forcePity: true, maxSalary: -1})
+			if err != nil {
+				return nil, err
+			}
+			preview.PityOdds = pityPlan.Odds
+			if !pityPlan.PityActive {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("no generals of tier %d or better left for the pity draw", table.PityTier))
+			}
+		}
+
+		if plan.Available == 0 {
+			preview.Warnings = append(preview.Warnings, "pool is empty")
+		}
+		for _, tier := range weightTiers(table.Weights) {
+			if len(plan.Generals[tier]) == 0 {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("tier %d has weight %d but no generals", tier, table.Weights[tier]))
+			}
+		}
+		if len(table.Weights) > 0 {
+			for _, tier := range generalTiers(plan.Generals) {
+				if table.Weights[tier] == 0 {
+					preview.Warnings = append(preview.Warnings, fmt.Sprintf("%d generals of tier %d are only drawn once the weighted tiers run out", len(plan.Generals[tier]), tier))
+				}
+			}
+		}
+		previews = append(previews, preview)
+	}
+	return previews, nil
+}
+
+// planDraw works out the tier odds of a player's next draw from a pool
+// Tiers the player has capped are left out and a triggered pity counter keeps only
+// PityTier or better. When none of the weighted tiers is left, every remaining
+// general is equally likely so the pool never jams. userID 0 plans for a player
+// without draws.
+func planDraw(userID uint, table *model.DrawTable, filter drawFilter) (*drawPlan, error) {
+	db := database.GetDB()
+
+	var generals []model.General
+	if err := db.Where("pool_type = ? AND is_available = ? AND owner_id IS NULL", table.PoolType, true).
+		Order("id asc").Find(&generals).Error; err != nil {
+		return nil, err
+	}
+
+	plan := &drawPlan{
+		PoolType: table.PoolType,
+		Generals: map[int][]model.General{},
+		Odds:     []TierOdds{},
+	}
+	for _, general := range generals {
+		if filter.exclude[general.ID] {
+			continue
+		}
+		if filter.maxSalary >= 0 && general.Salary > filter.maxSalary {
+			plan.Unaffordable++
+			continue
+		}
+		plan.Generals[general.Tier] = append(plan.Generals[general.Tier], general)
+		plan.Available++
+	}
+
+	// Earlier draws of the player here drive the caps and the pity counter
+	drawn := map[int]int{}
+	if userID != 0 {
+		var records []model.DrawRecord
+		if err := db.Select("tier").Where("user_id = ? AND draw_type = ? AND status NOT IN ?", userID, table.PoolType, releasedDrawStatuses).
+			Order("id asc").Find(&records).Error; err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			drawn[record.Tier]++
+			if table.PityTier > 0 && record.Tier <= table.PityTier {
+				plan.Pity = 0
+			} else {
+				plan.Pity++
+			}
+		}
+	}
+
+	eligible := []int{}
+	for _, tier := range generalTiers(plan.Generals) {
+		if limit, ok := table.Caps[tier]; ok && drawn[tier] >= limit {
+			continue
+		}
+		eligible = append(eligible, tier)
+	}
+
+	if table.PityTier > 0 && (filter.forcePity || (table.PityAfter > 0 && plan.Pity >= table.PityAfter)) {
+		pityTiers := []int{}
+		for _, tier := range eligible {
+			if tier <= table.PityTier {
+				pityTiers = append(pityTiers, tier)
+			}
+		}
+		if len(pityTiers) > 0 {
+			eligible = pityTiers
+			plan.PityActive = true
+		}
+	}
+
+	weightOf := func(tier int) int { return table.Weights[tier] }
+	configured := 0
+	for _, tier := range eligible {
+		configured += weightOf(tier)
+	}
+	if configured == 0 {
+		weightOf = func(tier int) int { return len(plan.Generals[tier]) }
+	}
+
+	for _, tier := range eligible {
+		if weight := weightOf(tier); weight > 0 {
+			plan.Odds = append(plan.Odds, TierOdds{
+				Tier:      tier,
+				Weight:    weight,
+				Available: len(plan.Generals[tier]),
+			})
+			plan.TotalWeight += weight
+		}
+	}
+	for i := range plan.Odds {
+		odds := &plan.Odds[i]
+		odds.Probability = float64(odds.Weight) / float64(plan.TotalWeight)
+		odds.PerGeneral = odds.Probability / float64(odds.Available)
+	}
+	return plan, nil
+}
+
+// drawPick is one roll of a draw with what is needed to audit it
+type drawPick struct {
+	General    model.General
+	Tier       int
+	Index      int
+	Candidates string // JSON general IDs of the rolled tier, sorted by ID
+	TierOdds   string // JSON odds the tier was rolled from
+}
+
+// checkDrawPlan reports why a plan leaves nothing to draw
+func checkDrawPlan(plan *drawPlan) error {
+	switch {
+	case plan.Available == 0 && plan.Unaffordable > 0:
+		return ErrInsufficientSpace
+	case plan.Available == 0:
+		return ErrNoAvailableDrawGeneral
+	case len(plan.Odds) == 0:
+		return ErrDrawTierCapped
+	}
+	return nil
+}
+
+// planHasAffordable reports whether any general the plan can roll fits in space
+func planHasAffordable(plan *drawPlan, space int) bool {
+	for _, odds := range plan.Odds {
+		for _, general := range plan.Generals[odds.Tier] {
+			if general.Salary <= space {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rollDraw rolls the tier and then the general of a draw
+func rollDraw(seed *model.DrawSeed, nonce uint64, plan *drawPlan) *drawPick {
+	tier := rollTier(seed.Seed, nonce, plan.Odds)
+	generals := plan.Generals[tier]
+	index := drawRoll(seed.Seed, drawIndexMessage(nonce), len(generals))
+
+	candidateIDs := make([]uint, 0, len(generals))
+	for _, general := range generals {
+		candidateIDs = append(candidateIDs, general.ID)
+	}
+	candidates, _ := json.Marshal(candidateIDs)
+	tierOdds, _ := json.Marshal(plan.Odds)
+
+	return &drawPick{
+		General:    generals[index],
+		Tier:       tier,
+		Index:      index,
+		Candidates: string(candidates),
+		TierOdds:   string(tierOdds),
+	}
+}
+
+// rollTier picks the tier of a draw from its odds
+func rollTier(seed string, nonce uint64, odds []TierOdds) int {
+	total := 0
+	for _, tier := range odds {
+		total += tier.Weight
+	}
+	if total <= 0 {
+		return 0
+	}
+	roll := drawRoll(seed, drawTierMessage(nonce), total)
+	for _, tier := range odds {
+		if roll < tier.Weight {
+			return tier.Tier
+		}
+		roll -= tier.Weight
+	}
+	return odds[len(odds)-1].Tier
+}
+
+// generalTiers returns the tiers that have generals, in ascending order
+func generalTiers(byTier map[int][]model.General) []int {
+	tiers := make([]int, 0, len(byTier))
+	for tier := range byTier {
+		tiers = append(tiers, tier)
+	}
+	sort.Ints(tiers)
+	return tiers
+}
+
+// weightTiers returns the tiers of a weight or cap map, in ascending order
+func weightTiers(byTier map[int]int) []int {
+	tiers := make([]int, 0, len(byTier))
+	for tier := range byTier {
+		tiers = append(tiers, tier)
+	}
+	sort.Ints(tiers)
+	return tiers
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"san11-trade/internal/model"
+)
+
+func TestPlanDraw(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := model.User{Username: "player", Password: "x", Nickname: "player"}
+	db.Create(&user)
+
+	// Tier 1: 1-2, tier 2: 3-4, tier 3: 5-7; salary 10 * ID
+	// 8-10 are never drawable: taken, unavailable or in another pool
+	generals := []model.General{
+		{ID: 1, Tier: 1}, {ID: 2, Tier: 1}, {ID: 3, Tier: 2}, {ID: 4, Tier: 2},
+		{ID: 5, Tier: 3}, {ID: 6, Tier: 3}, {ID: 7, Tier: 3},
+		{ID: 8, Tier: 1, OwnerID: &user.ID}, {ID: 9, Tier: 1}, {ID: 10, Tier: 1, PoolType: "initial_guarantee"},
+	}
+	for _, general := range generals {
+		general.ExcelID = int(general.ID)
+		general.Name = "general"
+		general.Salary = 10 * int(general.ID)
+		general.IsAvailable = true
+		if general.PoolType == "" {
+			general.PoolType = "initial_normal"
+		}
+		if err := db.Create(&general).Error; err != nil {
+			t.Fatalf("create general %d: %v", general.ID, err)
+		}
+	}
+	db.Model(&model.General{}).Where("id = ?", 9).Update("is_available", false)
+
+	table := &model.DrawTable{
+		PoolType:  "initial_normal",
+		Weights:   map[int]int{1: 1, 2: 3, 3: 6},
+		Caps:      map[int]int{1: 1},
+		PityTier:  1,
+		PityAfter: 3,
+	}
+
+	type record struct {
+		tier     int
+		status   string
+		drawType string
+	}
+	tests := []struct {
+		name             string
+		table            *model.DrawTable
+		records          []record
+		filter           drawFilter
+		wantTiers        []int
+		wantWeights      []int
+		wantAvailable    int
+		wantUnaffordable int
+		wantPity         int
+		wantPityActive   bool
+	}{
+		{
+			name:          "no earlier draws",
+			table:         table,
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{1, 3, 6},
+			wantAvailable: 7,
+		},
+		{
+			name:          "capped tier is left out",
+			table:         table,
+			records:       []record{{tier: 1}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{2, 3},
+			wantWeights:   []int{3, 6},
+			wantAvailable: 7,
+		},
+		{
+			name:           "pity counter keeps only the pity tier",
+			table:          table,
+			records:        []record{{tier: 2}, {tier: 3}, {tier: 3}},
+			filter:         noDrawFilter,
+			wantTiers:      []int{1},
+			wantWeights:    []int{1},
+			wantAvailable:  7,
+			wantPity:       3,
+			wantPityActive: true,
+		},
+		{
+			name:          "pity counter restarts after a pity tier draw",
+			table:         &model.DrawTable{PoolType: "initial_normal", Weights: table.Weights, PityTier: 1, PityAfter: 3},
+			records:       []record{{tier: 3}, {tier: 3}, {tier: 1}, {tier: 2}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{1, 3, 6},
+			wantAvailable: 7,
+			wantPity:      1,
+		},
+		{
+			name:          "capped pity tier does not jam the pool",
+			table:         table,
+			records:       []record{{tier: 1}, {tier: 3}, {tier: 3}, {tier: 3}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{2, 3},
+			wantWeights:   []int{3, 6},
+			wantAvailable: 7,
+			wantPity:      3,
+		},
+		{
+			name:          "returned and reset draws do not count",
+			table:         table,
+			records:       []record{{tier: 1, status: "returned"}, {tier: 2, status: "reset"}, {tier: 3, status: "reset"}, {tier: 3, status: "reset"}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{1, 3, 6},
+			wantAvailable: 7,
+		},
+		{
+			name:          "voided draws do not count",
+			table:         table,
+			records:       []record{{tier: 1, status: "voided"}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{1, 3, 6},
+			wantAvailable: 7,
+		},
+		{
+			name:          "draws from another pool do not count",
+			table:         table,
+			records:       []record{{tier: 1, drawType: "initial_guarantee"}, {tier: 3, drawType: "initial_guarantee"}, {tier: 3, drawType: "initial_guarantee"}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{1, 3, 6},
+			wantAvailable: 7,
+		},
+		{
+			name:           "forced pity",
+			table:          table,
+			filter:         drawFilter{forcePity: true, maxSalary: -1},
+			wantTiers:      []int{1},
+			wantWeights:    []int{1},
+			wantAvailable:  7,
+			wantPityActive: true,
+		},
+		{
+			name:             "salary limit and re-rolled picks",
+			table:            table,
+			filter:           drawFilter{maxSalary: 50, exclude: map[uint]bool{1: true, 2: true}},
+			wantTiers:        []int{2, 3},
+			wantWeights:      []int{3, 6},
+			wantAvailable:    3,
+			wantUnaffordable: 2,
+		},
+		{
+			name:          "no weights draws every general equally",
+			table:         &model.DrawTable{PoolType: "initial_normal"},
+			filter:        noDrawFilter,
+			wantTiers:     []int{1, 2, 3},
+			wantWeights:   []int{2, 2, 3},
+			wantAvailable: 7,
+		},
+		{
+			name:          "no weighted tier left draws the rest equally",
+			table:         &model.DrawTable{PoolType: "initial_normal", Weights: map[int]int{1: 5}, Caps: map[int]int{1: 1}},
+			records:       []record{{tier: 1}},
+			filter:        noDrawFilter,
+			wantTiers:     []int{2, 3},
+			wantWeights:   []int{2, 3},
+			wantAvailable: 7,
+			wantPity:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db.Where("1 = 1").Delete(&model.DrawRecord{})
+			for _, r := range tt.records {
+				drawType := r.drawType
+				if drawType == "" {
+					drawType = "initial_normal"
+				}
+				status := r.status
+				if status == "" {
+					status = "drawn"
+				}
+				db.Create(&model.DrawRecord{UserID: user.ID, GeneralID: 1, DrawType: drawType, Tier: r.tier, Status: status})
+			}
+
+			plan, err := planDraw(user.ID, tt.table, tt.filter)
+			if err != nil {
+				t.Fatalf("planDraw error: %v", err)
+			}
+
+			tiers := []int{}
+			weights := []int{}
+			probability := 0.0
+			for _, odds := range plan.Odds {
+				tiers = append(tiers, odds.Tier)
+				weights = append(weights, odds.Weight)
+				probability += odds.Probability
+				if odds.Available != len(plan.Generals[odds.Tier]) {
+					t.Errorf("tier %d available = %d, want %d", odds.Tier, odds.Available, len(plan.Generals[odds.Tier]))
+				}
+				if want := odds.Probability / float64(odds.Available); math.Abs(odds.PerGeneral-want) > 1e-9 {
+					t.Errorf("tier %d per general = %v, want %v", odds.Tier, odds.PerGeneral, want)
+				}
+			}
+			if !reflect.DeepEqual(tiers, tt.wantTiers) {
+				t.Errorf("tiers = %v, want %v", tiers, tt.wantTiers)
+			}
+			if !reflect.DeepEqual(weights, tt.wantWeights) {
+				t.Errorf("weights = %v, want %v", weights, tt.wantWeights)
+			}
+			if math.Abs(probability-1) > 1e-9 {
+				t.Errorf("probabilities add up to %v, want 1", probability)
+			}
+			if plan.Available != tt.wantAvailable {
+				t.Errorf("available = %d, want %d", plan.Available, tt.wantAvailable)
+			}
+			if plan.Unaffordable != tt.wantUnaffordable {
+				t.Errorf("unaffordable = %d, want %d", plan.Unaffordable, tt.wantUnaffordable)
+			}
+			if plan.Pity != tt.wantPity {
+				t.Errorf("pity = %d, want %d", plan.Pity, tt.wantPity)
+			}
+			if plan.PityActive != tt.wantPityActive {
+				t.Errorf("pity active = %v, want %v", plan.PityActive, tt.wantPityActive)
+			}
+		})
+	}
+}
+
+func TestCheckDrawPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		plan drawPlan
+		want error
+	}{
+		{"drawable", drawPlan{Available: 2, Odds: []TierOdds{{Tier: 1, Weight: 1}}}, nil},
+		{"only unaffordable generals", drawPlan{Unaffordable: 3}, ErrInsufficientSpace},
+		{"empty pool", drawPlan{}, ErrNoAvailableDrawGeneral},
+		{"every tier capped", drawPlan{Available: 2}, ErrDrawTierCapped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkDrawPlan(&tt.plan); err != tt.want {
+				t.Errorf("checkDrawPlan = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
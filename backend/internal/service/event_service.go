@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types pushed through the /api/events stream
+const (
+	EventPhaseChanged       = "phase.changed"
+	EventSeasonRulesUpdated = "season.rules"
+	EventDrawCompleted      = "draw.completed"
+	EventDrawSeedCommitted  = "draw.seed_committed"
+	EventDrawSeedRevealed   = "draw.seed_revealed"
+	EventDrawVoided         = "draw.voided"
+	EventDrawOverCap        = "draw.over_cap" // A player is still over the cap after their over-cap window
+	EventDrawReturned       = "draw.returned"
+	EventDrawCeremony       = "draw.ceremony"
+	EventDrawCeremonyReveal = "draw.ceremony_reveal"
+	EventDraftPicked        = "draft.picked"
+	EventDraftUpdated       = "draft.updated"
+	EventTradeCreated       = "trade.created"
+	EventTradeUpdated       = "trade.updated"
+	EventTradeCompleted     = "trade.completed"
+	EventTradeMessage       = "trade.message"
+	EventPolicySelected     = "policy.selected"
+	EventPolicyEffects      = "policy.effects"
+	EventAuctionAssigned    = "auction.assigned"
+	EventAuctionBid         = "auction.bid"
+	EventWaiverReleased     = "waiver.released"
+	EventWaiverProcessed    = "waiver.processed"
+	EventGeneralInjured     = "general.injured"
+	EventGeneralHealed      = "general.healed"
+	EventTournamentUpdated  = "tournament.updated"
+	EventMatchReported      = "match.reported"
+	EventMatchCompleted     = "match.completed"
+	EventLineupLocked       = "lineup.locked"
+	EventLineupRevealed     = "lineup.revealed"
+	EventResync             = "resync" // Sent when a resume token can no longer be honoured
+)
+
+const (
+	eventBufferSize     = 1000 // Events kept in memory for resuming clients
+	eventSubscriberSize = 64   // Per-subscriber backlog before the subscriber is dropped
+)
+
+// Event is a single typed notification
+type Event struct {
+	ID        string      `json:"id"` // Resume token, "<epoch>-<seq>"
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	seq        uint64
+	recipients []uint // nil means everyone
+}
+
+// visibleTo reports whether a user may receive the event
+func (e *Event) visibleTo(userID uint) bool {
+	if e.recipients == nil {
+		return true
+	}
+	for _, id := range e.recipients {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// EventSubscription is a live subscriber of the event stream
+type EventSubscription struct {
+	C      chan Event
+	userID uint // 0 for anonymous spectators
+}
+
+// eventHub fans events out to subscribers and keeps a ring buffer for resuming
+type eventHub struct {
+	mu     sync.Mutex
+	epoch  string
+	seq    uint64
+	buffer []Event
+	subs   map[*EventSubscription]struct{}
+}
+
+var events = &eventHub{
+	epoch: strconv.FormatInt(time.Now().Unix(), 36),
+	subs:  make(map[*EventSubscription]struct{}),
+}
+
+// PublishEvent pushes an event to subscribers
+// With no recipients the event is public; otherwise only the listed users get it.
+func PublishEvent(eventType string, data interface{}, recipients ...uint) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	events.seq++
+	event := Event{
+		ID:        fmt.Sprintf("%s-%d", events.epoch, events.seq),
+		Type:      eventType,
+		Data:      data,
+		CreatedAt: time.Now(),
+		seq:       events.seq,
+	}
+	if len(recipients) > 0 {
+		event.recipients = recipients
+	}
+
+	events.buffer = append(events.buffer, event)
+	if len(events.buffer) > eventBufferSize {
+		events.buffer = events.buffer[len(events.buffer)-eventBufferSize:]
+	}
+
+	for sub := range events.subs {
+		if !event.visibleTo(sub.userID) {
+			continue
+		}
+		select {
+		case sub.C <- event:
+		default:
+			// Subscriber is too slow; drop it so it reconnects with its resume token
+			delete(events.subs, sub)
+			close(sub.C)
+		}
+	}
+}
+
+// SubscribeEvents registers a subscriber and returns the events it missed since resumeToken
+// If the token is unknown (server restarted or too old) a single resync event is returned
+// instead, telling the client to reload its state.
+func SubscribeEvents(userID uint, resumeToken string) (*EventSubscription, []Event) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	sub := &EventSubscription{
+		C:      make(chan Event, eventSubscriberSize),
+		userID: userID,
+	}
+	events.subs[sub] = struct{}{}
+
+	if resumeToken == "" {
+		return sub, nil
+	}
+
+	var backlog []Event
+	seq, ok := parseResumeToken(resumeToken)
+	if !ok || (len(events.buffer) > 0 && seq+1 < events.buffer[0].seq) || seq > events.seq {
+		backlog = append(backlog, Event{
+			ID:        fmt.Sprintf("%s-%d", events.epoch, events.seq),
+			Type:      EventResync,
+			CreatedAt: time.Now(),
+		})
+		return sub, backlog
+	}
+
+	for _, event := range events.buffer {
+		if event.seq > seq && event.visibleTo(userID) {
+			backlog = append(backlog, event)
+		}
+	}
+	return sub, backlog
+}
+
+// UnsubscribeEvents removes a subscriber
+func UnsubscribeEvents(sub *EventSubscription) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	if _, ok := events.subs[sub]; ok {
+		delete(events.subs, sub)
+		close(sub.C)
+	}
+}
+
+// parseResumeToken extracts the sequence number from a token of the current epoch
+func parseResumeToken(token string) (uint64, bool) {
+	parts := strings.SplitN(token, "-", 2)
+	if len(parts) != 2 || parts[0] != events.epoch {
+		return 0, false
+	}
+	seq, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return seq, true
+}
@@ -2,9 +2,12 @@ package service
 
 import (
 	"errors"
+	"time"
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -45,11 +48,59 @@ func SetGamePhase(phaseName string, roundNumber int, draftRound int) error {
 		return errors.New("invalid phase name")
 	}
 
-	return db.Model(&model.GamePhase{}).Where("id = 1").Updates(map[string]interface{}{
+	current, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{
 		"current_phase": phaseName,
 		"round_number":  roundNumber,
-		"draft_round":   draftRound,
-	}).Error
+	}
+	// The draft engine owns the round counter while a draft is running
+	if current.DraftStatus != DraftStatusRunning {
+		updates["draft_round"] = draftRound
+	}
+
+	tx := db.Begin()
+
+	if err := tx.Model(&model.GamePhase{}).Where("id = 1").Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Injuries that ended before the new round clear with the round change itself
+	var healed []uint
+	if roundNumber != current.RoundNumber {
+		healed, err = healInjuries(tx, roundNumber)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishHealedGenerals(healed, roundNumber)
+
+	// The draw seed is committed before drawing starts and revealed once it is over
+	if phaseName == "draw" && current.CurrentPhase != "draw" {
+		if _, err := CommitDrawSeed(); err != nil {
+			return err
+		}
+	}
+	if current.CurrentPhase == "draw" && phaseName != "draw" {
+		if _, err := RevealDrawSeeds(); err != nil {
+			return err
+		}
+	}
+
+	if phase, err := GetGamePhase(); err == nil {
+		PublishEvent(EventPhaseChanged, phase)
+	}
+
+	return nil
 }
 
 // SignUp registers a user for the current season
@@ -76,17 +127,22 @@ func SignUp(userID uint) error {
 		return ErrAlreadyRegistered
 	}
 
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return err
+	}
+
 	// Check if registration is full
 	var count int64
 	db.Model(&model.User{}).Where("is_registered = ?", true).Count(&count)
-	if count >= 32 {
+	if count >= int64(rules.MaxPlayers) {
 		return ErrRegistrationFull
 	}
 
 	// Register the user
 	return db.Model(&user).Updates(map[string]interface{}{
 		"is_registered": true,
-		"space":         350,
+		"space":         rules.InitialSpace,
 		"used_space":    0,
 	}).Error
 }
@@ -119,40 +175,53 @@ func GetRegisteredCount() (int64, error) {
 func ResetSeason() error {
 	db := database.GetDB()
 
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return err
+	}
+
 	// Begin transaction
 	tx := db.Begin()
 
-	// Reset all generals ownership
-	if err := tx.Model(&model.General{}).Updates(map[string]interface{}{
+	// Reset all generals ownership; GORM refuses updates without a condition
+	if err := tx.Model(&model.General{}).Where("1 = 1").Updates(map[string]interface{}{
 		"owner_id":      nil,
 		"is_available":  true,
 		"injured_until": nil,
+		"version":       gorm.Expr("version + 1"),
 	}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	// Reset all treasures ownership
-	if err := tx.Model(&model.Treasure{}).Updates(map[string]interface{}{
+	if err := tx.Model(&model.Treasure{}).Where("1 = 1").Updates(map[string]interface{}{
 		"owner_id":     nil,
 		"is_available": true,
+		"version":      gorm.Expr("version + 1"),
 	}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	// Reset all clubs ownership
-	if err := tx.Model(&model.Club{}).Update("owner_id", nil).Error; err != nil {
+	if err := tx.Model(&model.Club{}).Where("owner_id IS NOT NULL").Update("owner_id", nil).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
 	// Reset all users
 	if err := tx.Model(&model.User{}).Where("is_admin = ?", false).Updates(map[string]interface{}{
-		"is_registered": false,
-		"space":         350,
-		"used_space":    0,
-		"club_id":       nil,
+		"is_registered":      false,
+		"space":              rules.InitialSpace,
+		"used_space":         0,
+		"club_id":            nil,
+		"waiver_priority":    0,
+		"policy_space":       0,
+		"policy_salary":      0,
+		"over_cap_deadline":  nil,
+		"over_cap_escalated": false,
+		"mulligan_tokens":    0,
 	}).Error; err != nil {
 		tx.Rollback()
 		return err
@@ -173,9 +242,106 @@ func ResetSeason() error {
 		tx.Rollback()
 		return err
 	}
+	if err := tx.Exec("DELETE FROM trade_messages").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM trade_veto_votes").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM trade_listings").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Clear waivers
+	if err := tx.Exec("DELETE FROM waiver_claims").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM waivers").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM multi_trade_items").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM multi_trade_participants").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM multi_trade_veto_votes").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM multi_trades").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Clear injury records
+	if err := tx.Exec("DELETE FROM injuries").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Clear tournament
+	if err := tx.Exec("DELETE FROM match_reports").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM matches").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM tournament_group_members").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM tournament_groups").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Clear lineups
+	if err := tx.Exec("DELETE FROM lineups").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM lineup_rounds").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Clear draw records; seeded draws stay as "reset" so their seed still verifies
+	if err := tx.Exec("DELETE FROM draw_records WHERE seed_id IS NULL").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.DrawRecord{}).Where("status <> ?", "reset").
+		Updates(map[string]interface{}{"status": "reset", "over_cap": false}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM draw_rerolls WHERE seed_id IS NULL").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM draw_ceremonies").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	// The next season's draws commit to a fresh seed, starting again at nonce 1
+	if err := tx.Model(&model.DrawSeed{}).Where("revealed = ?", false).
+		Updates(map[string]interface{}{"revealed": true, "revealed_at": time.Now()}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	// Clear draw records
-	if err := tx.Exec("DELETE FROM draw_records").Error; err != nil {
+	// Clear draft queues
+	if err := tx.Exec("DELETE FROM draft_queues").Error; err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -192,6 +358,16 @@ func ResetSeason() error {
 		return err
 	}
 
+	// Clear live auction lots and bids
+	if err := tx.Exec("DELETE FROM auction_bids").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM auction_lots").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Clear policy bids
 	if err := tx.Exec("DELETE FROM policy_bids").Error; err != nil {
 		tx.Rollback()
@@ -223,14 +399,26 @@ func ResetSeason() error {
 
 	// Reset game phase
 	if err := tx.Model(&model.GamePhase{}).Where("id = 1").Updates(map[string]interface{}{
-		"current_phase": "signup",
-		"round_number":  1,
-		"draft_round":   0,
-		"draft_order":   "[]",
+		"current_phase":  "signup",
+		"round_number":   1,
+		"draft_round":    0,
+		"draft_order":    "[]",
+		"draft_pick":     0,
+		"draft_rounds":   0,
+		"draft_mode":     "",
+		"draft_status":   "",
+		"draft_timeout":  0,
+		"draft_deadline": nil,
 	}).Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// A new season never reuses the old draw seed
+	_, err = RevealDrawSeeds()
+	return err
 }
@@ -2,17 +2,14 @@ package service
 
 import (
 	"errors"
-	"math/rand"
+	"fmt"
+	"log"
+	"time"
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
-)
 
-const (
-	// GuaranteeDraws is the number of guarantee draws
-	GuaranteeDraws = 3
-	// NormalDraws is the number of normal draws
-	NormalDraws = 7
+	"gorm.io/gorm"
 )
 
 var (
@@ -22,43 +19,167 @@ var (
 	ErrUserNotRegistered      = errors.New("user not registered")
 )
 
+// releasedDrawStatuses are the statuses of draws that gave their slot back:
+// returned with a mulligan, voided for going over the cap or undone by an admin reset
+var releasedDrawStatuses = []string{"returned", "voided", "reset"}
+
 // GetDrawCount returns the count of draws for a user by draw type
+// Draws returned with a mulligan, voided or reset give their slot back.
 func GetDrawCount(userID uint, drawType string) (int, error) {
 	db := database.GetDB()
 	var count int64
 	if err := db.Model(&model.DrawRecord{}).
-		Where("user_id = ? AND draw_type = ?", userID, drawType).
+		Where("user_id = ? AND draw_type = ? AND status NOT IN ?", userID, drawType, releasedDrawStatuses).
 		Count(&count).Error; err != nil {
 		return 0, err
 	}
 	return int(count), nil
 }
 
+// drawAllowance returns how many guarantee and normal draws a user gets:
+// the season's base counts plus 国策 effects
+func drawAllowance(userID uint) (int, int, error) {
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return 0, 0, err
+	}
+	status, err := GetPlayerPolicyStatus(userID)
+	if err != nil {
+		return 0, 0, err
+	}
+	guarantee := rules.GuaranteeDraws + status.Totals[PolicyEffectGuaranteeDraws]
+	normal := rules.NormalDraws + status.Totals[PolicyEffectNormalDraws]
+	if guarantee < 0 {
+		guarantee = 0
+	}
+	if normal < 0 {
+		normal = 0
+	}
+	return guarantee, normal, nil
+}
+
 // DrawStatus represents the status of draws for a user
 type DrawStatus struct {
+	GuaranteeTotal     int `json:"guarantee_total"` // Guarantee draws allowed, including 国策 effects
+	NormalTotal        int `json:"normal_total"`    // Normal draws allowed, including 国策 effects
 	GuaranteeRemaining int `json:"guarantee_remaining"`
 	NormalRemaining    int `json:"normal_remaining"`
 	TotalRemaining     int `json:"total_remaining"`
 	GuaranteeDone      int `json:"guarantee_done"`
 	NormalDone         int `json:"normal_done"`
 	TotalDone          int `json:"total_done"`
+
+	// Odds of the next draw
+	NextPool   string     `json:"next_pool"`
+	Odds       []TierOdds `json:"odds"`
+	Pity       int        `json:"pity"`        // Draws from the next pool since its pity tier last came up
+	PityAfter  int        `json:"pity_after"`  // Pity triggers at this count, 0 = no pity
+	PityActive bool       `json:"pity_active"` // The next draw is guaranteed the pity tier
+
+	// Space handling
+	RemainingSpace  int        `json:"remaining_space"`
+	OverCapPolicy   string     `json:"over_cap_policy"`
+	OverCapDeadline *time.Time `json:"over_cap_deadline"` // Get back under the cap by then
+
+	// Mulligans
+	MulliganTotal     int        `json:"mulligan_total"` // Tokens granted, including 国策 effects
+	MulliganUsed      int        `json:"mulligan_used"`
+	MulliganRemaining int        `json:"mulligan_remaining"`
+	MulliganRecordID  *uint      `json:"mulligan_record_id"` // Draw a mulligan would return right now
+	MulliganDeadline  *time.Time `json:"mulligan_deadline"`  // Until when it can be returned
 }
 
-// GetDrawStatus returns the draw status for a user
+// GetDrawStatus returns the draw status for a user, with the odds of their next draw
 func GetDrawStatus(userID uint) (*DrawStatus, error) {
-	guaranteeDone, err := GetDrawCount(userID, "initial_guarantee")
+	status, err := getDrawCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	status.Odds = []TierOdds{}
+
+	var user model.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return nil, err
+	}
+	status.RemainingSpace = user.Space - user.UsedSpace
+	status.OverCapPolicy = rules.OverCapPolicy
+	status.OverCapDeadline = user.OverCapDeadline
+
+	if err := fillMulliganStatus(status, &user, rules); err != nil {
+		return nil, err
+	}
+
+	poolType, err := nextDrawPool(status)
+	if err == ErrDrawLimitReached {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	table, err := getDrawTable(poolType)
+	if err != nil {
+		return nil, err
+	}
+	filter := noDrawFilter
+	if rules.OverCapPolicy == OverCapFilter {
+		filter.maxSalary = status.RemainingSpace
+	}
+	plan, err := planDraw(userID, table, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	status.NextPool = poolType
+	status.Odds = plan.Odds
+	status.Pity = plan.Pity
+	status.PityAfter = table.PityAfter
+	status.PityActive = plan.PityActive
+	return status, nil
+}
+
+// nextDrawPool returns the first pool in the season's order the user has draws left in
+func nextDrawPool(status *DrawStatus) (string, error) {
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return "", err
+	}
+	remaining := map[string]int{
+		DrawPoolGuarantee: status.GuaranteeRemaining,
+		DrawPoolNormal:    status.NormalRemaining,
+	}
+	for _, pool := range rules.PoolList {
+		if remaining[pool] > 0 {
+			return pool, nil
+		}
+	}
+	return "", ErrDrawLimitReached
+}
+
+// getDrawCounts returns how many draws a user has made and has left per pool
+func getDrawCounts(userID uint) (*DrawStatus, error) {
+	guaranteeDone, err := GetDrawCount(userID, DrawPoolGuarantee)
 	if err != nil {
 		return nil, err
 	}
-	normalDone, err := GetDrawCount(userID, "initial_normal")
+	normalDone, err := GetDrawCount(userID, DrawPoolNormal)
+	if err != nil {
+		return nil, err
+	}
+	guaranteeDraws, normalDraws, err := drawAllowance(userID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &DrawStatus{
-		GuaranteeRemaining: GuaranteeDraws - guaranteeDone,
-		NormalRemaining:    NormalDraws - normalDone,
-		TotalRemaining:     (GuaranteeDraws + NormalDraws) - (guaranteeDone + normalDone),
+		GuaranteeTotal:     guaranteeDraws,
+		NormalTotal:        normalDraws,
+		GuaranteeRemaining: guaranteeDraws - guaranteeDone,
+		NormalRemaining:    normalDraws - normalDone,
+		TotalRemaining:     (guaranteeDraws + normalDraws) - (guaranteeDone + normalDone),
 		GuaranteeDone:      guaranteeDone,
 		NormalDone:         normalDone,
 		TotalDone:          guaranteeDone + normalDone,
@@ -66,7 +187,7 @@ func GetDrawStatus(userID uint) (*DrawStatus, error) {
 }
 
 // Draw performs a draw for a user
-// It automatically picks from guarantee pool first, then normal pool
+// It automatically picks from the pools in the season's pool order
 func Draw(userID uint) (*model.General, string, error) {
 	// Check phase
 	phase, err := GetGamePhase()
@@ -76,6 +197,10 @@ func Draw(userID uint) (*model.General, string, error) {
 	if phase.CurrentPhase != "draw" {
 		return nil, "", ErrNotInDrawPhase
 	}
+	// The ceremony draws for everyone in turn until it is finished
+	if err := checkNoDrawCeremony(); err != nil {
+		return nil, "", err
+	}
 
 	return performDraw(userID)
 }
@@ -86,10 +211,11 @@ func AdminDraw(userID uint) (*model.General, string, error) {
 }
 
 // performDraw is the core draw logic
-func performDraw(userID uint) (*model.General, string, error) {
+// Generals in exclude cannot come up, e.g. the one a mulligan just returned.
+func performDraw(userID uint, exclude ...uint) (*model.General, string, error) {
 	db := database.GetDB()
 	// Get current draw status
-	status, err := GetDrawStatus(userID)
+	status, err := getDrawCounts(userID)
 	if err != nil {
 		return nil, "", err
 	}
@@ -110,68 +236,172 @@ func performDraw(userID uint) (*model.General, string, error) {
 	}
 
 	// Determine which pool to draw from
-	var poolType string
-	var drawType string
-	if status.GuaranteeRemaining > 0 {
-		poolType = "initial_guarantee"
-		drawType = "initial_guarantee"
-	} else {
-		poolType = "initial_normal"
-		drawType = "initial_normal"
+	poolType, err := nextDrawPool(status)
+	if err != nil {
+		return nil, "", err
 	}
+	drawType := poolType
 
-	// Get available generals from the pool
-	var generals []model.General
-	if err := db.Where("pool_type = ? AND is_available = ? AND owner_id IS NULL", poolType, true).
-		Find(&generals).Error; err != nil {
+	rules, err := GetSeasonRules()
+	if err != nil {
 		return nil, "", err
 	}
-
-	if len(generals) == 0 {
-		return nil, "", ErrNoAvailableDrawGeneral
+	remainingSpace := user.Space - user.UsedSpace
+	filter := drawFilter{maxSalary: -1, exclude: map[uint]bool{}}
+	for _, generalID := range exclude {
+		filter.exclude[generalID] = true
+	}
+	if rules.OverCapPolicy == OverCapFilter {
+		filter.maxSalary = remainingSpace
 	}
 
-	// Random select one
-	selected := generals[rand.Intn(len(generals))]
+	// Work out the tier odds from the pool's draw table
+	table, err := getDrawTable(poolType)
+	if err != nil {
+		return nil, "", err
+	}
+	plan, err := planDraw(userID, table, filter)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// Check space
-	remainingSpace := user.Space - user.UsedSpace
-	if selected.Salary > remainingSpace {
+	// Re-rolling only makes sense while something affordable can come up
+	if rules.OverCapPolicy == OverCapReroll && len(plan.Odds) > 0 && !planHasAffordable(plan, remainingSpace) {
 		return nil, "", ErrInsufficientSpace
 	}
 
+	// Derive the tier and the pick from the committed seed and the next nonce;
+	// under the reroll policy every unaffordable pick takes another nonce
+	seed, err := currentDrawSeed()
+	if err != nil {
+		return nil, "", err
+	}
+	nonce := seed.DrawCount
+	var pick *drawPick
+	var rerolls []model.DrawReroll
+	for {
+		if err := checkDrawPlan(plan); err != nil {
+			if len(rerolls) > 0 {
+				// Everything left was thrown back or is out of reach
+				log.Printf("Draw for user %d gave up after %d re-rolls: %v", userID, len(rerolls), err)
+				err = ErrInsufficientSpace
+			}
+			return nil, "", err
+		}
+		nonce++
+		pick = rollDraw(seed, nonce, plan)
+		if pick.General.Salary <= remainingSpace || rules.OverCapPolicy == OverCapAllow {
+			break
+		}
+		if rules.OverCapPolicy != OverCapReroll {
+			return nil, "", ErrInsufficientSpace
+		}
+
+		rerolls = append(rerolls, model.DrawReroll{
+			UserID:     userID,
+			GeneralID:  pick.General.ID,
+			DrawType:   drawType,
+			Reason:     fmt.Sprintf("salary %d exceeds remaining space %d", pick.General.Salary, remainingSpace),
+			SeedID:     &seed.ID,
+			Nonce:      nonce,
+			PickIndex:  pick.Index,
+			Candidates: pick.Candidates,
+			Tier:       pick.Tier,
+			TierOdds:   pick.TierOdds,
+		})
+		filter.exclude[pick.General.ID] = true
+		if plan, err = planDraw(userID, table, filter); err != nil {
+			return nil, "", err
+		}
+	}
+	selected := pick.General
+	overCap := selected.Salary > remainingSpace
+
 	// Begin transaction
 	tx := db.Begin()
 
+	// Claim the nonces; a concurrent draw would have drawn from a different pool
+	result := tx.Model(&model.DrawSeed{}).Where("id = ? AND draw_count = ?", seed.ID, seed.DrawCount).
+		Update("draw_count", nonce)
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, "", ErrDrawConflict
+	}
+
+	for i := range rerolls {
+		if err := tx.Create(&rerolls[i]).Error; err != nil {
+			tx.Rollback()
+			return nil, "", err
+		}
+	}
+
 	// Assign general to user
-	if err := tx.Model(&selected).Updates(map[string]interface{}{
+	result = tx.Model(&model.General{}).Where("id = ? AND owner_id IS NULL", selected.ID).Updates(map[string]interface{}{
 		"owner_id":     userID,
 		"is_available": false,
-	}).Error; err != nil {
+		"version":      gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
 		tx.Rollback()
-		return nil, "", err
+		return nil, "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, "", ErrDrawConflict
 	}
 
-	// Update user's used space
-	if err := tx.Model(&user).Update("used_space", user.UsedSpace+selected.Salary).Error; err != nil {
+	// Update user's used space; an over-cap draw starts the window to get back under
+	userUpdates := map[string]interface{}{
+		"used_space": gorm.Expr("used_space + ?", selected.Salary),
+	}
+	if overCap && user.OverCapDeadline == nil {
+		userUpdates["over_cap_deadline"] = time.Now().Add(time.Duration(rules.OverCapHours) * time.Hour)
+	}
+	if err := tx.Model(&model.User{}).Where("id = ?", userID).Updates(userUpdates).Error; err != nil {
 		tx.Rollback()
 		return nil, "", err
 	}
 
 	// Record the draw
 	record := model.DrawRecord{
-		UserID:    userID,
-		GeneralID: selected.ID,
-		DrawType:  drawType,
+		UserID:     userID,
+		GeneralID:  selected.ID,
+		DrawType:   drawType,
+		SeedID:     &seed.ID,
+		Nonce:      nonce,
+		PickIndex:  pick.Index,
+		Candidates: pick.Candidates,
+		Tier:       pick.Tier,
+		TierOdds:   pick.TierOdds,
+		OverCap:    overCap,
 	}
 	if err := tx.Create(&record).Error; err != nil {
 		tx.Rollback()
 		return nil, "", err
 	}
 
+	effects, err := applyClubEffects(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return nil, "", err
 	}
+	publishClubEffects(effects)
+
+	PublishEvent(EventDrawCompleted, map[string]interface{}{
+		"user_id":   userID,
+		"general":   selected,
+		"draw_type": drawType,
+		"over_cap":  overCap,
+		"rerolls":   len(rerolls),
+	})
 
 	return &selected, drawType, nil
 }
@@ -199,13 +429,18 @@ func GetAllDrawResults() ([]DrawResult, error) {
 	for _, user := range users {
 		// Get draw records for this user
 		var records []model.DrawRecord
-		if err := db.Where("user_id = ? AND (draw_type = ? OR draw_type = ?)",
-			user.ID, "initial_guarantee", "initial_normal").
+		if err := db.Where("user_id = ? AND (draw_type = ? OR draw_type = ?) AND status = ?",
+			user.ID, DrawPoolGuarantee, DrawPoolNormal, "drawn").
 			Preload("General").
 			Find(&records).Error; err != nil {
 			return nil, err
 		}
 
+		guaranteeDraws, normalDraws, err := drawAllowance(user.ID)
+		if err != nil {
+			return nil, err
+		}
+
 		generals := make([]model.General, 0, len(records))
 		totalSalary := 0
 		for _, record := range records {
@@ -218,7 +453,7 @@ func GetAllDrawResults() ([]DrawResult, error) {
 			Nickname:     user.Nickname,
 			Generals:     generals,
 			TotalSalary:  totalSalary,
-			DrawComplete: len(records) >= GuaranteeDraws+NormalDraws,
+			DrawComplete: len(records) >= guaranteeDraws+normalDraws,
 		})
 	}
 
@@ -239,6 +474,7 @@ func GetDrawPool(poolType string) ([]model.General, error) {
 }
 
 // ResetUserDraw resets a user's draw results (admin only)
+// The records are kept with status reset so the seed's nonce sequence stays verifiable.
 func ResetUserDraw(userID uint) error {
 	db := database.GetDB()
 
@@ -250,8 +486,8 @@ func ResetUserDraw(userID uint) error {
 
 	// Get all draw records for this user
 	var records []model.DrawRecord
-	if err := db.Where("user_id = ? AND (draw_type = ? OR draw_type = ?)",
-		userID, "initial_guarantee", "initial_normal").
+	if err := db.Where("user_id = ? AND (draw_type = ? OR draw_type = ?) AND status <> ?",
+		userID, DrawPoolGuarantee, DrawPoolNormal, "reset").
 		Preload("General").
 		Find(&records).Error; err != nil {
 		return err
@@ -264,40 +500,60 @@ func ResetUserDraw(userID uint) error {
 	// Begin transaction
 	tx := db.Begin()
 
-	// Calculate total salary to return
+	// Calculate total salary to return; voided and returned draws already went
+	// back to the pool and may belong to someone else by now
 	totalSalary := 0
 	for _, record := range records {
-		totalSalary += record.General.Salary
+		if record.Status != "drawn" {
+			continue
+		}
 
 		// Return general to pool
-		if err := tx.Model(&model.General{}).Where("id = ?", record.GeneralID).Updates(map[string]interface{}{
-			"owner_id":     nil,
-			"is_available": true,
-		}).Error; err != nil {
+		result := tx.Model(&model.General{}).Where("id = ? AND owner_id = ?", record.GeneralID, userID).
+			Updates(map[string]interface{}{
+				"owner_id":     nil,
+				"is_available": true,
+				"version":      gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
 			tx.Rollback()
-			return err
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			totalSalary += record.General.Salary
 		}
 	}
 
-	// Delete draw records
-	if err := tx.Where("user_id = ? AND (draw_type = ? OR draw_type = ?)",
-		userID, "initial_guarantee", "initial_normal").
-		Delete(&model.DrawRecord{}).Error; err != nil {
+	// Mark the draw records reset; deleting them would leave gaps in the seed's nonces
+	if err := tx.Model(&model.DrawRecord{}).Where("user_id = ? AND (draw_type = ? OR draw_type = ?) AND status <> ?",
+		userID, DrawPoolGuarantee, DrawPoolNormal, "reset").
+		Update("status", "reset").Error; err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	// Update user's used space
-	newUsedSpace := user.UsedSpace - totalSalary
-	if newUsedSpace < 0 {
-		newUsedSpace = 0
+	// Update user's used space; it is not clamped here, the 国策 salary adjustment
+	// included in it is recomputed below and keeps it from going negative
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"used_space":         gorm.Expr("used_space - ?", totalSalary),
+		"over_cap_deadline":  nil,
+		"over_cap_escalated": false,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
 	}
-	if err := tx.Model(&user).Update("used_space", newUsedSpace).Error; err != nil {
+
+	effects, err := applyClubEffects(tx, userID)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishClubEffects(effects)
+	return nil
 }
 
 // ResetAllUsersDraw resets all users' draw results (admin only)
@@ -325,6 +581,11 @@ func ResetAllUsersDraw() (int, error) {
 func DrawForUser(userID uint) ([]model.General, error) {
 	generals := make([]model.General, 0)
 
+	// A running ceremony reveals every draw in turn
+	if err := checkNoDrawCeremony(); err != nil {
+		return generals, err
+	}
+
 	for {
 		general, _, err := AdminDraw(userID)
 		if err == ErrDrawLimitReached {
@@ -339,26 +600,40 @@ func DrawForUser(userID uint) ([]model.General, error) {
 	return generals, nil
 }
 
+// DrawFailure is a player whose draws stopped early in a batch draw
+type DrawFailure struct {
+	UserID uint   `json:"user_id"`
+	Error  string `json:"error"`
+}
+
 // DrawForAllUsers performs all draws for all registered users (admin only)
-func DrawForAllUsers() (map[uint][]model.General, error) {
+// A player whose draws fail is recorded with the error and the batch moves on;
+// the generals drawn before the failure are kept in the results.
+func DrawForAllUsers() (map[uint][]model.General, []DrawFailure, error) {
 	db := database.GetDB()
 
+	if err := checkNoDrawCeremony(); err != nil {
+		return nil, nil, err
+	}
+
 	// Get all registered users
 	var users []model.User
 	if err := db.Where("is_registered = ?", true).Find(&users).Error; err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	results := make(map[uint][]model.General)
+	failures := []DrawFailure{}
 	for _, user := range users {
 		generals, err := DrawForUser(user.ID)
+		results[user.ID] = generals
 		if err != nil && err != ErrDrawLimitReached {
-			return results, err
+			log.Printf("Draw for all: user %d stopped after %d draws: %v", user.ID, len(generals), err)
+			failures = append(failures, DrawFailure{UserID: user.ID, Error: err.Error()})
 		}
-		results[user.ID] = generals
 	}
 
-	return results, nil
+	return results, failures, nil
 }
 
 // GetInitialDrawPool returns available generals in initial draw pools
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidInjury     = errors.New("injury must last at least one round")
+	ErrGeneralNotFound   = errors.New("general not found")
+	ErrGeneralNotInjured = errors.New("general is not injured")
+)
+
+// Injury sources
+const (
+	InjurySourceAdmin  = "admin"
+	InjurySourceImport = "import"
+	InjurySourceMatch  = "match"
+)
+
+// InjuryRequest flags a general injured for a number of rounds
+type InjuryRequest struct {
+	GeneralID uint   `json:"general_id" binding:"required"`
+	Rounds    int    `json:"rounds" binding:"required"`
+	FromRound int    `json:"from_round"` // First round missed, 0 = current round
+	Reason    string `json:"reason"`
+}
+
+// InjureGeneral flags a general injured
+// A longer injury already in place is kept.
+func InjureGeneral(req *InjuryRequest, source string, reportedBy uint) (*model.Injury, error) {
+	db := database.GetDB()
+
+	if req.Rounds < 1 || req.FromRound < 0 {
+		return nil, ErrInvalidInjury
+	}
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+
+	var general model.General
+	if err := db.First(&general, req.GeneralID).Error; err != nil {
+		return nil, ErrGeneralNotFound
+	}
+
+	from := req.FromRound
+	if from == 0 {
+		from = phase.RoundNumber
+	}
+	until := from + req.Rounds - 1
+
+	injury := &model.Injury{
+		GeneralID:  general.ID,
+		FromRound:  from,
+		UntilRound: until,
+		Reason:     req.Reason,
+		Source:     source,
+		ReportedBy: reportedBy,
+		Status:     "active",
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	if err := tx.Create(injury).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := tx.Model(&model.General{}).
+		Where("id = ? AND (injured_until IS NULL OR injured_until < ?)", general.ID, until).
+		Update("injured_until", until).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	if general.InjuredUntil == nil || *general.InjuredUntil < until {
+		general.InjuredUntil = &until
+	}
+	general.Injured = generalInjured(&general, phase.RoundNumber)
+	injury.General = general
+	PublishEvent(EventGeneralInjured, injury)
+
+	return injury, nil
+}
+
+// ClearInjury makes an injured general available again (admin only)
+func ClearInjury(generalID uint) error {
+	db := database.GetDB()
+
+	var general model.General
+	if err := db.First(&general, generalID).Error; err != nil {
+		return ErrGeneralNotFound
+	}
+	if general.InjuredUntil == nil {
+		return ErrGeneralNotInjured
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	if err := tx.Model(&model.General{}).Where("id = ?", generalID).Update("injured_until", nil).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&model.Injury{}).Where("general_id = ? AND status = ?", generalID, "active").Updates(map[string]interface{}{
+		"status":     "cleared",
+		"cleared_at": time.Now(),
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	PublishEvent(EventGeneralHealed, map[string]interface{}{
+		"general_ids": []uint{generalID},
+	})
+	return nil
+}
+
+// GetInjuries returns injury records, only active ones when activeOnly is set
+func GetInjuries(activeOnly bool) ([]model.Injury, error) {
+	db := database.GetDB()
+
+	query := db.Preload("General.Owner")
+	if activeOnly {
+		query = query.Where("status = ?", "active")
+	}
+
+	var injuries []model.Injury
+	if err := query.Order("created_at DESC").Find(&injuries).Error; err != nil {
+		return nil, err
+	}
+	return injuries, nil
+}
+
+// healInjuries clears every injury that ended before round inside tx and
+// returns the healed generals. Called with the round change itself.
+func healInjuries(tx *gorm.DB, round int) ([]uint, error) {
+	var generalIDs []uint
+	if err := tx.Model(&model.General{}).Where("injured_until IS NOT NULL AND injured_until < ?", round).
+		Pluck("id", &generalIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if len(generalIDs) > 0 {
+		if err := tx.Model(&model.General{}).Where("id IN ?", generalIDs).Update("injured_until", nil).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Model(&model.Injury{}).Where("status = ? AND until_round < ?", "active", round).Updates(map[string]interface{}{
+		"status":     "healed",
+		"cleared_at": time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return generalIDs, nil
+}
+
+// publishHealedGenerals announces the generals healInjuries cleared
+func publishHealedGenerals(generalIDs []uint, round int) {
+	if len(generalIDs) == 0 {
+		return
+	}
+	log.Printf("Round %d: %d generals recovered from injury", round, len(generalIDs))
+	PublishEvent(EventGeneralHealed, map[string]interface{}{
+		"general_ids": generalIDs,
+		"round":       round,
+	})
+}
+
+// generalInjured reports whether a general misses the given round
+func generalInjured(general *model.General, round int) bool {
+	return general.InjuredUntil != nil && *general.InjuredUntil >= round
+}
+
+// markInjuredGenerals sets the Injured flag of each general for the current round
+func markInjuredGenerals(generals []model.General) error {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+	for i := range generals {
+		generals[i].Injured = generalInjured(&generals[i], phase.RoundNumber)
+	}
+	return nil
+}
+
+// flagInjuredTradeGenerals fills in the injured generals of each trade
+// so the side receiving them knows before accepting.
+func flagInjuredTradeGenerals(trades ...*model.Trade) error {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+
+	var injured []model.General
+	if err := db.Where("injured_until IS NOT NULL AND injured_until >= ?", phase.RoundNumber).Find(&injured).Error; err != nil {
+		return err
+	}
+	injuredUntil := make(map[uint]int, len(injured))
+	for _, general := range injured {
+		injuredUntil[general.ID] = *general.InjuredUntil
+	}
+
+	for _, trade := range trades {
+		trade.InjuredGenerals = nil
+		for _, field := range []string{trade.OfferGenerals, trade.RequestGenerals} {
+			var ids []uint
+			json.Unmarshal([]byte(field), &ids)
+			for _, id := range ids {
+				if until, ok := injuredUntil[id]; ok {
+					trade.InjuredGenerals = append(trade.InjuredGenerals, model.InjuredGeneral{
+						GeneralID:  id,
+						UntilRound: until,
+					})
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// flagInjuredTradeList flags the injured generals of a list of trades
+func flagInjuredTradeList(trades []model.Trade) error {
+	ptrs := make([]*model.Trade, len(trades))
+	for i := range trades {
+		ptrs[i] = &trades[i]
+	}
+	return flagInjuredTradeGenerals(ptrs...)
+}
+
+// flagInjuredMultiTradeGenerals fills in the injured generals of each multi-party trade
+func flagInjuredMultiTradeGenerals(trades ...*model.MultiTrade) error {
+	db := database.GetDB()
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+
+	for _, trade := range trades {
+		var ids []uint
+		for _, item := range trade.Items {
+			if item.ItemType == "general" {
+				ids = append(ids, item.ItemID)
+			}
+		}
+		trade.InjuredGenerals = nil
+		if len(ids) == 0 {
+			continue
+		}
+
+		var injured []model.General
+		if err := db.Where("id IN ? AND injured_until IS NOT NULL AND injured_until >= ?", ids, phase.RoundNumber).
+			Find(&injured).Error; err != nil {
+			return err
+		}
+		for _, general := range injured {
+			trade.InjuredGenerals = append(trade.InjuredGenerals, model.InjuredGeneral{
+				GeneralID:  general.ID,
+				UntilRound: *general.InjuredUntil,
+			})
+		}
+	}
+	return nil
+}
+
+// ImportInjuries flags injuries from imported rows, skipping generals that cannot be found
+// Each row names the general by Excel ID or name.
+func ImportInjuries(rows []InjuryImportRow) (int, error) {
+	db := database.GetDB()
+
+	imported := 0
+	for _, row := range rows {
+		var general model.General
+		var err error
+		if row.ExcelID > 0 {
+			err = db.Where("excel_id = ?", row.ExcelID).First(&general).Error
+		} else {
+			err = db.Where("name = ?", row.Name).First(&general).Error
+		}
+		if err == gorm.ErrRecordNotFound {
+			log.Printf("Injury import: general %d %q not found, skipped", row.ExcelID, row.Name)
+			continue
+		}
+		if err != nil {
+			return imported, err
+		}
+
+		req := &InjuryRequest{
+			GeneralID: general.ID,
+			Rounds:    row.Rounds,
+			FromRound: row.FromRound,
+			Reason:    row.Reason,
+		}
+		if _, err := InjureGeneral(req, InjurySourceImport, 0); err != nil {
+			if err == ErrInvalidInjury {
+				log.Printf("Injury import: %s: %v, skipped", general.Name, err)
+				continue
+			}
+			return imported, fmt.Errorf("injure %s: %w", general.Name, err)
+		}
+		imported++
+	}
+	return imported, nil
+}
+
+// InjuryImportRow is one row of the "伤病" import sheet
+type InjuryImportRow struct {
+	ExcelID   int
+	Name      string
+	Rounds    int
+	FromRound int
+	Reason    string
+}
@@ -0,0 +1,671 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+var (
+	ErrLineupNotFound       = errors.New("lineup not found")
+	ErrLineupLocked         = errors.New("lineup is already locked")
+	ErrLineupDeadlinePassed = errors.New("lineup deadline has passed")
+	ErrLineupRoundPassed    = errors.New("cannot submit a lineup for a past round")
+	ErrIllegalLineup        = errors.New("illegal lineup")
+	ErrInvalidLineupRound   = errors.New("invalid lineup round settings")
+	ErrNotInMatchPhase      = errors.New("lineups can only be submitted in the match phase")
+)
+
+// finalLineupStatuses are the statuses of lineups fixed for their round
+// An illegal lineup is one that failed validation at the deadline.
+var finalLineupStatuses = []string{"locked", "illegal"}
+
+// Default lineup size rules for rounds without settings
+const (
+	DefaultLineupMinGenerals = 1
+)
+
+// LineupRequest submits a lineup for a round
+type LineupRequest struct {
+	RoundNumber int    `json:"round_number"` // 0 = current round
+	GeneralIDs  []uint `json:"general_ids"`
+	TreasureIDs []uint `json:"treasure_ids"`
+	Lock        bool   `json:"lock"` // Lock right away instead of keeping a draft
+}
+
+// LineupRoundRequest sets the lineup rules of a round (admin)
+type LineupRoundRequest struct {
+	RoundNumber  int        `json:"round_number" binding:"required"`
+	LockAt       *time.Time `json:"lock_at"`
+	MinGenerals  int        `json:"min_generals"`
+	MaxGenerals  int        `json:"max_generals"`
+	MaxTreasures int        `json:"max_treasures"`
+}
+
+// LineupRestrictionRequest sets the lineup restriction of a club (admin)
+type LineupRestrictionRequest struct {
+	MaxGenerals  int    `json:"max_generals"`
+	MaxTreasures int    `json:"max_treasures"`
+	MaxSalary    int    `json:"max_salary"`
+	Note         string `json:"note"`
+}
+
+// MatchLineups are both lineups of a match; the opponent's stays hidden until both are locked
+type MatchLineups struct {
+	Match    *model.Match  `json:"match"`
+	Home     *model.Lineup `json:"home"`
+	Away     *model.Lineup `json:"away"`
+	Revealed bool          `json:"revealed"`
+}
+
+// GetLineupRound returns the lineup rules of a round, the defaults when none are set
+func GetLineupRound(round int) (*model.LineupRound, error) {
+	db := database.GetDB()
+
+	var settings model.LineupRound
+	if err := db.Where("round_number = ?", round).Limit(1).Find(&settings).Error; err != nil {
+		return nil, err
+	}
+	if settings.ID == 0 {
+		settings = model.LineupRound{
+			RoundNumber: round,
+			MinGenerals: DefaultLineupMinGenerals,
+		}
+	}
+	return &settings, nil
+}
+
+// GetLineupRounds returns the lineup rules of every configured round
+func GetLineupRounds() ([]model.LineupRound, error) {
+	db := database.GetDB()
+
+	var rounds []model.LineupRound
+	if err := db.Order("round_number ASC").Find(&rounds).Error; err != nil {
+		return nil, err
+	}
+	return rounds, nil
+}
+
+// SetLineupRound creates or replaces the lineup rules of a round (admin only)
+// Moving the deadline into the future reopens the round.
+func SetLineupRound(req *LineupRoundRequest) (*model.LineupRound, error) {
+	db := database.GetDB()
+
+	if req.RoundNumber < 1 || req.MinGenerals < 0 || req.MaxGenerals < 0 || req.MaxTreasures < 0 {
+		return nil, ErrInvalidLineupRound
+	}
+	if req.MaxGenerals > 0 && req.MinGenerals > req.MaxGenerals {
+		return nil, ErrInvalidLineupRound
+	}
+
+	settings, err := GetLineupRound(req.RoundNumber)
+	if err != nil {
+		return nil, err
+	}
+	settings.LockAt = req.LockAt
+	settings.MinGenerals = req.MinGenerals
+	settings.MaxGenerals = req.MaxGenerals
+	settings.MaxTreasures = req.MaxTreasures
+	if req.LockAt == nil || req.LockAt.After(time.Now()) {
+		settings.Closed = false
+	}
+
+	if err := db.Save(settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// GetLineupRestrictions returns the lineup restrictions of all clubs
+func GetLineupRestrictions() ([]model.LineupRestriction, error) {
+	db := database.GetDB()
+
+	var restrictions []model.LineupRestriction
+	if err := db.Order("club_id ASC").Find(&restrictions).Error; err != nil {
+		return nil, err
+	}
+	return restrictions, nil
+}
+
+// SetLineupRestriction creates or replaces the lineup restriction of a club (admin only)
+func SetLineupRestriction(clubID uint, req *LineupRestrictionRequest) (*model.LineupRestriction, error) {
+	db := database.GetDB()
+
+	if err := db.First(&model.Club{}, clubID).Error; err != nil {
+		return nil, ErrClubNotFound
+	}
+	if req.MaxGenerals < 0 || req.MaxTreasures < 0 || req.MaxSalary < 0 {
+		return nil, ErrInvalidLineupRound
+	}
+
+	var restriction model.LineupRestriction
+	if err := db.Where("club_id = ?", clubID).Limit(1).Find(&restriction).Error; err != nil {
+		return nil, err
+	}
+	restriction.ClubID = clubID
+	restriction.MaxGenerals = req.MaxGenerals
+	restriction.MaxTreasures = req.MaxTreasures
+	restriction.MaxSalary = req.MaxSalary
+	restriction.Note = req.Note
+
+	if err := db.Save(&restriction).Error; err != nil {
+		return nil, err
+	}
+	return &restriction, nil
+}
+
+// SubmitLineup saves a player's lineup for a round, locking it when asked to
+func SubmitLineup(userID uint, req *LineupRequest) (*model.Lineup, error) {
+	db := database.GetDB()
+
+	if err := checkLineupPhase(); err != nil {
+		return nil, err
+	}
+	round, err := resolveLineupRound(req.RoundNumber)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := GetLineupRound(round)
+	if err != nil {
+		return nil, err
+	}
+	if lineupDeadlinePassed(settings) {
+		return nil, ErrLineupDeadlinePassed
+	}
+
+	var lineup model.Lineup
+	if err := db.Where("user_id = ? AND round_number = ?", userID, round).Limit(1).Find(&lineup).Error; err != nil {
+		return nil, err
+	}
+	if lineup.Status == "locked" {
+		return nil, ErrLineupLocked
+	}
+
+	generalIDs := uniqueIDs(req.GeneralIDs)
+	treasureIDs := uniqueIDs(req.TreasureIDs)
+	if err := validateLineup(userID, settings, generalIDs, treasureIDs); err != nil {
+		return nil, err
+	}
+
+	generalsJSON, _ := json.Marshal(generalIDs)
+	treasuresJSON, _ := json.Marshal(treasureIDs)
+	lineup.UserID = userID
+	lineup.RoundNumber = round
+	lineup.Generals = string(generalsJSON)
+	lineup.Treasures = string(treasuresJSON)
+	lineup.Status = "draft"
+	lineup.Problems = ""
+
+	if err := db.Save(&lineup).Error; err != nil {
+		return nil, err
+	}
+
+	if req.Lock {
+		return LockLineup(userID, round)
+	}
+	if err := loadLineupItems(&lineup); err != nil {
+		return nil, err
+	}
+	return &lineup, nil
+}
+
+// LockLineup locks a player's draft lineup after checking it is still legal
+// Once both players of a match have locked, their lineups are revealed to each other.
+func LockLineup(userID uint, roundNumber int) (*model.Lineup, error) {
+	db := database.GetDB()
+
+	if err := checkLineupPhase(); err != nil {
+		return nil, err
+	}
+	round, err := resolveLineupRound(roundNumber)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := GetLineupRound(round)
+	if err != nil {
+		return nil, err
+	}
+	if lineupDeadlinePassed(settings) {
+		return nil, ErrLineupDeadlinePassed
+	}
+
+	var lineup model.Lineup
+	if err := db.Where("user_id = ? AND round_number = ?", userID, round).First(&lineup).Error; err != nil {
+		return nil, ErrLineupNotFound
+	}
+	if lineup.Status == "locked" {
+		return nil, ErrLineupLocked
+	}
+
+	// The roster may have changed through trades since the draft was saved
+	if err := validateLineup(userID, settings, lineupIDs(lineup.Generals), lineupIDs(lineup.Treasures)); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := db.Model(&model.Lineup{}).Where("id = ? AND status IN ?", lineup.ID, []string{"draft", "illegal"}).Updates(map[string]interface{}{
+		"status":    "locked",
+		"problems":  "",
+		"locked_at": now,
+	})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrLineupLocked
+	}
+	lineup.Status = "locked"
+	lineup.Problems = ""
+	lineup.LockedAt = &now
+
+	if err := publishLineupLocks(round, userID); err != nil {
+		return nil, err
+	}
+
+	if err := loadLineupItems(&lineup); err != nil {
+		return nil, err
+	}
+	return &lineup, nil
+}
+
+// GetMyLineup returns a player's own lineup for a round
+func GetMyLineup(userID uint, roundNumber int) (*model.Lineup, error) {
+	db := database.GetDB()
+
+	round, err := resolveLineupRound(roundNumber)
+	if err != nil && err != ErrLineupRoundPassed {
+		return nil, err
+	}
+
+	var lineup model.Lineup
+	if err := db.Where("user_id = ? AND round_number = ?", userID, round).First(&lineup).Error; err != nil {
+		return nil, ErrLineupNotFound
+	}
+	if err := loadLineupItems(&lineup); err != nil {
+		return nil, err
+	}
+	return &lineup, nil
+}
+
+// GetMatchLineups returns the lineups of a match as seen by viewerID
+// Players always see their own lineup and the opponent's only once both are locked; admins see both.
+func GetMatchLineups(matchID uint, viewerID uint, isAdmin bool) (*MatchLineups, error) {
+	db := database.GetDB()
+
+	match, err := GetMatchByID(matchID)
+	if err != nil {
+		return nil, err
+	}
+	if match.HomeID == nil || match.AwayID == nil {
+		return nil, ErrMatchNotPlayable
+	}
+	if !isAdmin && *match.HomeID != viewerID && *match.AwayID != viewerID {
+		return nil, ErrNotMatchPlayer
+	}
+
+	var lineups []model.Lineup
+	if err := db.Where("round_number = ? AND user_id IN ?", match.RoundNumber, []uint{*match.HomeID, *match.AwayID}).
+		Find(&lineups).Error; err != nil {
+		return nil, err
+	}
+
+	view := &MatchLineups{Match: match}
+	for i := range lineups {
+		if lineups[i].UserID == *match.HomeID {
+			view.Home = &lineups[i]
+		} else {
+			view.Away = &lineups[i]
+		}
+	}
+	view.Revealed = lineupsRevealed(view.Home, view.Away)
+
+	if !view.Revealed && !isAdmin {
+		if *match.HomeID != viewerID {
+			view.Home = hiddenLineup(view.Home)
+		}
+		if *match.AwayID != viewerID {
+			view.Away = hiddenLineup(view.Away)
+		}
+	}
+
+	for _, lineup := range []*model.Lineup{view.Home, view.Away} {
+		if lineup != nil && lineup.Generals != "" {
+			if err := loadLineupItems(lineup); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return view, nil
+}
+
+// GetRoundLineups returns every lineup of a round (admin)
+func GetRoundLineups(round int) ([]model.Lineup, error) {
+	db := database.GetDB()
+
+	var lineups []model.Lineup
+	if err := db.Where("round_number = ?", round).Preload("User").Order("user_id ASC").Find(&lineups).Error; err != nil {
+		return nil, err
+	}
+	for i := range lineups {
+		if err := loadLineupItems(&lineups[i]); err != nil {
+			return nil, err
+		}
+	}
+	return lineups, nil
+}
+
+// LockDueLineups locks the draft lineups of every round whose deadline has passed
+// Lineups that are no longer legal (e.g. a general was traded away) are marked
+// illegal with the reasons, for the admin to rule on.
+func LockDueLineups() (int, error) {
+	db := database.GetDB()
+
+	var rounds []model.LineupRound
+	if err := db.Where("closed = ? AND lock_at IS NOT NULL AND lock_at <= ?", false, time.Now()).
+		Find(&rounds).Error; err != nil {
+		return 0, err
+	}
+
+	locked := 0
+	for i := range rounds {
+		settings := &rounds[i]
+
+		var drafts []model.Lineup
+		if err := db.Where("round_number = ? AND status = ?", settings.RoundNumber, "draft").Find(&drafts).Error; err != nil {
+			return locked, err
+		}
+
+		now := time.Now()
+		roundLocked := 0
+
+		// Begin transaction
+		tx := db.Begin()
+
+		for _, lineup := range drafts {
+			updates := map[string]interface{}{
+				"status":    "locked",
+				"locked_at": now,
+			}
+			if err := validateLineup(lineup.UserID, settings, lineupIDs(lineup.Generals), lineupIDs(lineup.Treasures)); err != nil {
+				if !errors.Is(err, ErrIllegalLineup) {
+					tx.Rollback()
+					return locked, err
+				}
+				log.Printf("Round %d: lineup of user %d is illegal at the deadline: %v", settings.RoundNumber, lineup.UserID, err)
+				updates["status"] = "illegal"
+				updates["problems"] = strings.TrimPrefix(err.Error(), ErrIllegalLineup.Error()+": ")
+			}
+
+			result := tx.Model(&model.Lineup{}).Where("id = ? AND status = ?", lineup.ID, "draft").Updates(updates)
+			if result.Error != nil {
+				tx.Rollback()
+				return locked, result.Error
+			}
+			roundLocked += int(result.RowsAffected)
+		}
+		if err := tx.Model(settings).Update("closed", true).Error; err != nil {
+			tx.Rollback()
+			return locked, err
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return locked, err
+		}
+		locked += roundLocked
+
+		if err := publishLineupLocks(settings.RoundNumber, 0); err != nil {
+			return locked, err
+		}
+	}
+	return locked, nil
+}
+
+// NextLineupDeadline returns the earliest open lineup deadline, or nil. Used by the background scheduler.
+func NextLineupDeadline() (*time.Time, error) {
+	db := database.GetDB()
+
+	var settings model.LineupRound
+	err := db.Where("closed = ? AND lock_at IS NOT NULL", false).
+		Order("lock_at ASC").
+		Limit(1).
+		Find(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	if settings.ID == 0 {
+		return nil, nil
+	}
+	return settings.LockAt, nil
+}
+
+// validateLineup checks a lineup against the player's roster, injuries, the round's size rules
+// and the restriction of the player's club
+func validateLineup(userID uint, settings *model.LineupRound, generalIDs []uint, treasureIDs []uint) error {
+	db := database.GetDB()
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	var problems []string
+
+	var generals []model.General
+	if len(generalIDs) > 0 {
+		if err := db.Where("id IN ?", generalIDs).Find(&generals).Error; err != nil {
+			return err
+		}
+	}
+	if len(generals) != len(generalIDs) {
+		problems = append(problems, "unknown general")
+	}
+	salary := 0
+	for i := range generals {
+		general := &generals[i]
+		if general.OwnerID == nil || *general.OwnerID != userID {
+			problems = append(problems, fmt.Sprintf("%s is not on your roster", general.Name))
+		}
+		if generalInjured(general, settings.RoundNumber) {
+			problems = append(problems, fmt.Sprintf("%s is injured until round %d", general.Name, *general.InjuredUntil))
+		}
+		salary += general.Salary
+	}
+
+	var treasures []model.Treasure
+	if len(treasureIDs) > 0 {
+		if err := db.Where("id IN ?", treasureIDs).Find(&treasures).Error; err != nil {
+			return err
+		}
+	}
+	if len(treasures) != len(treasureIDs) {
+		problems = append(problems, "unknown treasure")
+	}
+	for _, treasure := range treasures {
+		if treasure.OwnerID == nil || *treasure.OwnerID != userID {
+			problems = append(problems, fmt.Sprintf("%s is not on your roster", treasure.Name))
+		}
+	}
+
+	if len(generalIDs) < settings.MinGenerals {
+		problems = append(problems, fmt.Sprintf("at least %d generals required", settings.MinGenerals))
+	}
+	if settings.MaxGenerals > 0 && len(generalIDs) > settings.MaxGenerals {
+		problems = append(problems, fmt.Sprintf("at most %d generals allowed", settings.MaxGenerals))
+	}
+	if settings.MaxTreasures > 0 && len(treasureIDs) > settings.MaxTreasures {
+		problems = append(problems, fmt.Sprintf("at most %d treasures allowed", settings.MaxTreasures))
+	}
+
+	// Club policy restriction
+	if user.ClubID != nil {
+		var restriction model.LineupRestriction
+		if err := db.Where("club_id = ?", *user.ClubID).Limit(1).Find(&restriction).Error; err != nil {
+			return err
+		}
+		if restriction.MaxGenerals > 0 && len(generalIDs) > restriction.MaxGenerals {
+			problems = append(problems, fmt.Sprintf("your club allows at most %d generals", restriction.MaxGenerals))
+		}
+		if restriction.MaxTreasures > 0 && len(treasureIDs) > restriction.MaxTreasures {
+			problems = append(problems, fmt.Sprintf("your club allows at most %d treasures", restriction.MaxTreasures))
+		}
+		if restriction.MaxSalary > 0 {
+			// Active 国策 can raise the cap
+			bonus, err := policyEffectTotal(userID, PolicyEffectLineupSalary)
+			if err != nil {
+				return err
+			}
+			if maxSalary := restriction.MaxSalary + bonus; salary > maxSalary {
+				problems = append(problems, fmt.Sprintf("your club allows a salary of at most %d, lineup has %d", maxSalary, salary))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrIllegalLineup, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// checkLineupPhase rejects lineup changes outside the match phase
+func checkLineupPhase() error {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return err
+	}
+	if phase.CurrentPhase != "match" {
+		return ErrNotInMatchPhase
+	}
+	return nil
+}
+
+// resolveLineupRound turns 0 into the current round and rejects past rounds
+func resolveLineupRound(round int) (int, error) {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return 0, err
+	}
+	if round <= 0 {
+		return phase.RoundNumber, nil
+	}
+	if round < phase.RoundNumber {
+		return round, ErrLineupRoundPassed
+	}
+	return round, nil
+}
+
+// lineupDeadlinePassed reports whether a round no longer takes lineup changes
+func lineupDeadlinePassed(settings *model.LineupRound) bool {
+	return settings.Closed || (settings.LockAt != nil && !settings.LockAt.After(time.Now()))
+}
+
+// lineupsRevealed reports whether both lineups of a match are final
+func lineupsRevealed(home, away *model.Lineup) bool {
+	return home != nil && away != nil && home.Status != "draft" && away.Status != "draft"
+}
+
+// hiddenLineup strips the picks from an opponent's lineup, keeping only whether it was submitted
+func hiddenLineup(lineup *model.Lineup) *model.Lineup {
+	if lineup == nil {
+		return nil
+	}
+	return &model.Lineup{
+		ID:          lineup.ID,
+		UserID:      lineup.UserID,
+		RoundNumber: lineup.RoundNumber,
+		Status:      lineup.Status,
+		LockedAt:    lineup.LockedAt,
+		CreatedAt:   lineup.CreatedAt,
+		UpdatedAt:   lineup.UpdatedAt,
+	}
+}
+
+// publishLineupLocks tells opponents a lineup was locked and reveals the matches of the round
+// where both sides have now locked. userID limits it to that player's matches, 0 = whole round.
+func publishLineupLocks(round int, userID uint) error {
+	db := database.GetDB()
+
+	query := db.Where("round_number = ? AND home_id IS NOT NULL AND away_id IS NOT NULL", round)
+	if userID > 0 {
+		query = query.Where("home_id = ? OR away_id = ?", userID, userID)
+	}
+	var matches []model.Match
+	if err := query.Find(&matches).Error; err != nil {
+		return err
+	}
+
+	for _, match := range matches {
+		var lineups []model.Lineup
+		if err := db.Where("round_number = ? AND user_id IN ? AND status IN ?", round, []uint{*match.HomeID, *match.AwayID}, finalLineupStatuses).
+			Find(&lineups).Error; err != nil {
+			return err
+		}
+
+		if len(lineups) == 2 {
+			PublishEvent(EventLineupRevealed, map[string]interface{}{
+				"match_id": match.ID,
+				"round":    round,
+			}, *match.HomeID, *match.AwayID)
+			continue
+		}
+		if userID > 0 {
+			opponentID := *match.HomeID
+			if opponentID == userID {
+				opponentID = *match.AwayID
+			}
+			PublishEvent(EventLineupLocked, map[string]interface{}{
+				"match_id": match.ID,
+				"round":    round,
+				"user_id":  userID,
+			}, opponentID)
+		}
+	}
+	return nil
+}
+
+// loadLineupItems fills in the generals and treasures of a lineup
+func loadLineupItems(lineup *model.Lineup) error {
+	db := database.GetDB()
+
+	lineup.GeneralList = nil
+	lineup.TreasureList = nil
+	if ids := lineupIDs(lineup.Generals); len(ids) > 0 {
+		if err := db.Where("id IN ?", ids).Find(&lineup.GeneralList).Error; err != nil {
+			return err
+		}
+		for i := range lineup.GeneralList {
+			lineup.GeneralList[i].Injured = generalInjured(&lineup.GeneralList[i], lineup.RoundNumber)
+		}
+	}
+	if ids := lineupIDs(lineup.Treasures); len(ids) > 0 {
+		if err := db.Where("id IN ?", ids).Find(&lineup.TreasureList).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lineupIDs decodes a JSON array of IDs stored on a lineup
+func lineupIDs(field string) []uint {
+	var ids []uint
+	json.Unmarshal([]byte(field), &ids)
+	return ids
+}
+
+// uniqueIDs drops duplicate IDs, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	out := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
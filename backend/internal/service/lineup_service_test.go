@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"san11-trade/internal/model"
+)
+
+func TestLineupLockAndReveal(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("match", 1, 0)
+
+	home := createTestUser(t, db, "home")
+	away := createTestUser(t, db, "away")
+	homeGeneral := createTestGeneral(t, db, 1, "draft", 100)
+	awayGeneral := createTestGeneral(t, db, 2, "draft", 100)
+	giveTestGeneral(t, db, homeGeneral, home)
+	giveTestGeneral(t, db, awayGeneral, away)
+	match, err := CreateMatch(&CreateMatchRequest{HomeID: home.ID, AwayID: away.ID, RoundNumber: 1}, "admin")
+	if err != nil {
+		t.Fatalf("CreateMatch: %v", err)
+	}
+
+	if _, err := SubmitLineup(home.ID, &LineupRequest{GeneralIDs: []uint{awayGeneral.ID}}); !errors.Is(err, ErrIllegalLineup) {
+		t.Errorf("lineup with an opponent's general = %v, want ErrIllegalLineup", err)
+	}
+	if _, err := SubmitLineup(home.ID, &LineupRequest{GeneralIDs: []uint{homeGeneral.ID}}); err != nil {
+		t.Fatalf("SubmitLineup home: %v", err)
+	}
+	if _, err := SubmitLineup(away.ID, &LineupRequest{GeneralIDs: []uint{awayGeneral.ID}}); err != nil {
+		t.Fatalf("SubmitLineup away: %v", err)
+	}
+	if _, err := LockLineup(home.ID, 0); err != nil {
+		t.Fatalf("LockLineup home: %v", err)
+	}
+
+	// Only one side locked: each still sees just its own picks
+	view, err := GetMatchLineups(match.ID, away.ID, false)
+	if err != nil {
+		t.Fatalf("GetMatchLineups: %v", err)
+	}
+	if view.Revealed {
+		t.Error("lineups revealed with the away lineup still a draft")
+	}
+	if view.Home == nil || view.Home.Status != "locked" || view.Home.Generals != "" {
+		t.Errorf("away sees home lineup %+v, want it locked with its picks hidden", view.Home)
+	}
+	if view.Away == nil || len(view.Away.GeneralList) != 1 {
+		t.Errorf("away sees own lineup %+v, want its general", view.Away)
+	}
+
+	if _, err := SubmitLineup(home.ID, &LineupRequest{GeneralIDs: []uint{homeGeneral.ID}}); err != ErrLineupLocked {
+		t.Errorf("resubmit a locked lineup = %v, want ErrLineupLocked", err)
+	}
+	if _, err := LockLineup(away.ID, 0); err != nil {
+		t.Fatalf("LockLineup away: %v", err)
+	}
+
+	view, err = GetMatchLineups(match.ID, away.ID, false)
+	if err != nil {
+		t.Fatalf("GetMatchLineups: %v", err)
+	}
+	if !view.Revealed {
+		t.Error("lineups not revealed with both locked")
+	}
+	if view.Home == nil || len(view.Home.GeneralList) != 1 || view.Home.GeneralList[0].ID != homeGeneral.ID {
+		t.Errorf("away sees home lineup %+v, want its general revealed", view.Home)
+	}
+
+	SetGamePhase("trading", 1, 0)
+	if _, err := SubmitLineup(home.ID, &LineupRequest{GeneralIDs: []uint{homeGeneral.ID}}); err != ErrNotInMatchPhase {
+		t.Errorf("submit outside the match phase = %v, want ErrNotInMatchPhase", err)
+	}
+}
+
+func TestLockDueLineups(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("match", 1, 0)
+
+	home := createTestUser(t, db, "home")
+	away := createTestUser(t, db, "away")
+	homeGeneral := createTestGeneral(t, db, 1, "draft", 100)
+	awayGeneral := createTestGeneral(t, db, 2, "draft", 100)
+	giveTestGeneral(t, db, homeGeneral, home)
+	giveTestGeneral(t, db, awayGeneral, away)
+	match, err := CreateMatch(&CreateMatchRequest{HomeID: home.ID, AwayID: away.ID, RoundNumber: 1}, "admin")
+	if err != nil {
+		t.Fatalf("CreateMatch: %v", err)
+	}
+
+	lockAt := time.Now().Add(time.Hour)
+	if _, err := SetLineupRound(&LineupRoundRequest{RoundNumber: 1, LockAt: &lockAt, MinGenerals: 1}); err != nil {
+		t.Fatalf("SetLineupRound: %v", err)
+	}
+	if _, err := SubmitLineup(home.ID, &LineupRequest{GeneralIDs: []uint{homeGeneral.ID}}); err != nil {
+		t.Fatalf("SubmitLineup home: %v", err)
+	}
+	if _, err := SubmitLineup(away.ID, &LineupRequest{GeneralIDs: []uint{awayGeneral.ID}}); err != nil {
+		t.Fatalf("SubmitLineup away: %v", err)
+	}
+
+	// The away general is traded to home after the draft was saved
+	db.Model(&model.General{}).Where("id = ?", awayGeneral.ID).Update("owner_id", home.ID)
+	db.Model(&model.LineupRound{}).Where("round_number = ?", 1).Update("lock_at", time.Now().Add(-time.Minute))
+
+	locked, err := LockDueLineups()
+	if err != nil {
+		t.Fatalf("LockDueLineups: %v", err)
+	}
+	if locked != 2 {
+		t.Errorf("LockDueLineups locked %d lineups, want 2", locked)
+	}
+
+	var homeLineup, awayLineup model.Lineup
+	db.Where("user_id = ? AND round_number = ?", home.ID, 1).First(&homeLineup)
+	db.Where("user_id = ? AND round_number = ?", away.ID, 1).First(&awayLineup)
+	if homeLineup.Status != "locked" || homeLineup.LockedAt == nil {
+		t.Errorf("home lineup status = %q, want locked", homeLineup.Status)
+	}
+	if awayLineup.Status != "illegal" || awayLineup.Problems == "" {
+		t.Errorf("away lineup status = %q problems = %q, want illegal with problems", awayLineup.Status, awayLineup.Problems)
+	}
+
+	settings, err := GetLineupRound(1)
+	if err != nil {
+		t.Fatalf("GetLineupRound: %v", err)
+	}
+	if !settings.Closed {
+		t.Error("round not closed after its deadline")
+	}
+	if _, err := SubmitLineup(away.ID, &LineupRequest{GeneralIDs: []uint{}}); err != ErrLineupDeadlinePassed {
+		t.Errorf("submit after the deadline = %v, want ErrLineupDeadlinePassed", err)
+	}
+
+	// An illegal lineup is final too, so the match is revealed
+	view, err := GetMatchLineups(match.ID, home.ID, false)
+	if err != nil {
+		t.Fatalf("GetMatchLineups: %v", err)
+	}
+	if !view.Revealed {
+		t.Error("lineups not revealed after the deadline")
+	}
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+var (
+	ErrListingNotFound      = errors.New("listing not found")
+	ErrInvalidListing       = errors.New("invalid listing")
+	ErrListingExists        = errors.New("this item is already on the block")
+	ErrListingClosed        = errors.New("listing is closed")
+	ErrOwnListing           = errors.New("you cannot respond to your own listing")
+	ErrListingFilterNoMatch = errors.New("offered general does not match the wanted listing")
+)
+
+// Listing types
+const (
+	ListingBlock  = "block"  // An owned general/treasure is available
+	ListingWanted = "wanted" // Looking for a general matching filters
+)
+
+// aptitudeKeys maps aptitude filter keys (English or 枪/戟/弩/骑/兵/水) to General fields
+var aptitudeKeys = map[string]string{
+	"spear": "spear", "枪": "spear",
+	"halberd": "halberd", "戟": "halberd",
+	"crossbow": "crossbow", "弩": "crossbow",
+	"cavalry": "cavalry", "骑": "cavalry",
+	"soldier": "soldier", "兵": "soldier",
+	"water": "water", "水": "water",
+}
+
+// statKeys maps stat filter keys (English or 统率/武力/智力/政治/魅力) to General fields
+var statKeys = map[string]string{
+	"command": "command", "统率": "command",
+	"force": "force", "武力": "force",
+	"intelligence": "intelligence", "智力": "intelligence",
+	"politics": "politics", "政治": "politics",
+	"charm": "charm", "魅力": "charm",
+}
+
+// aptitudeRanks orders aptitude grades for "at least" comparisons
+var aptitudeRanks = map[string]int{"C": 1, "B": 2, "A": 3, "S": 4}
+
+// GeneralFilter describes the generals a wanted listing or search is after
+type GeneralFilter struct {
+	Keyword   string            `json:"keyword,omitempty"`   // Substring of the name
+	Skill     string            `json:"skill,omitempty"`     // Required 特技
+	Aptitudes map[string]string `json:"aptitudes,omitempty"` // Minimum grade per aptitude, e.g. {"骑": "S"}
+	MinStats  map[string]int    `json:"min_stats,omitempty"` // Minimum per stat, e.g. {"武力": 90}
+	MaxSalary int               `json:"max_salary,omitempty"`
+}
+
+// ListingRequest creates a listing
+type ListingRequest struct {
+	Type        string         `json:"type"` // block/wanted
+	GeneralID   *uint          `json:"general_id"`
+	TreasureID  *uint          `json:"treasure_id"`
+	AskingSpace int            `json:"asking_space"`
+	Terms       string         `json:"terms"`
+	Filters     *GeneralFilter `json:"filters"`
+}
+
+// ListingQuery filters the listing search
+type ListingQuery struct {
+	Type     string // block/wanted, empty for both
+	ItemType string // general/treasure, block listings only
+	UserID   uint
+	General  GeneralFilter // Applied to the listed general of block listings
+}
+
+// ListingProposalRequest optionally adjusts the trade generated from a listing
+type ListingProposalRequest struct {
+	OfferGenerals  []uint     `json:"offer_generals"`
+	OfferTreasures []uint     `json:"offer_treasures"`
+	OfferSpace     *int       `json:"offer_space"`   // Defaults to the block listing's asking space
+	RequestSpace   *int       `json:"request_space"` // Defaults to the wanted listing's offered space
+	Message        string     `json:"message"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+}
+
+// CreateListing puts an owned asset on the block or posts a wanted listing
+func CreateListing(userID uint, req *ListingRequest) (*model.TradeListing, error) {
+	db := database.GetDB()
+
+	if req.AskingSpace < 0 {
+		return nil, ErrInvalidListing
+	}
+
+	listing := &model.TradeListing{
+		UserID:      userID,
+		Type:        req.Type,
+		AskingSpace: req.AskingSpace,
+		Terms:       req.Terms,
+		Status:      "active",
+	}
+
+	switch req.Type {
+	case ListingBlock:
+		// Exactly one owned item
+		if (req.GeneralID == nil) == (req.TreasureID == nil) {
+			return nil, ErrInvalidListing
+		}
+		var generalIDs, treasureIDs []uint
+		query := db.Model(&model.TradeListing{}).Where("type = ? AND status = ?", ListingBlock, "active")
+		if req.GeneralID != nil {
+			generalIDs = []uint{*req.GeneralID}
+			query = query.Where("general_id = ?", *req.GeneralID)
+		} else {
+			treasureIDs = []uint{*req.TreasureID}
+			query = query.Where("treasure_id = ?", *req.TreasureID)
+		}
+		if err := validateOwnership(userID, generalIDs, treasureIDs); err != nil {
+			return nil, err
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			return nil, ErrListingExists
+		}
+		listing.GeneralID = req.GeneralID
+		listing.TreasureID = req.TreasureID
+
+	case ListingWanted:
+		if req.Filters == nil {
+			return nil, ErrInvalidListing
+		}
+		if err := normalizeGeneralFilter(req.Filters); err != nil {
+			return nil, err
+		}
+		filtersJSON, _ := json.Marshal(req.Filters)
+		listing.Filters = string(filtersJSON)
+
+	default:
+		return nil, ErrInvalidListing
+	}
+
+	if err := db.Create(listing).Error; err != nil {
+		return nil, err
+	}
+
+	return GetListingByID(listing.ID)
+}
+
+// CloseListing takes a listing down (owner only)
+func CloseListing(listingID uint, userID uint) error {
+	db := database.GetDB()
+
+	var listing model.TradeListing
+	if err := db.First(&listing, listingID).Error; err != nil {
+		return ErrListingNotFound
+	}
+	if listing.UserID != userID {
+		return ErrNotTradeParticipant
+	}
+	if listing.Status != "active" {
+		return ErrListingClosed
+	}
+
+	return db.Model(&listing).Update("status", "closed").Error
+}
+
+// GetListingByID returns a listing with its item
+func GetListingByID(listingID uint) (*model.TradeListing, error) {
+	db := database.GetDB()
+
+	var listing model.TradeListing
+	if err := db.Preload("User").Preload("General").Preload("Treasure").First(&listing, listingID).Error; err != nil {
+		return nil, ErrListingNotFound
+	}
+	return &listing, nil
+}
+
+// SearchListings browses active listings
+// Block listings whose item changed hands are closed on the way.
+func SearchListings(query *ListingQuery) ([]model.TradeListing, error) {
+	db := database.GetDB()
+
+	if err := normalizeGeneralFilter(&query.General); err != nil {
+		return nil, err
+	}
+
+	q := db.Where("status = ?", "active")
+	if query.Type != "" {
+		q = q.Where("type = ?", query.Type)
+	}
+	if query.UserID != 0 {
+		q = q.Where("user_id = ?", query.UserID)
+	}
+	switch query.ItemType {
+	case "general":
+		q = q.Where("general_id IS NOT NULL")
+	case "treasure":
+		q = q.Where("treasure_id IS NOT NULL")
+	}
+
+	var listings []model.TradeListing
+	if err := q.Preload("User").Preload("General").Preload("Treasure").
+		Order("created_at DESC").
+		Find(&listings).Error; err != nil {
+		return nil, err
+	}
+
+	var result []model.TradeListing
+	var stale []uint
+	for _, listing := range listings {
+		if listing.Type == ListingBlock {
+			if !listingItemOwned(&listing) {
+				stale = append(stale, listing.ID)
+				continue
+			}
+			if listing.General != nil && !matchGeneral(listing.General, &query.General) {
+				continue
+			}
+			if listing.General == nil && !query.General.isEmpty() {
+				continue
+			}
+		}
+		result = append(result, listing)
+	}
+
+	if len(stale) > 0 {
+		db.Model(&model.TradeListing{}).Where("id IN ?", stale).Update("status", "closed")
+	}
+
+	return result, nil
+}
+
+// GetListingMatches returns owned generals of other players that satisfy a wanted listing
+func GetListingMatches(listingID uint) ([]model.General, error) {
+	db := database.GetDB()
+
+	listing, err := GetListingByID(listingID)
+	if err != nil {
+		return nil, err
+	}
+	if listing.Type != ListingWanted {
+		return nil, ErrInvalidListing
+	}
+	filter, err := parseListingFilter(listing)
+	if err != nil {
+		return nil, err
+	}
+
+	var generals []model.General
+	if err := db.Where("owner_id IS NOT NULL AND owner_id <> ?", listing.UserID).
+		Preload("Owner").
+		Order("salary DESC").
+		Find(&generals).Error; err != nil {
+		return nil, err
+	}
+
+	matches := []model.General{}
+	for i := range generals {
+		if matchGeneral(&generals[i], filter) {
+			matches = append(matches, generals[i])
+		}
+	}
+	return matches, nil
+}
+
+// ProposeFromListing turns a listing into a trade proposal to the listing owner
+// A block listing requests the listed item and offers the asking space by
+// default; a wanted listing offers the caller's matching generals.
+func ProposeFromListing(listingID uint, userID uint, req *ListingProposalRequest) (*model.Trade, error) {
+	listing, err := GetListingByID(listingID)
+	if err != nil {
+		return nil, err
+	}
+	if listing.Status != "active" {
+		return nil, ErrListingClosed
+	}
+	if listing.UserID == userID {
+		return nil, ErrOwnListing
+	}
+
+	tradeReq := &TradeRequest{
+		ReceiverID:     listing.UserID,
+		OfferGenerals:  req.OfferGenerals,
+		OfferTreasures: req.OfferTreasures,
+		Message:        req.Message,
+		ExpiresAt:      req.ExpiresAt,
+		ListingID:      &listing.ID,
+	}
+	if req.OfferSpace != nil {
+		tradeReq.OfferSpace = *req.OfferSpace
+	}
+	if req.RequestSpace != nil {
+		tradeReq.RequestSpace = *req.RequestSpace
+	}
+
+	switch listing.Type {
+	case ListingBlock:
+		if listing.GeneralID != nil {
+			tradeReq.RequestGenerals = []uint{*listing.GeneralID}
+		} else {
+			tradeReq.RequestTreasures = []uint{*listing.TreasureID}
+		}
+		if req.OfferSpace == nil {
+			tradeReq.OfferSpace = listing.AskingSpace
+		}
+
+	case ListingWanted:
+		if len(req.OfferGenerals) == 0 {
+			return nil, ErrListingFilterNoMatch
+		}
+		filter, err := parseListingFilter(listing)
+		if err != nil {
+			return nil, err
+		}
+		db := database.GetDB()
+		for _, gid := range req.OfferGenerals {
+			var general model.General
+			if err := db.First(&general, gid).Error; err != nil {
+				return nil, ErrInvalidTradeItems
+			}
+			if !matchGeneral(&general, filter) {
+				return nil, ErrListingFilterNoMatch
+			}
+		}
+		if req.RequestSpace == nil {
+			tradeReq.RequestSpace = listing.AskingSpace
+		}
+	}
+
+	return CreateTrade(userID, tradeReq)
+}
+
+// listingItemOwned reports whether a block listing's owner still holds the item
+func listingItemOwned(listing *model.TradeListing) bool {
+	if listing.General != nil {
+		return listing.General.OwnerID != nil && *listing.General.OwnerID == listing.UserID
+	}
+	if listing.Treasure != nil {
+		return listing.Treasure.OwnerID != nil && *listing.Treasure.OwnerID == listing.UserID
+	}
+	return false
+}
+
+// parseListingFilter decodes a wanted listing's filters
+func parseListingFilter(listing *model.TradeListing) (*GeneralFilter, error) {
+	var filter GeneralFilter
+	if err := json.Unmarshal([]byte(listing.Filters), &filter); err != nil {
+		return nil, ErrInvalidListing
+	}
+	return &filter, nil
+}
+
+// normalizeGeneralFilter maps Chinese or English keys to field names and validates grades
+func normalizeGeneralFilter(filter *GeneralFilter) error {
+	if len(filter.Aptitudes) > 0 {
+		aptitudes := make(map[string]string, len(filter.Aptitudes))
+		for key, grade := range filter.Aptitudes {
+			field, ok := aptitudeKeys[strings.ToLower(strings.TrimSpace(key))]
+			grade = strings.ToUpper(strings.TrimSpace(grade))
+			if !ok || aptitudeRanks[grade] == 0 {
+				return ErrInvalidListing
+			}
+			aptitudes[field] = grade
+		}
+		filter.Aptitudes = aptitudes
+	}
+	if len(filter.MinStats) > 0 {
+		stats := make(map[string]int, len(filter.MinStats))
+		for key, min := range filter.MinStats {
+			field, ok := statKeys[strings.ToLower(strings.TrimSpace(key))]
+			if !ok {
+				return ErrInvalidListing
+			}
+			stats[field] = min
+		}
+		filter.MinStats = stats
+	}
+	return nil
+}
+
+// isEmpty reports whether the filter restricts anything
+func (f *GeneralFilter) isEmpty() bool {
+	return f.Keyword == "" && f.Skill == "" && len(f.Aptitudes) == 0 && len(f.MinStats) == 0 && f.MaxSalary == 0
+}
+
+// matchGeneral checks a general against a normalized filter
+func matchGeneral(general *model.General, filter *GeneralFilter) bool {
+	if filter.Keyword != "" && !strings.Contains(general.Name, filter.Keyword) {
+		return false
+	}
+	if filter.MaxSalary > 0 && general.Salary > filter.MaxSalary {
+		return false
+	}
+	if filter.Skill != "" {
+		found := false
+		for _, skill := range splitSkills(general.Skills) {
+			if skill == filter.Skill {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	aptitudes := map[string]string{
+		"spear":    general.Spear,
+		"halberd":  general.Halberd,
+		"crossbow": general.Crossbow,
+		"cavalry":  general.Cavalry,
+		"soldier":  general.Soldier,
+		"water":    general.Water,
+	}
+	for field, min := range filter.Aptitudes {
+		grade := strings.ToUpper(strings.TrimSpace(aptitudes[field]))
+		if grade == "" || aptitudeRanks[grade[:1]] < aptitudeRanks[min] {
+			return false
+		}
+	}
+
+	stats := map[string]int{
+		"command":      general.Command,
+		"force":        general.Force,
+		"intelligence": general.Intelligence,
+		"politics":     general.Politics,
+		"charm":        general.Charm,
+	}
+	for field, min := range filter.MinStats {
+		if stats[field] < min {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,512 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotMatchPlayer         = errors.New("you are not playing in this match")
+	ErrInvalidMatchPlayers    = errors.New("a match needs two different registered players")
+	ErrNoPendingReport        = errors.New("no result is waiting for confirmation")
+	ErrCannotConfirmOwnReport = errors.New("the opponent has to confirm your result")
+	ErrDisputeReasonRequired  = errors.New("a reason is required to dispute a result")
+	ErrInvalidMatchStage      = errors.New("matches can only be added to the league or a group")
+	ErrMatchDisputed          = errors.New("the result is disputed, an admin has to decide it")
+	ErrMatchNotReportable     = errors.New("the match can no longer be reported, please reload")
+)
+
+// reportableMatchStatuses are the statuses players may still report on
+// A disputed match only moves on through RecordMatchResult.
+var reportableMatchStatuses = []string{"scheduled", "reported"}
+
+// CreateMatchRequest creates a one-off match (admin)
+type CreateMatchRequest struct {
+	HomeID      uint   `json:"home_id" binding:"required"`
+	AwayID      uint   `json:"away_id" binding:"required"`
+	RoundNumber int    `json:"round_number"` // 0 = current round
+	Stage       string `json:"stage"`        // group/league, default league
+	GroupID     *uint  `json:"group_id"`
+}
+
+// MatchReportRequest is a player's result submission
+type MatchReportRequest struct {
+	HomeScore  int    `json:"home_score"`
+	AwayScore  int    `json:"away_score"`
+	SaveHash   string `json:"save_hash"`  // Hash of the save file
+	Screenshot string `json:"screenshot"` // Screenshot URL or reference
+	Note       string `json:"note"`
+}
+
+// MatchImportRow is one row of the "赛果" import sheet
+type MatchImportRow struct {
+	RoundNumber  int
+	Home         string // Nickname or username
+	Away         string
+	HomeScore    *int // nil = not played yet
+	AwayScore    *int
+	Injured      string // Generals injured in the match, 、-separated
+	InjuryRounds int
+}
+
+// CreateMatch schedules a match between two registered players
+func CreateMatch(req *CreateMatchRequest, source string) (*model.Match, error) {
+	db := database.GetDB()
+
+	if req.HomeID == req.AwayID {
+		return nil, ErrInvalidMatchPlayers
+	}
+	for _, userID := range []uint{req.HomeID, req.AwayID} {
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil || !user.IsRegistered {
+			return nil, ErrInvalidMatchPlayers
+		}
+	}
+
+	stage := req.Stage
+	if stage == "" {
+		stage = MatchStageLeague
+	}
+	if stage != MatchStageLeague && (stage != MatchStageGroup || req.GroupID == nil) {
+		return nil, ErrInvalidMatchStage
+	}
+
+	round := req.RoundNumber
+	if round <= 0 {
+		phase, err := GetGamePhase()
+		if err != nil {
+			return nil, err
+		}
+		round = phase.RoundNumber
+	}
+
+	homeID, awayID := req.HomeID, req.AwayID
+	match := &model.Match{
+		Stage:       stage,
+		GroupID:     req.GroupID,
+		RoundNumber: round,
+		HomeID:      &homeID,
+		AwayID:      &awayID,
+		Status:      "scheduled",
+		Source:      source,
+	}
+	if err := db.Create(match).Error; err != nil {
+		return nil, err
+	}
+
+	return GetMatchByID(match.ID)
+}
+
+// SubmitMatchReport records a player's result for their match
+// A matching report from the opponent completes the match; a conflicting one disputes it.
+func SubmitMatchReport(userID uint, matchID uint, req *MatchReportRequest) (*model.MatchReport, error) {
+	db := database.GetDB()
+
+	match, err := getOpenMatchForPlayer(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if req.HomeScore < 0 || req.AwayScore < 0 {
+		return nil, ErrInvalidMatchScore
+	}
+	if match.Stage == MatchStageKnockout && req.HomeScore == req.AwayScore {
+		return nil, ErrKnockoutDraw
+	}
+
+	var pending model.MatchReport
+	if err := db.Where("match_id = ? AND status = ?", match.ID, "pending").
+		Order("id DESC").Limit(1).Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	report := &model.MatchReport{
+		MatchID:    match.ID,
+		ReportedBy: userID,
+		HomeScore:  req.HomeScore,
+		AwayScore:  req.AwayScore,
+		SaveHash:   req.SaveHash,
+		Screenshot: req.Screenshot,
+		Note:       req.Note,
+		Status:     "pending",
+	}
+	matchStatus := "reported"
+	now := time.Now()
+
+	// Begin transaction
+	tx := db.Begin()
+
+	switch {
+	case pending.ID == 0:
+	case pending.ReportedBy == userID:
+		// Resubmission replaces the previous report
+		if err := tx.Model(&model.MatchReport{}).Where("id = ?", pending.ID).Update("status", "superseded").Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	case pending.HomeScore == req.HomeScore && pending.AwayScore == req.AwayScore:
+		// Both players agree
+		report.Status = "confirmed"
+		matchStatus = "completed"
+		if err := tx.Model(&model.MatchReport{}).Where("id = ?", pending.ID).Updates(map[string]interface{}{
+			"status":       "confirmed",
+			"responded_by": userID,
+			"responded_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	default:
+		// The players disagree; an admin has to resolve it
+		report.Status = "disputed"
+		matchStatus = "disputed"
+		if err := tx.Model(&model.MatchReport{}).Where("id = ?", pending.ID).Updates(map[string]interface{}{
+			"status":       "disputed",
+			"responded_by": userID,
+			"response":     fmt.Sprintf("Opponent reported %d:%d", req.HomeScore, req.AwayScore),
+			"responded_at": now,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Create(report).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if matchStatus == "completed" {
+		if err := completeMatch(tx, match, req.HomeScore, req.AwayScore, reportableMatchStatuses); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	} else if err := setMatchStatus(tx, match, matchStatus); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	publishMatchStatus(match, matchStatus)
+	return report, nil
+}
+
+// ConfirmMatchReport accepts the opponent's pending result and completes the match
+func ConfirmMatchReport(userID uint, matchID uint) (*model.Match, error) {
+	db := database.GetDB()
+
+	match, err := getOpenMatchForPlayer(matchID, userID)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := getPendingMatchReport(match.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	result := tx.Model(&model.MatchReport{}).Where("id = ? AND status = ?", pending.ID, "pending").Updates(map[string]interface{}{
+		"status":       "confirmed",
+		"responded_by": userID,
+		"responded_at": time.Now(),
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrNoPendingReport
+	}
+
+	if err := completeMatch(tx, match, pending.HomeScore, pending.AwayScore, reportableMatchStatuses); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	publishMatchStatus(match, "completed")
+	return GetMatchByID(match.ID)
+}
+
+// DisputeMatchReport rejects the opponent's pending result and sends the match to an admin
+func DisputeMatchReport(userID uint, matchID uint, reason string) error {
+	db := database.GetDB()
+
+	if strings.TrimSpace(reason) == "" {
+		return ErrDisputeReasonRequired
+	}
+
+	match, err := getOpenMatchForPlayer(matchID, userID)
+	if err != nil {
+		return err
+	}
+	pending, err := getPendingMatchReport(match.ID, userID)
+	if err != nil {
+		return err
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	result := tx.Model(&model.MatchReport{}).Where("id = ? AND status = ?", pending.ID, "pending").Updates(map[string]interface{}{
+		"status":       "disputed",
+		"responded_by": userID,
+		"response":     reason,
+		"responded_at": time.Now(),
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrNoPendingReport
+	}
+
+	if err := setMatchStatus(tx, match, "disputed"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishMatchStatus(match, "disputed")
+	return nil
+}
+
+// GetDisputedMatches returns matches waiting for an admin decision with their reports
+func GetDisputedMatches() ([]model.Match, error) {
+	db := database.GetDB()
+
+	var matches []model.Match
+	if err := db.Where("status = ?", "disputed").
+		Preload("Home").Preload("Away").
+		Preload("Reports", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).Preload("Reports.Reporter").
+		Order("round_number ASC, id ASC").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// getOpenMatchForPlayer loads a match still waiting for a result and checks the user plays in it
+func getOpenMatchForPlayer(matchID uint, userID uint) (*model.Match, error) {
+	db := database.GetDB()
+
+	var match model.Match
+	if err := db.First(&match, matchID).Error; err != nil {
+		return nil, ErrMatchNotFound
+	}
+	if match.HomeID == nil || match.AwayID == nil {
+		return nil, ErrMatchNotPlayable
+	}
+	if *match.HomeID != userID && *match.AwayID != userID {
+		return nil, ErrNotMatchPlayer
+	}
+	if match.Status == "completed" || match.Status == "bye" {
+		return nil, ErrMatchAlreadyCompleted
+	}
+	if match.Status == "disputed" {
+		return nil, ErrMatchDisputed
+	}
+	return &match, nil
+}
+
+// getPendingMatchReport returns the opponent's pending report on a match
+func getPendingMatchReport(matchID uint, userID uint) (*model.MatchReport, error) {
+	db := database.GetDB()
+
+	var pending model.MatchReport
+	if err := db.Where("match_id = ? AND status = ?", matchID, "pending").
+		Order("id DESC").Limit(1).Find(&pending).Error; err != nil {
+		return nil, err
+	}
+	if pending.ID == 0 {
+		return nil, ErrNoPendingReport
+	}
+	if pending.ReportedBy == userID {
+		return nil, ErrCannotConfirmOwnReport
+	}
+	return &pending, nil
+}
+
+// setMatchStatus moves a reportable match to reported or disputed inside tx
+func setMatchStatus(tx *gorm.DB, match *model.Match, status string) error {
+	result := tx.Model(&model.Match{}).Where("id = ? AND status IN ?", match.ID, reportableMatchStatuses).Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMatchNotReportable
+	}
+	match.Status = status
+	return nil
+}
+
+// publishMatchStatus notifies both players that a match changed status
+func publishMatchStatus(match *model.Match, status string) {
+	var recipients []uint
+	if match.HomeID != nil {
+		recipients = append(recipients, *match.HomeID)
+	}
+	if match.AwayID != nil {
+		recipients = append(recipients, *match.AwayID)
+	}
+
+	if status == "completed" {
+		PublishEvent(EventMatchCompleted, match)
+		return
+	}
+	PublishEvent(EventMatchReported, map[string]interface{}{
+		"match_id": match.ID,
+		"status":   status,
+	}, recipients...)
+}
+
+// ImportMatches creates matches and records results from imported rows
+// Rows name players by nickname or username; a row for a pair that already has a
+// match in that round fills in that match instead of creating a new one. Rows of
+// matches that are already completed are skipped, so importing a sheet again never
+// counts a result or its injuries twice.
+func ImportMatches(rows []MatchImportRow) (int, error) {
+	db := database.GetDB()
+
+	imported := 0
+	for _, row := range rows {
+		home, err := findPlayerByName(row.Home)
+		if err != nil {
+			log.Printf("Match import: player %q not found, skipped", row.Home)
+			continue
+		}
+		away, err := findPlayerByName(row.Away)
+		if err != nil {
+			log.Printf("Match import: player %q not found, skipped", row.Away)
+			continue
+		}
+
+		var match model.Match
+		if err := db.Where("round_number = ? AND ((home_id = ? AND away_id = ?) OR (home_id = ? AND away_id = ?))",
+			row.RoundNumber, home.ID, away.ID, away.ID, home.ID).
+			Order("id asc").Limit(1).Find(&match).Error; err != nil {
+			return imported, err
+		}
+		if match.Status == "completed" {
+			if row.HomeScore != nil && row.AwayScore != nil && !importedScoreMatches(&match, home.ID, *row.HomeScore, *row.AwayScore) {
+				log.Printf("Match import: %s vs %s round %d is already completed %d:%d, correct it from the match instead; skipped",
+					row.Home, row.Away, row.RoundNumber, match.HomeScore, match.AwayScore)
+			}
+			continue
+		}
+		if match.ID == 0 {
+			created, err := CreateMatch(&CreateMatchRequest{
+				HomeID:      home.ID,
+				AwayID:      away.ID,
+				RoundNumber: row.RoundNumber,
+			}, "import")
+			if err != nil {
+				log.Printf("Match import: %s vs %s: %v, skipped", row.Home, row.Away, err)
+				continue
+			}
+			match = *created
+		}
+
+		played := false
+		if row.HomeScore != nil && row.AwayScore != nil {
+			homeScore, awayScore := *row.HomeScore, *row.AwayScore
+			// The sheet may list the pair the other way round
+			if *match.HomeID != home.ID {
+				homeScore, awayScore = awayScore, homeScore
+			}
+
+			tx := db.Begin()
+			if err := completeMatch(tx, &match, homeScore, awayScore, openMatchStatuses); err != nil {
+				tx.Rollback()
+				log.Printf("Match import: %s vs %s: %v, skipped", row.Home, row.Away, err)
+				continue
+			}
+			if err := tx.Model(&model.MatchReport{}).Where("match_id = ? AND status IN ?", match.ID, []string{"pending", "disputed"}).
+				Update("status", "resolved").Error; err != nil {
+				tx.Rollback()
+				return imported, err
+			}
+			if err := tx.Commit().Error; err != nil {
+				return imported, err
+			}
+			PublishEvent(EventMatchCompleted, match)
+			played = true
+		}
+
+		// Injuries picked up in the match keep the general out from the next round
+		if played && row.Injured != "" {
+			rounds := row.InjuryRounds
+			if rounds <= 0 {
+				rounds = 1
+			}
+			for _, name := range splitSkills(row.Injured) {
+				var general model.General
+				if err := db.Where("name = ?", name).First(&general).Error; err != nil {
+					log.Printf("Match import: general %q not found, injury skipped", name)
+					continue
+				}
+				req := &InjuryRequest{
+					GeneralID: general.ID,
+					Rounds:    rounds,
+					FromRound: row.RoundNumber + 1,
+					Reason:    fmt.Sprintf("Round %d: %s vs %s", row.RoundNumber, home.Nickname, away.Nickname),
+				}
+				if _, err := InjureGeneral(req, InjurySourceMatch, 0); err != nil {
+					return imported, err
+				}
+			}
+		}
+
+		imported++
+	}
+	return imported, nil
+}
+
+// importedScoreMatches reports whether an imported score is the one a match was completed with
+// homeID is the player the sheet lists first, who may be the away side of the match.
+func importedScoreMatches(match *model.Match, homeID uint, homeScore, awayScore int) bool {
+	if *match.HomeID != homeID {
+		homeScore, awayScore = awayScore, homeScore
+	}
+	return match.HomeScore == homeScore && match.AwayScore == awayScore
+}
+
+// findPlayerByName looks up a registered player by nickname, username or ID
+func findPlayerByName(name string) (*model.User, error) {
+	db := database.GetDB()
+
+	name = strings.TrimSpace(name)
+	var user model.User
+	query := db.Where("is_registered = ? AND (nickname = ? OR username = ?)", true, name, name)
+	if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+		query = db.Where("is_registered = ? AND (nickname = ? OR username = ? OR id = ?)", true, name, name, id)
+	}
+	if err := query.First(&user).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
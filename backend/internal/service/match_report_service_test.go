@@ -0,0 +1,97 @@
+package service
+
+import (
+	"testing"
+
+	"san11-trade/internal/model"
+)
+
+func TestImportMatchesTwice(t *testing.T) {
+	db := setupTestDB(t)
+
+	home := createTestUser(t, db, "player1")
+	away := createTestUser(t, db, "player2")
+	general := createTestGeneral(t, db, 1, "draft", 10)
+
+	two, one := 2, 1
+	rows := []MatchImportRow{
+		{RoundNumber: 1, Home: home.Username, Away: away.Username, HomeScore: &two, AwayScore: &one, Injured: general.Name, InjuryRounds: 2},
+	}
+	if count, err := ImportMatches(rows); err != nil || count != 1 {
+		t.Fatalf("first ImportMatches = %d, %v, want 1", count, err)
+	}
+
+	// The same sheet again, and the same result with the pair the other way round
+	if count, err := ImportMatches(rows); err != nil || count != 0 {
+		t.Errorf("second ImportMatches = %d, %v, want 0", count, err)
+	}
+	reversed := []MatchImportRow{
+		{RoundNumber: 1, Home: away.Username, Away: home.Username, HomeScore: &one, AwayScore: &two, Injured: general.Name, InjuryRounds: 2},
+	}
+	if count, err := ImportMatches(reversed); err != nil || count != 0 {
+		t.Errorf("reversed ImportMatches = %d, %v, want 0", count, err)
+	}
+	// A different score leaves the completed match alone
+	conflicting := []MatchImportRow{
+		{RoundNumber: 1, Home: home.Username, Away: away.Username, HomeScore: &one, AwayScore: &two},
+	}
+	if count, err := ImportMatches(conflicting); err != nil || count != 0 {
+		t.Errorf("conflicting ImportMatches = %d, %v, want 0", count, err)
+	}
+
+	var matches []model.Match
+	db.Find(&matches)
+	if len(matches) != 1 {
+		t.Fatalf("%d matches after importing twice, want 1", len(matches))
+	}
+	if matches[0].Status != "completed" || matches[0].HomeScore != 2 || matches[0].AwayScore != 1 {
+		t.Errorf("match is %s %d:%d, want completed 2:1", matches[0].Status, matches[0].HomeScore, matches[0].AwayScore)
+	}
+
+	var injuries int64
+	db.Model(&model.Injury{}).Count(&injuries)
+	if injuries != 1 {
+		t.Errorf("%d injuries after importing twice, want 1", injuries)
+	}
+
+	standings, err := GetStandings()
+	if err != nil {
+		t.Fatalf("GetStandings: %v", err)
+	}
+	for _, row := range standings.Overall {
+		if row.Played != 1 {
+			t.Errorf("user %d played %d matches, want 1", row.UserID, row.Played)
+		}
+	}
+	if top := standings.Overall[0]; top.UserID != home.ID || top.Points != pointsWin {
+		t.Errorf("top of the table is user %d with %d points, want user %d with %d", top.UserID, top.Points, home.ID, pointsWin)
+	}
+}
+
+func TestImportMatchesFillsScheduledMatch(t *testing.T) {
+	db := setupTestDB(t)
+
+	home := createTestUser(t, db, "player1")
+	away := createTestUser(t, db, "player2")
+	scheduled, err := CreateMatch(&CreateMatchRequest{HomeID: home.ID, AwayID: away.ID, RoundNumber: 3}, "admin")
+	if err != nil {
+		t.Fatalf("CreateMatch: %v", err)
+	}
+
+	zero, three := 0, 3
+	rows := []MatchImportRow{{RoundNumber: 3, Home: away.Username, Away: home.Username, HomeScore: &three, AwayScore: &zero}}
+	if count, err := ImportMatches(rows); err != nil || count != 1 {
+		t.Fatalf("ImportMatches = %d, %v, want 1", count, err)
+	}
+
+	var match model.Match
+	db.First(&match, scheduled.ID)
+	if match.Status != "completed" || match.HomeScore != 0 || match.AwayScore != 3 {
+		t.Errorf("match is %s %d:%d, want completed 0:3", match.Status, match.HomeScore, match.AwayScore)
+	}
+	var count int64
+	db.Model(&model.Match{}).Count(&count)
+	if count != 1 {
+		t.Errorf("%d matches, want the scheduled one only", count)
+	}
+}
@@ -0,0 +1,636 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMultiTradeNotFound     = errors.New("multi-party trade not found")
+	ErrTooFewTradeParticipant = errors.New("a multi-party trade needs at least three participants")
+	ErrProposerNotInTrade     = errors.New("proposer must send or receive something in the trade")
+	ErrDuplicateTradeItem     = errors.New("the same item is listed more than once")
+	ErrAlreadyAcceptedTrade   = errors.New("you have already accepted this trade")
+)
+
+// MultiTradeItemRequest is one asset moving from one participant to another
+type MultiTradeItemRequest struct {
+	FromUserID uint   `json:"from_user_id"`
+	ToUserID   uint   `json:"to_user_id"`
+	Type       string `json:"type"`   // general/treasure/space
+	ID         uint   `json:"id"`     // General or treasure ID
+	Amount     int    `json:"amount"` // Space amount
+}
+
+// MultiTradeRequest represents a multi-party trade proposal
+type MultiTradeRequest struct {
+	Items   []MultiTradeItemRequest `json:"items"`
+	Message string                  `json:"message"`
+}
+
+// CreateMultiTrade creates a trade between three or more players
+// The proposer accepts implicitly; every other participant must accept.
+func CreateMultiTrade(proposerID uint, req *MultiTradeRequest) (*model.MultiTrade, error) {
+	db := database.GetDB()
+
+	// Check phase and trade window
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate items and collect participants in order of appearance
+	var participantIDs []uint
+	seenUsers := make(map[uint]bool)
+	addParticipant := func(id uint) {
+		if !seenUsers[id] {
+			seenUsers[id] = true
+			participantIDs = append(participantIDs, id)
+		}
+	}
+	seenGenerals := make(map[uint]bool)
+	seenTreasures := make(map[uint]bool)
+	versions := make(map[string]int)
+
+	if len(req.Items) == 0 {
+		return nil, ErrInvalidTradeItems
+	}
+	for _, item := range req.Items {
+		if item.FromUserID == 0 || item.ToUserID == 0 {
+			return nil, ErrInvalidTradeItems
+		}
+		if item.FromUserID == item.ToUserID {
+			return nil, ErrCannotTradeWithSelf
+		}
+
+		switch item.Type {
+		case "general":
+			if seenGenerals[item.ID] {
+				return nil, ErrDuplicateTradeItem
+			}
+			seenGenerals[item.ID] = true
+			if err := recordItemVersions(item.FromUserID, []uint{item.ID}, nil, versions); err != nil {
+				return nil, err
+			}
+		case "treasure":
+			if seenTreasures[item.ID] {
+				return nil, ErrDuplicateTradeItem
+			}
+			seenTreasures[item.ID] = true
+			if err := recordItemVersions(item.FromUserID, nil, []uint{item.ID}, versions); err != nil {
+				return nil, err
+			}
+		case "space":
+			if item.Amount <= 0 {
+				return nil, ErrInvalidTradeItems
+			}
+		default:
+			return nil, ErrInvalidTradeItems
+		}
+
+		addParticipant(item.FromUserID)
+		addParticipant(item.ToUserID)
+	}
+
+	if !seenUsers[proposerID] {
+		return nil, ErrProposerNotInTrade
+	}
+	if len(participantIDs) < 3 {
+		return nil, ErrTooFewTradeParticipant
+	}
+
+	// No participant may be past the window's trade limit
+	if err := checkTradeLimit(db, window, phase.RoundNumber, participantIDs...); err != nil {
+		return nil, err
+	}
+
+	// Make sure every participant exists
+	var count int64
+	if err := db.Model(&model.User{}).Where("id IN ?", participantIDs).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if int(count) != len(participantIDs) {
+		return nil, ErrUserNotFound
+	}
+
+	// Score what each participant gives and gets so lopsided deals stand out
+	giveValues, receiveValues, err := valueMultiTrade(req.Items)
+	if err != nil {
+		return nil, err
+	}
+	imbalance := 0.0
+	for _, userID := range participantIDs {
+		if v := tradeImbalance(giveValues[userID], receiveValues[userID]); v > imbalance {
+			imbalance = v
+		}
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	trade := &model.MultiTrade{
+		ProposerID: proposerID,
+		Status:     "pending",
+		Message:    req.Message,
+		Imbalance:  imbalance,
+	}
+	if err := tx.Create(trade).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, userID := range participantIDs {
+		participant := model.MultiTradeParticipant{
+			MultiTradeID: trade.ID,
+			UserID:       userID,
+			GiveValue:    giveValues[userID],
+			ReceiveValue: receiveValues[userID],
+		}
+		if userID == proposerID {
+			participant.Accepted = true
+			participant.AcceptedAt = &now
+		}
+		if err := tx.Create(&participant).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	for _, item := range req.Items {
+		record := model.MultiTradeItem{
+			MultiTradeID: trade.ID,
+			FromUserID:   item.FromUserID,
+			ToUserID:     item.ToUserID,
+			ItemType:     item.Type,
+			ItemID:       item.ID,
+			Amount:       item.Amount,
+		}
+		if item.Type == "space" {
+			record.ItemID = 0
+		} else {
+			record.Amount = 0
+			version := versions[itemVersionKey(item.Type, item.ID)]
+			record.Version = &version
+		}
+		if err := tx.Create(&record).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := logMultiTradeTx(tx, trade.ID, "created", proposerID, "Multi-party trade created"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	result, err := GetMultiTradeByID(trade.ID)
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventTradeCreated, result, participantIDs...)
+
+	return result, nil
+}
+
+// AcceptMultiTrade records a participant's acceptance
+// The last acceptance settles the whole trade in one transaction, or queues it
+// for approval when the league reviews trades.
+func AcceptMultiTrade(tradeID uint, userID uint) error {
+	db := database.GetDB()
+
+	trade, err := GetMultiTradeByID(tradeID)
+	if err != nil {
+		return err
+	}
+	participant := findMultiTradeParticipant(trade, userID)
+	if participant == nil {
+		return ErrNotTradeParticipant
+	}
+	if trade.Status != "pending" {
+		return ErrTradeAlreadyProcessed
+	}
+	if participant.Accepted {
+		return ErrAlreadyAcceptedTrade
+	}
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return err
+	}
+	if err := checkTradeLimit(db, window, phase.RoundNumber, multiTradeParticipantIDs(trade)...); err != nil {
+		return err
+	}
+	settings, err := GetTradeSettings()
+	if err != nil {
+		return err
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	// Make sure the trade is still open while we record the answer
+	var current model.MultiTrade
+	if err := tx.First(&current, trade.ID).Error; err != nil {
+		tx.Rollback()
+		return ErrMultiTradeNotFound
+	}
+	if current.Status != "pending" {
+		tx.Rollback()
+		return ErrTradeAlreadyProcessed
+	}
+
+	now := time.Now()
+	result := tx.Model(&model.MultiTradeParticipant{}).
+		Where("id = ? AND accepted = ?", participant.ID, false).
+		Updates(map[string]interface{}{
+			"accepted":    true,
+			"accepted_at": now,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrAlreadyAcceptedTrade
+	}
+	if err := logMultiTradeTx(tx, trade.ID, "accepted", userID, "Participant accepted"); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var waiting int64
+	if err := tx.Model(&model.MultiTradeParticipant{}).
+		Where("multi_trade_id = ? AND accepted = ?", trade.ID, false).
+		Count(&waiting).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if waiting > 0 {
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+		PublishEvent(EventTradeUpdated, map[string]interface{}{
+			"multi_trade_id": trade.ID,
+			"status":         "pending",
+			"accepted_by":    userID,
+		}, multiTradeParticipantIDs(trade)...)
+		return nil
+	}
+
+	// Everyone agreed: leagues with a review step hold the trade for approval
+	if settings.ReviewMode != TradeReviewNone {
+		if err := submitMultiTradeForReview(tx, trade, userID, settings); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+		publishMultiTradeReview(trade, settings)
+		return nil
+	}
+
+	cancelled, cancelledMulti, effects, err := settleMultiTrade(tx, trade, "pending", window, phase.RoundNumber, userID)
+	if err != nil {
+		tx.Rollback()
+		return failMultiTrade(trade, "pending", err)
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	finishMultiTrade(trade, cancelled, cancelledMulti, effects)
+
+	return nil
+}
+
+// executeMultiTrade settles a multi-party trade that is awaiting approval
+func executeMultiTrade(trade *model.MultiTrade, round int, performedBy uint, action, details string) error {
+	db := database.GetDB()
+
+	window, err := tradeLimitWindow(round)
+	if err != nil {
+		return err
+	}
+
+	tx := db.Begin()
+
+	cancelled, cancelledMulti, effects, err := settleMultiTrade(tx, trade, "awaiting_approval", window, round, performedBy)
+	if err != nil {
+		tx.Rollback()
+		return failMultiTrade(trade, "awaiting_approval", err)
+	}
+	if err := logMultiTradeTx(tx, trade.ID, action, performedBy, details); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	finishMultiTrade(trade, cancelled, cancelledMulti, effects)
+
+	return nil
+}
+
+// failMultiTrade cancels a multi-party trade whose assets changed hands since it was proposed
+// Other errors are returned as-is so the caller can retry.
+func failMultiTrade(trade *model.MultiTrade, fromStatus string, err error) error {
+	if err != ErrItemNotOwned && err != ErrInvalidTradeItems && err != ErrItemChanged {
+		return err
+	}
+
+	// The trade can never settle
+	db := database.GetDB()
+	result := db.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trade.ID, fromStatus).Update("status", "cancelled")
+	if result.Error == nil && result.RowsAffected > 0 {
+		logMultiTradeTx(db, trade.ID, "auto_cancelled", 0, "A participant no longer owns the listed items")
+		publishMultiTradeStatus(trade, "cancelled")
+	}
+	return errors.New("a participant no longer owns the listed items")
+}
+
+// finishMultiTrade announces a settled multi-party trade and what it changed
+func finishMultiTrade(trade *model.MultiTrade, cancelled []model.Trade, cancelledMulti []model.MultiTrade, effects []clubEffectsUpdate) {
+	publishClubEffects(effects)
+
+	publishMultiTradeStatus(trade, "accepted")
+	PublishEvent(EventTradeCompleted, map[string]interface{}{
+		"multi_trade_id": trade.ID,
+		"participants":   multiTradeParticipantIDs(trade),
+	})
+	for i := range cancelled {
+		publishTradeStatus(&cancelled[i], "cancelled")
+	}
+	for i := range cancelledMulti {
+		publishMultiTradeStatus(&cancelledMulti[i], "cancelled")
+	}
+}
+
+// settleMultiTrade moves every item of a trade currently in fromStatus inside tx
+func settleMultiTrade(tx *gorm.DB, trade *model.MultiTrade, fromStatus string, window *model.TradeWindow, round int, performedBy uint) ([]model.Trade, []model.MultiTrade, []clubEffectsUpdate, error) {
+	// Trades settled since the caller's check count too
+	if err := checkTradeLimit(tx, window, round, multiTradeParticipantIDs(trade)...); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Claim the trade; a concurrent reject/cancel loses here
+	result := tx.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trade.ID, fromStatus).Updates(map[string]interface{}{
+		"status":         "accepted",
+		"accepted_at":    time.Now(),
+		"accepted_round": round,
+	})
+	if result.Error != nil {
+		return nil, nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, nil, ErrTradeAlreadyProcessed
+	}
+
+	spaceChanges := make(map[uint]int)
+	var movedGenerals, movedTreasures []uint
+
+	for _, item := range trade.Items {
+		switch item.ItemType {
+		case "general":
+			salary, err := transferGeneral(tx, item.ItemID, item.FromUserID, item.ToUserID, item.Version)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			spaceChanges[item.FromUserID] -= salary
+			spaceChanges[item.ToUserID] += salary
+			movedGenerals = append(movedGenerals, item.ItemID)
+		case "treasure":
+			if err := transferTreasure(tx, item.ItemID, item.FromUserID, item.ToUserID, item.Version); err != nil {
+				return nil, nil, nil, err
+			}
+			movedTreasures = append(movedTreasures, item.ItemID)
+		case "space":
+			// Same direction as Trade.OfferSpace
+			spaceChanges[item.FromUserID] -= item.Amount
+			spaceChanges[item.ToUserID] += item.Amount
+		}
+
+		if err := logMultiTradeTx(tx, trade.ID, "transferred", performedBy, multiTradeItemDetails(item)); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// Validate and apply each participant's space
+	for _, participant := range trade.Participants {
+		if err := applyTradeSpace(tx, participant.UserID, spaceChanges[participant.UserID]); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// A participant cannot be pushed over the cap by a lost 国策 bonus either
+	effects, err := applyClubEffects(tx, multiTradeParticipantIDs(trade)...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err := checkClubEffectsCap(effects); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Other pending trades can no longer be executed as proposed
+	reason := fmt.Sprintf("Auto-cancelled: assets moved by accepted multi-party trade #%d", trade.ID)
+	cancelled, err := cancelConflictingTrades(tx, 0, performedBy, reason, movedGenerals, movedTreasures)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cancelledMulti, err := cancelConflictingMultiTrades(tx, trade.ID, movedGenerals, movedTreasures)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return cancelled, cancelledMulti, effects, nil
+}
+
+// RejectMultiTrade rejects a multi-party trade on behalf of any participant
+func RejectMultiTrade(tradeID uint, userID uint) error {
+	return closeMultiTrade(tradeID, userID, "rejected")
+}
+
+// CancelMultiTrade withdraws a multi-party trade (proposer only)
+func CancelMultiTrade(tradeID uint, userID uint) error {
+	return closeMultiTrade(tradeID, userID, "cancelled")
+}
+
+// closeMultiTrade moves a pending multi-party trade to rejected or cancelled
+func closeMultiTrade(tradeID uint, userID uint, status string) error {
+	db := database.GetDB()
+
+	trade, err := GetMultiTradeByID(tradeID)
+	if err != nil {
+		return err
+	}
+	if status == "cancelled" && trade.ProposerID != userID {
+		return ErrNotTradeParticipant
+	}
+	if findMultiTradeParticipant(trade, userID) == nil {
+		return ErrNotTradeParticipant
+	}
+	if trade.Status != "pending" {
+		return ErrTradeAlreadyProcessed
+	}
+
+	result := db.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trade.ID, "pending").Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeAlreadyProcessed
+	}
+	if err := logMultiTradeTx(db, trade.ID, status, userID, "Multi-party trade "+status); err != nil {
+		return err
+	}
+
+	publishMultiTradeStatus(trade, status)
+
+	return nil
+}
+
+// GetMultiTrades returns multi-party trades the user takes part in
+func GetMultiTrades(userID uint) ([]model.MultiTrade, error) {
+	db := database.GetDB()
+
+	var trades []model.MultiTrade
+	if err := db.Where("id IN (?)", db.Model(&model.MultiTradeParticipant{}).Select("multi_trade_id").Where("user_id = ?", userID)).
+		Preload("Proposer").Preload("Participants.User").Preload("Items").
+		Order("created_at DESC").
+		Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range trades {
+		if err := flagInjuredMultiTradeGenerals(&trades[i]); err != nil {
+			return nil, err
+		}
+	}
+	return trades, nil
+}
+
+// GetMultiTradeByID returns a multi-party trade with participants and items
+func GetMultiTradeByID(tradeID uint) (*model.MultiTrade, error) {
+	db := database.GetDB()
+
+	var trade model.MultiTrade
+	if err := db.Preload("Proposer").Preload("Participants.User").Preload("Items").First(&trade, tradeID).Error; err != nil {
+		return nil, ErrMultiTradeNotFound
+	}
+
+	// Warn about injured generals changing hands
+	if err := flagInjuredMultiTradeGenerals(&trade); err != nil {
+		return nil, err
+	}
+
+	return &trade, nil
+}
+
+// cancelConflictingMultiTrades cancels other open multi-party trades that reference any moved asset
+func cancelConflictingMultiTrades(tx *gorm.DB, acceptedID uint, generalIDs, treasureIDs []uint) ([]model.MultiTrade, error) {
+	if len(generalIDs) == 0 && len(treasureIDs) == 0 {
+		return nil, nil
+	}
+
+	// IN () is not valid SQL, so pad both lists with an ID that never exists
+	generals := append([]uint{0}, generalIDs...)
+	treasures := append([]uint{0}, treasureIDs...)
+
+	var pending []model.MultiTrade
+	query := tx.Model(&model.MultiTrade{}).Where("status IN ? AND id <> ?", openTradeStatuses, acceptedID)
+	query = query.Where("id IN (?)", tx.Model(&model.MultiTradeItem{}).Select("multi_trade_id").
+		Where("(item_type = ? AND item_id IN ?) OR (item_type = ? AND item_id IN ?)",
+			"general", generals, "treasure", treasures))
+	if err := query.Preload("Participants").Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	var cancelled []model.MultiTrade
+	for _, other := range pending {
+		result := tx.Model(&model.MultiTrade{}).Where("id = ? AND status IN ?", other.ID, openTradeStatuses).Update("status", "cancelled")
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := logMultiTradeTx(tx, other.ID, "auto_cancelled", 0, "Auto-cancelled: assets moved by another trade"); err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, other)
+	}
+
+	return cancelled, nil
+}
+
+// findMultiTradeParticipant returns the user's participant row, or nil
+func findMultiTradeParticipant(trade *model.MultiTrade, userID uint) *model.MultiTradeParticipant {
+	for i := range trade.Participants {
+		if trade.Participants[i].UserID == userID {
+			return &trade.Participants[i]
+		}
+	}
+	return nil
+}
+
+// multiTradeParticipantIDs returns the user IDs of all participants
+func multiTradeParticipantIDs(trade *model.MultiTrade) []uint {
+	ids := make([]uint, 0, len(trade.Participants))
+	for _, participant := range trade.Participants {
+		ids = append(ids, participant.UserID)
+	}
+	return ids
+}
+
+// publishMultiTradeStatus notifies all participants that a multi-party trade changed status
+func publishMultiTradeStatus(trade *model.MultiTrade, status string) {
+	PublishEvent(EventTradeUpdated, map[string]interface{}{
+		"multi_trade_id": trade.ID,
+		"status":         status,
+	}, multiTradeParticipantIDs(trade)...)
+}
+
+// valueMultiTrade scores what each participant sends and receives
+func valueMultiTrade(items []MultiTradeItemRequest) (map[uint]float64, map[uint]float64, error) {
+	give := make(map[uint]float64)
+	receive := make(map[uint]float64)
+	for _, item := range items {
+		var side *SideValuation
+		var err error
+		switch item.Type {
+		case "general":
+			side, err = valueSide([]uint{item.ID}, nil, 0)
+		case "treasure":
+			side, err = valueSide(nil, []uint{item.ID}, 0)
+		default:
+			side, err = valueSide(nil, nil, item.Amount)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		give[item.FromUserID] = roundValue(give[item.FromUserID] + side.Total)
+		receive[item.ToUserID] = roundValue(receive[item.ToUserID] + side.Total)
+	}
+	return give, receive, nil
+}
+
+// multiTradeItemDetails describes a settled multi-party trade item for the trade log
+func multiTradeItemDetails(item model.MultiTradeItem) string {
+	if item.ItemType == "space" {
+		return fmt.Sprintf("space %d: user #%d -> user #%d", item.Amount, item.FromUserID, item.ToUserID)
+	}
+	return fmt.Sprintf("%s #%d: user #%d -> user #%d", item.ItemType, item.ItemID, item.FromUserID, item.ToUserID)
+}
@@ -0,0 +1,266 @@
+package service
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// threeWayTrade is a round robin between three players, each sending one general
+type threeWayTrade struct {
+	users    [3]*model.User
+	generals [3]*model.General
+	trade    *model.MultiTrade
+}
+
+// createThreeWayTrade has users[i] send generals[i] to users[i+1], proposed by users[0]
+func createThreeWayTrade(t *testing.T, db *gorm.DB, salaries [3]int) *threeWayTrade {
+	t.Helper()
+	tw := &threeWayTrade{}
+	for i, name := range []string{"a", "b", "c"} {
+		tw.users[i] = createTestUser(t, db, name)
+		tw.generals[i] = createTestGeneral(t, db, uint(i+1), "draft", salaries[i])
+		giveTestGeneral(t, db, tw.generals[i], tw.users[i])
+	}
+
+	var items []MultiTradeItemRequest
+	for i := range tw.users {
+		items = append(items, MultiTradeItemRequest{
+			FromUserID: tw.users[i].ID,
+			ToUserID:   tw.users[(i+1)%3].ID,
+			Type:       "general",
+			ID:         tw.generals[i].ID,
+		})
+	}
+	trade, err := CreateMultiTrade(tw.users[0].ID, &MultiTradeRequest{Items: items})
+	if err != nil {
+		t.Fatalf("CreateMultiTrade: %v", err)
+	}
+	tw.trade = trade
+	return tw
+}
+
+// generalOwner returns the current owner of a general, 0 when unowned
+func generalOwner(t *testing.T, db *gorm.DB, generalID uint) uint {
+	t.Helper()
+	var general model.General
+	if err := db.First(&general, generalID).Error; err != nil {
+		t.Fatalf("load general %d: %v", generalID, err)
+	}
+	if general.OwnerID == nil {
+		return 0
+	}
+	return *general.OwnerID
+}
+
+func TestCreateMultiTradeChecks(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	a := createTestUser(t, db, "a")
+	b := createTestUser(t, db, "b")
+	c := createTestUser(t, db, "c")
+	general := createTestGeneral(t, db, 1, "draft", 10)
+	giveTestGeneral(t, db, general, a)
+
+	twoWay := []MultiTradeItemRequest{
+		{FromUserID: a.ID, ToUserID: b.ID, Type: "general", ID: general.ID},
+		{FromUserID: b.ID, ToUserID: a.ID, Type: "space", Amount: 5},
+	}
+	if _, err := CreateMultiTrade(a.ID, &MultiTradeRequest{Items: twoWay}); err != ErrTooFewTradeParticipant {
+		t.Errorf("two-player trade = %v, want ErrTooFewTradeParticipant", err)
+	}
+
+	twice := []MultiTradeItemRequest{
+		{FromUserID: a.ID, ToUserID: b.ID, Type: "general", ID: general.ID},
+		{FromUserID: a.ID, ToUserID: c.ID, Type: "general", ID: general.ID},
+	}
+	if _, err := CreateMultiTrade(a.ID, &MultiTradeRequest{Items: twice}); err != ErrDuplicateTradeItem {
+		t.Errorf("general sent twice = %v, want ErrDuplicateTradeItem", err)
+	}
+
+	outsider := createTestUser(t, db, "outsider")
+	threeWay := []MultiTradeItemRequest{
+		{FromUserID: a.ID, ToUserID: b.ID, Type: "general", ID: general.ID},
+		{FromUserID: b.ID, ToUserID: c.ID, Type: "space", Amount: 5},
+	}
+	if _, err := CreateMultiTrade(outsider.ID, &MultiTradeRequest{Items: threeWay}); err != ErrProposerNotInTrade {
+		t.Errorf("trade proposed by an outsider = %v, want ErrProposerNotInTrade", err)
+	}
+
+	SetGamePhase("match", 1, 0)
+	if _, err := CreateMultiTrade(a.ID, &MultiTradeRequest{Items: threeWay}); err != ErrNotInTradingPhase {
+		t.Errorf("trade outside the trading phase = %v, want ErrNotInTradingPhase", err)
+	}
+}
+
+func TestAcceptMultiTradeIsAtomic(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	tw := createThreeWayTrade(t, db, [3]int{100, 200, 50})
+	a, b, c := tw.users[0], tw.users[1], tw.users[2]
+
+	// c cannot fit b's general, so the last acceptance must move nothing at all
+	db.Model(&model.User{}).Where("id = ?", c.ID).Update("space", 100)
+	if err := AcceptMultiTrade(tw.trade.ID, b.ID); err != nil {
+		t.Fatalf("AcceptMultiTrade b: %v", err)
+	}
+	if err := AcceptMultiTrade(tw.trade.ID, b.ID); err != ErrAlreadyAcceptedTrade {
+		t.Errorf("second acceptance = %v, want ErrAlreadyAcceptedTrade", err)
+	}
+	if err := AcceptMultiTrade(tw.trade.ID, c.ID); err != ErrTradeOverCap {
+		t.Fatalf("acceptance pushing c over the cap = %v, want ErrTradeOverCap", err)
+	}
+
+	for i, general := range tw.generals {
+		if owner := generalOwner(t, db, general.ID); owner != tw.users[i].ID {
+			t.Errorf("general %d moved to user %d by a failed trade", general.ID, owner)
+		}
+	}
+	for i, want := range []int{100, 200, 50} {
+		if got := reloadTestUser(t, db, tw.users[i].ID).UsedSpace; got != want {
+			t.Errorf("user %d used space = %d after a failed trade, want %d", tw.users[i].ID, got, want)
+		}
+	}
+	trade, err := GetMultiTradeByID(tw.trade.ID)
+	if err != nil {
+		t.Fatalf("GetMultiTradeByID: %v", err)
+	}
+	if trade.Status != "pending" || findMultiTradeParticipant(trade, c.ID).Accepted {
+		t.Errorf("trade status = %q, c accepted = %v, want pending without c's acceptance", trade.Status, findMultiTradeParticipant(trade, c.ID).Accepted)
+	}
+
+	// With the room made, c's acceptance settles every leg at once
+	db.Model(&model.User{}).Where("id = ?", c.ID).Update("space", 350)
+	if err := AcceptMultiTrade(tw.trade.ID, c.ID); err != nil {
+		t.Fatalf("AcceptMultiTrade c: %v", err)
+	}
+	for i, general := range tw.generals {
+		want := tw.users[(i+1)%3].ID
+		if owner := generalOwner(t, db, general.ID); owner != want {
+			t.Errorf("general %d owner = %d, want %d", general.ID, owner, want)
+		}
+	}
+	for user, want := range map[uint]int{a.ID: 50, b.ID: 100, c.ID: 200} {
+		if got := reloadTestUser(t, db, user).UsedSpace; got != want {
+			t.Errorf("user %d used space = %d, want %d", user, got, want)
+		}
+	}
+	trade, _ = GetMultiTradeByID(tw.trade.ID)
+	if trade.Status != "accepted" {
+		t.Errorf("trade status = %q, want accepted", trade.Status)
+	}
+}
+
+func TestAcceptMultiTradeAfterItemMoved(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	tw := createThreeWayTrade(t, db, [3]int{10, 10, 10})
+	outsider := createTestUser(t, db, "outsider")
+
+	// b's general leaves b's roster before everyone has accepted
+	db.Model(&model.General{}).Where("id = ?", tw.generals[1].ID).Update("owner_id", outsider.ID)
+	if err := AcceptMultiTrade(tw.trade.ID, tw.users[1].ID); err != nil {
+		t.Fatalf("AcceptMultiTrade b: %v", err)
+	}
+	if err := AcceptMultiTrade(tw.trade.ID, tw.users[2].ID); err == nil {
+		t.Fatal("trade settled without b owning the listed general")
+	}
+
+	if owner := generalOwner(t, db, tw.generals[0].ID); owner != tw.users[0].ID {
+		t.Errorf("a's general moved to user %d by a failed trade", owner)
+	}
+	trade, err := GetMultiTradeByID(tw.trade.ID)
+	if err != nil {
+		t.Fatalf("GetMultiTradeByID: %v", err)
+	}
+	if trade.Status != "cancelled" {
+		t.Errorf("trade status = %q, want cancelled", trade.Status)
+	}
+}
+
+func TestRejectMultiTrade(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	tw := createThreeWayTrade(t, db, [3]int{10, 10, 10})
+	outsider := createTestUser(t, db, "outsider")
+
+	if err := RejectMultiTrade(tw.trade.ID, outsider.ID); err != ErrNotTradeParticipant {
+		t.Errorf("reject by an outsider = %v, want ErrNotTradeParticipant", err)
+	}
+	if err := CancelMultiTrade(tw.trade.ID, tw.users[1].ID); err != ErrNotTradeParticipant {
+		t.Errorf("cancel by a non-proposer = %v, want ErrNotTradeParticipant", err)
+	}
+	if err := AcceptMultiTrade(tw.trade.ID, tw.users[1].ID); err != nil {
+		t.Fatalf("AcceptMultiTrade b: %v", err)
+	}
+
+	// Any one participant can turn the whole trade down
+	if err := RejectMultiTrade(tw.trade.ID, tw.users[2].ID); err != nil {
+		t.Fatalf("RejectMultiTrade: %v", err)
+	}
+	if err := AcceptMultiTrade(tw.trade.ID, tw.users[2].ID); err != ErrTradeAlreadyProcessed {
+		t.Errorf("accept a rejected trade = %v, want ErrTradeAlreadyProcessed", err)
+	}
+	for i, general := range tw.generals {
+		if owner := generalOwner(t, db, general.ID); owner != tw.users[i].ID {
+			t.Errorf("general %d moved to user %d by a rejected trade", general.ID, owner)
+		}
+	}
+}
+
+func TestMultiTradeLeagueVeto(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+	if _, err := UpdateTradeSettings(&TradeSettingsRequest{ReviewMode: TradeReviewLeagueVote, VetoThreshold: 2, VoteHours: 24}); err != nil {
+		t.Fatalf("UpdateTradeSettings: %v", err)
+	}
+
+	tw := createThreeWayTrade(t, db, [3]int{10, 10, 10})
+	d := createTestUser(t, db, "d")
+	e := createTestUser(t, db, "e")
+
+	if err := CastMultiTradeVeto(tw.trade.ID, d.ID, "lopsided"); err != ErrTradeNotUnderReview {
+		t.Errorf("veto before every participant accepted = %v, want ErrTradeNotUnderReview", err)
+	}
+	for _, user := range tw.users[1:] {
+		if err := AcceptMultiTrade(tw.trade.ID, user.ID); err != nil {
+			t.Fatalf("AcceptMultiTrade user %d: %v", user.ID, err)
+		}
+	}
+	trade, err := GetMultiTradeByID(tw.trade.ID)
+	if err != nil {
+		t.Fatalf("GetMultiTradeByID: %v", err)
+	}
+	if trade.Status != "awaiting_approval" || trade.ReviewDeadline == nil {
+		t.Fatalf("trade status = %q, want awaiting_approval with a vote deadline", trade.Status)
+	}
+
+	if err := CastMultiTradeVeto(tw.trade.ID, tw.users[0].ID, "changed my mind"); err != ErrCannotVetoOwnTrade {
+		t.Errorf("veto by a participant = %v, want ErrCannotVetoOwnTrade", err)
+	}
+	if err := CastMultiTradeVeto(tw.trade.ID, d.ID, "lopsided"); err != nil {
+		t.Fatalf("CastMultiTradeVeto d: %v", err)
+	}
+	if err := CastMultiTradeVeto(tw.trade.ID, d.ID, "lopsided"); err != ErrAlreadyVotedVeto {
+		t.Errorf("second vote = %v, want ErrAlreadyVotedVeto", err)
+	}
+	if err := CastMultiTradeVeto(tw.trade.ID, e.ID, "lopsided"); err != nil {
+		t.Fatalf("CastMultiTradeVeto e: %v", err)
+	}
+
+	trade, _ = GetMultiTradeByID(tw.trade.ID)
+	if trade.Status != "vetoed" {
+		t.Errorf("trade status = %q, want vetoed at the threshold", trade.Status)
+	}
+	for i, general := range tw.generals {
+		if owner := generalOwner(t, db, general.ID); owner != tw.users[i].ID {
+			t.Errorf("general %d moved to user %d by a vetoed trade", general.ID, owner)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"errors"
+	"sort"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPolicyNotFound      = errors.New("policy not found")
+	ErrClubEffectsConflict = errors.New("club effects changed concurrently, please retry")
+	ErrClubEffectsOverCap  = errors.New("the change would cost a policy bonus and push the roster over its space cap")
+)
+
+// clubEffectsRetries is how often ApplyClubEffects retries after a concurrent update
+const clubEffectsRetries = 3
+
+// clubEffectsUpdate is a committed change of the 国策 effects applied to a player
+type clubEffectsUpdate struct {
+	UserID       uint
+	PolicySpace  int
+	PolicySalary int
+	Totals       map[string]int
+	OverCap      bool // The change took room away and left the roster over its cap
+}
+
+// PolicyEvaluation is whether one 国策 is active for a player's roster and why
+type PolicyEvaluation struct {
+	Policy   model.Policy   `json:"policy"`
+	Enforced bool           `json:"enforced"` // Has a machine-readable rule
+	Active   bool           `json:"active"`
+	Reasons  []string       `json:"reasons"`
+	Effects  []PolicyEffect `json:"effects"` // Resolved effects of an active rule
+}
+
+// PlayerPolicyStatus is the evaluation of every 国策 of a player's club
+type PlayerPolicyStatus struct {
+	UserID   uint               `json:"user_id"`
+	Club     *model.Club        `json:"club"`
+	Policies []PolicyEvaluation `json:"policies"`
+	Totals   map[string]int     `json:"totals"` // Summed effects of the active policies per target
+}
+
+// GetPlayerPolicyStatus evaluates the policies of a player's club against their current roster
+func GetPlayerPolicyStatus(userID uint) (*PlayerPolicyStatus, error) {
+	return playerPolicyStatus(database.GetDB(), userID)
+}
+
+// playerPolicyStatus evaluates a player's policies using db, which may be a transaction
+func playerPolicyStatus(db *gorm.DB, userID uint) (*PlayerPolicyStatus, error) {
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	status := &PlayerPolicyStatus{
+		UserID:   userID,
+		Policies: []PolicyEvaluation{},
+		Totals:   map[string]int{},
+	}
+	if user.ClubID == nil {
+		return status, nil
+	}
+
+	var club model.Club
+	if err := db.Preload("Policies").First(&club, *user.ClubID).Error; err != nil {
+		return nil, err
+	}
+	policies := club.Policies
+	club.Policies = nil
+	status.Club = &club
+
+	var generals []model.General
+	if err := db.Where("owner_id = ?", userID).Find(&generals).Error; err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(policies, func(i, j int) bool {
+		return policies[i].SortOrder < policies[j].SortOrder
+	})
+	for _, policy := range policies {
+		evaluation := evaluatePolicy(policy, generals)
+		for _, effect := range evaluation.Effects {
+			status.Totals[effect.Target] += effect.Amount
+		}
+		status.Policies = append(status.Policies, evaluation)
+	}
+	return status, nil
+}
+
+// evaluatePolicy evaluates a single policy against a roster
+func evaluatePolicy(policy model.Policy, generals []model.General) PolicyEvaluation {
+	evaluation := PolicyEvaluation{Policy: policy}
+	if policy.Rule == "" {
+		evaluation.Reasons = []string{"no machine-readable rule"}
+		return evaluation
+	}
+
+	rule, err := ParsePolicyRule(policy.Rule)
+	if err != nil {
+		evaluation.Reasons = []string{err.Error()}
+		return evaluation
+	}
+	evaluation.Enforced = true
+	evaluation.Active, evaluation.Reasons, evaluation.Effects = rule.evaluate(generals)
+	return evaluation
+}
+
+// SetPolicyRule sets or clears the machine-readable rule of a policy (admin only)
+func SetPolicyRule(policyID uint, rule string) (*model.Policy, error) {
+	db := database.GetDB()
+
+	var policy model.Policy
+	if err := db.First(&policy, policyID).Error; err != nil {
+		return nil, ErrPolicyNotFound
+	}
+	if rule != "" {
+		if _, err := ParsePolicyRule(rule); err != nil {
+			return nil, err
+		}
+	}
+
+	tx := db.Begin()
+
+	if err := tx.Model(&policy).Update("rule", rule).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The club's owner gets the new rule applied right away
+	var ownerIDs []uint
+	if err := tx.Model(&model.User{}).Where("club_id = ?", policy.ClubID).Pluck("id", &ownerIDs).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	effects, err := applyClubEffects(tx, ownerIDs...)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	publishClubEffects(effects)
+
+	return &policy, nil
+}
+
+// ApplyClubEffects brings a player's space and salary in line with their active 国策
+// It is retried when the player's effects change concurrently.
+func ApplyClubEffects(userID uint) error {
+	db := database.GetDB()
+
+	var err error
+	for attempt := 0; attempt < clubEffectsRetries; attempt++ {
+		tx := db.Begin()
+
+		var effects []clubEffectsUpdate
+		effects, err = applyClubEffects(tx, userID)
+		if err != nil {
+			tx.Rollback()
+			if err == ErrClubEffectsConflict {
+				continue
+			}
+			return err
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+		publishClubEffects(effects)
+		return nil
+	}
+	return err
+}
+
+// applyClubEffects recomputes the 国策 effects of players inside tx
+// The applied amounts are kept on the user, so a recompute only applies the difference
+// and a player without a club gets everything reverted. Callers changing a roster
+// apply the effects in the same transaction, check the cap with checkClubEffectsCap
+// where the change is optional, and publish the updates once tx is committed.
+// Players that fit their space afterwards have their over-cap window cleared.
+func applyClubEffects(tx *gorm.DB, userIDs ...uint) ([]clubEffectsUpdate, error) {
+	var updates []clubEffectsUpdate
+	for _, userID := range userIDs {
+		status, err := playerPolicyStatus(tx, userID)
+		if err != nil {
+			return nil, err
+		}
+
+		var user model.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return nil, err
+		}
+
+		space := status.Totals[PolicyEffectSpace]
+		salary := status.Totals[PolicyEffectSalary]
+		// A salary discount cannot take used space below zero
+		if base := user.UsedSpace - user.PolicySalary; base+salary < 0 {
+			salary = -base
+		}
+		if space == user.PolicySpace && salary == user.PolicySalary {
+			continue
+		}
+
+		result := tx.Model(&model.User{}).
+			Where("id = ? AND policy_space = ? AND policy_salary = ?", userID, user.PolicySpace, user.PolicySalary).
+			Updates(map[string]interface{}{
+				"space":         gorm.Expr("space + ?", space-user.PolicySpace),
+				"used_space":    gorm.Expr("used_space + ?", salary-user.PolicySalary),
+				"policy_space":  space,
+				"policy_salary": salary,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, ErrClubEffectsConflict
+		}
+
+		roomChange := (space - user.PolicySpace) - (salary - user.PolicySalary)
+		usedSpace := user.UsedSpace + salary - user.PolicySalary
+		updates = append(updates, clubEffectsUpdate{
+			UserID:       userID,
+			PolicySpace:  space,
+			PolicySalary: salary,
+			Totals:       status.Totals,
+			OverCap:      roomChange < 0 && usedSpace > user.Space+space-user.PolicySpace,
+		})
+	}
+	if len(userIDs) > 0 {
+		if err := clearOverCapWindows(tx, userIDs); err != nil {
+			return nil, err
+		}
+	}
+	return updates, nil
+}
+
+// checkClubEffectsCap rejects effect updates that left a roster over its space cap
+func checkClubEffectsCap(updates []clubEffectsUpdate) error {
+	for _, update := range updates {
+		if update.OverCap {
+			return ErrClubEffectsOverCap
+		}
+	}
+	return nil
+}
+
+// publishClubEffects announces effect updates once their transaction is committed
+func publishClubEffects(updates []clubEffectsUpdate) {
+	for _, update := range updates {
+		PublishEvent(EventPolicyEffects, map[string]interface{}{
+			"user_id":       update.UserID,
+			"policy_space":  update.PolicySpace,
+			"policy_salary": update.PolicySalary,
+			"totals":        update.Totals,
+		}, update.UserID)
+	}
+}
+
+// ApplyAllClubEffects recomputes the 国策 effects of every registered player (admin only)
+func ApplyAllClubEffects() (int, error) {
+	db := database.GetDB()
+
+	var userIDs []uint
+	if err := db.Model(&model.User{}).Where("is_registered = ?", true).Pluck("id", &userIDs).Error; err != nil {
+		return 0, err
+	}
+	for i, userID := range userIDs {
+		if err := ApplyClubEffects(userID); err != nil {
+			return i, err
+		}
+	}
+	return len(userIDs), nil
+}
+
+// policyEffectTotal returns the summed active effect of a player's policies on one target
+func policyEffectTotal(userID uint, target string) (int, error) {
+	status, err := GetPlayerPolicyStatus(userID)
+	if err != nil {
+		return 0, err
+	}
+	return status.Totals[target], nil
+}
@@ -0,0 +1,556 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"san11-trade/internal/model"
+)
+
+// Policy rules are a small DSL that makes a 国策 machine-evaluable:
+//
+//	rule    = [ "if" cond { "and" cond } "then" ] effect { "," effect }
+//	cond    = ( "count" | "weighted" ) "(" filter { "," filter } ")" cmp INT
+//	        | "skills" "(" NAME { "," NAME } ")"
+//	filter  = field cmp value          e.g. 戟 >= S, 武力 >= 90, 统武 >= 160, skill = 陷阵
+//	effect  = target ( "+" | "-" ) INT [ "*" "X" ]
+//
+// count counts the roster generals matching every filter; weighted does the same but
+// counts 神 aptitudes twice and 圣 three times (the sheet's "神2圣3"). skills requires
+// every listed 特技 somewhere on the roster. X in an effect is the value of the first
+// count/weighted condition, e.g. "if count(戟 >= S) >= 1 then space +5*X".
+// A rule without "if" is unconditional.
+
+var ErrInvalidPolicyRule = errors.New("invalid policy rule")
+
+// Policy effect targets
+const (
+	PolicyEffectSpace          = "space"           // Added to the roster space cap
+	PolicyEffectSalary         = "salary"          // Added to the salary counted against space
+	PolicyEffectGuaranteeDraws = "guarantee_draws" // Extra guarantee draws
+	PolicyEffectNormalDraws    = "normal_draws"    // Extra normal draws
+	PolicyEffectLineupSalary   = "lineup_salary"   // Added to the club's lineup salary cap
+	PolicyEffectMulligans      = "mulligans"       // Extra mulligan tokens
+)
+
+// policyEffectKeys maps effect targets (English or Chinese) to their canonical name
+var policyEffectKeys = map[string]string{
+	"space": PolicyEffectSpace, "空间": PolicyEffectSpace,
+	"salary": PolicyEffectSalary, "薪资": PolicyEffectSalary,
+	"guarantee_draws": PolicyEffectGuaranteeDraws, "保底抽": PolicyEffectGuaranteeDraws,
+	"normal_draws": PolicyEffectNormalDraws, "普通抽": PolicyEffectNormalDraws,
+	"lineup_salary": PolicyEffectLineupSalary, "出场薪资": PolicyEffectLineupSalary,
+	"mulligans": PolicyEffectMulligans, "重抽": PolicyEffectMulligans,
+}
+
+// policyStatKeys maps rule stat fields to a getter; 统帅 is accepted next to 统率
+var policyStatKeys = map[string]func(*model.General) int{
+	"command":      func(g *model.General) int { return g.Command },
+	"统率":           func(g *model.General) int { return g.Command },
+	"统帅":           func(g *model.General) int { return g.Command },
+	"force":        func(g *model.General) int { return g.Force },
+	"武力":           func(g *model.General) int { return g.Force },
+	"intelligence": func(g *model.General) int { return g.Intelligence },
+	"智力":           func(g *model.General) int { return g.Intelligence },
+	"politics":     func(g *model.General) int { return g.Politics },
+	"政治":           func(g *model.General) int { return g.Politics },
+	"charm":        func(g *model.General) int { return g.Charm },
+	"魅力":           func(g *model.General) int { return g.Charm },
+	"salary":       func(g *model.General) int { return g.Salary },
+	"薪资":           func(g *model.General) int { return g.Salary },
+	"tier":         func(g *model.General) int { return g.Tier },
+	"统武":           func(g *model.General) int { return g.Command + g.Force },
+	"五维": func(g *model.General) int {
+		return g.Command + g.Force + g.Intelligence + g.Politics + g.Charm
+	},
+}
+
+// policyGradeRanks orders aptitude grades, including the mod's 神/圣 above S
+var policyGradeRanks = map[string]int{"C": 1, "B": 2, "A": 3, "S": 4, "神": 5, "圣": 6}
+
+// policyGradeWeights is how many generals a grade counts as in weighted()
+var policyGradeWeights = map[string]int{"神": 2, "圣": 3}
+
+// PolicyRule is a parsed policy rule
+type PolicyRule struct {
+	Conditions []PolicyCondition `json:"conditions"`
+	Effects    []PolicyEffect    `json:"effects"`
+}
+
+// PolicyCondition is one condition of a rule
+type PolicyCondition struct {
+	Kind    string         `json:"kind"` // count/weighted/skills
+	Filters []PolicyFilter `json:"filters,omitempty"`
+	Skills  []string       `json:"skills,omitempty"`
+	Op      string         `json:"op,omitempty"`
+	Value   int            `json:"value,omitempty"`
+}
+
+// PolicyFilter restricts which generals a count condition counts
+type PolicyFilter struct {
+	Field string `json:"field"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// PolicyEffect is one effect of a rule
+type PolicyEffect struct {
+	Target string `json:"target"`
+	Amount int    `json:"amount"`          // Signed amount, multiplied by X when PerX is set
+	PerX   bool   `json:"per_x,omitempty"` // Amount is per unit of the first count condition
+}
+
+// ParsePolicyRule parses a policy rule, see the grammar above
+func ParsePolicyRule(text string) (*PolicyRule, error) {
+	tokens, err := tokenizePolicyRule(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &policyRuleParser{tokens: tokens}
+
+	rule := &PolicyRule{}
+	if p.peekWord("if") {
+		p.next()
+		for {
+			cond, err := p.parseCondition()
+			if err != nil {
+				return nil, err
+			}
+			rule.Conditions = append(rule.Conditions, *cond)
+			if !p.peekWord("and") {
+				break
+			}
+			p.next()
+		}
+		if err := p.expectWord("then"); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		effect, err := p.parseEffect()
+		if err != nil {
+			return nil, err
+		}
+		rule.Effects = append(rule.Effects, *effect)
+		if !p.peek(",") {
+			break
+		}
+		p.next()
+	}
+
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.tokens[p.pos])
+	}
+	for _, effect := range rule.Effects {
+		if effect.PerX && rule.countCondition() == nil {
+			return nil, fmt.Errorf("%w: X needs a count or weighted condition", ErrInvalidPolicyRule)
+		}
+	}
+	return rule, nil
+}
+
+// countCondition returns the first count/weighted condition, the one X refers to
+func (r *PolicyRule) countCondition() *PolicyCondition {
+	for i := range r.Conditions {
+		if r.Conditions[i].Kind != "skills" {
+			return &r.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// evaluate checks the rule against a roster
+// It returns whether every condition holds, a reason per condition and the resolved effects.
+func (r *PolicyRule) evaluate(generals []model.General) (bool, []string, []PolicyEffect) {
+	active := true
+	x := 0
+	xSet := false
+	var reasons []string
+
+	for _, cond := range r.Conditions {
+		switch cond.Kind {
+		case "skills":
+			owned := make(map[string]bool)
+			for i := range generals {
+				for _, skill := range splitSkills(generals[i].Skills) {
+					owned[skill] = true
+				}
+			}
+			var missing []string
+			for _, skill := range cond.Skills {
+				if !owned[skill] {
+					missing = append(missing, skill)
+				}
+			}
+			if len(missing) > 0 {
+				active = false
+				reasons = append(reasons, fmt.Sprintf("missing skills %s", strings.Join(missing, "、")))
+			} else {
+				reasons = append(reasons, fmt.Sprintf("has skills %s", strings.Join(cond.Skills, "、")))
+			}
+
+		default:
+			n := 0
+			for i := range generals {
+				if weight := cond.matchGeneral(&generals[i]); weight > 0 {
+					n += weight
+				}
+			}
+			if !xSet {
+				x, xSet = n, true
+			}
+			ok := compareInt(n, cond.Op, cond.Value)
+			if !ok {
+				active = false
+			}
+			reasons = append(reasons, fmt.Sprintf("%s(%s) = %d, needs %s %d", cond.Kind, cond.describeFilters(), n, cond.Op, cond.Value))
+		}
+	}
+	if len(r.Conditions) == 0 {
+		reasons = append(reasons, "unconditional")
+	}
+
+	if !active {
+		return false, reasons, nil
+	}
+	effects := make([]PolicyEffect, 0, len(r.Effects))
+	for _, effect := range r.Effects {
+		amount := effect.Amount
+		if effect.PerX {
+			amount *= x
+		}
+		effects = append(effects, PolicyEffect{Target: effect.Target, Amount: amount})
+	}
+	return true, reasons, effects
+}
+
+// matchGeneral returns how many generals g counts as for the condition, 0 if it does not match
+func (c *PolicyCondition) matchGeneral(g *model.General) int {
+	weight := 1
+	for _, filter := range c.Filters {
+		if filter.Field == "skill" {
+			found := false
+			for _, skill := range splitSkills(g.Skills) {
+				if skill == filter.Value {
+					found = true
+					break
+				}
+			}
+			if found != (filter.Op == "=") {
+				return 0
+			}
+			continue
+		}
+		if getter, ok := policyStatKeys[filter.Field]; ok {
+			value, _ := strconv.Atoi(filter.Value)
+			if !compareInt(getter(g), filter.Op, value) {
+				return 0
+			}
+			continue
+		}
+
+		grade := normalizeGrade(generalAptitude(g, filter.Field))
+		if grade == "" || !compareInt(policyGradeRanks[grade], filter.Op, policyGradeRanks[filter.Value]) {
+			return 0
+		}
+		if c.Kind == "weighted" && policyGradeWeights[grade] > weight {
+			weight = policyGradeWeights[grade]
+		}
+	}
+	return weight
+}
+
+// describeFilters renders the filters of a condition back into rule syntax
+func (c *PolicyCondition) describeFilters() string {
+	parts := make([]string, len(c.Filters))
+	for i, filter := range c.Filters {
+		parts[i] = filter.Field + filter.Op + filter.Value
+	}
+	return strings.Join(parts, ", ")
+}
+
+// generalAptitude returns a general's grade for an aptitude field
+func generalAptitude(g *model.General, field string) string {
+	switch field {
+	case "spear":
+		return g.Spear
+	case "halberd":
+		return g.Halberd
+	case "crossbow":
+		return g.Crossbow
+	case "cavalry":
+		return g.Cavalry
+	case "soldier":
+		return g.Soldier
+	case "water":
+		return g.Water
+	}
+	return ""
+}
+
+// normalizeGrade turns an aptitude grade into its rank key, folding full-width letters (Ｓ) to ASCII
+func normalizeGrade(grade string) string {
+	grade = strings.TrimSpace(grade)
+	if grade == "" {
+		return ""
+	}
+	r := []rune(grade)[0]
+	if r >= 'Ａ' && r <= 'Ｚ' {
+		r = r - 'Ａ' + 'A'
+	}
+	return strings.ToUpper(string(r))
+}
+
+// compareInt applies a comparison operator
+func compareInt(a int, op string, b int) bool {
+	switch op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	}
+	return false
+}
+
+// policyRuleParser is a recursive-descent parser over rule tokens
+type policyRuleParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *policyRuleParser) done() bool { return p.pos >= len(p.tokens) }
+
+func (p *policyRuleParser) next() string {
+	if p.done() {
+		return ""
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	return tok
+}
+
+func (p *policyRuleParser) peek(tok string) bool {
+	return !p.done() && p.tokens[p.pos] == tok
+}
+
+func (p *policyRuleParser) peekWord(word string) bool {
+	return !p.done() && strings.EqualFold(p.tokens[p.pos], word)
+}
+
+func (p *policyRuleParser) expect(tok string) error {
+	if !p.peek(tok) {
+		return p.errorf("expected %q", tok)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *policyRuleParser) expectWord(word string) error {
+	if !p.peekWord(word) {
+		return p.errorf("expected %q", word)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *policyRuleParser) expectInt() (int, error) {
+	if p.done() {
+		return 0, p.errorf("expected a number")
+	}
+	n, err := strconv.Atoi(p.tokens[p.pos])
+	if err != nil {
+		return 0, p.errorf("expected a number")
+	}
+	p.pos++
+	return n, nil
+}
+
+func (p *policyRuleParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w at token %d: %s", ErrInvalidPolicyRule, p.pos+1, fmt.Sprintf(format, args...))
+}
+
+// parseCondition parses count(...)/weighted(...) cmp INT or skills(...)
+func (p *policyRuleParser) parseCondition() (*PolicyCondition, error) {
+	kind := strings.ToLower(p.next())
+	if kind != "count" && kind != "weighted" && kind != "skills" {
+		return nil, p.errorf("expected count, weighted or skills")
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	cond := &PolicyCondition{Kind: kind}
+	for {
+		if kind == "skills" {
+			name := p.next()
+			if name == "" || isPolicyRulePunct(name) {
+				return nil, p.errorf("expected a skill name")
+			}
+			cond.Skills = append(cond.Skills, name)
+		} else {
+			filter, err := p.parseFilter()
+			if err != nil {
+				return nil, err
+			}
+			cond.Filters = append(cond.Filters, *filter)
+		}
+		if !p.peek(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if kind == "skills" {
+		return cond, nil
+	}
+
+	op := p.next()
+	if !isPolicyRuleOp(op) {
+		return nil, p.errorf("expected a comparison")
+	}
+	value, err := p.expectInt()
+	if err != nil {
+		return nil, err
+	}
+	cond.Op = op
+	cond.Value = value
+	return cond, nil
+}
+
+// parseFilter parses field cmp value
+func (p *policyRuleParser) parseFilter() (*PolicyFilter, error) {
+	field := strings.ToLower(p.next())
+	op := p.next()
+	if !isPolicyRuleOp(op) {
+		return nil, p.errorf("expected a comparison")
+	}
+	value := p.next()
+	if value == "" || isPolicyRulePunct(value) {
+		return nil, p.errorf("expected a value")
+	}
+
+	switch {
+	case field == "skill" || field == "特技":
+		if op != "=" && op != "!=" {
+			return nil, p.errorf("skills can only be compared with = or !=")
+		}
+		return &PolicyFilter{Field: "skill", Op: op, Value: value}, nil
+	case policyStatKeys[field] != nil:
+		if _, err := strconv.Atoi(value); err != nil {
+			return nil, p.errorf("%s needs a number", field)
+		}
+		if canonical, ok := statKeys[field]; ok {
+			field = canonical
+		}
+		if field == "统帅" {
+			field = "command"
+		}
+		if field == "薪资" {
+			field = "salary"
+		}
+		return &PolicyFilter{Field: field, Op: op, Value: value}, nil
+	case aptitudeKeys[field] != "":
+		grade := normalizeGrade(value)
+		if policyGradeRanks[grade] == 0 {
+			return nil, p.errorf("unknown aptitude grade %q", value)
+		}
+		return &PolicyFilter{Field: aptitudeKeys[field], Op: op, Value: grade}, nil
+	}
+	return nil, p.errorf("unknown field %q", field)
+}
+
+// parseEffect parses target +/- INT [* X]
+func (p *policyRuleParser) parseEffect() (*PolicyEffect, error) {
+	target, ok := policyEffectKeys[strings.ToLower(p.next())]
+	if !ok {
+		return nil, p.errorf("unknown effect target")
+	}
+	sign := p.next()
+	if sign != "+" && sign != "-" {
+		return nil, p.errorf("expected + or -")
+	}
+	amount, err := p.expectInt()
+	if err != nil {
+		return nil, err
+	}
+	if sign == "-" {
+		amount = -amount
+	}
+
+	effect := &PolicyEffect{Target: target, Amount: amount}
+	if p.peek("*") {
+		p.next()
+		if !p.peekWord("x") {
+			return nil, p.errorf("expected X")
+		}
+		p.next()
+		effect.PerX = true
+	}
+	return effect, nil
+}
+
+// tokenizePolicyRule splits a rule into words, numbers, operators and punctuation
+// ≥/≤/＞/＜ and full-width brackets and commas are accepted.
+func tokenizePolicyRule(text string) ([]string, error) {
+	replacer := strings.NewReplacer("≥", ">=", "≤", "<=", "＞", ">", "＜", "<", "＝", "=",
+		"（", "(", "）", ")", "，", ",", "、", ",", "＋", "+", "－", "-", "×", "*")
+	runes := []rune(replacer.Replace(text))
+
+	var tokens []string
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("(),*+-", r):
+			tokens = append(tokens, string(r))
+			i++
+		case strings.ContainsRune("<>=!", r):
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, string(runes[i:i+2]))
+				i += 2
+			} else if r == '!' {
+				return nil, fmt.Errorf("%w: stray '!'", ErrInvalidPolicyRule)
+			} else {
+				tokens = append(tokens, string(r))
+				i++
+			}
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("(),*+-<>=!", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidPolicyRule)
+	}
+	return tokens, nil
+}
+
+func isPolicyRuleOp(tok string) bool {
+	switch tok {
+	case ">=", "<=", ">", "<", "=", "!=":
+		return true
+	}
+	return false
+}
+
+func isPolicyRulePunct(tok string) bool {
+	return tok == "(" || tok == ")" || tok == "," || tok == "*" || tok == "+" || tok == "-" || isPolicyRuleOp(tok)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"san11-trade/internal/model"
+)
+
+func TestParsePolicyRule(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want *PolicyRule
+	}{
+		{
+			name: "count condition",
+			text: "if count(戟 >= S) >= 3 then space +20",
+			want: &PolicyRule{
+				Conditions: []PolicyCondition{{Kind: "count", Filters: []PolicyFilter{{Field: "halberd", Op: ">=", Value: "S"}}, Op: ">=", Value: 3}},
+				Effects:    []PolicyEffect{{Target: PolicyEffectSpace, Amount: 20}},
+			},
+		},
+		{
+			name: "weighted condition with X",
+			text: "if weighted(骑 >= S) >= 1 then space +5*X",
+			want: &PolicyRule{
+				Conditions: []PolicyCondition{{Kind: "weighted", Filters: []PolicyFilter{{Field: "cavalry", Op: ">=", Value: "S"}}, Op: ">=", Value: 1}},
+				Effects:    []PolicyEffect{{Target: PolicyEffectSpace, Amount: 5, PerX: true}},
+			},
+		},
+		{
+			name: "unconditional chinese target",
+			text: "空间 +10",
+			want: &PolicyRule{
+				Effects: []PolicyEffect{{Target: PolicyEffectSpace, Amount: 10}},
+			},
+		},
+		{
+			name: "skills with full-width punctuation",
+			text: "if skills（陷阵，铁壁） then salary -5",
+			want: &PolicyRule{
+				Conditions: []PolicyCondition{{Kind: "skills", Skills: []string{"陷阵", "铁壁"}}},
+				Effects:    []PolicyEffect{{Target: PolicyEffectSalary, Amount: -5}},
+			},
+		},
+		{
+			name: "several conditions and effects",
+			text: "if count(武力 >= 90) >= 5 and count(skill = 陷阵) >= 1 then space +10, mulligans +1",
+			want: &PolicyRule{
+				Conditions: []PolicyCondition{
+					{Kind: "count", Filters: []PolicyFilter{{Field: "force", Op: ">=", Value: "90"}}, Op: ">=", Value: 5},
+					{Kind: "count", Filters: []PolicyFilter{{Field: "skill", Op: "=", Value: "陷阵"}}, Op: ">=", Value: 1},
+				},
+				Effects: []PolicyEffect{{Target: PolicyEffectSpace, Amount: 10}, {Target: PolicyEffectMulligans, Amount: 1}},
+			},
+		},
+		{
+			name: "combined stat and unicode comparison",
+			text: "if count(统武 >= 160) ≥ 2 then normal_draws +1",
+			want: &PolicyRule{
+				Conditions: []PolicyCondition{{Kind: "count", Filters: []PolicyFilter{{Field: "统武", Op: ">=", Value: "160"}}, Op: ">=", Value: 2}},
+				Effects:    []PolicyEffect{{Target: PolicyEffectNormalDraws, Amount: 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePolicyRule(tt.text)
+			if err != nil {
+				t.Fatalf("ParsePolicyRule(%q) error: %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePolicyRule(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePolicyRuleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+	}{
+		{"empty", "  "},
+		{"X without count", "space +5*X"},
+		{"unknown grade", "if count(戟 >= Z) >= 1 then space +1"},
+		{"skill compared by rank", "if count(skill >= 陷阵) >= 1 then space +1"},
+		{"stat needs a number", "if count(武力 >= S) >= 1 then space +1"},
+		{"unknown field", "if count(身高 >= 180) >= 1 then space +1"},
+		{"unknown target", "gold +5"},
+		{"missing sign", "space 5"},
+		{"missing then", "if count(戟 >= S) >= 1 space +1"},
+		{"trailing tokens", "space +5 extra"},
+		{"stray bang", "if count(skill ! 陷阵) >= 1 then space +1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePolicyRule(tt.text); !errors.Is(err, ErrInvalidPolicyRule) {
+				t.Errorf("ParsePolicyRule(%q) error = %v, want ErrInvalidPolicyRule", tt.text, err)
+			}
+		})
+	}
+}
+
+func TestPolicyRuleEvaluate(t *testing.T) {
+	roster := []model.General{
+		{Halberd: "S", Force: 95, Skills: "陷阵、铁壁"},
+		{Halberd: "神", Force: 80, Cavalry: "圣"},
+		{Halberd: "A", Cavalry: "Ｓ", Command: 90, Force: 75},
+	}
+
+	tests := []struct {
+		name       string
+		rule       string
+		wantActive bool
+		want       []PolicyEffect
+	}{
+		{"count met", "if count(戟 >= S) >= 2 then space +20", true, []PolicyEffect{{Target: PolicyEffectSpace, Amount: 20}}},
+		{"count not met", "if count(戟 >= S) >= 3 then space +20", false, nil},
+		{"weighted counts 神 twice", "if weighted(戟 >= S) >= 1 then space +5*X", true, []PolicyEffect{{Target: PolicyEffectSpace, Amount: 15}}},
+		{"weighted counts 圣 three times and full-width grades", "if weighted(骑 >= S) >= 1 then space +1*X", true, []PolicyEffect{{Target: PolicyEffectSpace, Amount: 4}}},
+		{"skills present", "if skills(陷阵, 铁壁) then salary -5", true, []PolicyEffect{{Target: PolicyEffectSalary, Amount: -5}}},
+		{"skills missing", "if skills(陷阵, 金刚) then salary -5", false, nil},
+		{"combined stat", "if count(统武 >= 160) >= 1 then normal_draws +1", true, []PolicyEffect{{Target: PolicyEffectNormalDraws, Amount: 1}}},
+		{"negated skill filter", "if count(skill != 陷阵, 戟 >= S) = 1 then guarantee_draws +1", true, []PolicyEffect{{Target: PolicyEffectGuaranteeDraws, Amount: 1}}},
+		{"X is the first count after skills", "if skills(陷阵) and count(戟 >= S) >= 1 then space +2*X", true, []PolicyEffect{{Target: PolicyEffectSpace, Amount: 4}}},
+		{"one failing condition disables the rule", "if count(戟 >= S) >= 1 and count(武力 >= 100) >= 1 then space +1", false, nil},
+		{"unconditional", "mulligans +1", true, []PolicyEffect{{Target: PolicyEffectMulligans, Amount: 1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := ParsePolicyRule(tt.rule)
+			if err != nil {
+				t.Fatalf("ParsePolicyRule(%q) error: %v", tt.rule, err)
+			}
+			active, reasons, effects := rule.evaluate(roster)
+			if active != tt.wantActive {
+				t.Errorf("active = %v, want %v (reasons %v)", active, tt.wantActive, reasons)
+			}
+			if len(reasons) == 0 {
+				t.Errorf("no reasons given")
+			}
+			if !reflect.DeepEqual(effects, tt.want) {
+				t.Errorf("effects = %+v, want %+v", effects, tt.want)
+			}
+		})
+	}
+}
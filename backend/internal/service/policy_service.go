@@ -375,7 +375,20 @@ func SelectClub(userID uint, clubID uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	effects, err := applyClubEffects(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishClubEffects(effects)
+
+	publishPolicySelection(&selection)
+
+	return nil
 }
 
 // moveToNextSelector moves to the next person in the selection queue
@@ -497,7 +510,31 @@ func AutoAssignClub(userID uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	effects, err := applyClubEffects(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishClubEffects(effects)
+
+	publishPolicySelection(&selection)
+
+	return nil
+}
+
+// publishPolicySelection notifies everyone of a club selection and the next selector
+func publishPolicySelection(selection *model.PolicySelection) {
+	data := map[string]interface{}{
+		"selection": selection,
+	}
+	if config, err := GetPolicyPhaseConfig(); err == nil {
+		data["config"] = config
+	}
+	PublishEvent(EventPolicySelected, data)
 }
 
 // CheckAndHandleTimeout checks if current selector has timed out and handles it
@@ -529,6 +566,26 @@ func CheckAndHandleTimeout() (bool, error) {
 	return false, nil
 }
 
+// NextPolicyDeadline returns when the current selector's turn expires, or nil
+// if no selection is running. Used by the background scheduler.
+func NextPolicyDeadline() (*time.Time, error) {
+	config, err := GetPolicyPhaseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Status != "selecting" || config.CurrentSelector == nil || config.CurrentDeadline == nil {
+		return nil, nil
+	}
+
+	// Timeouts are not enforced before the official start time
+	due := *config.CurrentDeadline
+	if config.StartTime != nil && config.StartTime.After(due) {
+		due = *config.StartTime
+	}
+	return &due, nil
+}
+
 // GetPolicySelectionStatus returns the current selection status
 func GetPolicySelectionStatus() (map[string]interface{}, error) {
 	db := database.GetDB()
@@ -588,6 +645,13 @@ func GetAllPolicySelections() ([]model.PolicySelection, error) {
 // ResetPolicyPhase resets the entire policy phase (admin only)
 func ResetPolicyPhase() error {
 	db := database.GetDB()
+
+	// Players whose club effects need reverting afterwards
+	var clubUserIDs []uint
+	if err := db.Model(&model.User{}).Where("club_id IS NOT NULL").Pluck("id", &clubUserIDs).Error; err != nil {
+		return err
+	}
+
 	tx := db.Begin()
 
 	// Delete all selections
@@ -636,7 +700,18 @@ func ResetPolicyPhase() error {
 		}
 	}
 
-	return tx.Commit().Error
+	effects, err := applyClubEffects(tx, clubUserIDs...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishClubEffects(effects)
+	return nil
 }
 
 // ResetUserPolicySelection resets a specific user's selection (admin only)
@@ -675,7 +750,19 @@ func ResetUserPolicySelection(userID uint) error {
 		return err
 	}
 
-	return tx.Commit().Error
+	// Revert the effects of the club's 国策
+	effects, err := applyClubEffects(tx, userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishClubEffects(effects)
+	return nil
 }
 
 // GetClubsWithTags retrieves all clubs with their tags
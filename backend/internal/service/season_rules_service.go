@@ -0,0 +1,234 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"san11-trade/internal/config"
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+// Draw pools of the initial draw, in their default order
+const (
+	DrawPoolGuarantee = "initial_guarantee"
+	DrawPoolNormal    = "initial_normal"
+)
+
+// What a draw does when the general does not fit the player's remaining space
+const (
+	OverCapFilter = "filter"   // Only affordable generals can be drawn
+	OverCapAllow  = "over_cap" // The draw goes through; the player must get back under the cap in time
+	OverCapReroll = "reroll"   // The pick is logged and rolled again
+)
+
+var (
+	ErrInvalidSeasonRules = errors.New("invalid season rules")
+	ErrSeasonRulesLocked  = errors.New("season rule can no longer be changed in this phase")
+)
+
+// SeasonRulesRequest updates the season rules; omitted fields keep their value
+type SeasonRulesRequest struct {
+	GuaranteeDraws  *int     `json:"guarantee_draws"`
+	NormalDraws     *int     `json:"normal_draws"`
+	PoolOrder       []string `json:"pool_order"`
+	InitialSpace    *int     `json:"initial_space"`
+	MaxPlayers      *int     `json:"max_players"`
+	DraftRounds     *int     `json:"draft_rounds"`
+	OverCapPolicy   string   `json:"over_cap_policy"`
+	OverCapHours    *int     `json:"over_cap_hours"`
+	MulliganMinutes *int     `json:"mulligan_minutes"`
+}
+
+// GetSeasonRules gets or creates the rule set of the current season
+// A fresh rule set starts from the game config defaults.
+func GetSeasonRules() (*model.SeasonRules, error) {
+	db := database.GetDB()
+	var rules model.SeasonRules
+	if err := db.First(&rules).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			gameCfg := config.AppConfig.Game
+			rules = model.SeasonRules{
+				GuaranteeDraws:  gameCfg.GuaranteeDraws,
+				NormalDraws:     gameCfg.NormalDraws,
+				PoolOrder:       DrawPoolGuarantee + "," + DrawPoolNormal,
+				InitialSpace:    gameCfg.InitialSpace,
+				MaxPlayers:      gameCfg.PlayersPerSeason,
+				DraftRounds:     gameCfg.DraftRounds,
+				OverCapPolicy:   OverCapFilter,
+				OverCapHours:    24,
+				MulliganMinutes: 10,
+			}
+			if err := db.Create(&rules).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+	rules.PoolList = parsePoolOrder(rules.PoolOrder)
+	// Rule sets saved before the over-cap policy and mulligans existed
+	if rules.OverCapPolicy == "" {
+		rules.OverCapPolicy = OverCapFilter
+	}
+	if rules.OverCapHours <= 0 {
+		rules.OverCapHours = 24
+	}
+	if rules.MulliganMinutes <= 0 {
+		rules.MulliganMinutes = 10
+	}
+	return &rules, nil
+}
+
+// UpdateSeasonRules changes the season rules (admin only)
+// Draw rules are frozen once the draw phase is over and the initial space once
+// signup has closed; a space change during signup is applied to everyone
+// already registered.
+func UpdateSeasonRules(req *SeasonRulesRequest) (*model.SeasonRules, error) {
+	db := database.GetDB()
+
+	rules, err := GetSeasonRules()
+	if err != nil {
+		return nil, err
+	}
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	registered, err := GetRegisteredCount()
+	if err != nil {
+		return nil, err
+	}
+
+	drawOpen := phase.CurrentPhase == "signup" || phase.CurrentPhase == "draw"
+	if req.GuaranteeDraws != nil || req.NormalDraws != nil || req.PoolOrder != nil || req.OverCapPolicy != "" {
+		if !drawOpen {
+			return nil, ErrSeasonRulesLocked
+		}
+	}
+
+	if req.GuaranteeDraws != nil {
+		if *req.GuaranteeDraws < 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.GuaranteeDraws = *req.GuaranteeDraws
+	}
+	if req.NormalDraws != nil {
+		if *req.NormalDraws < 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.NormalDraws = *req.NormalDraws
+	}
+	if rules.GuaranteeDraws+rules.NormalDraws <= 0 {
+		return nil, ErrInvalidSeasonRules
+	}
+	if req.PoolOrder != nil {
+		if !validPoolOrder(req.PoolOrder) {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.PoolOrder = strings.Join(req.PoolOrder, ",")
+	}
+	switch req.OverCapPolicy {
+	case "":
+	case OverCapFilter, OverCapAllow, OverCapReroll:
+		rules.OverCapPolicy = req.OverCapPolicy
+	default:
+		return nil, ErrInvalidSeasonRules
+	}
+	if req.OverCapHours != nil {
+		if *req.OverCapHours <= 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.OverCapHours = *req.OverCapHours
+	}
+	if req.MulliganMinutes != nil {
+		if *req.MulliganMinutes <= 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.MulliganMinutes = *req.MulliganMinutes
+	}
+
+	spaceDelta := 0
+	if req.InitialSpace != nil && *req.InitialSpace != rules.InitialSpace {
+		if *req.InitialSpace <= 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		if phase.CurrentPhase != "signup" {
+			return nil, ErrSeasonRulesLocked
+		}
+		spaceDelta = *req.InitialSpace - rules.InitialSpace
+		rules.InitialSpace = *req.InitialSpace
+	}
+	if req.MaxPlayers != nil {
+		if *req.MaxPlayers <= 0 || int64(*req.MaxPlayers) < registered {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.MaxPlayers = *req.MaxPlayers
+	}
+	if req.DraftRounds != nil {
+		if *req.DraftRounds <= 0 {
+			return nil, ErrInvalidSeasonRules
+		}
+		rules.DraftRounds = *req.DraftRounds
+	}
+
+	tx := db.Begin()
+
+	if err := tx.Save(rules).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if spaceDelta != 0 {
+		if err := tx.Model(&model.User{}).Where("is_registered = ?", true).
+			Update("space", gorm.Expr("space + ?", spaceDelta)).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := clearOverCapWindows(tx, nil); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	rules.PoolList = parsePoolOrder(rules.PoolOrder)
+	PublishEvent(EventSeasonRulesUpdated, rules)
+	return rules, nil
+}
+
+// parsePoolOrder splits a stored pool order, falling back to the default order
+func parsePoolOrder(order string) []string {
+	pools := []string{}
+	for _, pool := range strings.Split(order, ",") {
+		if pool = strings.TrimSpace(pool); pool != "" {
+			pools = append(pools, pool)
+		}
+	}
+	if !validPoolOrder(pools) {
+		return []string{DrawPoolGuarantee, DrawPoolNormal}
+	}
+	return pools
+}
+
+// validPoolOrder reports whether pools lists every initial draw pool exactly once
+func validPoolOrder(pools []string) bool {
+	if len(pools) != 2 {
+		return false
+	}
+	seen := map[string]bool{}
+	for _, pool := range pools {
+		if pool != DrawPoolGuarantee && pool != DrawPoolNormal {
+			return false
+		}
+		if seen[pool] {
+			return false
+		}
+		seen[pool] = true
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"path/filepath"
+	"testing"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"san11-trade/internal/config"
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+// setupTestDB points the database at a fresh sqlite file for one test
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	config.Init()
+	config.AppConfig.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	if err := database.Init(); err != nil {
+		t.Fatalf("database.Init: %v", err)
+	}
+	database.DB = database.DB.Session(&gorm.Session{Logger: logger.Discard})
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database.DB
+}
+
+// createTestUser adds a registered player with the default space
+func createTestUser(t *testing.T, db *gorm.DB, username string) *model.User {
+	t.Helper()
+	user := model.User{Username: username, Password: "x", Nickname: username, IsRegistered: true, Space: 350}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", username, err)
+	}
+	return &user
+}
+
+// createTestGeneral adds an available general to a pool
+func createTestGeneral(t *testing.T, db *gorm.DB, id uint, poolType string, salary int) *model.General {
+	t.Helper()
+	general := model.General{ID: id, ExcelID: int(id), Name: "general", PoolType: poolType, Salary: salary, Tier: 1, IsAvailable: true}
+	if err := db.Create(&general).Error; err != nil {
+		t.Fatalf("create general %d: %v", id, err)
+	}
+	return &general
+}
+
+// giveTestGeneral puts a general on a player's roster and charges its salary
+func giveTestGeneral(t *testing.T, db *gorm.DB, general *model.General, user *model.User) {
+	t.Helper()
+	if err := db.Model(general).Updates(map[string]interface{}{"owner_id": user.ID, "is_available": false}).Error; err != nil {
+		t.Fatalf("give general %d: %v", general.ID, err)
+	}
+	if err := db.Model(user).Update("used_space", gorm.Expr("used_space + ?", general.Salary)).Error; err != nil {
+		t.Fatalf("charge general %d: %v", general.ID, err)
+	}
+	general.OwnerID = &user.ID
+	general.IsAvailable = false
+}
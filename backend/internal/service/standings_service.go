@@ -0,0 +1,123 @@
+package service
+
+import (
+	"sort"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+// Standings holds every table of the season
+type Standings struct {
+	Groups  []GroupStandings `json:"groups"`
+	Overall []StandingRow    `json:"overall"` // All group and league matches of registered players
+}
+
+// GetStandings returns the group tables and the overall table
+func GetStandings() (*Standings, error) {
+	db := database.GetDB()
+
+	groups, err := GetGroupStandings()
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := GetRegisteredPlayers()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]StandingRow, 0, len(players))
+	for _, player := range players {
+		rows = append(rows, StandingRow{UserID: player.ID, Nickname: player.Nickname})
+	}
+
+	var matches []model.Match
+	if err := db.Where("stage IN ? AND status = ?", []string{MatchStageGroup, MatchStageLeague}, "completed").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	return &Standings{
+		Groups:  groups,
+		Overall: computeStandings(rows, matches),
+	}, nil
+}
+
+// computeStandings fills in and ranks the table rows from completed matches
+// Matches involving players outside the table are ignored.
+// Tiebreakers: points, head-to-head points, score difference, scores for, wins.
+func computeStandings(rows []StandingRow, matches []model.Match) []StandingRow {
+	index := make(map[uint]int, len(rows))
+	for i := range rows {
+		index[rows[i].UserID] = i
+	}
+
+	var played []model.Match
+	for _, match := range matches {
+		if match.HomeID == nil || match.AwayID == nil {
+			continue
+		}
+		home, ok := index[*match.HomeID]
+		if !ok {
+			continue
+		}
+		away, ok := index[*match.AwayID]
+		if !ok {
+			continue
+		}
+		addStandingResult(&rows[home], match.HomeScore, match.AwayScore)
+		addStandingResult(&rows[away], match.AwayScore, match.HomeScore)
+		played = append(played, match)
+	}
+	for i := range rows {
+		rows[i].ScoreDiff = rows[i].ScoreFor - rows[i].ScoreAgainst
+	}
+
+	// Head-to-head only counts matches between players level on points
+	points := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		points[row.UserID] = row.Points
+	}
+	headToHead := make(map[uint]int, len(rows))
+	for _, match := range played {
+		if points[*match.HomeID] != points[*match.AwayID] {
+			continue
+		}
+		switch {
+		case match.HomeScore > match.AwayScore:
+			headToHead[*match.HomeID] += pointsWin
+		case match.HomeScore < match.AwayScore:
+			headToHead[*match.AwayID] += pointsWin
+		default:
+			headToHead[*match.HomeID] += pointsDraw
+			headToHead[*match.AwayID] += pointsDraw
+		}
+	}
+	for i := range rows {
+		rows[i].HeadToHead = headToHead[rows[i].UserID]
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.HeadToHead != b.HeadToHead {
+			return a.HeadToHead > b.HeadToHead
+		}
+		if a.ScoreDiff != b.ScoreDiff {
+			return a.ScoreDiff > b.ScoreDiff
+		}
+		if a.ScoreFor != b.ScoreFor {
+			return a.ScoreFor > b.ScoreFor
+		}
+		if a.Won != b.Won {
+			return a.Won > b.Won
+		}
+		return a.UserID < b.UserID
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+	return rows
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"san11-trade/internal/model"
+)
+
+func testMatch(home, away uint, homeScore, awayScore int) model.Match {
+	return model.Match{HomeID: &home, AwayID: &away, HomeScore: homeScore, AwayScore: awayScore}
+}
+
+func TestComputeStandings(t *testing.T) {
+	tests := []struct {
+		name       string
+		users      []uint
+		matches    []model.Match
+		wantOrder  []uint
+		wantPoints []int
+	}{
+		{
+			name:  "points",
+			users: []uint{1, 2, 3},
+			matches: []model.Match{
+				testMatch(1, 2, 2, 1),
+				testMatch(2, 3, 1, 0),
+				testMatch(3, 1, 0, 3),
+			},
+			wantOrder:  []uint{1, 2, 3},
+			wantPoints: []int{6, 3, 0},
+		},
+		{
+			name:  "head to head before score difference",
+			users: []uint{1, 2, 3, 4},
+			matches: []model.Match{
+				testMatch(1, 2, 1, 0),
+				testMatch(2, 3, 9, 0),
+				testMatch(4, 1, 1, 0),
+				testMatch(4, 2, 1, 0),
+			},
+			wantOrder:  []uint{4, 1, 2, 3},
+			wantPoints: []int{6, 3, 3, 0},
+		},
+		{
+			name:  "score difference",
+			users: []uint{1, 2, 3},
+			matches: []model.Match{
+				testMatch(1, 2, 0, 0),
+				testMatch(1, 3, 1, 0),
+				testMatch(2, 3, 3, 0),
+			},
+			wantOrder:  []uint{2, 1, 3},
+			wantPoints: []int{4, 4, 0},
+		},
+		{
+			name:  "score for",
+			users: []uint{1, 2, 3},
+			matches: []model.Match{
+				testMatch(1, 2, 1, 1),
+				testMatch(1, 3, 2, 2),
+				testMatch(2, 3, 0, 0),
+			},
+			wantOrder:  []uint{1, 3, 2},
+			wantPoints: []int{2, 2, 2},
+		},
+		{
+			name:       "user id breaks full ties",
+			users:      []uint{3, 1, 2},
+			wantOrder:  []uint{1, 2, 3},
+			wantPoints: []int{0, 0, 0},
+		},
+		{
+			name:  "unscheduled and foreign matches are ignored",
+			users: []uint{1, 2},
+			matches: []model.Match{
+				{HomeID: func() *uint { id := uint(1); return &id }(), HomeScore: 5},
+				testMatch(2, 99, 3, 0),
+				testMatch(1, 2, 0, 1),
+			},
+			wantOrder:  []uint{2, 1},
+			wantPoints: []int{3, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows := make([]StandingRow, len(tt.users))
+			for i, id := range tt.users {
+				rows[i] = StandingRow{UserID: id}
+			}
+			rows = computeStandings(rows, tt.matches)
+
+			var order []uint
+			var points []int
+			for i, row := range rows {
+				order = append(order, row.UserID)
+				points = append(points, row.Points)
+				if row.Rank != i+1 {
+					t.Errorf("user %d rank = %d, want %d", row.UserID, row.Rank, i+1)
+				}
+				if row.ScoreDiff != row.ScoreFor-row.ScoreAgainst {
+					t.Errorf("user %d score diff = %d, want %d", row.UserID, row.ScoreDiff, row.ScoreFor-row.ScoreAgainst)
+				}
+				if row.Played != row.Won+row.Drawn+row.Lost {
+					t.Errorf("user %d played = %d, want %d", row.UserID, row.Played, row.Won+row.Drawn+row.Lost)
+				}
+			}
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("order = %v, want %v", order, tt.wantOrder)
+			}
+			if !reflect.DeepEqual(points, tt.wantPoints) {
+				t.Errorf("points = %v, want %v", points, tt.wantPoints)
+			}
+		})
+	}
+}
+
+func TestComputeStandingsHeadToHead(t *testing.T) {
+	rows := []StandingRow{{UserID: 1}, {UserID: 2}, {UserID: 3}}
+	matches := []model.Match{
+		testMatch(1, 2, 1, 1),
+		testMatch(1, 3, 0, 1),
+		testMatch(2, 3, 0, 1),
+	}
+	rows = computeStandings(rows, matches)
+
+	want := map[uint]int{3: 0, 1: 1, 2: 1}
+	for _, row := range rows {
+		if row.HeadToHead != want[row.UserID] {
+			t.Errorf("user %d head to head = %d, want %d", row.UserID, row.HeadToHead, want[row.UserID])
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package service
+
+import (
+	"errors"
+	"math/rand"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrGroupsAlreadyDrawn    = errors.New("groups have already been drawn")
+	ErrGroupsNotDrawn        = errors.New("groups have not been drawn yet")
+	ErrInvalidGroupCount     = errors.New("every group needs at least two players")
+	ErrInvalidSeed           = errors.New("seeded players must be registered and listed once")
+	ErrGroupStageUnfinished  = errors.New("group stage matches are still being played")
+	ErrKnockoutAlreadyExists = errors.New("knockout bracket has already been generated")
+	ErrInvalidAdvanceCount   = errors.New("invalid number of players advancing per group")
+	ErrMatchNotFound         = errors.New("match not found")
+	ErrMatchNotPlayable      = errors.New("match is not ready to be played")
+	ErrMatchAlreadyCompleted = errors.New("match has already been completed")
+	ErrKnockoutDraw          = errors.New("knockout matches cannot end in a draw")
+	ErrInvalidMatchScore     = errors.New("scores cannot be negative")
+)
+
+// Match stages
+const (
+	MatchStageGroup    = "group"
+	MatchStageKnockout = "knockout"
+	MatchStageLeague   = "league" // One-off match created by an admin or imported
+)
+
+// Group stage points
+const (
+	pointsWin  = 3
+	pointsDraw = 1
+)
+
+// openMatchStatuses are the statuses of matches still waiting for a final result
+var openMatchStatuses = []string{"scheduled", "reported", "disputed"}
+
+// DrawGroupsRequest configures the group draw
+type DrawGroupsRequest struct {
+	GroupCount       int    `json:"group_count" binding:"required"`
+	Seeds            []uint `json:"seeds"`              // Seeded user IDs, strongest first; spread over the groups
+	StartRound       int    `json:"start_round"`        // Round of the first matchday, 0 = current round
+	DoubleRoundRobin bool   `json:"double_round_robin"` // Play every opponent home and away
+}
+
+// KnockoutRequest configures the knockout bracket
+type KnockoutRequest struct {
+	AdvancePerGroup int `json:"advance_per_group"` // Default 2
+	StartRound      int `json:"start_round"`       // Round of the first knockout round, 0 = after the group stage
+}
+
+// MatchResultRequest records the score of a match
+type MatchResultRequest struct {
+	HomeScore int `json:"home_score"`
+	AwayScore int `json:"away_score"`
+}
+
+// StandingRow is one player's line in a group table
+type StandingRow struct {
+	Rank         int    `json:"rank"`
+	UserID       uint   `json:"user_id"`
+	Nickname     string `json:"nickname"`
+	Seed         int    `json:"seed"`
+	Played       int    `json:"played"`
+	Won          int    `json:"won"`
+	Drawn        int    `json:"drawn"`
+	Lost         int    `json:"lost"`
+	ScoreFor     int    `json:"score_for"`
+	ScoreAgainst int    `json:"score_against"`
+	ScoreDiff    int    `json:"score_diff"`
+	Points       int    `json:"points"`
+	HeadToHead   int    `json:"head_to_head"` // Points against players level on points
+}
+
+// GroupStandings is the table of one group
+type GroupStandings struct {
+	GroupID uint          `json:"group_id"`
+	Name    string        `json:"name"`
+	Rows    []StandingRow `json:"rows"`
+}
+
+// DrawGroups splits the registered players into groups and schedules the round-robin fixtures (admin only)
+// Seeded players are spread over the groups in snake order; everyone else is placed at random.
+func DrawGroups(req *DrawGroupsRequest) ([]model.TournamentGroup, error) {
+	db := database.GetDB()
+
+	var existing int64
+	if err := db.Model(&model.TournamentGroup{}).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrGroupsAlreadyDrawn
+	}
+
+	players, err := GetRegisteredPlayers()
+	if err != nil {
+		return nil, err
+	}
+	if req.GroupCount < 1 || len(players) < req.GroupCount*2 {
+		return nil, ErrInvalidGroupCount
+	}
+
+	// Seeded players first, in seed order, then the rest shuffled
+	registered := make(map[uint]bool, len(players))
+	for _, player := range players {
+		registered[player.ID] = true
+	}
+	seeds := make(map[uint]int, len(req.Seeds))
+	order := make([]uint, 0, len(players))
+	for i, userID := range req.Seeds {
+		if !registered[userID] || seeds[userID] > 0 {
+			return nil, ErrInvalidSeed
+		}
+		seeds[userID] = i + 1
+		order = append(order, userID)
+	}
+	var unseeded []uint
+	for _, player := range players {
+		if seeds[player.ID] == 0 {
+			unseeded = append(unseeded, player.ID)
+		}
+	}
+	rand.Shuffle(len(unseeded), func(i, j int) {
+		unseeded[i], unseeded[j] = unseeded[j], unseeded[i]
+	})
+	order = append(order, unseeded...)
+
+	// Snake distribution: A B C C B A A B C ...
+	members := make([][]uint, req.GroupCount)
+	for i, userID := range order {
+		pot, pos := i/req.GroupCount, i%req.GroupCount
+		if pot%2 == 1 {
+			pos = req.GroupCount - 1 - pos
+		}
+		members[pos] = append(members[pos], userID)
+	}
+
+	startRound := req.StartRound
+	if startRound <= 0 {
+		phase, err := GetGamePhase()
+		if err != nil {
+			return nil, err
+		}
+		startRound = phase.RoundNumber
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	for g, userIDs := range members {
+		group := model.TournamentGroup{Name: groupName(g)}
+		if err := tx.Create(&group).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		for _, userID := range userIDs {
+			member := model.TournamentGroupMember{
+				GroupID: group.ID,
+				UserID:  userID,
+				Seed:    seeds[userID],
+			}
+			if err := tx.Create(&member).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+
+		rounds := roundRobinRounds(userIDs)
+		legs := 1
+		if req.DoubleRoundRobin {
+			legs = 2
+		}
+		for leg := 0; leg < legs; leg++ {
+			for r, pairs := range rounds {
+				for _, pair := range pairs {
+					home, away := pair[0], pair[1]
+					if leg == 1 {
+						home, away = away, home
+					}
+					groupID := group.ID
+					match := model.Match{
+						Stage:       MatchStageGroup,
+						GroupID:     &groupID,
+						RoundNumber: startRound + leg*len(rounds) + r,
+						HomeID:      &home,
+						AwayID:      &away,
+						Status:      "scheduled",
+					}
+					if err := tx.Create(&match).Error; err != nil {
+						tx.Rollback()
+						return nil, err
+					}
+				}
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	groups, err := GetTournamentGroups()
+	if err != nil {
+		return nil, err
+	}
+	PublishEvent(EventTournamentUpdated, map[string]interface{}{"stage": MatchStageGroup})
+	return groups, nil
+}
+
+// groupName returns the letter of the i-th group
+func groupName(i int) string {
+	if i < 26 {
+		return string(rune('A' + i))
+	}
+	return string(rune('A'+i/26-1)) + string(rune('A'+i%26))
+}
+
+// roundRobinRounds pairs every player with every other once using the circle method
+// Each entry is one matchday; players with an odd count sit out in turn.
+func roundRobinRounds(userIDs []uint) [][][2]uint {
+	players := append([]uint(nil), userIDs...)
+	if len(players)%2 == 1 {
+		players = append(players, 0) // 0 = bye
+	}
+	n := len(players)
+
+	rounds := make([][][2]uint, 0, n-1)
+	for r := 0; r < n-1; r++ {
+		var pairs [][2]uint
+		for i := 0; i < n/2; i++ {
+			home, away := players[i], players[n-1-i]
+			if home == 0 || away == 0 {
+				continue
+			}
+			// Alternate home side between matchdays
+			if r%2 == 1 {
+				home, away = away, home
+			}
+			pairs = append(pairs, [2]uint{home, away})
+		}
+		rounds = append(rounds, pairs)
+
+		// Rotate everyone but the first player
+		last := players[n-1]
+		copy(players[2:], players[1:n-1])
+		players[1] = last
+	}
+	return rounds
+}
+
+// GetTournamentGroups returns the groups with their members
+func GetTournamentGroups() ([]model.TournamentGroup, error) {
+	db := database.GetDB()
+
+	var groups []model.TournamentGroup
+	if err := db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("seed = 0, seed ASC, id ASC")
+	}).Preload("Members.User").Order("id ASC").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetMatches returns fixtures, optionally filtered by round, stage and player
+func GetMatches(round int, stage string, userID uint) ([]model.Match, error) {
+	db := database.GetDB()
+
+	query := db.Preload("Home").Preload("Away")
+	if round > 0 {
+		query = query.Where("round_number = ?", round)
+	}
+	if stage != "" {
+		query = query.Where("stage = ?", stage)
+	}
+	if userID > 0 {
+		query = query.Where("(home_id = ? OR away_id = ?)", userID, userID)
+	}
+
+	var matches []model.Match
+	if err := query.Order("round_number ASC, group_id ASC, knockout_round ASC, bracket_slot ASC, id ASC").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// GetMatchByID returns a match by ID
+func GetMatchByID(matchID uint) (*model.Match, error) {
+	db := database.GetDB()
+
+	var match model.Match
+	if err := db.Preload("Home").Preload("Away").
+		Preload("Reports", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).Preload("Reports.Reporter").
+		First(&match, matchID).Error; err != nil {
+		return nil, ErrMatchNotFound
+	}
+	return &match, nil
+}
+
+// GetKnockoutBracket returns the knockout matches in bracket order
+func GetKnockoutBracket() ([]model.Match, error) {
+	db := database.GetDB()
+
+	var matches []model.Match
+	if err := db.Where("stage = ?", MatchStageKnockout).
+		Preload("Home").Preload("Away").
+		Order("knockout_round ASC, bracket_slot ASC").
+		Find(&matches).Error; err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// GetGroupStandings computes every group table from completed group matches
+func GetGroupStandings() ([]GroupStandings, error) {
+	db := database.GetDB()
+
+	groups, err := GetTournamentGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []model.Match
+	if err := db.Where("stage = ? AND status = ?", MatchStageGroup, "completed").Find(&matches).Error; err != nil {
+		return nil, err
+	}
+
+	standings := make([]GroupStandings, 0, len(groups))
+	for _, group := range groups {
+		rows := make([]StandingRow, 0, len(group.Members))
+		for _, member := range group.Members {
+			rows = append(rows, StandingRow{
+				UserID:   member.UserID,
+				Nickname: member.User.Nickname,
+				Seed:     member.Seed,
+			})
+		}
+
+		var groupMatches []model.Match
+		for _, match := range matches {
+			if match.GroupID != nil && *match.GroupID == group.ID {
+				groupMatches = append(groupMatches, match)
+			}
+		}
+
+		standings = append(standings, GroupStandings{
+			GroupID: group.ID,
+			Name:    group.Name,
+			Rows:    computeStandings(rows, groupMatches),
+		})
+	}
+	return standings, nil
+}
+
+// addStandingResult adds one match to a player's table row
+func addStandingResult(row *StandingRow, scored, conceded int) {
+	row.Played++
+	row.ScoreFor += scored
+	row.ScoreAgainst += conceded
+	switch {
+	case scored > conceded:
+		row.Won++
+		row.Points += pointsWin
+	case scored == conceded:
+		row.Drawn++
+		row.Points += pointsDraw
+	default:
+		row.Lost++
+	}
+}
+
+// GenerateKnockout seeds the knockout bracket from the final group standings (admin only)
+// Group winners are seeded first, then runners-up, so players from the same group
+// meet as late as possible. Top seeds get byes when the field is not a power of two.
+func GenerateKnockout(req *KnockoutRequest) ([]model.Match, error) {
+	db := database.GetDB()
+
+	var existing int64
+	if err := db.Model(&model.Match{}).Where("stage = ?", MatchStageKnockout).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrKnockoutAlreadyExists
+	}
+
+	var unfinished int64
+	if err := db.Model(&model.Match{}).Where("stage = ? AND status IN ?", MatchStageGroup, openMatchStatuses).Count(&unfinished).Error; err != nil {
+		return nil, err
+	}
+	if unfinished > 0 {
+		return nil, ErrGroupStageUnfinished
+	}
+
+	standings, err := GetGroupStandings()
+	if err != nil {
+		return nil, err
+	}
+	if len(standings) == 0 {
+		return nil, ErrGroupsNotDrawn
+	}
+
+	advance := req.AdvancePerGroup
+	if advance == 0 {
+		advance = 2
+	}
+	if advance < 1 {
+		return nil, ErrInvalidAdvanceCount
+	}
+	for _, group := range standings {
+		if len(group.Rows) < advance {
+			return nil, ErrInvalidAdvanceCount
+		}
+	}
+
+	var qualifiers []uint
+	for pos := 0; pos < advance; pos++ {
+		for _, group := range standings {
+			qualifiers = append(qualifiers, group.Rows[pos].UserID)
+		}
+	}
+	if len(qualifiers) < 2 {
+		return nil, ErrInvalidAdvanceCount
+	}
+
+	startRound := req.StartRound
+	if startRound <= 0 {
+		var lastRound int
+		if err := db.Model(&model.Match{}).Where("stage = ?", MatchStageGroup).
+			Select("COALESCE(MAX(round_number), 0)").Scan(&lastRound).Error; err != nil {
+			return nil, err
+		}
+		startRound = lastRound + 1
+	}
+
+	size := 2
+	rounds := 1
+	for size < len(qualifiers) {
+		size *= 2
+		rounds++
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	// Build from the final backwards so every match knows where its winner goes
+	byRound := make([][]model.Match, rounds+1)
+	for k := rounds; k >= 1; k-- {
+		count := size >> k
+		byRound[k] = make([]model.Match, count)
+		for slot := 1; slot <= count; slot++ {
+			match := &byRound[k][slot-1]
+			match.Stage = MatchStageKnockout
+			match.KnockoutRound = k
+			match.BracketSlot = slot
+			match.RoundNumber = startRound + k - 1
+			match.Status = "scheduled"
+			if k < rounds {
+				next := byRound[k+1][(slot-1)/2].ID
+				match.NextMatchID = &next
+			}
+			if k == 1 {
+				order := bracketSeedOrder(size)
+				if seed := order[2*(slot-1)]; seed <= len(qualifiers) {
+					match.HomeID = &qualifiers[seed-1]
+				}
+				if seed := order[2*(slot-1)+1]; seed <= len(qualifiers) {
+					match.AwayID = &qualifiers[seed-1]
+				}
+			}
+			if err := tx.Create(match).Error; err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+	}
+
+	// Byes: the seeded player goes straight through
+	for i := range byRound[1] {
+		match := &byRound[1][i]
+		if match.HomeID != nil && match.AwayID != nil {
+			continue
+		}
+		winner := match.HomeID
+		if winner == nil {
+			winner = match.AwayID
+		}
+		match.WinnerID = winner
+		match.Status = "bye"
+		if err := tx.Model(&model.Match{}).Where("id = ?", match.ID).Updates(map[string]interface{}{
+			"winner_id": winner,
+			"status":    "bye",
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if err := advanceKnockoutWinner(tx, match); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	PublishEvent(EventTournamentUpdated, map[string]interface{}{"stage": MatchStageKnockout})
+	return GetKnockoutBracket()
+}
+
+// bracketSeedOrder returns the seed of every bracket position so that seed 1 meets seed 2 only in the final
+// e.g. size 8: 1 8 4 5 2 7 3 6
+func bracketSeedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		n := len(order) * 2
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// advanceKnockoutWinner moves the winner of a knockout match into the next round
+// Odd bracket slots feed the home side of the next match, even slots the away side.
+func advanceKnockoutWinner(tx *gorm.DB, match *model.Match) error {
+	if match.NextMatchID == nil || match.WinnerID == nil {
+		return nil
+	}
+	return tx.Model(&model.Match{}).Where("id = ?", *match.NextMatchID).Update(knockoutNextSide(match), *match.WinnerID).Error
+}
+
+// knockoutNextSide returns the column of the next match the winner of match fills
+func knockoutNextSide(match *model.Match) string {
+	if match.BracketSlot%2 == 1 {
+		return "home_id"
+	}
+	return "away_id"
+}
+
+// reopenKnockoutMatch undoes the result of a knockout match whose line-up changed
+// The match goes back to scheduled, its reports are superseded, and if it was
+// already played its winner is taken out of the following match as well.
+func reopenKnockoutMatch(tx *gorm.DB, matchID uint) error {
+	var match model.Match
+	if err := tx.First(&match, matchID).Error; err != nil {
+		return ErrMatchNotFound
+	}
+
+	if match.Status == "completed" && match.NextMatchID != nil && match.WinnerID != nil {
+		if err := tx.Model(&model.Match{}).Where("id = ?", *match.NextMatchID).Update(knockoutNextSide(&match), nil).Error; err != nil {
+			return err
+		}
+		if err := reopenKnockoutMatch(tx, *match.NextMatchID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&model.Match{}).Where("id = ?", match.ID).Updates(map[string]interface{}{
+		"home_score": 0,
+		"away_score": 0,
+		"winner_id":  nil,
+		"status":     "scheduled",
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.MatchReport{}).Where("match_id = ? AND status IN ?", match.ID, []string{"pending", "disputed", "confirmed"}).
+		Update("status", "superseded").Error
+}
+
+// RecordMatchResult sets the final score of a match, overruling player reports (admin only)
+// A completed match may be corrected; a changed knockout winner is re-advanced
+// and any later match the old winner reached is reopened.
+func RecordMatchResult(matchID uint, req *MatchResultRequest) (*model.Match, error) {
+	db := database.GetDB()
+
+	var match model.Match
+	if err := db.First(&match, matchID).Error; err != nil {
+		return nil, ErrMatchNotFound
+	}
+
+	// Begin transaction
+	tx := db.Begin()
+
+	var err error
+	if match.Status == "completed" {
+		err = overrideMatchResult(tx, &match, req.HomeScore, req.AwayScore)
+	} else {
+		err = completeMatch(tx, &match, req.HomeScore, req.AwayScore, openMatchStatuses)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The admin's score settles any open reports
+	if err := tx.Model(&model.MatchReport{}).Where("match_id = ? AND status IN ?", match.ID, []string{"pending", "disputed"}).
+		Update("status", "resolved").Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	PublishEvent(EventMatchCompleted, match)
+	return GetMatchByID(match.ID)
+}
+
+// completeMatch records the score of a match in one of fromStatuses inside tx and advances knockout winners
+func completeMatch(tx *gorm.DB, match *model.Match, homeScore, awayScore int, fromStatuses []string) error {
+	winner, err := matchWinner(match, homeScore, awayScore)
+	if err != nil {
+		return err
+	}
+
+	result := tx.Model(&model.Match{}).Where("id = ? AND status IN ?", match.ID, fromStatuses).Updates(map[string]interface{}{
+		"home_score": homeScore,
+		"away_score": awayScore,
+		"winner_id":  winner,
+		"status":     "completed",
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMatchAlreadyCompleted
+	}
+
+	match.HomeScore = homeScore
+	match.AwayScore = awayScore
+	match.WinnerID = winner
+	match.Status = "completed"
+	return advanceKnockoutWinner(tx, match)
+}
+
+// overrideMatchResult corrects the score of a completed match inside tx
+func overrideMatchResult(tx *gorm.DB, match *model.Match, homeScore, awayScore int) error {
+	winner, err := matchWinner(match, homeScore, awayScore)
+	if err != nil {
+		return err
+	}
+	winnerChanged := (winner == nil) != (match.WinnerID == nil) ||
+		(winner != nil && match.WinnerID != nil && *winner != *match.WinnerID)
+
+	result := tx.Model(&model.Match{}).Where("id = ? AND status = ?", match.ID, "completed").Updates(map[string]interface{}{
+		"home_score": homeScore,
+		"away_score": awayScore,
+		"winner_id":  winner,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMatchNotPlayable
+	}
+
+	match.HomeScore = homeScore
+	match.AwayScore = awayScore
+	match.WinnerID = winner
+	if !winnerChanged || match.NextMatchID == nil {
+		return nil
+	}
+
+	// The old winner may have played on already; undo that before re-advancing
+	if err := reopenKnockoutMatch(tx, *match.NextMatchID); err != nil {
+		return err
+	}
+	return advanceKnockoutWinner(tx, match)
+}
+
+// matchWinner validates a score and returns the winner, nil for a draw
+func matchWinner(match *model.Match, homeScore, awayScore int) (*uint, error) {
+	if match.HomeID == nil || match.AwayID == nil {
+		return nil, ErrMatchNotPlayable
+	}
+	if homeScore < 0 || awayScore < 0 {
+		return nil, ErrInvalidMatchScore
+	}
+
+	switch {
+	case homeScore > awayScore:
+		return match.HomeID, nil
+	case awayScore > homeScore:
+		return match.AwayID, nil
+	case match.Stage == MatchStageKnockout:
+		return nil, ErrKnockoutDraw
+	}
+	return nil, nil
+}
+
+// ResetTournament deletes all groups and matches (admin only)
+func ResetTournament() error {
+	db := database.GetDB()
+
+	// Begin transaction
+	tx := db.Begin()
+
+	if err := tx.Exec("DELETE FROM match_reports").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM matches").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM tournament_group_members").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Exec("DELETE FROM tournament_groups").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	PublishEvent(EventTournamentUpdated, map[string]interface{}{"stage": ""})
+	return nil
+}
@@ -0,0 +1,599 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidReviewMode   = errors.New("invalid trade review mode")
+	ErrTradeNotUnderReview = errors.New("trade is not awaiting approval")
+	ErrLeagueVoteDisabled  = errors.New("league veto voting is not enabled")
+	ErrCannotVetoOwnTrade  = errors.New("you cannot vote on your own trade")
+	ErrAlreadyVotedVeto    = errors.New("you have already voted to veto this trade")
+	ErrVetoReasonRequired  = errors.New("a reason is required to veto a trade")
+	ErrNotRegisteredToVote = errors.New("only registered players can vote")
+	ErrReviewVoteClosed    = errors.New("the veto vote for this trade has ended")
+)
+
+// Trade review modes
+const (
+	TradeReviewNone         = "none"         // Trades execute on acceptance
+	TradeReviewCommissioner = "commissioner" // An admin approves or vetoes every trade
+	TradeReviewLeagueVote   = "league_vote"  // Trades execute unless enough players veto in time
+)
+
+// TradeSettingsRequest updates the trade review configuration
+type TradeSettingsRequest struct {
+	ReviewMode    string `json:"review_mode"`
+	VetoThreshold int    `json:"veto_threshold"`
+	VoteHours     int    `json:"vote_hours"`
+}
+
+// GetTradeSettings gets or creates the trade review configuration
+func GetTradeSettings() (*model.TradeSettings, error) {
+	db := database.GetDB()
+	var settings model.TradeSettings
+	if err := db.First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Create default settings
+			settings = model.TradeSettings{
+				ReviewMode:    TradeReviewNone,
+				VetoThreshold: 3,
+				VoteHours:     24,
+			}
+			if err := db.Create(&settings).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+	return &settings, nil
+}
+
+// UpdateTradeSettings changes the trade review configuration (admin only)
+// Trades already awaiting approval keep the rules they were submitted under.
+func UpdateTradeSettings(req *TradeSettingsRequest) (*model.TradeSettings, error) {
+	db := database.GetDB()
+
+	switch req.ReviewMode {
+	case TradeReviewNone, TradeReviewCommissioner, TradeReviewLeagueVote:
+	default:
+		return nil, ErrInvalidReviewMode
+	}
+	if req.ReviewMode == TradeReviewLeagueVote && (req.VetoThreshold <= 0 || req.VoteHours <= 0) {
+		return nil, ErrInvalidReviewMode
+	}
+
+	settings, err := GetTradeSettings()
+	if err != nil {
+		return nil, err
+	}
+	settings.ReviewMode = req.ReviewMode
+	if req.VetoThreshold > 0 {
+		settings.VetoThreshold = req.VetoThreshold
+	}
+	if req.VoteHours > 0 {
+		settings.VoteHours = req.VoteHours
+	}
+	if err := db.Save(settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// submitTradeForReview moves an accepted trade to the review queue
+func submitTradeForReview(trade *model.Trade, userID uint, settings *model.TradeSettings) error {
+	db := database.GetDB()
+
+	updates := map[string]interface{}{"status": "awaiting_approval"}
+	details := "Trade accepted, awaiting commissioner approval"
+	if settings.ReviewMode == TradeReviewLeagueVote {
+		deadline := time.Now().Add(time.Duration(settings.VoteHours) * time.Hour)
+		updates["review_deadline"] = deadline
+		details = fmt.Sprintf("Trade accepted, league veto vote open until %s", deadline.Format("2006-01-02 15:04"))
+	}
+
+	tx := db.Begin()
+
+	result := tx.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, "pending").Updates(updates)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrTradeAlreadyProcessed
+	}
+
+	if err := logTradeTx(tx, trade.ID, "awaiting_approval", userID, details); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishTradeStatus(trade, "awaiting_approval")
+	if settings.ReviewMode == TradeReviewLeagueVote {
+		// Everyone may vote, so everyone hears about it
+		PublishEvent(EventTradeUpdated, map[string]interface{}{
+			"trade_id": trade.ID,
+			"status":   "awaiting_approval",
+		})
+	}
+	return nil
+}
+
+// GetTradeReviewQueue returns trades awaiting approval with their veto votes
+func GetTradeReviewQueue() ([]model.Trade, error) {
+	db := database.GetDB()
+
+	var trades []model.Trade
+	if err := db.Where("status = ?", "awaiting_approval").
+		Preload("Proposer").Preload("Receiver").Preload("Vetoes.User").
+		Order("updated_at ASC").
+		Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// ApproveTrade executes a trade awaiting approval (admin only)
+func ApproveTrade(tradeID uint, adminID uint, reason string) error {
+	trade, err := getTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+
+	// Approval can come long after acceptance; trading must still be open
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return err
+	}
+	if err := checkTradeLimit(database.GetDB(), window, phase.RoundNumber, trade.ProposerID, trade.ReceiverID); err != nil {
+		return err
+	}
+
+	details := "Approved by commissioner"
+	if reason != "" {
+		details += ": " + reason
+	}
+	return executeTrade(trade, "awaiting_approval", phase.RoundNumber, adminID, "approved", details)
+}
+
+// VetoTrade blocks a trade awaiting approval (admin only)
+func VetoTrade(tradeID uint, adminID uint, reason string) error {
+	if reason == "" {
+		return ErrVetoReasonRequired
+	}
+
+	trade, err := getTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+
+	return vetoTrade(trade, adminID, "Vetoed by commissioner: "+reason)
+}
+
+// CastTradeVeto records a league member's veto vote
+// Reaching the threshold vetoes the trade immediately.
+func CastTradeVeto(tradeID uint, userID uint, reason string) error {
+	db := database.GetDB()
+
+	trade, err := getTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+	if trade.ReviewDeadline == nil {
+		return ErrLeagueVoteDisabled
+	}
+	if time.Now().After(*trade.ReviewDeadline) {
+		return ErrReviewVoteClosed
+	}
+	if trade.ProposerID == userID || trade.ReceiverID == userID {
+		return ErrCannotVetoOwnTrade
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if !user.IsRegistered {
+		return ErrNotRegisteredToVote
+	}
+
+	if err := db.Where("trade_id = ? AND user_id = ?", trade.ID, userID).First(&model.TradeVetoVote{}).Error; err == nil {
+		return ErrAlreadyVotedVeto
+	}
+
+	vote := model.TradeVetoVote{
+		TradeID: trade.ID,
+		UserID:  userID,
+		Reason:  reason,
+	}
+	if err := db.Create(&vote).Error; err != nil {
+		return err
+	}
+	logTrade(trade.ID, "veto_vote", userID, reason)
+
+	settings, err := GetTradeSettings()
+	if err != nil {
+		return err
+	}
+
+	var votes int64
+	if err := db.Model(&model.TradeVetoVote{}).Where("trade_id = ?", trade.ID).Count(&votes).Error; err != nil {
+		return err
+	}
+	if int(votes) >= settings.VetoThreshold {
+		err := vetoTrade(trade, 0, fmt.Sprintf("Vetoed by league vote (%d votes)", votes))
+		if err != nil && err != ErrTradeNotUnderReview {
+			return err
+		}
+		return nil
+	}
+
+	PublishEvent(EventTradeUpdated, map[string]interface{}{
+		"trade_id":   trade.ID,
+		"status":     "awaiting_approval",
+		"veto_votes": votes,
+	})
+	return nil
+}
+
+// vetoTrade moves a trade under review to vetoed
+func vetoTrade(trade *model.Trade, performedBy uint, details string) error {
+	db := database.GetDB()
+
+	tx := db.Begin()
+
+	result := tx.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, "awaiting_approval").Update("status", "vetoed")
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrTradeNotUnderReview
+	}
+
+	if err := logTradeTx(tx, trade.ID, "vetoed", performedBy, details); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishTradeStatus(trade, "vetoed")
+	return nil
+}
+
+// getTradeUnderReview loads a trade and checks it is awaiting approval
+func getTradeUnderReview(tradeID uint) (*model.Trade, error) {
+	db := database.GetDB()
+
+	var trade model.Trade
+	if err := db.First(&trade, tradeID).Error; err != nil {
+		return nil, ErrTradeNotFound
+	}
+	if trade.Status != "awaiting_approval" {
+		return nil, ErrTradeNotUnderReview
+	}
+	return &trade, nil
+}
+
+// ProcessTradeReviews executes trades whose league veto vote ended without a veto
+func ProcessTradeReviews() (int, error) {
+	approved, err := processTradeReviews()
+	if err != nil {
+		return approved, err
+	}
+	approvedMulti, err := processMultiTradeReviews()
+	return approved + approvedMulti, err
+}
+
+// processTradeReviews executes the 1:1 trades whose veto vote ended
+func processTradeReviews() (int, error) {
+	db := database.GetDB()
+
+	var trades []model.Trade
+	if err := db.Where("status = ? AND review_deadline IS NOT NULL AND review_deadline <= ?", "awaiting_approval", time.Now()).
+		Find(&trades).Error; err != nil {
+		return 0, err
+	}
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return 0, err
+	}
+
+	approved := 0
+	for i := range trades {
+		err := executeTrade(&trades[i], "awaiting_approval", phase.RoundNumber, 0, "approved", "Approved: league veto vote ended without enough votes")
+		if err == nil {
+			approved++
+			continue
+		}
+		if err == ErrTradeConflict {
+			return approved, err
+		}
+		// The trade can no longer execute (assets moved, over cap...); take it out of the queue
+		result := db.Model(&model.Trade{}).Where("id = ? AND status = ?", trades[i].ID, "awaiting_approval").Update("status", "cancelled")
+		if result.Error == nil && result.RowsAffected > 0 {
+			logTrade(trades[i].ID, "auto_cancelled", 0, "Could not execute after veto vote: "+err.Error())
+			publishTradeStatus(&trades[i], "cancelled")
+		}
+	}
+	return approved, nil
+}
+
+// NextTradeReviewDeadline returns when the next league veto vote ends, or nil. Used by the background scheduler.
+func NextTradeReviewDeadline() (*time.Time, error) {
+	db := database.GetDB()
+
+	var trade model.Trade
+	err := db.Where("status = ? AND review_deadline IS NOT NULL", "awaiting_approval").
+		Order("review_deadline ASC").
+		Limit(1).
+		Find(&trade).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var multiTrade model.MultiTrade
+	err = db.Where("status = ? AND review_deadline IS NOT NULL", "awaiting_approval").
+		Order("review_deadline ASC").
+		Limit(1).
+		Find(&multiTrade).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if multiTrade.ID != 0 && (trade.ID == 0 || multiTrade.ReviewDeadline.Before(*trade.ReviewDeadline)) {
+		return multiTrade.ReviewDeadline, nil
+	}
+	if trade.ID == 0 {
+		return nil, nil
+	}
+	return trade.ReviewDeadline, nil
+}
+
+// submitMultiTradeForReview moves a fully accepted multi-party trade to the review queue inside tx
+func submitMultiTradeForReview(tx *gorm.DB, trade *model.MultiTrade, userID uint, settings *model.TradeSettings) error {
+	updates := map[string]interface{}{"status": "awaiting_approval"}
+	details := "All participants accepted, awaiting commissioner approval"
+	if settings.ReviewMode == TradeReviewLeagueVote {
+		deadline := time.Now().Add(time.Duration(settings.VoteHours) * time.Hour)
+		updates["review_deadline"] = deadline
+		details = fmt.Sprintf("All participants accepted, league veto vote open until %s", deadline.Format("2006-01-02 15:04"))
+	}
+
+	result := tx.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trade.ID, "pending").Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeAlreadyProcessed
+	}
+
+	return logMultiTradeTx(tx, trade.ID, "awaiting_approval", userID, details)
+}
+
+// publishMultiTradeReview announces a multi-party trade entering the review queue
+func publishMultiTradeReview(trade *model.MultiTrade, settings *model.TradeSettings) {
+	publishMultiTradeStatus(trade, "awaiting_approval")
+	if settings.ReviewMode == TradeReviewLeagueVote {
+		// Everyone may vote, so everyone hears about it
+		PublishEvent(EventTradeUpdated, map[string]interface{}{
+			"multi_trade_id": trade.ID,
+			"status":         "awaiting_approval",
+		})
+	}
+}
+
+// GetMultiTradeReviewQueue returns multi-party trades awaiting approval with their veto votes
+func GetMultiTradeReviewQueue() ([]model.MultiTrade, error) {
+	db := database.GetDB()
+
+	var trades []model.MultiTrade
+	if err := db.Where("status = ?", "awaiting_approval").
+		Preload("Participants.User").Preload("Items").Preload("Vetoes.User").
+		Order("updated_at ASC").
+		Find(&trades).Error; err != nil {
+		return nil, err
+	}
+
+	return trades, nil
+}
+
+// ApproveMultiTrade settles a multi-party trade awaiting approval (admin only)
+func ApproveMultiTrade(tradeID uint, adminID uint, reason string) error {
+	trade, err := getMultiTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+
+	// Approval can come long after acceptance; trading must still be open
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return err
+	}
+	if err := checkTradeLimit(database.GetDB(), window, phase.RoundNumber, multiTradeParticipantIDs(trade)...); err != nil {
+		return err
+	}
+
+	details := "Approved by commissioner"
+	if reason != "" {
+		details += ": " + reason
+	}
+	return executeMultiTrade(trade, phase.RoundNumber, adminID, "approved", details)
+}
+
+// VetoMultiTrade blocks a multi-party trade awaiting approval (admin only)
+func VetoMultiTrade(tradeID uint, adminID uint, reason string) error {
+	if reason == "" {
+		return ErrVetoReasonRequired
+	}
+
+	trade, err := getMultiTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+
+	return vetoMultiTrade(trade, adminID, "Vetoed by commissioner: "+reason)
+}
+
+// CastMultiTradeVeto records a league member's veto vote on a multi-party trade
+// Reaching the threshold vetoes the trade immediately.
+func CastMultiTradeVeto(tradeID uint, userID uint, reason string) error {
+	db := database.GetDB()
+
+	trade, err := getMultiTradeUnderReview(tradeID)
+	if err != nil {
+		return err
+	}
+	if trade.ReviewDeadline == nil {
+		return ErrLeagueVoteDisabled
+	}
+	if time.Now().After(*trade.ReviewDeadline) {
+		return ErrReviewVoteClosed
+	}
+	if findMultiTradeParticipant(trade, userID) != nil {
+		return ErrCannotVetoOwnTrade
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if !user.IsRegistered {
+		return ErrNotRegisteredToVote
+	}
+
+	if err := db.Where("multi_trade_id = ? AND user_id = ?", trade.ID, userID).First(&model.MultiTradeVetoVote{}).Error; err == nil {
+		return ErrAlreadyVotedVeto
+	}
+
+	vote := model.MultiTradeVetoVote{
+		MultiTradeID: trade.ID,
+		UserID:       userID,
+		Reason:       reason,
+	}
+	if err := db.Create(&vote).Error; err != nil {
+		return err
+	}
+	logMultiTradeTx(db, trade.ID, "veto_vote", userID, reason)
+
+	settings, err := GetTradeSettings()
+	if err != nil {
+		return err
+	}
+
+	var votes int64
+	if err := db.Model(&model.MultiTradeVetoVote{}).Where("multi_trade_id = ?", trade.ID).Count(&votes).Error; err != nil {
+		return err
+	}
+	if int(votes) >= settings.VetoThreshold {
+		err := vetoMultiTrade(trade, 0, fmt.Sprintf("Vetoed by league vote (%d votes)", votes))
+		if err != nil && err != ErrTradeNotUnderReview {
+			return err
+		}
+		return nil
+	}
+
+	PublishEvent(EventTradeUpdated, map[string]interface{}{
+		"multi_trade_id": trade.ID,
+		"status":         "awaiting_approval",
+		"veto_votes":     votes,
+	})
+	return nil
+}
+
+// vetoMultiTrade moves a multi-party trade under review to vetoed
+func vetoMultiTrade(trade *model.MultiTrade, performedBy uint, details string) error {
+	db := database.GetDB()
+
+	tx := db.Begin()
+
+	result := tx.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trade.ID, "awaiting_approval").Update("status", "vetoed")
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrTradeNotUnderReview
+	}
+
+	if err := logMultiTradeTx(tx, trade.ID, "vetoed", performedBy, details); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	publishMultiTradeStatus(trade, "vetoed")
+	return nil
+}
+
+// getMultiTradeUnderReview loads a multi-party trade and checks it is awaiting approval
+func getMultiTradeUnderReview(tradeID uint) (*model.MultiTrade, error) {
+	trade, err := GetMultiTradeByID(tradeID)
+	if err != nil {
+		return nil, err
+	}
+	if trade.Status != "awaiting_approval" {
+		return nil, ErrTradeNotUnderReview
+	}
+	return trade, nil
+}
+
+// processMultiTradeReviews settles the multi-party trades whose veto vote ended
+func processMultiTradeReviews() (int, error) {
+	db := database.GetDB()
+
+	var trades []model.MultiTrade
+	if err := db.Where("status = ? AND review_deadline IS NOT NULL AND review_deadline <= ?", "awaiting_approval", time.Now()).
+		Preload("Participants").Preload("Items").
+		Find(&trades).Error; err != nil {
+		return 0, err
+	}
+
+	phase, err := GetGamePhase()
+	if err != nil {
+		return 0, err
+	}
+
+	approved := 0
+	for i := range trades {
+		err := executeMultiTrade(&trades[i], phase.RoundNumber, 0, "approved", "Approved: league veto vote ended without enough votes")
+		if err == nil {
+			approved++
+			continue
+		}
+		if err == ErrTradeConflict {
+			return approved, err
+		}
+		// The trade can no longer settle (over the limit, over cap...); take it out of the queue
+		result := db.Model(&model.MultiTrade{}).Where("id = ? AND status = ?", trades[i].ID, "awaiting_approval").Update("status", "cancelled")
+		if result.Error == nil && result.RowsAffected > 0 {
+			logMultiTradeTx(db, trades[i].ID, "auto_cancelled", 0, "Could not settle after veto vote: "+err.Error())
+			publishMultiTradeStatus(&trades[i], "cancelled")
+		}
+	}
+	return approved, nil
+}
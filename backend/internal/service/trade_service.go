@@ -3,9 +3,14 @@ package service
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
 	"san11-trade/internal/database"
 	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
 )
 
 var (
@@ -16,8 +21,15 @@ var (
 	ErrTradeAlreadyProcessed = errors.New("trade has already been processed")
 	ErrInvalidTradeItems     = errors.New("invalid trade items")
 	ErrItemNotOwned          = errors.New("you don't own the item you're offering")
+	ErrTradeConflict         = errors.New("trade items changed concurrently, please retry")
+	ErrItemChanged           = errors.New("an item changed hands since the trade was proposed")
+	ErrTradeOverCap          = errors.New("trade would push a roster over its space cap")
+	ErrEmptyTradeMessage     = errors.New("message cannot be empty")
 )
 
+// openTradeStatuses are the statuses of trades that may still execute
+var openTradeStatuses = []string{"pending", "awaiting_approval"}
+
 // TradeItem represents an item in trade
 type TradeItem struct {
 	Type string `json:"type"` // general/treasure/space
@@ -35,19 +47,127 @@ type TradeRequest struct {
 	RequestTreasures []uint `json:"request_treasures"`
 	RequestSpace     int    `json:"request_space"`
 	Message          string `json:"message"`
+
+	ExpiresAt *time.Time `json:"expires_at"` // Optional offer expiry
+	ListingID *uint      `json:"-"`          // Set when proposed from a listing
 }
 
 // CreateTrade creates a new trade proposal
 func CreateTrade(proposerID uint, req *TradeRequest) (*model.Trade, error) {
 	db := database.GetDB()
 
-	// Check phase
-	phase, err := GetGamePhase()
+	trade, err := buildTrade(proposerID, req)
 	if err != nil {
 		return nil, err
 	}
-	if phase.CurrentPhase != "trading" && phase.CurrentPhase != "draft" {
-		return nil, ErrNotInTradingPhase
+
+	tx := db.Begin()
+
+	if err := tx.Create(trade).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// A new proposal starts its own negotiation thread
+	trade.ThreadID = trade.ID
+	if err := tx.Model(trade).Update("thread_id", trade.ThreadID).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := createTradeMessage(tx, trade, proposerID, req.Message); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	// Log the trade creation
+	logTrade(trade.ID, "created", proposerID, "Trade created")
+
+	PublishEvent(EventTradeCreated, trade, trade.ProposerID, trade.ReceiverID)
+
+	return trade, nil
+}
+
+// CounterTrade answers a pending trade with a modified offer
+// The receiver becomes the proposer of a linked trade in the same thread and
+// the original trade is closed as countered.
+func CounterTrade(tradeID uint, userID uint, req *TradeRequest) (*model.Trade, error) {
+	db := database.GetDB()
+
+	var parent model.Trade
+	if err := db.First(&parent, tradeID).Error; err != nil {
+		return nil, ErrTradeNotFound
+	}
+
+	// Only the receiver can counter
+	if parent.ReceiverID != userID {
+		return nil, ErrNotTradeParticipant
+	}
+	if parent.Status != "pending" {
+		return nil, ErrTradeAlreadyProcessed
+	}
+
+	// Roles swap: the counter goes back to the original proposer
+	req.ReceiverID = parent.ProposerID
+	trade, err := buildTrade(userID, req)
+	if err != nil {
+		return nil, err
+	}
+	trade.ParentTradeID = &parent.ID
+	trade.ThreadID = tradeThreadID(&parent)
+
+	tx := db.Begin()
+
+	// Close the parent (only if nobody settled it first)
+	result := tx.Model(&model.Trade{}).Where("id = ? AND status = ?", parent.ID, "pending").Update("status", "countered")
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrTradeAlreadyProcessed
+	}
+
+	if err := tx.Create(trade).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := createTradeMessage(tx, trade, userID, req.Message); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := logTradeTx(tx, parent.ID, "countered", userID, fmt.Sprintf("Countered with trade #%d", trade.ID)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := logTradeTx(tx, trade.ID, "created", userID, fmt.Sprintf("Counter-offer to trade #%d", parent.ID)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	publishTradeStatus(&parent, "countered")
+	PublishEvent(EventTradeCreated, trade, trade.ProposerID, trade.ReceiverID)
+
+	return trade, nil
+}
+
+// buildTrade validates a trade request and builds the unsaved trade
+func buildTrade(proposerID uint, req *TradeRequest) (*model.Trade, error) {
+	// Check phase and trade window
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return nil, err
 	}
 
 	// Cannot trade with self
@@ -55,21 +175,38 @@ func CreateTrade(proposerID uint, req *TradeRequest) (*model.Trade, error) {
 		return nil, ErrCannotTradeWithSelf
 	}
 
-	// Validate that proposer owns the offered items
-	if err := validateOwnership(proposerID, req.OfferGenerals, req.OfferTreasures); err != nil {
+	// Neither side may be past the window's trade limit
+	if err := checkTradeLimit(database.GetDB(), window, phase.RoundNumber, proposerID, req.ReceiverID); err != nil {
+		return nil, err
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, ErrInvalidTradeExpiry
+	}
+
+	// Validate that proposer owns the offered items, remembering their versions
+	versions := make(map[string]int)
+	if err := recordItemVersions(proposerID, req.OfferGenerals, req.OfferTreasures, versions); err != nil {
 		return nil, err
 	}
 
 	// Validate that receiver owns the requested items
-	if err := validateOwnership(req.ReceiverID, req.RequestGenerals, req.RequestTreasures); err != nil {
+	if err := recordItemVersions(req.ReceiverID, req.RequestGenerals, req.RequestTreasures, versions); err != nil {
 		return nil, ErrInvalidTradeItems
 	}
 
+	// Score both sides so lopsided deals stand out
+	evaluation, err := EvaluateTrade(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Serialize arrays to JSON
 	offerGeneralsJSON, _ := json.Marshal(req.OfferGenerals)
 	offerTreasuresJSON, _ := json.Marshal(req.OfferTreasures)
 	requestGeneralsJSON, _ := json.Marshal(req.RequestGenerals)
 	requestTreasuresJSON, _ := json.Marshal(req.RequestTreasures)
+	versionsJSON, _ := json.Marshal(versions)
 
 	trade := &model.Trade{
 		ProposerID:       proposerID,
@@ -82,20 +219,100 @@ func CreateTrade(proposerID uint, req *TradeRequest) (*model.Trade, error) {
 		RequestSpace:     req.RequestSpace,
 		Status:           "pending",
 		Message:          req.Message,
+		ExpiresAt:        req.ExpiresAt,
+		OfferValue:       evaluation.Offer.Total,
+		RequestValue:     evaluation.Request.Total,
+		Imbalance:        evaluation.Imbalance,
+		ListingID:        req.ListingID,
+		ItemVersions:     string(versionsJSON),
 	}
 
-	if err := db.Create(trade).Error; err != nil {
+	// Let the receiver see injured generals in the offer before accepting
+	if err := flagInjuredTradeGenerals(trade); err != nil {
 		return nil, err
 	}
+	return trade, nil
+}
 
-	// Log the trade creation
-	logTrade(trade.ID, "created", proposerID, "Trade created")
+// checkTradingOpen checks the game phase and the admin trade windows
+// The returned window is nil when the current round has no windows configured.
+func checkTradingOpen() (*model.GamePhase, *model.TradeWindow, error) {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, nil, err
+	}
+	if phase.CurrentPhase != "trading" && phase.CurrentPhase != "draft" {
+		return nil, nil, ErrNotInTradingPhase
+	}
 
-	return trade, nil
+	window, err := activeTradeWindow(phase)
+	if err != nil {
+		return nil, nil, err
+	}
+	return phase, window, nil
+}
+
+// AddTradeMessage posts a chat message to a trade's negotiation thread
+func AddTradeMessage(tradeID uint, userID uint, content string) (*model.TradeMessage, error) {
+	db := database.GetDB()
+
+	var trade model.Trade
+	if err := db.First(&trade, tradeID).Error; err != nil {
+		return nil, ErrTradeNotFound
+	}
+	if trade.ProposerID != userID && trade.ReceiverID != userID {
+		return nil, ErrNotTradeParticipant
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrEmptyTradeMessage
+	}
+
+	message := &model.TradeMessage{
+		ThreadID: tradeThreadID(&trade),
+		TradeID:  trade.ID,
+		UserID:   userID,
+		Content:  content,
+	}
+	if err := db.Create(message).Error; err != nil {
+		return nil, err
+	}
+	db.Preload("User").First(message, message.ID)
+
+	PublishEvent(EventTradeMessage, message, trade.ProposerID, trade.ReceiverID)
+
+	return message, nil
+}
+
+// createTradeMessage stores a proposal's note as the first message of the offer
+func createTradeMessage(tx *gorm.DB, trade *model.Trade, userID uint, content string) error {
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+	return tx.Create(&model.TradeMessage{
+		ThreadID: trade.ThreadID,
+		TradeID:  trade.ID,
+		UserID:   userID,
+		Content:  content,
+	}).Error
+}
+
+// tradeThreadID returns the negotiation thread of a trade
+// Trades created before threads existed are their own thread.
+func tradeThreadID(trade *model.Trade) uint {
+	if trade.ThreadID != 0 {
+		return trade.ThreadID
+	}
+	return trade.ID
 }
 
 // validateOwnership checks if a user owns the specified generals and treasures
 func validateOwnership(userID uint, generalIDs []uint, treasureIDs []uint) error {
+	return recordItemVersions(userID, generalIDs, treasureIDs, nil)
+}
+
+// recordItemVersions checks ownership like validateOwnership and, if versions
+// is not nil, stores each item's current version keyed by itemVersionKey
+func recordItemVersions(userID uint, generalIDs []uint, treasureIDs []uint, versions map[string]int) error {
 	db := database.GetDB()
 
 	// Check generals
@@ -107,6 +324,9 @@ func validateOwnership(userID uint, generalIDs []uint, treasureIDs []uint) error
 		if general.OwnerID == nil || *general.OwnerID != userID {
 			return ErrItemNotOwned
 		}
+		if versions != nil {
+			versions[itemVersionKey("general", gid)] = general.Version
+		}
 	}
 
 	// Check treasures
@@ -118,12 +338,35 @@ func validateOwnership(userID uint, generalIDs []uint, treasureIDs []uint) error
 		if treasure.OwnerID == nil || *treasure.OwnerID != userID {
 			return ErrItemNotOwned
 		}
+		if versions != nil {
+			versions[itemVersionKey("treasure", tid)] = treasure.Version
+		}
 	}
 
 	return nil
 }
 
+// itemVersionKey is the Trade.ItemVersions key of a general or treasure
+func itemVersionKey(itemType string, id uint) string {
+	return fmt.Sprintf("%s:%d", itemType, id)
+}
+
+// tradeItemVersion returns the version an item had when the trade was proposed
+// Trades proposed before versions were recorded return nil.
+func tradeItemVersion(trade *model.Trade, itemType string, id uint) *int {
+	var versions map[string]int
+	if trade.ItemVersions == "" || json.Unmarshal([]byte(trade.ItemVersions), &versions) != nil {
+		return nil
+	}
+	version, ok := versions[itemVersionKey(itemType, id)]
+	if !ok {
+		return nil
+	}
+	return &version
+}
+
 // AcceptTrade accepts a trade proposal
+// Depending on the review mode the trade is executed right away or queued for approval.
 func AcceptTrade(tradeID uint, userID uint) error {
 	db := database.GetDB()
 
@@ -142,6 +385,43 @@ func AcceptTrade(tradeID uint, userID uint) error {
 		return ErrTradeAlreadyProcessed
 	}
 
+	// Expired offers can no longer be accepted
+	if trade.ExpiresAt != nil && !time.Now().Before(*trade.ExpiresAt) {
+		if _, err := expireTrade(&trade); err != nil {
+			return err
+		}
+		return ErrTradeExpired
+	}
+
+	// Check phase, trade window and per-round limits
+	phase, window, err := checkTradingOpen()
+	if err != nil {
+		return err
+	}
+	if err := checkTradeLimit(db, window, phase.RoundNumber, trade.ProposerID, trade.ReceiverID); err != nil {
+		return err
+	}
+
+	// Leagues with a review step hold the trade for approval instead of executing it
+	settings, err := GetTradeSettings()
+	if err != nil {
+		return err
+	}
+	if settings.ReviewMode != TradeReviewNone {
+		return submitTradeForReview(&trade, userID, settings)
+	}
+
+	return executeTrade(&trade, "pending", phase.RoundNumber, userID, "accepted", "Trade accepted")
+}
+
+// executeTrade settles a trade that is currently in fromStatus
+// Everything happens in one transaction: the trade is claimed with a status
+// guard, each asset moves only if its owner and version are unchanged, both
+// rosters are checked against their cap, and any other open trade that
+// referenced a moved asset is auto-cancelled.
+func executeTrade(trade *model.Trade, fromStatus string, round int, performedBy uint, action, details string) error {
+	db := database.GetDB()
+
 	// Parse items
 	var offerGenerals, requestGenerals []uint
 	var offerTreasures, requestTreasures []uint
@@ -150,77 +430,308 @@ func AcceptTrade(tradeID uint, userID uint) error {
 	json.Unmarshal([]byte(trade.RequestGenerals), &requestGenerals)
 	json.Unmarshal([]byte(trade.RequestTreasures), &requestTreasures)
 
-	// Re-validate ownership before executing
-	if err := validateOwnership(trade.ProposerID, offerGenerals, offerTreasures); err != nil {
-		// Items no longer valid, cancel trade
-		db.Model(&trade).Update("status", "cancelled")
-		return errors.New("proposer no longer owns the offered items")
-	}
-	if err := validateOwnership(trade.ReceiverID, requestGenerals, requestTreasures); err != nil {
-		db.Model(&trade).Update("status", "cancelled")
-		return errors.New("receiver no longer owns the requested items")
+	limitWindow, err := tradeLimitWindow(round)
+	if err != nil {
+		return err
 	}
 
 	// Begin transaction
 	tx := db.Begin()
 
-	// Get users for space calculations
-	var proposer, receiver model.User
-	tx.First(&proposer, trade.ProposerID)
-	tx.First(&receiver, trade.ReceiverID)
+	// Trades settled since the caller's check count too
+	if err := checkTradeLimit(tx, limitWindow, round, trade.ProposerID, trade.ReceiverID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Claim the trade; a concurrent accept/reject/cancel loses here
+	result := tx.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, fromStatus).Updates(map[string]interface{}{
+		"status":         "accepted",
+		"accepted_at":    time.Now(),
+		"accepted_round": round,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrTradeAlreadyProcessed
+	}
 
 	// Calculate space changes
 	var proposerSpaceChange, receiverSpaceChange int
 
 	// Transfer generals from proposer to receiver
 	for _, gid := range offerGenerals {
-		var g model.General
-		tx.First(&g, gid)
-		tx.Model(&g).Update("owner_id", trade.ReceiverID)
-		proposerSpaceChange -= g.Salary
-		receiverSpaceChange += g.Salary
+		salary, err := transferGeneral(tx, gid, trade.ProposerID, trade.ReceiverID, tradeItemVersion(trade, "general", gid))
+		if err != nil {
+			tx.Rollback()
+			return failTrade(trade, err, "proposer no longer owns the offered items")
+		}
+		proposerSpaceChange -= salary
+		receiverSpaceChange += salary
 	}
 
 	// Transfer generals from receiver to proposer
 	for _, gid := range requestGenerals {
-		var g model.General
-		tx.First(&g, gid)
-		tx.Model(&g).Update("owner_id", trade.ProposerID)
-		receiverSpaceChange -= g.Salary
-		proposerSpaceChange += g.Salary
+		salary, err := transferGeneral(tx, gid, trade.ReceiverID, trade.ProposerID, tradeItemVersion(trade, "general", gid))
+		if err != nil {
+			tx.Rollback()
+			return failTrade(trade, err, "receiver no longer owns the requested items")
+		}
+		receiverSpaceChange -= salary
+		proposerSpaceChange += salary
 	}
 
 	// Transfer treasures from proposer to receiver
 	for _, tid := range offerTreasures {
-		tx.Model(&model.Treasure{}).Where("id = ?", tid).Update("owner_id", trade.ReceiverID)
+		if err := transferTreasure(tx, tid, trade.ProposerID, trade.ReceiverID, tradeItemVersion(trade, "treasure", tid)); err != nil {
+			tx.Rollback()
+			return failTrade(trade, err, "proposer no longer owns the offered items")
+		}
 	}
 
 	// Transfer treasures from receiver to proposer
 	for _, tid := range requestTreasures {
-		tx.Model(&model.Treasure{}).Where("id = ?", tid).Update("owner_id", trade.ProposerID)
+		if err := transferTreasure(tx, tid, trade.ReceiverID, trade.ProposerID, tradeItemVersion(trade, "treasure", tid)); err != nil {
+			tx.Rollback()
+			return failTrade(trade, err, "receiver no longer owns the requested items")
+		}
 	}
 
 	// Handle space exchange
 	proposerSpaceChange += trade.RequestSpace - trade.OfferSpace
 	receiverSpaceChange += trade.OfferSpace - trade.RequestSpace
 
-	// Update used space
-	tx.Model(&proposer).Update("used_space", proposer.UsedSpace+proposerSpaceChange)
-	tx.Model(&receiver).Update("used_space", receiver.UsedSpace+receiverSpaceChange)
+	// Update used space, rejecting the trade if it pushes either roster over its cap
+	if err := applyTradeSpace(tx, trade.ProposerID, proposerSpaceChange); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := applyTradeSpace(tx, trade.ReceiverID, receiverSpaceChange); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Nor may a 国策 bonus lost with the traded generals
+	effects, err := applyClubEffects(tx, trade.ProposerID, trade.ReceiverID)
+	if err == nil {
+		err = checkClubEffectsCap(effects)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
-	// Update trade status
-	tx.Model(&trade).Update("status", "accepted")
+	// Log the decision that executed the trade
+	if err := logTradeTx(tx, trade.ID, action, performedBy, details); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Other pending trades can no longer be executed as proposed
+	movedGenerals := append(append([]uint{}, offerGenerals...), requestGenerals...)
+	movedTreasures := append(append([]uint{}, offerTreasures...), requestTreasures...)
+	reason := fmt.Sprintf("Auto-cancelled: assets moved by accepted trade #%d", trade.ID)
+	cancelled, err := cancelConflictingTrades(tx, trade.ID, performedBy, reason, movedGenerals, movedTreasures)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	cancelledMulti, err := cancelConflictingMultiTrades(tx, 0, movedGenerals, movedTreasures)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		return err
 	}
+	publishClubEffects(effects)
 
-	// Log the trade acceptance
-	logTrade(tradeID, "accepted", userID, "Trade accepted")
+	publishTradeStatus(trade, "accepted")
+	PublishEvent(EventTradeCompleted, map[string]interface{}{
+		"trade_id":    trade.ID,
+		"proposer_id": trade.ProposerID,
+		"receiver_id": trade.ReceiverID,
+	})
+	for i := range cancelled {
+		publishTradeStatus(&cancelled[i], "cancelled")
+	}
+	for i := range cancelledMulti {
+		publishMultiTradeStatus(&cancelledMulti[i], "cancelled")
+	}
 
 	return nil
 }
 
+// transferGeneral moves a general between owners inside tx and returns its salary
+// A general that changed hands since the proposal (version differs from
+// version, when known) fails with ErrItemChanged. The update only matches if
+// owner and version are still what we just read.
+func transferGeneral(tx *gorm.DB, generalID, fromID, toID uint, version *int) (int, error) {
+	var general model.General
+	if err := tx.First(&general, generalID).Error; err != nil {
+		return 0, ErrInvalidTradeItems
+	}
+	if general.OwnerID == nil || *general.OwnerID != fromID {
+		return 0, ErrItemNotOwned
+	}
+	if version != nil && general.Version != *version {
+		return 0, ErrItemChanged
+	}
+
+	result := tx.Model(&model.General{}).
+		Where("id = ? AND owner_id = ? AND version = ?", general.ID, fromID, general.Version).
+		Updates(map[string]interface{}{
+			"owner_id": toID,
+			"version":  general.Version + 1,
+		})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrTradeConflict
+	}
+	return general.Salary, nil
+}
+
+// transferTreasure moves a treasure between owners inside tx
+// Versions are checked like in transferGeneral.
+func transferTreasure(tx *gorm.DB, treasureID, fromID, toID uint, version *int) error {
+	var treasure model.Treasure
+	if err := tx.First(&treasure, treasureID).Error; err != nil {
+		return ErrInvalidTradeItems
+	}
+	if treasure.OwnerID == nil || *treasure.OwnerID != fromID {
+		return ErrItemNotOwned
+	}
+	if version != nil && treasure.Version != *version {
+		return ErrItemChanged
+	}
+
+	result := tx.Model(&model.Treasure{}).
+		Where("id = ? AND owner_id = ? AND version = ?", treasure.ID, fromID, treasure.Version).
+		Updates(map[string]interface{}{
+			"owner_id": toID,
+			"version":  treasure.Version + 1,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeConflict
+	}
+	return nil
+}
+
+// applyTradeSpace adjusts a user's used space inside tx
+// A trade that adds salary to a roster already at or past its cap is rejected.
+func applyTradeSpace(tx *gorm.DB, userID uint, change int) error {
+	var user model.User
+	if err := tx.First(&user, userID).Error; err != nil {
+		return ErrUserNotFound
+	}
+	if change > 0 && user.UsedSpace+change > user.Space {
+		return ErrTradeOverCap
+	}
+
+	result := tx.Model(&model.User{}).
+		Where("id = ? AND used_space = ?", user.ID, user.UsedSpace).
+		Update("used_space", user.UsedSpace+change)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeConflict
+	}
+	return nil
+}
+
+// failTrade cancels a trade whose assets changed hands since it was proposed
+// Version conflicts are returned as-is so the caller can simply retry.
+func failTrade(trade *model.Trade, err error, reason string) error {
+	if err == ErrTradeConflict {
+		return err
+	}
+	if err != ErrItemNotOwned && err != ErrInvalidTradeItems && err != ErrItemChanged {
+		return err
+	}
+	if err == ErrItemChanged {
+		reason = err.Error()
+	}
+
+	db := database.GetDB()
+	result := db.Model(&model.Trade{}).Where("id = ? AND status IN ?", trade.ID, openTradeStatuses).Update("status", "cancelled")
+	if result.Error == nil && result.RowsAffected > 0 {
+		logTrade(trade.ID, "auto_cancelled", 0, reason)
+		publishTradeStatus(trade, "cancelled")
+	}
+	return errors.New(reason)
+}
+
+// cancelConflictingTrades cancels other open trades that reference any moved asset
+func cancelConflictingTrades(tx *gorm.DB, acceptedID uint, performedBy uint, reason string, generalIDs, treasureIDs []uint) ([]model.Trade, error) {
+	if len(generalIDs) == 0 && len(treasureIDs) == 0 {
+		return nil, nil
+	}
+
+	movedGenerals := make(map[uint]bool, len(generalIDs))
+	for _, id := range generalIDs {
+		movedGenerals[id] = true
+	}
+	movedTreasures := make(map[uint]bool, len(treasureIDs))
+	for _, id := range treasureIDs {
+		movedTreasures[id] = true
+	}
+
+	var pending []model.Trade
+	if err := tx.Where("status IN ? AND id <> ?", openTradeStatuses, acceptedID).Find(&pending).Error; err != nil {
+		return nil, err
+	}
+
+	var cancelled []model.Trade
+	for _, other := range pending {
+		var gids, tids []uint
+		for _, field := range []string{other.OfferGenerals, other.RequestGenerals} {
+			var ids []uint
+			json.Unmarshal([]byte(field), &ids)
+			gids = append(gids, ids...)
+		}
+		for _, field := range []string{other.OfferTreasures, other.RequestTreasures} {
+			var ids []uint
+			json.Unmarshal([]byte(field), &ids)
+			tids = append(tids, ids...)
+		}
+
+		conflict := false
+		for _, id := range gids {
+			conflict = conflict || movedGenerals[id]
+		}
+		for _, id := range tids {
+			conflict = conflict || movedTreasures[id]
+		}
+		if !conflict {
+			continue
+		}
+
+		result := tx.Model(&model.Trade{}).Where("id = ? AND status IN ?", other.ID, openTradeStatuses).Update("status", "cancelled")
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := logTradeTx(tx, other.ID, "auto_cancelled", performedBy, reason); err != nil {
+			return nil, err
+		}
+		cancelled = append(cancelled, other)
+	}
+
+	return cancelled, nil
+}
+
 // RejectTrade rejects a trade proposal
 func RejectTrade(tradeID uint, userID uint) error {
 	db := database.GetDB()
@@ -240,14 +751,20 @@ func RejectTrade(tradeID uint, userID uint) error {
 		return ErrTradeAlreadyProcessed
 	}
 
-	// Update trade status
-	if err := db.Model(&trade).Update("status", "rejected").Error; err != nil {
-		return err
+	// Update trade status (only if nobody else settled it first)
+	result := db.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, "pending").Update("status", "rejected")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeAlreadyProcessed
 	}
 
 	// Log the trade rejection
 	logTrade(tradeID, "rejected", userID, "Trade rejected")
 
+	publishTradeStatus(&trade, "rejected")
+
 	return nil
 }
 
@@ -270,14 +787,20 @@ func CancelTrade(tradeID uint, userID uint) error {
 		return ErrTradeAlreadyProcessed
 	}
 
-	// Update trade status
-	if err := db.Model(&trade).Update("status", "cancelled").Error; err != nil {
-		return err
+	// Update trade status (only if nobody else settled it first)
+	result := db.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, "pending").Update("status", "cancelled")
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeAlreadyProcessed
 	}
 
 	// Log the trade cancellation
 	logTrade(tradeID, "cancelled", userID, "Trade cancelled")
 
+	publishTradeStatus(&trade, "cancelled")
+
 	return nil
 }
 
@@ -293,6 +816,10 @@ func GetPendingTrades(userID uint) ([]model.Trade, error) {
 		return nil, err
 	}
 
+	if err := flagInjuredTradeList(trades); err != nil {
+		return nil, err
+	}
+
 	return trades, nil
 }
 
@@ -308,16 +835,25 @@ func GetTradeHistory(userID uint) ([]model.Trade, error) {
 		return nil, err
 	}
 
+	if err := flagInjuredTradeList(trades); err != nil {
+		return nil, err
+	}
+
 	return trades, nil
 }
 
 // GetAllTrades returns all trades (admin)
-func GetAllTrades() ([]model.Trade, error) {
+// With minImbalance > 0 only trades at least that lopsided are returned, most lopsided first.
+func GetAllTrades(minImbalance float64) ([]model.Trade, error) {
 	db := database.GetDB()
 
+	query := db.Preload("Proposer").Preload("Receiver")
+	if minImbalance > 0 {
+		query = query.Where("imbalance >= ?", minImbalance).Order("imbalance DESC")
+	}
+
 	var trades []model.Trade
-	if err := db.Preload("Proposer").Preload("Receiver").
-		Order("created_at DESC").
+	if err := query.Order("created_at DESC").
 		Find(&trades).Error; err != nil {
 		return nil, err
 	}
@@ -325,14 +861,37 @@ func GetAllTrades() ([]model.Trade, error) {
 	return trades, nil
 }
 
-// GetTradeByID returns a trade by ID
-func GetTradeByID(tradeID uint) (*model.Trade, error) {
+// GetTradeByID returns a trade by ID with its negotiation thread and messages
+// Only the two parties and admins may read it; the thread is private to them.
+func GetTradeByID(tradeID uint, userID uint, isAdmin bool) (*model.Trade, error) {
 	db := database.GetDB()
 
 	var trade model.Trade
 	if err := db.Preload("Proposer").Preload("Receiver").First(&trade, tradeID).Error; err != nil {
 		return nil, ErrTradeNotFound
 	}
+	if !isAdmin && trade.ProposerID != userID && trade.ReceiverID != userID {
+		return nil, ErrNotTradeParticipant
+	}
+
+	threadID := tradeThreadID(&trade)
+	if err := db.Where("thread_id = ? OR id = ?", threadID, threadID).
+		Preload("Proposer").Preload("Receiver").
+		Order("id ASC").
+		Find(&trade.Thread).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Where("thread_id = ?", threadID).
+		Preload("User").
+		Order("id ASC").
+		Find(&trade.Messages).Error; err != nil {
+		return nil, err
+	}
+
+	// Warn about injured generals changing hands
+	if err := flagInjuredTradeGenerals(&trade); err != nil {
+		return nil, err
+	}
 
 	return &trade, nil
 }
@@ -350,3 +909,35 @@ func logTrade(tradeID uint, action string, performedBy uint, details string) {
 
 	db.Create(&log)
 }
+
+// logTradeTx logs a trade action inside tx
+func logTradeTx(tx *gorm.DB, tradeID uint, action string, performedBy uint, details string) error {
+	log := model.TradeLog{
+		TradeID:     tradeID,
+		Action:      action,
+		PerformedBy: performedBy,
+		Details:     details,
+	}
+
+	return tx.Create(&log).Error
+}
+
+// logMultiTradeTx logs a multi-party trade action inside tx
+func logMultiTradeTx(tx *gorm.DB, multiTradeID uint, action string, performedBy uint, details string) error {
+	log := model.TradeLog{
+		MultiTradeID: multiTradeID,
+		Action:       action,
+		PerformedBy:  performedBy,
+		Details:      details,
+	}
+
+	return tx.Create(&log).Error
+}
+
+// publishTradeStatus notifies both participants that a trade changed status
+func publishTradeStatus(trade *model.Trade, status string) {
+	PublishEvent(EventTradeUpdated, map[string]interface{}{
+		"trade_id": trade.ID,
+		"status":   status,
+	}, trade.ProposerID, trade.ReceiverID)
+}
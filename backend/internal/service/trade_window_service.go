@@ -0,0 +1,284 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTradeWindowNotFound = errors.New("trade window not found")
+	ErrInvalidTradeWindow  = errors.New("trade window must close after it opens")
+	ErrTradeWindowClosed   = errors.New("trade window is closed")
+	ErrTradeLimitReached   = errors.New("trade limit for this round reached")
+	ErrTradeExpired        = errors.New("trade offer has expired")
+	ErrInvalidTradeExpiry  = errors.New("trade expiry must be in the future")
+)
+
+// TradeWindowRequest creates or updates a trade window
+type TradeWindowRequest struct {
+	RoundNumber        int        `json:"round_number"`
+	OpensAt            *time.Time `json:"opens_at"`
+	ClosesAt           *time.Time `json:"closes_at"`
+	MaxTradesPerPlayer int        `json:"max_trades_per_player"`
+	Note               string     `json:"note"`
+}
+
+// TradeWindowStatus describes whether trading is currently possible
+type TradeWindowStatus struct {
+	Open    bool                `json:"open"`
+	Window  *model.TradeWindow  `json:"window"`  // Active window, nil when the round has no windows
+	Windows []model.TradeWindow `json:"windows"` // All windows of the current round
+}
+
+// GetTradeWindows returns all trade windows (admin)
+func GetTradeWindows() ([]model.TradeWindow, error) {
+	db := database.GetDB()
+
+	var windows []model.TradeWindow
+	if err := db.Order("round_number ASC, opens_at ASC").Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// CreateTradeWindow adds a trade window (admin only)
+func CreateTradeWindow(req *TradeWindowRequest) (*model.TradeWindow, error) {
+	db := database.GetDB()
+
+	window := &model.TradeWindow{}
+	if err := applyTradeWindowRequest(window, req); err != nil {
+		return nil, err
+	}
+	if err := db.Create(window).Error; err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// UpdateTradeWindow replaces a trade window's settings (admin only)
+func UpdateTradeWindow(id uint, req *TradeWindowRequest) (*model.TradeWindow, error) {
+	db := database.GetDB()
+
+	var window model.TradeWindow
+	if err := db.First(&window, id).Error; err != nil {
+		return nil, ErrTradeWindowNotFound
+	}
+	if err := applyTradeWindowRequest(&window, req); err != nil {
+		return nil, err
+	}
+	if err := db.Save(&window).Error; err != nil {
+		return nil, err
+	}
+	return &window, nil
+}
+
+// DeleteTradeWindow removes a trade window (admin only)
+func DeleteTradeWindow(id uint) error {
+	db := database.GetDB()
+
+	result := db.Delete(&model.TradeWindow{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTradeWindowNotFound
+	}
+	return nil
+}
+
+// applyTradeWindowRequest validates a request and copies it onto window
+func applyTradeWindowRequest(window *model.TradeWindow, req *TradeWindowRequest) error {
+	if req.OpensAt != nil && req.ClosesAt != nil && !req.ClosesAt.After(*req.OpensAt) {
+		return ErrInvalidTradeWindow
+	}
+	if req.RoundNumber < 0 || req.MaxTradesPerPlayer < 0 {
+		return ErrInvalidTradeWindow
+	}
+
+	window.RoundNumber = req.RoundNumber
+	window.OpensAt = req.OpensAt
+	window.ClosesAt = req.ClosesAt
+	window.MaxTradesPerPlayer = req.MaxTradesPerPlayer
+	window.Note = req.Note
+	return nil
+}
+
+// GetTradeWindowStatus reports whether the trade window is open right now
+func GetTradeWindowStatus() (*TradeWindowStatus, error) {
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := roundTradeWindows(phase.RoundNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TradeWindowStatus{Windows: windows}
+	window, err := activeTradeWindow(phase)
+	switch err {
+	case nil:
+		status.Open = phase.CurrentPhase == "trading" || phase.CurrentPhase == "draft"
+		status.Window = window
+	case ErrTradeWindowClosed:
+	default:
+		return nil, err
+	}
+	return status, nil
+}
+
+// roundTradeWindows returns the windows that apply to a round
+func roundTradeWindows(round int) ([]model.TradeWindow, error) {
+	db := database.GetDB()
+
+	var windows []model.TradeWindow
+	if err := db.Where("round_number = ? OR round_number = 0", round).
+		Order("opens_at ASC").
+		Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	return windows, nil
+}
+
+// activeTradeWindow returns the window trading currently happens in
+// It returns nil without error when the round has no windows configured, and
+// ErrTradeWindowClosed when it has windows but none is open.
+func activeTradeWindow(phase *model.GamePhase) (*model.TradeWindow, error) {
+	windows, err := roundTradeWindows(phase.RoundNumber)
+	if err != nil {
+		return nil, err
+	}
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	for i := range windows {
+		window := &windows[i]
+		if window.OpensAt != nil && now.Before(*window.OpensAt) {
+			continue
+		}
+		if window.ClosesAt != nil && !now.Before(*window.ClosesAt) {
+			continue
+		}
+		return window, nil
+	}
+	return nil, ErrTradeWindowClosed
+}
+
+// checkTradeLimit rejects a trade if a player already used up the window's per-round limit
+// Settled multi-party trades count once for each participant. Pass the settling
+// transaction as db so the count sees trades committed just before.
+func checkTradeLimit(db *gorm.DB, window *model.TradeWindow, round int, userIDs ...uint) error {
+	if window == nil || window.MaxTradesPerPlayer <= 0 {
+		return nil
+	}
+
+	for _, userID := range userIDs {
+		var count int64
+		if err := db.Model(&model.Trade{}).
+			Where("status = ? AND accepted_round = ? AND (proposer_id = ? OR receiver_id = ?)", "accepted", round, userID, userID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		var multiCount int64
+		if err := db.Model(&model.MultiTradeParticipant{}).
+			Joins("JOIN multi_trades ON multi_trades.id = multi_trade_participants.multi_trade_id").
+			Where("multi_trades.status = ? AND multi_trades.accepted_round = ? AND multi_trade_participants.user_id = ?", "accepted", round, userID).
+			Count(&multiCount).Error; err != nil {
+			return err
+		}
+		if int(count+multiCount) >= window.MaxTradesPerPlayer {
+			return ErrTradeLimitReached
+		}
+	}
+	return nil
+}
+
+// tradeLimitWindow returns the window whose limit applies to a trade settling now
+// That is the open window, or once it has closed the last window of the round
+// that opened, so approvals after the close still count against it.
+func tradeLimitWindow(round int) (*model.TradeWindow, error) {
+	windows, err := roundTradeWindows(round)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var limitWindow *model.TradeWindow
+	for i := range windows {
+		window := &windows[i]
+		if window.OpensAt != nil && now.Before(*window.OpensAt) {
+			continue
+		}
+		if window.ClosesAt == nil || now.Before(*window.ClosesAt) {
+			return window, nil
+		}
+		limitWindow = window
+	}
+	return limitWindow, nil
+}
+
+// ExpireTrades marks pending trades past their expiry as expired
+func ExpireTrades() (int, error) {
+	db := database.GetDB()
+
+	var trades []model.Trade
+	if err := db.Where("status = ? AND expires_at IS NOT NULL AND expires_at <= ?", "pending", time.Now()).
+		Find(&trades).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range trades {
+		ok, err := expireTrade(&trades[i])
+		if err != nil {
+			return expired, err
+		}
+		if ok {
+			expired++
+		}
+	}
+	return expired, nil
+}
+
+// expireTrade moves a single pending trade to expired
+func expireTrade(trade *model.Trade) (bool, error) {
+	db := database.GetDB()
+
+	result := db.Model(&model.Trade{}).Where("id = ? AND status = ?", trade.ID, "pending").Update("status", "expired")
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	logTrade(trade.ID, "expired", 0, "Trade offer expired")
+	publishTradeStatus(trade, "expired")
+	return true, nil
+}
+
+// NextTradeExpiry returns the earliest expiry of a pending trade, or nil. Used by the background scheduler.
+func NextTradeExpiry() (*time.Time, error) {
+	db := database.GetDB()
+
+	var trade model.Trade
+	err := db.Where("status = ? AND expires_at IS NOT NULL", "pending").
+		Order("expires_at ASC").
+		Limit(1).
+		Find(&trade).Error
+	if err != nil {
+		return nil, err
+	}
+	if trade.ID == 0 {
+		return nil, nil
+	}
+	return trade.ExpiresAt, nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"math"
+	"strings"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+)
+
+// Valuation weights
+// Salary is the league's own price tag, so it anchors the scale; the other
+// components add what salary tends to miss.
+const (
+	valueSalaryWeight   = 1.0 // Per point of salary
+	valueStatBaseline   = 300 // Five-stat total of a filler general
+	valueStatWeight     = 0.1 // Per stat point above the baseline
+	valueSkillWeight    = 3.0 // Per skill
+	valueTreasureWeight = 1.0 // Per point of treasure value
+	valueSpaceWeight    = 1.0 // Per point of space
+)
+
+// aptitudeValues scores troop aptitude grades
+var aptitudeValues = map[string]float64{
+	"S": 4,
+	"A": 2,
+	"B": 0.5,
+	"C": 0,
+}
+
+// GeneralValuation is the score breakdown of a general
+type GeneralValuation struct {
+	GeneralID     uint    `json:"general_id"`
+	Name          string  `json:"name"`
+	SalaryScore   float64 `json:"salary_score"`
+	StatScore     float64 `json:"stat_score"`
+	AptitudeScore float64 `json:"aptitude_score"`
+	SkillScore    float64 `json:"skill_score"`
+	Total         float64 `json:"total"`
+}
+
+// TreasureValuation is the score of a treasure
+type TreasureValuation struct {
+	TreasureID uint    `json:"treasure_id"`
+	Name       string  `json:"name"`
+	Total      float64 `json:"total"`
+}
+
+// SideValuation is the score of everything one side gives up
+type SideValuation struct {
+	Generals   []GeneralValuation  `json:"generals"`
+	Treasures  []TreasureValuation `json:"treasures"`
+	SpaceScore float64             `json:"space_score"`
+	Total      float64             `json:"total"`
+}
+
+// TradeEvaluation compares both sides of a trade
+type TradeEvaluation struct {
+	Offer     SideValuation `json:"offer"`     // What the proposer gives
+	Request   SideValuation `json:"request"`   // What the receiver gives
+	Imbalance float64       `json:"imbalance"` // Difference as a percentage of the larger side
+	Favors    string        `json:"favors"`    // proposer/receiver/even
+}
+
+// EvaluateTrade scores both sides of a trade request
+func EvaluateTrade(req *TradeRequest) (*TradeEvaluation, error) {
+	offer, err := valueSide(req.OfferGenerals, req.OfferTreasures, req.OfferSpace)
+	if err != nil {
+		return nil, err
+	}
+	request, err := valueSide(req.RequestGenerals, req.RequestTreasures, req.RequestSpace)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluation := &TradeEvaluation{
+		Offer:     *offer,
+		Request:   *request,
+		Imbalance: tradeImbalance(offer.Total, request.Total),
+		Favors:    "even",
+	}
+	// The side that receives more value is favoured
+	if evaluation.Imbalance >= 1 {
+		if request.Total > offer.Total {
+			evaluation.Favors = "proposer"
+		} else {
+			evaluation.Favors = "receiver"
+		}
+	}
+	return evaluation, nil
+}
+
+// valueSide scores a set of generals, treasures and space
+func valueSide(generalIDs, treasureIDs []uint, space int) (*SideValuation, error) {
+	db := database.GetDB()
+	side := &SideValuation{
+		Generals:  []GeneralValuation{},
+		Treasures: []TreasureValuation{},
+	}
+
+	for _, gid := range generalIDs {
+		var general model.General
+		if err := db.First(&general, gid).Error; err != nil {
+			return nil, ErrInvalidTradeItems
+		}
+		valuation := ValueGeneral(&general)
+		side.Generals = append(side.Generals, valuation)
+		side.Total += valuation.Total
+	}
+
+	for _, tid := range treasureIDs {
+		var treasure model.Treasure
+		if err := db.First(&treasure, tid).Error; err != nil {
+			return nil, ErrInvalidTradeItems
+		}
+		valuation := TreasureValuation{
+			TreasureID: treasure.ID,
+			Name:       treasure.Name,
+			Total:      roundValue(float64(treasure.Value) * valueTreasureWeight),
+		}
+		side.Treasures = append(side.Treasures, valuation)
+		side.Total += valuation.Total
+	}
+
+	side.SpaceScore = roundValue(float64(space) * valueSpaceWeight)
+	side.Total = roundValue(side.Total + side.SpaceScore)
+	return side, nil
+}
+
+// ValueGeneral scores a general from salary, five stats, troop aptitudes and skills
+func ValueGeneral(general *model.General) GeneralValuation {
+	stats := general.Command + general.Force + general.Intelligence + general.Politics + general.Charm
+	statScore := float64(stats-valueStatBaseline) * valueStatWeight
+	if statScore < 0 {
+		statScore = 0
+	}
+
+	var aptitudeScore float64
+	for _, grade := range []string{general.Spear, general.Halberd, general.Crossbow, general.Cavalry, general.Soldier, general.Water} {
+		grade = strings.ToUpper(strings.TrimSpace(grade))
+		if grade == "" {
+			continue
+		}
+		aptitudeScore += aptitudeValues[grade[:1]]
+	}
+
+	skillScore := float64(len(splitSkills(general.Skills))) * valueSkillWeight
+
+	valuation := GeneralValuation{
+		GeneralID:     general.ID,
+		Name:          general.Name,
+		SalaryScore:   roundValue(float64(general.Salary) * valueSalaryWeight),
+		StatScore:     roundValue(statScore),
+		AptitudeScore: roundValue(aptitudeScore),
+		SkillScore:    roundValue(skillScore),
+	}
+	valuation.Total = roundValue(valuation.SalaryScore + valuation.StatScore + valuation.AptitudeScore + valuation.SkillScore)
+	return valuation
+}
+
+// splitSkills splits a 特技 cell, which may use spaces or Chinese/ASCII separators
+func splitSkills(skills string) []string {
+	return strings.FieldsFunc(skills, func(r rune) bool {
+		switch r {
+		case ' ', '\t', ',', '，', '、', '/', ';', '；':
+			return true
+		}
+		return false
+	})
+}
+
+// tradeImbalance returns the difference between both sides as a percentage of the larger one
+func tradeImbalance(offer, request float64) float64 {
+	larger := math.Max(offer, request)
+	if larger <= 0 {
+		return 0
+	}
+	return roundValue(math.Abs(offer-request) / larger * 100)
+}
+
+// roundValue rounds a score to one decimal
+func roundValue(v float64) float64 {
+	return math.Round(v*10) / 10
+}
@@ -0,0 +1,539 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"san11-trade/internal/database"
+	"san11-trade/internal/model"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrWaiverNotFound       = errors.New("waiver not found")
+	ErrWaiverClosed         = errors.New("waiver claim period is over")
+	ErrOwnWaiver            = errors.New("you cannot claim a general you released")
+	ErrAlreadyClaimed       = errors.New("you have already claimed this general")
+	ErrWaiverClaimNotFound  = errors.New("waiver claim not found")
+	ErrInvalidWaiverSetting = errors.New("invalid waiver settings")
+)
+
+// Waiver priority modes
+const (
+	WaiverPriorityRolling = "rolling" // Successful claimers drop to the back of the line
+	WaiverPrioritySpace   = "space"   // Most remaining space claims first
+)
+
+// WaiverSettingsRequest updates the waiver configuration
+type WaiverSettingsRequest struct {
+	RefundPercent *int   `json:"refund_percent"`
+	ClaimHours    int    `json:"claim_hours"`
+	PriorityMode  string `json:"priority_mode"`
+}
+
+// WaiverPriorityEntry is one player's place in the waiver order
+type WaiverPriorityEntry struct {
+	Rank           int    `json:"rank"`
+	UserID         uint   `json:"user_id"`
+	Nickname       string `json:"nickname"`
+	RemainingSpace int    `json:"remaining_space"`
+}
+
+// GetWaiverSettings gets or creates the waiver configuration
+func GetWaiverSettings() (*model.WaiverSettings, error) {
+	db := database.GetDB()
+	var settings model.WaiverSettings
+	if err := db.First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// Create default settings
+			settings = model.WaiverSettings{
+				RefundPercent: 100,
+				ClaimHours:    24,
+				PriorityMode:  WaiverPriorityRolling,
+			}
+			if err := db.Create(&settings).Error; err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, err
+		}
+	}
+	return &settings, nil
+}
+
+// UpdateWaiverSettings changes the waiver configuration (admin only)
+func UpdateWaiverSettings(req *WaiverSettingsRequest) (*model.WaiverSettings, error) {
+	db := database.GetDB()
+
+	settings, err := GetWaiverSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.RefundPercent != nil {
+		if *req.RefundPercent < 0 || *req.RefundPercent > 100 {
+			return nil, ErrInvalidWaiverSetting
+		}
+		settings.RefundPercent = *req.RefundPercent
+	}
+	if req.ClaimHours < 0 {
+		return nil, ErrInvalidWaiverSetting
+	}
+	if req.ClaimHours > 0 {
+		settings.ClaimHours = req.ClaimHours
+	}
+	switch req.PriorityMode {
+	case "":
+	case WaiverPriorityRolling, WaiverPrioritySpace:
+		settings.PriorityMode = req.PriorityMode
+	default:
+		return nil, ErrInvalidWaiverSetting
+	}
+
+	if err := db.Save(settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// ReleaseGeneral drops an owned general onto waivers
+// The releaser gets RefundPercent of the salary back and every open trade
+// involving the general is cancelled.
+func ReleaseGeneral(userID uint, generalID uint) (*model.Waiver, error) {
+	db := database.GetDB()
+
+	// Check phase
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "trading" && phase.CurrentPhase != "draft" {
+		// A player over the cap after a draw may release in any phase to get back under
+		var user model.User
+		if err := db.First(&user, userID).Error; err != nil {
+			return nil, ErrUserNotFound
+		}
+		if user.OverCapDeadline == nil {
+			return nil, ErrNotInTradingPhase
+		}
+	}
+
+	settings, err := GetWaiverSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var general model.General
+	if err := db.First(&general, generalID).Error; err != nil {
+		return nil, ErrInvalidTradeItems
+	}
+	if general.OwnerID == nil || *general.OwnerID != userID {
+		return nil, ErrItemNotOwned
+	}
+
+	refund := general.Salary * settings.RefundPercent / 100
+
+	// Begin transaction
+	tx := db.Begin()
+
+	// Take the general off the roster; it stays unavailable while on waivers
+	result := tx.Model(&model.General{}).
+		Where("id = ? AND owner_id = ? AND version = ?", general.ID, userID, general.Version).
+		Updates(map[string]interface{}{
+			"owner_id":     nil,
+			"is_available": false,
+			"version":      general.Version + 1,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil, ErrTradeConflict
+	}
+
+	if err := tx.Model(&model.User{}).Where("id = ?", userID).
+		Update("used_space", gorm.Expr("used_space - ?", refund)).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	waiver := &model.Waiver{
+		GeneralID:     general.ID,
+		ReleasedBy:    userID,
+		Salary:        general.Salary,
+		RefundedSpace: refund,
+		Status:        "open",
+		ClaimDeadline: time.Now().Add(time.Duration(settings.ClaimHours) * time.Hour),
+	}
+	if err := tx.Create(waiver).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Open trades involving the general can no longer execute
+	reason := fmt.Sprintf("Auto-cancelled: general released to waivers (#%d)", waiver.ID)
+	cancelled, err := cancelConflictingTrades(tx, 0, userID, reason, []uint{general.ID}, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	cancelledMulti, err := cancelConflictingMultiTrades(tx, 0, []uint{general.ID}, nil)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// Releasing a general can cost a 国策 bonus the roster needs to stay under the cap
+	effects, err := applyClubEffects(tx, userID)
+	if err == nil {
+		err = checkClubEffectsCap(effects)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	publishClubEffects(effects)
+
+	for i := range cancelled {
+		publishTradeStatus(&cancelled[i], "cancelled")
+	}
+	for i := range cancelledMulti {
+		publishMultiTradeStatus(&cancelledMulti[i], "cancelled")
+	}
+
+	waiver.General = general
+	PublishEvent(EventWaiverReleased, waiver)
+
+	return waiver, nil
+}
+
+// GetWaivers returns waivers, optionally filtered by status
+func GetWaivers(status string) ([]model.Waiver, error) {
+	db := database.GetDB()
+
+	query := db.Preload("General").Preload("Releaser").Preload("Claims.User")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var waivers []model.Waiver
+	if err := query.Order("claim_deadline ASC").Find(&waivers).Error; err != nil {
+		return nil, err
+	}
+	return waivers, nil
+}
+
+// ClaimWaiver puts in a claim for a general on waivers
+// Space is checked again when the claim period closes.
+func ClaimWaiver(userID uint, waiverID uint) (*model.WaiverClaim, error) {
+	db := database.GetDB()
+
+	// Claims follow the trading rules; only releasing is open to over-cap players in any phase
+	phase, err := GetGamePhase()
+	if err != nil {
+		return nil, err
+	}
+	if phase.CurrentPhase != "trading" && phase.CurrentPhase != "draft" {
+		return nil, ErrNotInTradingPhase
+	}
+
+	var waiver model.Waiver
+	if err := db.Preload("General").First(&waiver, waiverID).Error; err != nil {
+		return nil, ErrWaiverNotFound
+	}
+	if waiver.Status != "open" || !time.Now().Before(waiver.ClaimDeadline) {
+		return nil, ErrWaiverClosed
+	}
+	if waiver.ReleasedBy == userID {
+		return nil, ErrOwnWaiver
+	}
+
+	var user model.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.IsRegistered {
+		return nil, ErrUserNotRegistered
+	}
+	if user.Space-user.UsedSpace < waiver.General.Salary {
+		return nil, ErrInsufficientSpace
+	}
+
+	var existing int64
+	if err := db.Model(&model.WaiverClaim{}).Where("waiver_id = ? AND user_id = ?", waiverID, userID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, ErrAlreadyClaimed
+	}
+
+	claim := &model.WaiverClaim{
+		WaiverID: waiverID,
+		UserID:   userID,
+		Status:   "pending",
+	}
+	if err := db.Create(claim).Error; err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// CancelWaiverClaim withdraws a pending claim
+func CancelWaiverClaim(userID uint, waiverID uint) error {
+	db := database.GetDB()
+
+	result := db.Where("waiver_id = ? AND user_id = ? AND status = ?", waiverID, userID, "pending").Delete(&model.WaiverClaim{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWaiverClaimNotFound
+	}
+	return nil
+}
+
+// GetWaiverPriority returns the current claim order of registered players
+func GetWaiverPriority() ([]WaiverPriorityEntry, error) {
+	db := database.GetDB()
+
+	settings, err := GetWaiverSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	var users []model.User
+	if err := db.Where("is_registered = ?", true).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	sortWaiverPriority(users, settings.PriorityMode)
+
+	entries := make([]WaiverPriorityEntry, 0, len(users))
+	for i, user := range users {
+		entries = append(entries, WaiverPriorityEntry{
+			Rank:           i + 1,
+			UserID:         user.ID,
+			Nickname:       user.Nickname,
+			RemainingSpace: user.Space - user.UsedSpace,
+		})
+	}
+	return entries, nil
+}
+
+// sortWaiverPriority orders users by who claims first
+func sortWaiverPriority(users []model.User, mode string) {
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if mode == WaiverPrioritySpace {
+			if ra, rb := a.Space-a.UsedSpace, b.Space-b.UsedSpace; ra != rb {
+				return ra > rb
+			}
+		} else if a.WaiverPriority != b.WaiverPriority {
+			return a.WaiverPriority < b.WaiverPriority
+		}
+		return a.ID < b.ID
+	})
+}
+
+// ProcessWaivers resolves every waiver whose claim period has closed
+func ProcessWaivers() (int, error) {
+	db := database.GetDB()
+
+	var waivers []model.Waiver
+	if err := db.Where("status = ? AND claim_deadline <= ?", "open", time.Now()).
+		Order("claim_deadline ASC").
+		Find(&waivers).Error; err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range waivers {
+		if err := processWaiver(&waivers[i]); err != nil {
+			return processed, err
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// processWaiver awards a general to the highest-priority claimer with room for it
+// Unclaimed generals return to their pool.
+func processWaiver(waiver *model.Waiver) error {
+	db := database.GetDB()
+
+	settings, err := GetWaiverSettings()
+	if err != nil {
+		return err
+	}
+
+	var general model.General
+	if err := db.First(&general, waiver.GeneralID).Error; err != nil {
+		return err
+	}
+
+	var claims []model.WaiverClaim
+	if err := db.Where("waiver_id = ? AND status = ?", waiver.ID, "pending").Preload("User").Find(&claims).Error; err != nil {
+		return err
+	}
+	users := make([]model.User, 0, len(claims))
+	for _, claim := range claims {
+		users = append(users, claim.User)
+	}
+	sortWaiverPriority(users, settings.PriorityMode)
+
+	// Begin transaction
+	tx := db.Begin()
+
+	now := time.Now()
+	var winner *uint
+	var effects []clubEffectsUpdate
+	for _, user := range users {
+		if winner != nil {
+			break
+		}
+		if err := tx.SavePoint("claim").Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := applyTradeSpace(tx, user.ID, general.Salary); err != nil {
+			if err == ErrTradeOverCap || err == ErrTradeConflict {
+				if err := tx.Model(&model.WaiverClaim{}).Where("waiver_id = ? AND user_id = ?", waiver.ID, user.ID).
+					Update("status", "failed").Error; err != nil {
+					tx.Rollback()
+					return err
+				}
+				continue
+			}
+			tx.Rollback()
+			return err
+		}
+
+		result := tx.Model(&model.General{}).Where("id = ? AND owner_id IS NULL", general.ID).Updates(map[string]interface{}{
+			"owner_id": user.ID,
+			"version":  gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			tx.Rollback()
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			tx.Rollback()
+			return ErrGeneralNotAvailable
+		}
+
+		// A general that costs the claimer a 国策 bonus they need to stay under the cap does not fit either
+		claimEffects, err := applyClubEffects(tx, user.ID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if checkClubEffectsCap(claimEffects) != nil {
+			if err := tx.RollbackTo("claim").Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := tx.Model(&model.WaiverClaim{}).Where("waiver_id = ? AND user_id = ?", waiver.ID, user.ID).
+				Update("status", "failed").Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			continue
+		}
+		effects = claimEffects
+
+		if err := tx.Model(&model.WaiverClaim{}).Where("waiver_id = ? AND user_id = ?", waiver.ID, user.ID).
+			Update("status", "won").Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		// Rolling priority: the winner goes to the back of the line
+		if settings.PriorityMode == WaiverPriorityRolling {
+			var maxPriority int
+			if err := tx.Model(&model.User{}).Select("COALESCE(MAX(waiver_priority), 0)").Scan(&maxPriority).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+			if err := tx.Model(&model.User{}).Where("id = ?", user.ID).Update("waiver_priority", maxPriority+1).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+
+		id := user.ID
+		winner = &id
+	}
+
+	if err := tx.Model(&model.WaiverClaim{}).Where("waiver_id = ? AND status = ?", waiver.ID, "pending").
+		Update("status", "lost").Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	status := "claimed"
+	if winner == nil {
+		// Nobody could take the general: it clears waivers and returns to its pool
+		status = "cleared"
+		if err := tx.Model(&model.General{}).Where("id = ? AND owner_id IS NULL", general.ID).
+			Update("is_available", true).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	result := tx.Model(&model.Waiver{}).Where("id = ? AND status = ?", waiver.ID, "open").Updates(map[string]interface{}{
+		"status":       status,
+		"claimed_by":   winner,
+		"processed_at": now,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Processed concurrently
+		tx.Rollback()
+		return nil
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	publishClubEffects(effects)
+
+	log.Printf("Waiver %d (%s) %s", waiver.ID, general.Name, status)
+	PublishEvent(EventWaiverProcessed, map[string]interface{}{
+		"waiver_id":  waiver.ID,
+		"general_id": general.ID,
+		"status":     status,
+		"claimed_by": winner,
+	})
+	return nil
+}
+
+// NextWaiverDeadline returns when the next claim period closes, or nil. Used by the background scheduler.
+func NextWaiverDeadline() (*time.Time, error) {
+	db := database.GetDB()
+
+	var waiver model.Waiver
+	err := db.Where("status = ?", "open").
+		Order("claim_deadline ASC").
+		Limit(1).
+		Find(&waiver).Error
+	if err != nil {
+		return nil, err
+	}
+	if waiver.ID == 0 {
+		return nil, nil
+	}
+	return &waiver.ClaimDeadline, nil
+}
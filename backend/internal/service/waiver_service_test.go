@@ -0,0 +1,155 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"san11-trade/internal/model"
+)
+
+// releaseTestGeneral puts a player's general on waivers
+func releaseTestGeneral(t *testing.T, db *gorm.DB, user *model.User, general *model.General) *model.Waiver {
+	t.Helper()
+	waiver, err := ReleaseGeneral(user.ID, general.ID)
+	if err != nil {
+		t.Fatalf("ReleaseGeneral: %v", err)
+	}
+	return waiver
+}
+
+// closeTestWaiver ends a waiver's claim period
+func closeTestWaiver(db *gorm.DB, waiver *model.Waiver) {
+	db.Model(waiver).Update("claim_deadline", time.Now().Add(-time.Minute))
+}
+
+func TestClaimWaiverChecks(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	releaser := createTestUser(t, db, "releaser")
+	claimer := createTestUser(t, db, "claimer")
+	outsider := createTestUser(t, db, "outsider")
+	db.Model(outsider).Update("is_registered", false)
+	general := createTestGeneral(t, db, 1, "draft", 100)
+	giveTestGeneral(t, db, general, releaser)
+	waiver := releaseTestGeneral(t, db, releaser, general)
+
+	if _, err := ClaimWaiver(releaser.ID, waiver.ID); err != ErrOwnWaiver {
+		t.Errorf("claim own waiver = %v, want ErrOwnWaiver", err)
+	}
+	if _, err := ClaimWaiver(outsider.ID, waiver.ID); err != ErrUserNotRegistered {
+		t.Errorf("claim by an unregistered user = %v, want ErrUserNotRegistered", err)
+	}
+
+	SetGamePhase("match", 1, 0)
+	if _, err := ClaimWaiver(claimer.ID, waiver.ID); err != ErrNotInTradingPhase {
+		t.Errorf("claim outside the trading phase = %v, want ErrNotInTradingPhase", err)
+	}
+
+	SetGamePhase("trading", 1, 0)
+	if _, err := ClaimWaiver(claimer.ID, waiver.ID); err != nil {
+		t.Fatalf("ClaimWaiver: %v", err)
+	}
+	if _, err := ClaimWaiver(claimer.ID, waiver.ID); err != ErrAlreadyClaimed {
+		t.Errorf("second claim = %v, want ErrAlreadyClaimed", err)
+	}
+
+	late := createTestUser(t, db, "late")
+	closeTestWaiver(db, waiver)
+	if _, err := ClaimWaiver(late.ID, waiver.ID); err != ErrWaiverClosed {
+		t.Errorf("claim after the deadline = %v, want ErrWaiverClosed", err)
+	}
+}
+
+func TestProcessWaiversByPriority(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	releaser := createTestUser(t, db, "releaser")
+	full := createTestUser(t, db, "full")
+	first := createTestUser(t, db, "first")
+	second := createTestUser(t, db, "second")
+	general := createTestGeneral(t, db, 1, "draft", 100)
+	giveTestGeneral(t, db, general, releaser)
+
+	// full claims first but fills up before the claim is processed
+	db.Model(full).Update("waiver_priority", 0)
+	db.Model(first).Update("waiver_priority", 1)
+	db.Model(second).Update("waiver_priority", 2)
+
+	waiver := releaseTestGeneral(t, db, releaser, general)
+	if user := reloadTestUser(t, db, releaser.ID); user.UsedSpace != 0 {
+		t.Errorf("releaser used %d after the release, want the salary refunded", user.UsedSpace)
+	}
+	for _, user := range []*model.User{second, full, first} {
+		if _, err := ClaimWaiver(user.ID, waiver.ID); err != nil {
+			t.Fatalf("ClaimWaiver(%s): %v", user.Username, err)
+		}
+	}
+	db.Model(full).Update("used_space", 300)
+	closeTestWaiver(db, waiver)
+
+	if processed, err := ProcessWaivers(); err != nil || processed != 1 {
+		t.Fatalf("ProcessWaivers = %d, %v, want 1", processed, err)
+	}
+
+	db.First(waiver, waiver.ID)
+	if waiver.Status != "claimed" || waiver.ClaimedBy == nil || *waiver.ClaimedBy != first.ID {
+		t.Fatalf("waiver %s claimed by %v, want claimed by user %d", waiver.Status, waiver.ClaimedBy, first.ID)
+	}
+	var owned model.General
+	db.First(&owned, general.ID)
+	if owned.OwnerID == nil || *owned.OwnerID != first.ID {
+		t.Errorf("general owner = %v, want user %d", owned.OwnerID, first.ID)
+	}
+
+	want := map[uint]string{full.ID: "failed", first.ID: "won", second.ID: "lost"}
+	var claims []model.WaiverClaim
+	db.Where("waiver_id = ?", waiver.ID).Find(&claims)
+	for _, claim := range claims {
+		if claim.Status != want[claim.UserID] {
+			t.Errorf("claim of user %d is %s, want %s", claim.UserID, claim.Status, want[claim.UserID])
+		}
+	}
+
+	winner := reloadTestUser(t, db, first.ID)
+	if winner.UsedSpace != general.Salary {
+		t.Errorf("winner used %d, want %d", winner.UsedSpace, general.Salary)
+	}
+	// Rolling priority sends the winner to the back of the line
+	if winner.WaiverPriority <= 2 {
+		t.Errorf("winner priority = %d, want behind everyone else", winner.WaiverPriority)
+	}
+}
+
+func TestProcessWaiversWithoutClaims(t *testing.T) {
+	db := setupTestDB(t)
+	SetGamePhase("trading", 1, 0)
+
+	releaser := createTestUser(t, db, "releaser")
+	general := createTestGeneral(t, db, 1, "draft", 100)
+	giveTestGeneral(t, db, general, releaser)
+	waiver := releaseTestGeneral(t, db, releaser, general)
+
+	var onWaivers model.General
+	db.First(&onWaivers, general.ID)
+	if onWaivers.IsAvailable || onWaivers.OwnerID != nil {
+		t.Errorf("general on waivers available %v owner %v, want unavailable without owner", onWaivers.IsAvailable, onWaivers.OwnerID)
+	}
+
+	closeTestWaiver(db, waiver)
+	if _, err := ProcessWaivers(); err != nil {
+		t.Fatalf("ProcessWaivers: %v", err)
+	}
+	db.First(waiver, waiver.ID)
+	if waiver.Status != "cleared" {
+		t.Errorf("waiver status = %s, want cleared", waiver.Status)
+	}
+	var cleared model.General
+	db.First(&cleared, general.ID)
+	if !cleared.IsAvailable || cleared.OwnerID != nil {
+		t.Errorf("cleared general available %v owner %v, want back in its pool", cleared.IsAvailable, cleared.OwnerID)
+	}
+}
@@ -55,6 +55,7 @@ export const gameApi = {
   getPlayers: () => api.get('/players'),
   getPlayerRoster: (id) => api.get(`/players/${id}/roster`),
   getStatistics: () => api.get('/statistics'),
+  getSeasonRules: () => api.get('/season-rules'),
   getRegistrationConfig: () => api.get('/config/registration')
 }
 
@@ -62,11 +63,19 @@ export const gameApi = {
 export const drawApi = {
   draw: () => api.post('/draw'),
   getStatus: () => api.get('/draw/status'),
+  mulligan: () => api.post('/draw/mulligan'),
   getResults: () => api.get('/draw/results'),
   getPool: (type) => api.get(`/draw/pool${type ? '?type=' + type : ''}`),
+  getSeeds: () => api.get('/draw/seeds'),
+  verifySeed: (seedId) => api.get(`/draw/seeds/${seedId}/verify`),
+  getCeremony: () => api.get('/draw/ceremony'),
   // Draft
   getDraftPool: () => api.get('/draft/pool'),
-  draftPick: (generalId) => api.post('/draft/pick', { general_id: generalId })
+  draftPick: (generalId) => api.post('/draft/pick', { general_id: generalId }),
+  getDraftState: () => api.get('/draft/state'),
+  getDraftQueue: () => api.get('/draft/queue'),
+  setDraftQueue: (generalIds) => api.post('/draft/queue', { general_ids: generalIds }),
+  setAutoDraft: (enabled) => api.post('/draft/auto', { enabled })
 }
 
 // Asset APIs
@@ -79,25 +88,61 @@ export const assetApi = {
   getClub: (id) => api.get(`/clubs/${id}`),
   getClubDetail: (id) => api.get(`/clubs/${id}/detail`),
   getAllCities: () => api.get('/cities'),
-  getGameRules: () => api.get('/rules')
+  getGameRules: () => api.get('/rules'),
+  getInjuries: (all) => api.get('/injuries', { params: { all } })
 }
 
 // Trade APIs
 export const tradeApi = {
   createTrade: (data) => api.post('/trades', data),
+  evaluateTrade: (data) => api.post('/trades/evaluate', data),
   getPendingTrades: () => api.get('/trades/pending'),
   getTradeHistory: () => api.get('/trades/history'),
+  getTradeWindow: () => api.get('/trades/window'),
+  getTradeReviewQueue: () => api.get('/trades/review'),
+  vetoTrade: (id, reason) => api.post(`/trades/${id}/veto`, { reason }),
   getTrade: (id) => api.get(`/trades/${id}`),
   acceptTrade: (id) => api.post(`/trades/${id}/accept`),
   rejectTrade: (id) => api.post(`/trades/${id}/reject`),
-  cancelTrade: (id) => api.post(`/trades/${id}/cancel`)
+  cancelTrade: (id) => api.post(`/trades/${id}/cancel`),
+  counterTrade: (id, data) => api.post(`/trades/${id}/counter`, data),
+  sendTradeMessage: (id, content) => api.post(`/trades/${id}/messages`, { content }),
+
+  // Trade block / wanted listings
+  searchListings: (params) => api.get('/listings', { params }),
+  createListing: (data) => api.post('/listings', data),
+  getListing: (id) => api.get(`/listings/${id}`),
+  closeListing: (id) => api.delete(`/listings/${id}`),
+  getListingMatches: (id) => api.get(`/listings/${id}/matches`),
+  proposeFromListing: (id, data) => api.post(`/listings/${id}/propose`, data || {}),
+
+  // Multi-party trades
+  createMultiTrade: (data) => api.post('/multi-trades', data),
+  getMultiTrades: () => api.get('/multi-trades'),
+  getMultiTrade: (id) => api.get(`/multi-trades/${id}`),
+  acceptMultiTrade: (id) => api.post(`/multi-trades/${id}/accept`),
+  rejectMultiTrade: (id) => api.post(`/multi-trades/${id}/reject`),
+  cancelMultiTrade: (id) => api.post(`/multi-trades/${id}/cancel`),
+  getMultiTradeReviewQueue: () => api.get('/multi-trades/review'),
+  vetoMultiTrade: (id, reason) => api.post(`/multi-trades/${id}/veto`, { reason }),
+
+  // Waivers
+  getWaivers: (status) => api.get('/waivers', { params: { status } }),
+  getWaiverPriority: () => api.get('/waivers/priority'),
+  releaseGeneral: (generalId) => api.post('/waivers/release', { general_id: generalId }),
+  claimWaiver: (id) => api.post(`/waivers/${id}/claim`),
+  cancelWaiverClaim: (id) => api.delete(`/waivers/${id}/claim`)
 }
 
 // Auction APIs
 export const auctionApi = {
   getPool: () => api.get('/auction/pool'),
   getResults: () => api.get('/auction/results'),
-  getStats: () => api.get('/auction/stats')
+  getStats: () => api.get('/auction/stats'),
+  // Live auction
+  getLots: (status) => api.get('/auction/lots', { params: { status } }),
+  getLot: (id) => api.get(`/auction/lots/${id}`),
+  placeBid: (id, amount) => api.post(`/auction/lots/${id}/bid`, { amount })
 }
 
 // Policy APIs (国策拍卖)
@@ -110,14 +155,67 @@ export const policyApi = {
   selectClub: (clubId) => api.post('/policy/select', { club_id: clubId }),
   getResults: () => api.get('/policy/results'),
   getClubs: (params) => api.get('/policy/clubs', { params }),
-  getFilters: () => api.get('/policy/filters')
+  getFilters: () => api.get('/policy/filters'),
+  getMyEffects: () => api.get('/policy/effects'),
+  getPlayerEffects: (userId) => api.get(`/players/${userId}/policies`)
+}
+
+// Tournament APIs (小组赛/淘汰赛)
+export const tournamentApi = {
+  getMatches: (params) => api.get('/matches', { params }),
+  getMatch: (id) => api.get(`/matches/${id}`),
+  getGroups: () => api.get('/tournament/groups'),
+  getGroupStandings: () => api.get('/tournament/standings'),
+  getBracket: () => api.get('/tournament/bracket'),
+  getStandings: () => api.get('/standings'),
+  // Result reporting
+  reportResult: (id, data) => api.post(`/matches/${id}/report`, data),
+  confirmResult: (id) => api.post(`/matches/${id}/confirm`),
+  disputeResult: (id, reason) => api.post(`/matches/${id}/dispute`, { reason }),
+  // Lineups
+  getLineupRules: (round) => api.get('/lineups/rules', { params: { round } }),
+  getMyLineup: (round) => api.get('/lineups/me', { params: { round } }),
+  submitLineup: (data) => api.post('/lineups', data),
+  lockLineup: (round) => api.post('/lineups/lock', { round_number: round }),
+  getMatchLineups: (id) => api.get(`/matches/${id}/lineups`)
 }
 
 // Admin APIs
 export const adminApi = {
   setPhase: (data) => api.post('/admin/phase', data),
   resetSeason: () => api.post('/admin/reset'),
-  getAllTrades: () => api.get('/admin/trades'),
+  updateSeasonRules: (data) => api.put('/admin/season-rules', data),
+  getAllTrades: (params) => api.get('/admin/trades', { params }),
+  getTradeReviewQueue: () => api.get('/admin/trades/review'),
+  approveTrade: (id, reason) => api.post(`/admin/trades/${id}/approve`, { reason }),
+  vetoTrade: (id, reason) => api.post(`/admin/trades/${id}/veto`, { reason }),
+  getMultiTradeReviewQueue: () => api.get('/admin/multi-trades/review'),
+  approveMultiTrade: (id, reason) => api.post(`/admin/multi-trades/${id}/approve`, { reason }),
+  vetoMultiTrade: (id, reason) => api.post(`/admin/multi-trades/${id}/veto`, { reason }),
+  getTradeSettings: () => api.get('/admin/trade-settings'),
+  updateTradeSettings: (data) => api.put('/admin/trade-settings', data),
+  getTradeWindows: () => api.get('/admin/trade-windows'),
+  createTradeWindow: (data) => api.post('/admin/trade-windows', data),
+  updateTradeWindow: (id, data) => api.put(`/admin/trade-windows/${id}`, data),
+  deleteTradeWindow: (id) => api.delete(`/admin/trade-windows/${id}`),
+  getWaiverSettings: () => api.get('/admin/waiver-settings'),
+  updateWaiverSettings: (data) => api.put('/admin/waiver-settings', data),
+  processWaivers: () => api.post('/admin/waivers/process'),
+  injureGeneral: (data) => api.post('/admin/injuries', data),
+  clearInjury: (generalId) => api.delete(`/admin/injuries/${generalId}`),
+  drawGroups: (data) => api.post('/admin/tournament/groups', data),
+  generateKnockout: (data) => api.post('/admin/tournament/knockout', data || {}),
+  resetTournament: () => api.delete('/admin/tournament'),
+  recordMatchResult: (id, data) => api.post(`/admin/matches/${id}/result`, data),
+  createMatch: (data) => api.post('/admin/matches', data),
+  getDisputedMatches: () => api.get('/admin/matches/disputed'),
+  getLineups: (round) => api.get('/admin/lineups', { params: { round } }),
+  getMatchLineups: (id) => api.get(`/admin/matches/${id}/lineups`),
+  getLineupRounds: () => api.get('/admin/lineup-rounds'),
+  setLineupRound: (data) => api.put('/admin/lineup-rounds', data),
+  getLineupRestrictions: () => api.get('/admin/lineup-restrictions'),
+  setLineupRestriction: (clubId, data) => api.put(`/admin/clubs/${clubId}/lineup-restriction`, data),
+  lockDueLineups: () => api.post('/admin/lineups/lock-due'),
   importData: (formData) => api.post('/admin/import', formData, {
     headers: { 'Content-Type': 'multipart/form-data' }
   }),
@@ -132,10 +230,28 @@ export const adminApi = {
   resetAllDraw: () => api.post('/admin/draw/reset-all'),
   drawForUser: (userId) => api.post(`/admin/draw/for/${userId}`),
   drawForAll: () => api.post('/admin/draw/for-all'),
+  startDrawCeremony: (data) => api.post('/admin/draw/for-all', { ...data, ceremony: true }),
+  pauseDrawCeremony: () => api.post('/admin/draw/ceremony/pause'),
+  resumeDrawCeremony: () => api.post('/admin/draw/ceremony/resume'),
+  stepDrawCeremony: () => api.post('/admin/draw/ceremony/step'),
+  skipDrawCeremonyPlayer: () => api.post('/admin/draw/ceremony/skip'),
+  revealDrawSeed: () => api.post('/admin/draw/seed/reveal'),
+  getDrawTables: () => api.get('/admin/draw/tables'),
+  updateDrawTable: (data) => api.put('/admin/draw/tables', data),
+  previewDrawTables: () => api.get('/admin/draw/tables/preview'),
+  setGeneralTiers: (tiers) => api.put('/admin/generals/tiers', { tiers }),
+  getDrawRerolls: () => api.get('/admin/draw/rerolls'),
+  resolveOverCapDraws: () => api.post('/admin/draw/resolve-over-cap'),
+  setMulliganTokens: (userId, tokens) => api.put(`/admin/draw/mulligans/${userId}`, { tokens }),
+  // Draft management
+  startDraft: (data) => api.post('/admin/draft/start', data),
+  skipDraftPick: () => api.post('/admin/draft/skip'),
   // Auction management
   getAuctionStats: () => api.get('/admin/auction/stats'),
   assignAuction: (data) => api.post('/admin/auction/assign', data),
   resetAuction: (generalId) => api.post(`/admin/auction/reset/${generalId}`),
+  openAuctionLot: (data) => api.post('/admin/auction/lots', data),
+  settleAuctionLot: (id) => api.post(`/admin/auction/lots/${id}/settle`),
   // Policy management (国策管理)
   closePolicyBidding: () => api.post('/admin/policy/close-bidding'),
   startPolicySelection: (data) => api.post('/admin/policy/start-selection', data),
@@ -144,7 +260,21 @@ export const adminApi = {
   resetUserPolicySelection: (userId) => api.post(`/admin/policy/reset-user/${userId}`),
   selectClubForUser: (userId, clubId) => api.post(`/admin/policy/select-for/${userId}`, { club_id: clubId }),
   checkPolicyTimeout: () => api.post('/admin/policy/check-timeout'),
-  forceNextSelector: () => api.post('/admin/policy/force-next')
+  forceNextSelector: () => api.post('/admin/policy/force-next'),
+  parsePolicyRule: (rule) => api.post('/admin/policy/rules/parse', { rule }),
+  setPolicyRule: (policyId, rule) => api.put(`/admin/policies/${policyId}/rule`, { rule }),
+  applyClubEffects: () => api.post('/admin/policy/apply-effects')
+}
+
+// Event stream (Server-Sent Events); EventSource cannot send headers, so the token goes in the query
+export const eventsApi = {
+  open: (lastEventId) => {
+    const params = new URLSearchParams()
+    const token = localStorage.getItem('token')
+    if (token) params.set('token', token)
+    if (lastEventId) params.set('last_event_id', lastEventId)
+    return new EventSource(`/api/events?${params.toString()}`)
+  }
 }
 
 // Invite code APIs (public)